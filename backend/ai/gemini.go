@@ -6,16 +6,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/malharg/strategic-insight-analyst/backend/config"
-	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const geminiAPIURL = "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash:generateContent?key="
+// DefaultGeminiModel is the model used to generate insights unless another
+// is configured.
+const DefaultGeminiModel = "gemini-1.5-flash"
+
+// DefaultEmbeddingModel is the model used to embed chunks unless another is
+// configured.
+const DefaultEmbeddingModel = "text-embedding-004"
+
+const (
+	geminiAPIURL   = "https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent"
+	geminiModelURL = "https://generativelanguage.googleapis.com/v1beta/models/%s"
+	geminiEmbedURL = "https://generativelanguage.googleapis.com/v1beta/models/%s:batchEmbedContents"
+)
+
+// embedBatchSize is the most texts one batchEmbedContents call accepts.
+const embedBatchSize = 100
+
+var tracer = otel.Tracer("github.com/malharg/strategic-insight-analyst/backend/ai")
 
 type GeminiRequest struct {
 	Contents []Content `json:"contents"`
@@ -38,34 +58,74 @@ type GeminiResponse struct {
 			Role string `json:"role"`
 		} `json:"content"`
 	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		TotalTokenCount      int64 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+type embedRequest struct {
+	Requests []embedContentRequest `json:"requests"`
+}
+
+type embedContentRequest struct {
+	Model   string  `json:"model"`
+	Content Content `json:"content"`
+}
+
+type embedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// Gemini is a Provider and Embedder backed by the Gemini REST API.
+type Gemini struct {
+	APIKey string
+	Model  string
+	// EmbeddingModel is the model Embed calls.
+	EmbeddingModel string
+	Client         *http.Client
+}
+
+// NewGemini returns a Gemini provider for model authenticated with apiKey.
+// Each call may take up to timeout. Its requests are traced and carry the
+// caller's trace context.
+func NewGemini(apiKey, model string, timeout time.Duration) *Gemini {
+	return &Gemini{APIKey: apiKey, Model: model, EmbeddingModel: DefaultEmbeddingModel, Client: &http.Client{Timeout: timeout, Transport: tracing.Transport(nil)}}
 }
 
-func GenerateInsight(ctx context.Context, docID, userQuery string) (string, error) {
-	// 1. Retrieve all chunks for the given document ID from the database
-	rows, err := database.DB.QueryContext(ctx, "SELECT content FROM document_chunks WHERE document_id = ? ORDER BY chunk_index ASC", docID)
+// GenerateInsight answers userQuery using only the supplied document chunks as context.
+func (g *Gemini) GenerateInsight(ctx context.Context, chunks []string, userQuery string) (string, Usage, error) {
+	ctx, span := tracer.Start(ctx, "ai.GenerateInsight", trace.WithAttributes(
+		attribute.String("ai.provider", "gemini"),
+		attribute.Int("ai.chunks", len(chunks)),
+	))
+	defer span.End()
+
+	answer, usage, err := g.generateInsight(ctx, chunks, userQuery)
+	span.SetAttributes(
+		attribute.String("ai.model", usage.Model),
+		attribute.Int64("ai.prompt_tokens", usage.PromptTokens),
+		attribute.Int64("ai.response_tokens", usage.ResponseTokens),
+	)
 	if err != nil {
-		return "", fmt.Errorf("could not query document chunks: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
-	defer rows.Close()
+	return answer, usage, err
+}
 
+func (g *Gemini) generateInsight(ctx context.Context, chunks []string, userQuery string) (string, Usage, error) {
+	// 1. Join the document chunks into a single context block
 	var documentContext strings.Builder
-	var chunkCount int
-	for rows.Next() {
-		var content string
-		if err := rows.Scan(&content); err != nil {
-			return "", fmt.Errorf("could not scan chunk content: %w", err)
-		}
+	for _, content := range chunks {
 		documentContext.WriteString(content)
 		documentContext.WriteString("\n\n")
-		chunkCount++
-	}
-	if err := rows.Err(); err != nil {
-		return "", fmt.Errorf("error iterating over chunks: %w", err)
 	}
-	// =================================================================
-	// DEBUG LOG
-	log.Printf("DEBUG: Retrieved %d chunks for document ID %s. Total context size: %d chars.", chunkCount, docID, len(documentContext.String()))
-	// =================================================================
+	logging.FromContext(ctx).Debug("building prompt", "chunks", len(chunks), "context_chars", documentContext.Len())
 
 	// 2.  prompt
 	prompt := fmt.Sprintf(`
@@ -91,42 +151,148 @@ func GenerateInsight(ctx context.Context, docID, userQuery string) (string, erro
 	}
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("could not marshal request body: %w", err)
+		return "", Usage{Model: g.Model}, fmt.Errorf("could not marshal request body: %w", err)
 	}
 
-	fullURL := geminiAPIURL + config.AppConfig.GeminiAPIKey
-	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewBuffer(reqBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf(geminiAPIURL, g.Model), bytes.NewBuffer(reqBytes))
 	if err != nil {
-		return "", fmt.Errorf("could not create http request: %w", err)
+		return "", Usage{Model: g.Model}, fmt.Errorf("could not create http request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	// The key goes in a header rather than the URL so it stays out of traces.
+	req.Header.Set("x-goog-api-key", g.APIKey)
 
-	client := &http.Client{Timeout: time.Second * 60}
-	resp, err := client.Do(req)
+	resp, err := g.Client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to call Gemini API: %w", err)
+		return "", Usage{Model: g.Model}, fmt.Errorf("failed to call Gemini API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("could not read response body: %w", err)
+		return "", Usage{Model: g.Model}, fmt.Errorf("could not read response body: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Gemini API Error: %s", string(respBody))
-		return "", fmt.Errorf("gemini API returned non-200 status: %d", resp.StatusCode)
+		logging.FromContext(ctx).Error("gemini API error", "status", resp.StatusCode, "body", string(respBody))
+		return "", Usage{Model: g.Model}, fmt.Errorf("gemini API returned non-200 status: %d", resp.StatusCode)
 	}
 
 	// 4. Parse the response and extract the text
 	var geminiResp GeminiResponse
 	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
-		return "", fmt.Errorf("could not unmarshal response: %w", err)
+		return "", Usage{Model: g.Model}, fmt.Errorf("could not unmarshal response: %w", err)
 	}
 
+	answer := "No response generated by the AI."
 	if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
-		return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+		answer = geminiResp.Candidates[0].Content.Parts[0].Text
+	}
+
+	// 5. Record what the call cost. Estimate if the API left usage out.
+	usage := Usage{
+		Model:          g.Model,
+		PromptTokens:   geminiResp.UsageMetadata.PromptTokenCount,
+		ResponseTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+	}
+	if geminiResp.ModelVersion != "" {
+		usage.Model = geminiResp.ModelVersion
+	}
+	if usage.TotalTokens() == 0 {
+		usage.PromptTokens, usage.ResponseTokens = EstimateTokens(prompt), EstimateTokens(answer)
+	}
+	return answer, usage, nil
+}
+
+// Embed embeds texts with the embedding model, in batches of up to 100.
+// The API does not report token counts, so usage is estimated.
+func (g *Gemini) Embed(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	ctx, span := tracer.Start(ctx, "ai.Embed", trace.WithAttributes(
+		attribute.String("ai.provider", "gemini"),
+		attribute.String("ai.model", g.EmbeddingModel),
+		attribute.Int("ai.texts", len(texts)),
+	))
+	defer span.End()
+
+	usage := Usage{Model: g.EmbeddingModel}
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		batch := texts[start:min(start+embedBatchSize, len(texts))]
+		values, err := g.embedBatch(ctx, batch)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, usage, err
+		}
+		embeddings = append(embeddings, values...)
+		usage.PromptTokens += EstimateTokens(batch...)
+	}
+	span.SetAttributes(attribute.Int64("ai.prompt_tokens", usage.PromptTokens))
+	return embeddings, usage, nil
+}
+
+func (g *Gemini) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody := embedRequest{Requests: make([]embedContentRequest, len(texts))}
+	for i, text := range texts {
+		reqBody.Requests[i] = embedContentRequest{Model: "models/" + g.EmbeddingModel, Content: Content{Parts: []Part{{Text: text}}}}
+	}
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(geminiEmbedURL, g.EmbeddingModel), bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", g.APIKey)
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		logging.FromContext(ctx).Error("gemini API error", "status", resp.StatusCode, "body", string(respBody))
+		return nil, fmt.Errorf("gemini API returned non-200 status: %d", resp.StatusCode)
+	}
+
+	var embedResp embedResponse
+	if err := json.Unmarshal(respBody, &embedResp); err != nil {
+		return nil, fmt.Errorf("could not unmarshal response: %w", err)
+	}
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("gemini API returned %d embeddings for %d texts", len(embedResp.Embeddings), len(texts))
 	}
+	values := make([][]float32, len(texts))
+	for i, e := range embedResp.Embeddings {
+		values[i] = e.Values
+	}
+	return values, nil
+}
 
-	return "No response generated by the AI.", nil
+// Ping looks up the configured model, which spends no tokens but fails when
+// the API key is revoked or the model does not exist.
+func (g *Gemini) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(geminiModelURL, g.Model), nil)
+	if err != nil {
+		return fmt.Errorf("could not create http request: %w", err)
+	}
+	req.Header.Set("x-goog-api-key", g.APIKey)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gemini API returned %s for model %s", resp.Status, g.Model)
+	}
+	return nil
 }
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc sends requests to a test handler instead of the network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// fakeGemini returns a Gemini whose requests are answered by handler.
+func fakeGemini(handler http.HandlerFunc) *Gemini {
+	g := NewGemini("key", DefaultGeminiModel, 0)
+	g.Client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Result(), nil
+	})
+	return g
+}
+
+func TestEmbed(t *testing.T) {
+	var batches []int
+	g := fakeGemini(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/models/"+DefaultEmbeddingModel+":batchEmbedContents") || r.Header.Get("x-goog-api-key") != "key" {
+			t.Errorf("request to %s", r.URL)
+		}
+		var req embedRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		batches = append(batches, len(req.Requests))
+		var resp embedResponse
+		for _, c := range req.Requests {
+			resp.Embeddings = append(resp.Embeddings, struct {
+				Values []float32 `json:"values"`
+			}{[]float32{float32(len(c.Content.Parts[0].Text))}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	texts := make([]string, 150)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	embeddings, usage, err := g.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(batches) != "[100 50]" {
+		t.Errorf("batches = %v, want 100 then 50", batches)
+	}
+	if len(embeddings) != 150 || embeddings[149][0] != 150 {
+		t.Errorf("embeddings out of order: %d, last %v", len(embeddings), embeddings[len(embeddings)-1])
+	}
+	if usage.Model != DefaultEmbeddingModel || usage.PromptTokens != EstimateTokens(texts...) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestEmbedErrors(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"error status":       func(w http.ResponseWriter, r *http.Request) { http.Error(w, "quota", http.StatusTooManyRequests) },
+		"missing embeddings": func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"embeddings": [{"values": [1]}]}`)) },
+		"malformed response": func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`not json`)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, usage, err := fakeGemini(handler).Embed(context.Background(), []string{"a", "b"}); err == nil || usage.Model != DefaultEmbeddingModel {
+				t.Errorf("Embed = %+v, %v; want an error with the model named", usage, err)
+			}
+		})
+	}
+}
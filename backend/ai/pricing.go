@@ -0,0 +1,33 @@
+package ai
+
+import "strings"
+
+// Price is a model's list price in US dollars per million tokens.
+type Price struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// Prices lists the models we call. Versioned names such as
+// "gemini-1.5-flash-002" are priced as their base model.
+var Prices = map[string]Price{
+	"gemini-1.5-flash":   {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-pro":     {InputPerMillion: 1.25, OutputPerMillion: 5.00},
+	"text-embedding-004": {InputPerMillion: 0, OutputPerMillion: 0},
+}
+
+// EstimateCost returns the list price of u in US dollars, or 0 for models
+// without a known price.
+func EstimateCost(u Usage) float64 {
+	price, ok := Prices[u.Model]
+	if !ok {
+		// Fall back to the longest base model name that prefixes u.Model.
+		best := ""
+		for model, p := range Prices {
+			if strings.HasPrefix(u.Model, model) && len(model) > len(best) {
+				best, price = model, p
+			}
+		}
+	}
+	return (float64(u.PromptTokens)*price.InputPerMillion + float64(u.ResponseTokens)*price.OutputPerMillion) / 1e6
+}
@@ -0,0 +1,46 @@
+package ai
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		usage Usage
+		want  float64
+	}{
+		{Usage{Model: "gemini-1.5-flash", PromptTokens: 1_000_000, ResponseTokens: 1_000_000}, 0.375},
+		{Usage{Model: "gemini-1.5-pro", PromptTokens: 2000, ResponseTokens: 1000}, 0.0075},
+		// Versioned names are priced as their base model.
+		{Usage{Model: "gemini-1.5-flash-002", PromptTokens: 1_000_000}, 0.075},
+		{Usage{Model: "gemini-1.5-pro-latest", ResponseTokens: 1_000_000}, 5},
+		{Usage{Model: "unknown-model", PromptTokens: 1_000_000, ResponseTokens: 1_000_000}, 0},
+		{Usage{Model: "gemini-1.5-flash"}, 0},
+	}
+	for _, tt := range tests {
+		if got := EstimateCost(tt.usage); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("EstimateCost(%+v) = %v, want %v", tt.usage, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	for _, tt := range []struct {
+		texts []string
+		want  int64
+	}{
+		{nil, 0},
+		{[]string{""}, 0},
+		{[]string{"abc"}, 1},
+		{[]string{"abcd"}, 1},
+		{[]string{"abcd", "e"}, 2},
+	} {
+		if got := EstimateTokens(tt.texts...); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.texts, got, tt.want)
+		}
+	}
+	if u := (Usage{PromptTokens: 3, ResponseTokens: 4}); u.TotalTokens() != 7 {
+		t.Errorf("TotalTokens = %d, want 7", u.TotalTokens())
+	}
+}
@@ -0,0 +1,39 @@
+package ai
+
+import "context"
+
+// Usage is the token accounting for one model call.
+type Usage struct {
+	Model          string
+	PromptTokens   int64
+	ResponseTokens int64
+}
+
+// TotalTokens is the sum of prompt and response tokens.
+func (u Usage) TotalTokens() int64 {
+	return u.PromptTokens + u.ResponseTokens
+}
+
+// Provider generates answers from document context with a large language model.
+type Provider interface {
+	// GenerateInsight answers userQuery from chunks and reports the tokens
+	// the call consumed. The usage names the model even when the call fails.
+	GenerateInsight(ctx context.Context, chunks []string, userQuery string) (string, Usage, error)
+}
+
+// Embedder computes vector embeddings of text.
+type Embedder interface {
+	// Embed returns one embedding per text, in order, and the tokens the
+	// call consumed.
+	Embed(ctx context.Context, texts []string) ([][]float32, Usage, error)
+}
+
+// EstimateTokens approximates the tokens a model will count for texts, at
+// roughly four characters per token.
+func EstimateTokens(texts ...string) int64 {
+	var chars int
+	for _, t := range texts {
+		chars += len(t)
+	}
+	return int64((chars + 3) / 4)
+}
@@ -0,0 +1,102 @@
+// Package apierror writes API errors as a JSON envelope with a
+// machine-readable code, so clients can branch on the code instead of
+// matching message text:
+//
+//	{"error": {"code": "document_not_found", "message": "Document not found.", "requestId": "3be8bbbfdd41856e"}}
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+)
+
+// Code identifies the kind of error. Codes are part of the API: add new ones
+// rather than changing existing ones.
+type Code string
+
+// Request errors.
+const (
+	CodeInvalidRequest   Code = "invalid_request"
+	CodeRequestTooLarge  Code = "request_too_large"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeNotFound         Code = "not_found"
+	CodeConflict         Code = "conflict"
+)
+
+// Authentication and authorization errors.
+const (
+	CodeUnauthenticated     Code = "unauthenticated"
+	CodeInvalidToken        Code = "invalid_token"
+	CodeInsufficientScope   Code = "insufficient_scope"
+	CodeInteractiveRequired Code = "interactive_login_required"
+	CodeAdminRequired       Code = "admin_required"
+	CodeForbidden           Code = "forbidden"
+)
+
+// Missing resources.
+const (
+	CodeDocumentNotFound     Code = "document_not_found"
+	CodeOrganizationNotFound Code = "organization_not_found"
+	CodeCollectionNotFound   Code = "collection_not_found"
+	CodeShareNotFound        Code = "share_not_found"
+	CodeInviteNotFound       Code = "invite_not_found"
+	CodeMemberNotFound       Code = "member_not_found"
+	CodeAPIKeyNotFound       Code = "api_key_not_found"
+)
+
+// Upload errors.
+const (
+	CodeFileTooLarge        Code = "file_too_large"
+	CodeUnsupportedFileType Code = "unsupported_file_type"
+	CodeExtractionFailed    Code = "extraction_failed"
+)
+
+// Limits and dependency failures.
+const (
+	CodeRateLimited        Code = "rate_limited"
+	CodeQuotaExceeded      Code = "quota_exceeded"
+	CodeStorageUnavailable Code = "storage_unavailable"
+	CodeLLMUnavailable     Code = "llm_unavailable"
+	CodeInternal           Code = "internal_error"
+)
+
+// Codes lists every code, for the API documentation.
+func Codes() []Code {
+	return []Code{
+		CodeInvalidRequest, CodeRequestTooLarge, CodeMethodNotAllowed, CodeNotFound, CodeConflict,
+		CodeUnauthenticated, CodeInvalidToken, CodeInsufficientScope, CodeInteractiveRequired, CodeAdminRequired, CodeForbidden,
+		CodeDocumentNotFound, CodeOrganizationNotFound, CodeCollectionNotFound, CodeShareNotFound, CodeInviteNotFound, CodeMemberNotFound, CodeAPIKeyNotFound,
+		CodeFileTooLarge, CodeUnsupportedFileType, CodeExtractionFailed,
+		CodeRateLimited, CodeQuotaExceeded, CodeStorageUnavailable, CodeLLMUnavailable, CodeInternal,
+	}
+}
+
+// Detail is the body of an error response.
+type Detail struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	// RequestID matches the X-Request-ID header and the server logs.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// Response is the envelope every error is sent in.
+type Response struct {
+	Error Detail `json:"error"`
+}
+
+// Write sends an error response with the given status, code and
+// human-readable message. Like http.Error, it does not end the handler.
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code, message string) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: Detail{
+		Code:      code,
+		Message:   message,
+		RequestID: logging.RequestID(r.Context()),
+	}})
+}
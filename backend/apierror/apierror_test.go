@@ -0,0 +1,58 @@
+package apierror_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+)
+
+func TestWrite(t *testing.T) {
+	handler := logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Headers meant for a successful body must not survive.
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Length", "1024")
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeDocumentNotFound, "Document not found.")
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/api/documents/doc-1", nil)
+	r.Header.Set(logging.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		t.Errorf("Content-Length = %q, want it dropped", cl)
+	}
+	var body apierror.Response
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := apierror.Detail{Code: apierror.CodeDocumentNotFound, Message: "Document not found.", RequestID: "req-1"}
+	if body.Error != want {
+		t.Errorf("error = %+v, want %+v", body.Error, want)
+	}
+}
+
+func TestWriteOutsideRequestLogging(t *testing.T) {
+	w := httptest.NewRecorder()
+	apierror.Write(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusBadRequest, apierror.CodeInvalidRequest, "Bad.")
+
+	var body map[string]map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["error"]["requestId"]; ok {
+		t.Errorf("requestId present without a request ID: %v", body)
+	}
+	if body["error"]["code"] != "invalid_request" {
+		t.Errorf("code = %v", body["error"]["code"])
+	}
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/handlers"
+	"github.com/malharg/strategic-insight-analyst/backend/health"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/openapi"
+)
+
+// specPattern serves the OpenAPI document.
+const specPattern = "GET /api/openapi.json"
+
+// Query parameters shared by several routes.
+var (
+	orgFilter = []openapi.Param{
+		{Name: "orgId", Description: "Organization whose documents to use instead of the caller's own"},
+		{Name: "collectionId", Description: "Collection within orgId"},
+	}
+	usageFilter = []openapi.Param{
+		{Name: "groupBy", Description: "day (default), model, document or conversation"},
+		{Name: "from", Description: "First day, YYYY-MM-DD (default 30 days ago)"},
+		{Name: "to", Description: "Last day, YYYY-MM-DD (default today)"},
+	}
+	documentFilter = []openapi.Param{
+		{Name: "type", Description: "pdf or txt"},
+		{Name: "tag"},
+		{Name: "status", Description: "processing, ready or failed"},
+		{Name: "from", Description: "Uploaded at or after; RFC 3339 timestamp or YYYY-MM-DD"},
+		{Name: "to", Description: "Uploaded before; RFC 3339 timestamp or YYYY-MM-DD (inclusive)"},
+		{Name: "sort", Description: "name, uploadedAt (default) or size"},
+		{Name: "order", Description: "asc or desc; names default to asc, the rest to desc"},
+		{Name: "limit", Description: "1-200, default 50"},
+		{Name: "cursor", Description: "nextCursor from the previous page"},
+	}
+	auditFilter = []openapi.Param{
+		{Name: "userId"}, {Name: "documentId"}, {Name: "action"},
+		{Name: "from", Description: "RFC 3339 timestamp or YYYY-MM-DD"},
+		{Name: "to", Description: "RFC 3339 timestamp or YYYY-MM-DD (inclusive)"},
+	}
+)
+
+// routeDocs describes each route in the OpenAPI document, keyed by its mux
+// pattern. Request and response schemas come from the handler types, so a
+// new route only needs its entry here.
+var routeDocs = map[string]openapi.Operation{
+	"GET /api/health":       {Tag: "health", Summary: "Legacy health check", Public: true, Response: map[string]bool{}},
+	"GET /api/health/live":  {Tag: "health", Summary: "Liveness probe", Public: true, Response: map[string]string{}},
+	"GET /api/health/ready": {Tag: "health", Summary: "Readiness probe with per-dependency checks", Public: true, Response: health.Report{}, Errors: []int{http.StatusServiceUnavailable}},
+	specPattern:             {Tag: "health", Summary: "This OpenAPI document", Public: true, Response: map[string]any{}},
+
+	"GET /api/v1/secure-ping":        {Tag: "auth", Summary: "Check that credentials are accepted", Response: map[string]string{}},
+	"GET /api/v1/quota":              {Tag: "usage", Summary: "Caller's usage quotas", Response: []limits.QuotaStatus{}},
+	"GET /api/v1/usage":              {Tag: "usage", Summary: "Caller's LLM usage and estimated cost", Query: usageFilter, Response: handlers.UsageReport{}, Errors: []int{http.StatusBadRequest}},
+	"GET /api/v1/admin/usage":        {Tag: "admin", Summary: "Everyone's LLM usage", Query: append(slices.Clone(usageFilter), openapi.Param{Name: "userId"}), Response: handlers.UsageReport{}, Errors: []int{http.StatusBadRequest}},
+	"GET /api/v1/admin/audit":        {Tag: "admin", Summary: "Audit events, newest first", Query: append(slices.Clone(auditFilter), openapi.Param{Name: "limit", Description: "1-1000, default 100"}), Response: []handlers.AuditEventInfo{}, Errors: []int{http.StatusBadRequest}},
+	"GET /api/v1/admin/audit/export": {Tag: "admin", Summary: "Audit events as CSV", Query: auditFilter, ContentType: "text/csv", Errors: []int{http.StatusBadRequest}},
+
+	"GET /api/v1/documents":                 {Tag: "documents", Summary: "List, filter and sort documents a page at a time", Query: append(slices.Clone(orgFilter), documentFilter...), Response: handlers.DocumentPage{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"GET /api/documents":                    {Tag: "documents", Summary: "Every document, newest first", Query: orgFilter, Response: []handlers.DocumentInfo{}, Errors: []int{http.StatusNotFound}, Deprecated: true},
+	"POST /api/v1/documents":                {Tag: "documents", Summary: "Upload and ingest a .txt or .pdf file", Multipart: "document", Query: orgFilter, Status: http.StatusCreated, Response: handlers.UploadResult{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusBadGateway}},
+	"GET /api/v1/documents/{id}":            {Tag: "documents", Summary: "Get a document with its processing details: size, pages, chunks, extraction warnings, summary and timestamps", Response: handlers.DocumentDetail{}, Errors: []int{http.StatusNotFound}},
+	"PATCH /api/v1/documents/{id}":          {Tag: "documents", Summary: "Rename or re-tag a document", Request: handlers.UpdateDocumentRequest{}, Response: handlers.DocumentDetail{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge}},
+	"DELETE /api/v1/documents/{id}":         {Tag: "documents", Summary: "Delete a document", Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"GET /api/v1/documents/{id}/download":   {Tag: "documents", Summary: "Download the original file", ContentType: "application/octet-stream", Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusBadGateway}},
+	"POST /api/v1/documents/{id}/reingest":  {Tag: "documents", Summary: "Re-extract and re-chunk a document from its original file with the current pipeline", Response: handlers.ReingestResult{}, Errors: []int{http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusBadGateway}},
+	"GET /api/v1/documents/{id}/chunks":     {Tag: "documents", Summary: "Page through a document's chunks", Query: []openapi.Param{{Name: "limit", Description: "1-200, default 50"}, {Name: "cursor", Description: "nextCursor from the previous page"}}, Response: handlers.ChunkPage{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"POST /api/v1/documents/{id}/retrieval": {Tag: "chat", Summary: "Show which chunks chat would send with a query, with their scores", Request: handlers.RetrievalRequest{}, Response: handlers.RetrievalPreview{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge}},
+	"POST /api/v1/documents/{id}/chat":      {Tag: "chat", Summary: "Ask a question about a document", Request: handlers.ChatRequest{}, Response: handlers.ChatResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusBadGateway}},
+
+	"GET /api/v1/documents/shared":                   {Tag: "sharing", Summary: "Documents shared with the caller", Response: []handlers.SharedDocumentInfo{}},
+	"GET /api/v1/documents/{id}/shares":              {Tag: "sharing", Summary: "List a document's shares", Response: []handlers.ShareInfo{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"POST /api/v1/documents/{id}/shares":             {Tag: "sharing", Summary: "Share a document", Request: handlers.ShareRequest{}, Response: []handlers.ShareInfo{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"DELETE /api/v1/documents/{id}/shares/{shareId}": {Tag: "sharing", Summary: "Revoke a share", Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"GET /api/v1/documents/{id}/tags":                {Tag: "documents", Summary: "Get a document's tags", Response: handlers.TagsRequest{}, Errors: []int{http.StatusNotFound}},
+	"PUT /api/v1/documents/{id}/tags":                {Tag: "documents", Summary: "Replace a document's tags", Request: handlers.TagsRequest{}, Response: handlers.TagsRequest{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+	"GET /api/v1/orgs":                          {Tag: "orgs", Summary: "Caller's organizations", Response: []handlers.OrgInfo{}},
+	"POST /api/v1/orgs":                         {Tag: "orgs", Summary: "Create an organization", Request: handlers.NameRequest{}, Status: http.StatusCreated, Response: handlers.OrgInfo{}, Errors: []int{http.StatusBadRequest}},
+	"GET /api/v1/orgs/{id}/members":             {Tag: "orgs", Summary: "Members and pending invites", Response: handlers.OrgMembersResponse{}, Errors: []int{http.StatusNotFound}},
+	"POST /api/v1/orgs/{id}/members":            {Tag: "orgs", Summary: "Add or invite a member", Request: handlers.InviteRequest{}, Response: handlers.OrgMembersResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	"DELETE /api/v1/orgs/{id}/members/{userId}": {Tag: "orgs", Summary: "Remove a member", Response: handlers.OrgMembersResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	"DELETE /api/v1/orgs/{id}/invites/{email}":  {Tag: "orgs", Summary: "Withdraw an invite", Response: handlers.OrgMembersResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	"GET /api/v1/orgs/{id}/collections":         {Tag: "orgs", Summary: "List collections", Response: []handlers.CollectionInfo{}, Errors: []int{http.StatusNotFound}},
+	"POST /api/v1/orgs/{id}/collections":        {Tag: "orgs", Summary: "Create a collection", Request: handlers.NameRequest{}, Status: http.StatusCreated, Response: handlers.CollectionInfo{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+
+	"GET /api/v1/keys":         {Tag: "keys", Summary: "List API keys", Response: []handlers.APIKeyInfo{}},
+	"POST /api/v1/keys":        {Tag: "keys", Summary: "Create an API key; the secret is only returned here", Request: handlers.CreateAPIKeyRequest{}, Status: http.StatusCreated, Response: handlers.APIKeyInfo{}, Errors: []int{http.StatusBadRequest}},
+	"DELETE /api/v1/keys/{id}": {Tag: "keys", Summary: "Revoke an API key", Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+}
+
+// newSpec builds the OpenAPI document for the served routes. It fails if a
+// route has no entry in routeDocs or an entry matches no route, so the
+// document cannot drift from the router.
+func newSpec(routes []apiRoute) (*openapi.Builder, error) {
+	b := openapi.New("Strategic Insight Analyst API", "v1")
+	b.Name(apierror.Response{}, "ErrorResponse")
+	b.Name(apierror.Detail{}, "ErrorDetail")
+	b.Name(health.Report{}, "ReadinessReport")
+	codes := make([]string, 0, len(apierror.Codes()))
+	for _, c := range apierror.Codes() {
+		codes = append(codes, string(c))
+	}
+	b.Errors(apierror.Response{}, codes)
+
+	var undocumented, unserved []string
+	served := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		served[r.pattern] = true
+		op, ok := routeDocs[r.pattern]
+		if !ok {
+			undocumented = append(undocumented, r.pattern)
+			continue
+		}
+		b.Add(r.pattern, op, r.legacy...)
+	}
+	for pattern := range routeDocs {
+		if !served[pattern] {
+			unserved = append(unserved, pattern)
+		}
+	}
+	if len(undocumented) > 0 || len(unserved) > 0 {
+		slices.Sort(undocumented)
+		slices.Sort(unserved)
+		return nil, fmt.Errorf("app: OpenAPI document out of date: undocumented routes [%s], documented but not served [%s]",
+			strings.Join(undocumented, ", "), strings.Join(unserved, ", "))
+	}
+	return b, nil
+}
+
+// OpenAPI returns the API's OpenAPI document without starting a server, for
+// generating clients.
+func OpenAPI(cfg *config.Config) (*openapi.Document, error) {
+	h := &handlers.Handler{Config: cfg}
+	noAuth := func(next http.Handler) http.Handler { return next }
+	_, spec, err := routes(h, noAuth, health.NewReadiness(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Document(), nil
+}
@@ -0,0 +1,445 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/openapi"
+)
+
+// contract calls the API and checks every response against the operation
+// the OpenAPI document describes for it.
+type contract struct {
+	t   *testing.T
+	ts  *httptest.Server
+	doc *openapi.Document
+	// called records the operations answered with their success status.
+	called map[string]bool
+}
+
+// multipartBody is a request body sent as multipart/form-data.
+type multipartBody struct {
+	r           io.Reader
+	contentType string
+}
+
+// operation finds the documented path matching a request path. Literal
+// segments win over wildcards, so /api/v1/documents/shared is not taken for
+// /api/v1/documents/{id}.
+func (c *contract) operation(method, path string) (string, *openapi.Op) {
+	segments := strings.Split(path, "/")
+	best, bestWildcards := "", len(segments)+1
+	for template, ops := range c.doc.Paths {
+		if ops[strings.ToLower(method)] == nil {
+			continue
+		}
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		wildcards := 0
+		for i, p := range parts {
+			if strings.HasPrefix(p, "{") {
+				wildcards++
+			} else if p != segments[i] {
+				wildcards = -1
+				break
+			}
+		}
+		if wildcards >= 0 && wildcards < bestWildcards {
+			best, bestWildcards = template, wildcards
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	return best, c.doc.Paths[best][strings.ToLower(method)]
+}
+
+// call sends a request as userID and checks that the status is documented
+// for the operation, that it is want, and that the body matches the
+// documented schema. It returns the decoded JSON body, if any.
+func (c *contract) call(method, target, userID string, body any, want int) any {
+	c.t.Helper()
+	u, err := url.Parse(target)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	template, op := c.operation(method, u.Path)
+	if op == nil {
+		c.t.Fatalf("%s %s: no documented operation", method, u.Path)
+	}
+
+	var r io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case multipartBody:
+		r, contentType = b.r, b.contentType
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		r, contentType = bytes.NewReader(data), "application/json"
+	}
+	req, err := http.NewRequest(method, c.ts.URL+target, r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if userID != "" {
+		req.Header.Set("Authorization", "Bearer "+userID)
+	}
+	resp, err := c.ts.Client().Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	name := method + " " + target
+	if resp.StatusCode != want {
+		c.t.Fatalf("%s: status %d, want %d; body %s", name, resp.StatusCode, want, data)
+	}
+	documented := op.Responses[strconv.Itoa(resp.StatusCode)]
+	if documented == nil {
+		c.t.Fatalf("%s: status %d is not documented for %s %s", name, resp.StatusCode, method, template)
+	}
+	if want < 300 {
+		c.called[method+" "+template] = true
+	}
+
+	if len(documented.Content) == 0 {
+		if len(data) > 0 {
+			c.t.Errorf("%s: undocumented body %s", name, data)
+		}
+		return nil
+	}
+	media, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	content := documented.Content["application/json"]
+	if content == nil {
+		// Files are documented as binary of any type.
+		if documented.Content["application/octet-stream"] == nil && documented.Content[media] == nil {
+			c.t.Errorf("%s: content type %q, want one of %v", name, media, keys(documented.Content))
+		}
+		return nil
+	}
+	if media != "application/json" {
+		c.t.Errorf("%s: content type %q, want application/json", name, media)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		c.t.Fatalf("%s: decode %s: %v", name, data, err)
+	}
+	for _, problem := range validate(c.doc, content.Schema, v, "body") {
+		c.t.Errorf("%s: %s", name, problem)
+	}
+	return v
+}
+
+func keys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	slices.Sort(ks)
+	return ks
+}
+
+// validate reports where v, decoded with UseNumber, does not match s. Object
+// fields the schema does not list are reported too, so a field added to or
+// renamed in a response type fails until the document is regenerated.
+func validate(doc *openapi.Document, s *openapi.Schema, v any, at string) []string {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		resolved := doc.Components.Schemas[name]
+		if resolved == nil {
+			return []string{fmt.Sprintf("%s: unknown schema %s", at, s.Ref)}
+		}
+		// Pointers to named types are nullable without saying so.
+		if v == nil {
+			return nil
+		}
+		return validate(doc, resolved, v, at)
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: null, want %s", at, s.Type)}
+	}
+
+	mismatch := func(want string) []string {
+		return []string{fmt.Sprintf("%s: %T %v, want %s", at, v, v, want)}
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch("string")
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return mismatch("date-time")
+			}
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return []string{fmt.Sprintf("%s: %q is not one of the documented values", at, str)}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return mismatch("integer")
+		}
+		if _, err := n.Int64(); err != nil {
+			return mismatch("integer")
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return mismatch("number")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch("boolean")
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return mismatch("array")
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, validate(doc, s.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch("object")
+		}
+		var problems []string
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required field %q", at, name))
+			}
+		}
+		for _, name := range keys(obj) {
+			field := s.Properties[name]
+			switch {
+			case field != nil:
+				problems = append(problems, validate(doc, field, obj[name], at+"."+name)...)
+			case s.AdditionalProperties != nil:
+				problems = append(problems, validate(doc, s.AdditionalProperties, obj[name], at+"."+name)...)
+			case len(s.Properties) > 0:
+				problems = append(problems, fmt.Sprintf("%s: undocumented field %q", at, name))
+			}
+		}
+		return problems
+	default:
+		return []string{fmt.Sprintf("%s: unsupported schema type %q", at, s.Type)}
+	}
+	return nil
+}
+
+// field digs a value out of a decoded JSON body, e.g. field(v, "documents",
+// 0, "id").
+func field(t *testing.T, v any, path ...any) any {
+	t.Helper()
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				t.Fatalf("no field %q in %v", p, v)
+			}
+			v = obj[p]
+		case int:
+			arr, ok := v.([]any)
+			if !ok || p >= len(arr) {
+				t.Fatalf("no element %d in %v", p, v)
+			}
+			v = arr[p]
+		}
+	}
+	return v
+}
+
+func str(t *testing.T, v any, path ...any) string {
+	t.Helper()
+	s, ok := field(t, v, path...).(string)
+	if !ok || s == "" {
+		t.Fatalf("%v is not a non-empty string", path)
+	}
+	return s
+}
+
+// TestHandlersMatchOpenAPI calls every documented operation, including the
+// deprecated aliases, and checks each response against the document.
+func TestHandlersMatchOpenAPI(t *testing.T) {
+	ts := newTestServer(t, func(o *Options) { o.Config.AdminUIDs = []string{"admin"} })
+	doc, err := OpenAPI(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &contract{t: t, ts: ts, doc: doc, called: map[string]bool{}}
+	upload := func(path, userID, fileName string) string {
+		body, contentType := uploadForm(t, fileName, strings.Repeat("Revenue grew 20% in Q3. ", 100))
+		return str(t, c.call(http.MethodPost, path, userID, multipartBody{body, contentType}, http.StatusCreated), "id")
+	}
+
+	// Health and the document itself.
+	c.call(http.MethodGet, "/api/health", "", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/health/live", "", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/health/ready", "", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/openapi.json", "", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/secure-ping", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/secure-ping", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents", "", nil, http.StatusUnauthorized)
+
+	// Documents. Bob uploads first so he is a known user below.
+	bobDoc := upload("/api/v1/documents", "bob", "bob.txt")
+	id := upload("/api/v1/documents", "alice", "q3.txt")
+	legacyID := upload("/api/documents/upload", "alice", "q4.txt")
+	page := c.call(http.MethodGet, "/api/v1/documents?limit=1", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents?limit=1&cursor="+url.QueryEscape(str(t, page, "nextCursor")), "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents?sort=colour", "alice", nil, http.StatusBadRequest)
+	c.call(http.MethodGet, "/api/documents", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents/"+id, "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents/"+bobDoc, "alice", nil, http.StatusNotFound)
+	c.call(http.MethodPatch, "/api/v1/documents/"+id, "alice", map[string]any{"fileName": "Q3 report.txt", "tags": []string{"finance"}}, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents/"+id+"/tags", "alice", nil, http.StatusOK)
+	c.call(http.MethodPut, "/api/v1/documents/"+id+"/tags", "alice", map[string]any{"tags": []string{"finance", "q3"}}, http.StatusOK)
+	chunks := c.call(http.MethodGet, "/api/v1/documents/"+id+"/chunks?limit=1", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents/"+id+"/chunks?cursor="+url.QueryEscape(str(t, chunks, "nextCursor")), "alice", nil, http.StatusOK)
+	c.call(http.MethodPost, "/api/v1/documents/"+id+"/retrieval", "alice", map[string]string{"query": "revenue"}, http.StatusOK)
+	c.call(http.MethodPost, "/api/v1/documents/"+id+"/chat", "alice", map[string]string{"query": "How did revenue do?"}, http.StatusOK)
+	c.call(http.MethodPost, "/api/chat", "alice", map[string]string{"documentId": legacyID, "query": "And in Q4?"}, http.StatusOK)
+	c.call(http.MethodPost, "/api/v1/documents/"+id+"/chat", "alice", "not an object", http.StatusBadRequest)
+	c.call(http.MethodPost, "/api/v1/documents/"+id+"/reingest", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents/"+id+"/download", "alice", nil, http.StatusOK)
+
+	// Sharing.
+	c.call(http.MethodPost, "/api/v1/documents/"+id+"/shares", "alice", map[string]string{"userId": "bob", "role": "viewer"}, http.StatusOK)
+	shares := c.call(http.MethodGet, "/api/v1/documents/"+id+"/shares", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents/shared", "bob", nil, http.StatusOK)
+	c.call(http.MethodDelete, "/api/v1/documents/"+id+"/shares/"+str(t, shares, 0, "id"), "alice", nil, http.StatusNoContent)
+
+	// Organizations.
+	org := str(t, c.call(http.MethodPost, "/api/v1/orgs", "alice", map[string]string{"name": "Acme"}, http.StatusCreated), "id")
+	c.call(http.MethodGet, "/api/v1/orgs", "alice", nil, http.StatusOK)
+	c.call(http.MethodPost, "/api/v1/orgs/"+org+"/members", "alice", map[string]string{"email": "bob@example.com"}, http.StatusOK)
+	c.call(http.MethodPost, "/api/v1/orgs/"+org+"/members", "alice", map[string]string{"email": "carol@example.com", "role": "admin"}, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/orgs/"+org+"/members", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/orgs/"+org+"/members", "mallory", nil, http.StatusNotFound)
+	c.call(http.MethodDelete, "/api/v1/orgs/"+org+"/invites/carol@example.com", "alice", nil, http.StatusOK)
+	c.call(http.MethodDelete, "/api/v1/orgs/"+org+"/members/bob", "alice", nil, http.StatusOK)
+	collection := str(t, c.call(http.MethodPost, "/api/v1/orgs/"+org+"/collections", "alice", map[string]string{"name": "Reports"}, http.StatusCreated), "id")
+	c.call(http.MethodGet, "/api/v1/orgs/"+org+"/collections", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/documents?orgId="+org+"&collectionId="+collection, "alice", nil, http.StatusOK)
+
+	// API keys.
+	key := str(t, c.call(http.MethodPost, "/api/v1/keys", "alice", map[string]any{"name": "ci", "scopes": []string{"read"}}, http.StatusCreated), "id")
+	c.call(http.MethodGet, "/api/v1/keys", "alice", nil, http.StatusOK)
+	c.call(http.MethodDelete, "/api/v1/keys/"+key, "alice", nil, http.StatusNoContent)
+	c.call(http.MethodDelete, "/api/v1/keys/"+key, "alice", nil, http.StatusNotFound)
+
+	// Quotas, usage and administration.
+	c.call(http.MethodGet, "/api/v1/quota", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/usage?groupBy=model", "alice", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/usage?groupBy=colour", "alice", nil, http.StatusBadRequest)
+	c.call(http.MethodGet, "/api/v1/admin/usage?groupBy=user", "admin", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/admin/usage", "alice", nil, http.StatusForbidden)
+	c.call(http.MethodGet, "/api/v1/admin/audit", "admin", nil, http.StatusOK)
+	c.call(http.MethodGet, "/api/v1/admin/audit/export", "admin", nil, http.StatusOK)
+
+	// Deleting last, so everything above had documents to work with.
+	c.call(http.MethodDelete, "/api/v1/documents/"+id, "alice", nil, http.StatusNoContent)
+	c.call(http.MethodDelete, "/api/documents/delete?id="+legacyID, "alice", nil, http.StatusNoContent)
+	c.call(http.MethodGet, "/api/v1/documents/"+id, "alice", nil, http.StatusNotFound)
+
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			op := strings.ToUpper(method) + " " + path
+			if !c.called[op] {
+				t.Errorf("%s was not called successfully; add it to this test", op)
+			}
+		}
+	}
+}
+
+// TestServedOpenAPIMatchesGenerated checks that the document served at
+// /api/openapi.json is the one OpenAPI generates.
+func TestServedOpenAPIMatchesGenerated(t *testing.T) {
+	ts := newTestServer(t)
+	doc, err := OpenAPI(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(doc)
+
+	resp := call(t, ts, http.MethodGet, "/api/openapi.json", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	var served any
+	if err := json.NewDecoder(resp.Body).Decode(&served); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(served)
+	var generated any
+	json.Unmarshal(want, &generated)
+	want, _ = json.Marshal(generated)
+	if !bytes.Equal(got, want) {
+		t.Error("served OpenAPI document differs from the generated one")
+	}
+}
+
+// TestFrontendSpecIsCurrent checks that the document and TypeScript types
+// committed for the frontend match the API. Regenerate them with
+// "go run . openapi" and "go run . openapi -ts".
+func TestFrontendSpecIsCurrent(t *testing.T) {
+	doc, err := OpenAPI(config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec bytes.Buffer
+	enc := json.NewEncoder(&spec)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string][]byte{
+		"../../frontend/lib/openapi.json": spec.Bytes(),
+		"../../frontend/lib/api-types.ts": doc.TypeScript(),
+	} {
+		committed, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(committed, want) {
+			t.Errorf("%s is out of date; regenerate it from backend/ with go run . openapi", file)
+		}
+	}
+}
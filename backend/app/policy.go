@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+// routePolicy is the deadline and body size limit a route runs under.
+type routePolicy struct {
+	timeout time.Duration
+	maxBody int64
+}
+
+// routePolicies applies a default policy to every route, with overrides for
+// the slow or large ones and shorter deadlines for lists.
+type routePolicies struct {
+	def    routePolicy
+	routes map[string]routePolicy
+}
+
+func newRoutePolicies(cfg config.ServerConfig) *routePolicies {
+	return &routePolicies{
+		def: routePolicy{timeout: cfg.WriteTimeout, maxBody: cfg.MaxBodyBytes},
+		routes: map[string]routePolicy{
+			"POST /api/v1/documents":           {timeout: cfg.UploadTimeout, maxBody: cfg.MaxUploadBytes},
+			"/api/documents/upload":            {timeout: cfg.UploadTimeout, maxBody: cfg.MaxUploadBytes},
+			"POST /api/v1/documents/{id}/chat": {timeout: cfg.ChatTimeout, maxBody: cfg.MaxBodyBytes},
+			"/api/chat":                        {timeout: cfg.ChatTimeout, maxBody: cfg.MaxBodyBytes},
+
+			"GET /api/v1/documents":             {timeout: cfg.ListTimeout, maxBody: cfg.MaxBodyBytes},
+			"GET /api/documents":                {timeout: cfg.ListTimeout, maxBody: cfg.MaxBodyBytes},
+			"GET /api/v1/documents/shared":      {timeout: cfg.ListTimeout, maxBody: cfg.MaxBodyBytes},
+			"GET /api/v1/documents/{id}/chunks": {timeout: cfg.ListTimeout, maxBody: cfg.MaxBodyBytes},
+			"GET /api/v1/admin/audit":           {timeout: cfg.ListTimeout, maxBody: cfg.MaxBodyBytes},
+		},
+	}
+}
+
+// Middleware sets the route's read and write deadlines on the connection,
+// bounds the request context by the same deadline and caps the request body.
+// A body that declares a size over the limit is refused with 413 before any
+// of it is read; handlers see *http.MaxBytesError for the rest.
+func (p *routePolicies) Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := p.def
+		if _, pattern := mux.Handler(r); pattern != "" {
+			if override, ok := p.routes[pattern]; ok {
+				policy = override
+			}
+		}
+
+		if r.ContentLength > policy.maxBody {
+			apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, "Request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, policy.maxBody)
+
+		// The server's ReadTimeout and WriteTimeout are set per connection;
+		// extend or shorten them for this request. Writers that cannot set
+		// deadlines keep the server's.
+		deadline := time.Now().Add(policy.timeout)
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(deadline)
+		rc.SetWriteDeadline(deadline)
+
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+func TestRoutePolicies(t *testing.T) {
+	cfg := config.ServerConfig{
+		WriteTimeout:   time.Second,
+		UploadTimeout:  time.Minute,
+		ChatTimeout:    30 * time.Second,
+		ListTimeout:    5 * time.Second,
+		MaxBodyBytes:   16,
+		MaxUploadBytes: 64,
+	}
+
+	// Each handler reports its deadline and how much of the body it read.
+	var timeLeft time.Duration
+	var readErr error
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ := r.Context().Deadline()
+		timeLeft = time.Until(deadline)
+		_, readErr = io.ReadAll(r.Body)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/documents/upload", handler)
+	mux.HandleFunc("/api/chat", handler)
+	mux.HandleFunc("/api/documents", handler)
+	mux.HandleFunc("GET /api/v1/documents", handler)
+	h := newRoutePolicies(cfg).Middleware(mux, mux)
+
+	for _, tt := range []struct {
+		method     string
+		path       string
+		body       int
+		chunked    bool
+		wantStatus int
+		wantTime   time.Duration
+		wantTooBig bool
+	}{
+		{path: "/api/documents", body: 16, wantStatus: http.StatusOK, wantTime: time.Second},
+		{path: "/api/documents", body: 17, wantStatus: http.StatusRequestEntityTooLarge},
+		{path: "/api/chat", body: 10, wantStatus: http.StatusOK, wantTime: 30 * time.Second},
+		{path: "/api/documents/upload", body: 64, wantStatus: http.StatusOK, wantTime: time.Minute},
+		{path: "/api/documents/upload", body: 65, wantStatus: http.StatusRequestEntityTooLarge},
+		// A body without a declared length reaches the handler, which cannot
+		// read past the limit.
+		{path: "/api/documents", body: 17, chunked: true, wantStatus: http.StatusOK, wantTime: time.Second, wantTooBig: true},
+		{path: "/unknown", body: 0, wantStatus: http.StatusNotFound},
+		// Lists get a shorter deadline than ordinary routes.
+		{method: http.MethodGet, path: "/api/v1/documents", wantStatus: http.StatusOK, wantTime: 5 * time.Second},
+	} {
+		timeLeft, readErr = 0, nil
+		method := tt.method
+		if method == "" {
+			method = http.MethodPost
+		}
+		r := httptest.NewRequest(method, tt.path, strings.NewReader(strings.Repeat("x", tt.body)))
+		if tt.chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s with %d bytes: status %d, want %d", tt.path, tt.body, w.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantTime != 0 && (timeLeft > tt.wantTime || timeLeft < tt.wantTime-time.Second) {
+			t.Errorf("%s: deadline in %v, want %v", tt.path, timeLeft, tt.wantTime)
+		}
+		var tooBig *http.MaxBytesError
+		if gotTooBig := readErr != nil && errors.As(readErr, &tooBig); gotTooBig != tt.wantTooBig {
+			t.Errorf("%s: read error %v, want MaxBytesError %v", tt.path, readErr, tt.wantTooBig)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/handlers"
+	"github.com/malharg/strategic-insight-analyst/backend/health"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/openapi"
+)
+
+// apiRoute is a route described in the OpenAPI document: its mux pattern
+// and any deprecated aliases.
+type apiRoute struct {
+	pattern string
+	legacy  []string
+}
+
+func routes(h *handlers.Handler, requireAuth func(http.Handler) http.Handler, ready *health.Readiness, metricsHandler http.Handler) (*http.ServeMux, *openapi.Builder, error) {
+	// Main router
+	mux := http.NewServeMux()
+	// Every API route is recorded so the OpenAPI document can be checked
+	// against what is actually served.
+	var api []apiRoute
+
+	// --- Public Routes ---
+	public := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, handler)
+		api = append(api, apiRoute{pattern: pattern})
+	}
+	public("GET /api/health", healthCheckHandler)
+
+	// Probes: liveness only says the process is serving; readiness checks the
+	// database, storage, auth and LLM provider.
+	public("GET /api/health/live", health.LiveHandler)
+	public("GET /api/health/ready", ready.ReadyHandler)
+
+	if metricsHandler != nil {
+		mux.Handle("/metrics", metricsHandler)
+	}
+
+	//  public route for testing Supabase upload
+	mux.HandleFunc("/api/test-supabase-upload", h.TestSupabaseUpload)
+
+	// public route for minimal direct Supabase upload (no SDK)
+	mux.HandleFunc("/api/minimal-supabase-upload", h.MinimalSupabaseUpload)
+
+	// --- Protected Routes ---
+	// Routes that accept personal API keys also declare the scope a key needs.
+	withScope := func(scope string, h http.HandlerFunc) http.Handler {
+		return requireAuth(auth.RequireScope(scope)(h))
+	}
+	// Chat and upload also spend the caller's usage quota.
+	withQuota := func(metric string, next http.HandlerFunc) http.HandlerFunc {
+		return h.Quotas.Require(metric)(next).ServeHTTP
+	}
+	// Some routes need a signed-in user or an administrator.
+	interactive := func(h http.HandlerFunc) http.Handler {
+		return requireAuth(auth.RequireInteractive(h))
+	}
+	admin := func(next http.HandlerFunc) http.Handler {
+		return requireAuth(auth.RequireAdmin(h.Config.AdminUIDs)(next))
+	}
+
+	// Each v1 route is registered with its method. The paths served before
+	// v1 stay as deprecated aliases for one release and accept any method,
+	// as before.
+	v1 := func(pattern string, handler http.Handler, legacy ...string) {
+		mux.Handle(pattern, handler)
+		api = append(api, apiRoute{pattern: pattern, legacy: legacy})
+		_, path, _ := strings.Cut(pattern, " ")
+		for _, old := range legacy {
+			mux.Handle(old, deprecated(path, handler))
+		}
+	}
+
+	// Handler for the secure ping test
+	v1("GET /api/v1/secure-ping", requireAuth(http.HandlerFunc(securePingHandler)), "/api/secure-ping")
+
+	// Remaining usage quota for the caller
+	v1("GET /api/v1/quota", requireAuth(http.HandlerFunc(h.GetQuota)))
+
+	// LLM usage and estimated cost; the admin view covers every user.
+	v1("GET /api/v1/usage", requireAuth(http.HandlerFunc(h.GetUsage)))
+	v1("GET /api/v1/admin/usage", admin(h.GetAllUsage))
+
+	// Audit trail of security-relevant actions, for administrators.
+	v1("GET /api/v1/admin/audit", admin(h.ListAuditEvents))
+	v1("GET /api/v1/admin/audit/export", admin(h.ExportAuditEvents))
+
+	// Documents: list and upload, then one document by ID.
+	v1("GET /api/v1/documents", withScope(auth.ScopeRead, h.ListDocuments))
+	// The deprecated list predates paging and keeps returning a bare array.
+	mux.Handle("GET /api/documents", deprecated("/api/v1/documents", withScope(auth.ScopeRead, h.ListAllDocuments)))
+	api = append(api, apiRoute{pattern: "GET /api/documents"})
+	v1("POST /api/v1/documents", withScope(auth.ScopeWrite, withQuota(limits.MetricUploads, h.UploadDocument)), "/api/documents/upload")
+	v1("GET /api/v1/documents/{id}", withScope(auth.ScopeRead, h.GetDocument))
+	v1("PATCH /api/v1/documents/{id}", withScope(auth.ScopeWrite, h.UpdateDocument))
+	v1("DELETE /api/v1/documents/{id}", withScope(auth.ScopeWrite, h.DeleteDocument), "/api/documents/delete")
+
+	// Original file download
+	v1("GET /api/v1/documents/{id}/download", withScope(auth.ScopeRead, h.DownloadDocument))
+
+	// Re-run extraction and chunking on the stored original
+	v1("POST /api/v1/documents/{id}/reingest", withScope(auth.ScopeWrite, h.ReingestDocument))
+
+	// Chunk inspection and retrieval preview, for debugging missing answers
+	v1("GET /api/v1/documents/{id}/chunks", withScope(auth.ScopeRead, h.ListChunks))
+	v1("POST /api/v1/documents/{id}/retrieval", withScope(auth.ScopeRead, h.PreviewRetrieval))
+
+	// chat with a document
+	v1("POST /api/v1/documents/{id}/chat", withScope(auth.ScopeChat, withQuota(limits.MetricLLMTokens, h.Chat)), "/api/chat")
+
+	// Sharing: owners manage grants, anyone can list what was shared with them.
+	v1("GET /api/v1/documents/shared", withScope(auth.ScopeRead, h.ListSharedDocuments))
+	v1("GET /api/v1/documents/{id}/shares", withScope(auth.ScopeRead, h.ListShares))
+	v1("POST /api/v1/documents/{id}/shares", withScope(auth.ScopeWrite, h.ShareDocument))
+	v1("DELETE /api/v1/documents/{id}/shares/{shareId}", withScope(auth.ScopeWrite, h.UnshareDocument))
+
+	// Document tags
+	v1("GET /api/v1/documents/{id}/tags", withScope(auth.ScopeRead, h.GetDocumentTags))
+	v1("PUT /api/v1/documents/{id}/tags", withScope(auth.ScopeWrite, h.SetDocumentTags))
+
+	// Organizations. Membership changes need a signed-in user; members can
+	// browse workspaces and collections with a read-scoped key.
+	v1("GET /api/v1/orgs", withScope(auth.ScopeRead, h.ListOrgs))
+	v1("POST /api/v1/orgs", interactive(h.CreateOrg))
+	v1("GET /api/v1/orgs/{id}/members", withScope(auth.ScopeRead, h.ListOrgMembers))
+	v1("POST /api/v1/orgs/{id}/members", interactive(h.InviteMember))
+	v1("DELETE /api/v1/orgs/{id}/members/{userId}", interactive(h.RemoveMember))
+	v1("DELETE /api/v1/orgs/{id}/invites/{email}", interactive(h.RemoveMember))
+	v1("GET /api/v1/orgs/{id}/collections", withScope(auth.ScopeRead, h.ListCollections))
+	v1("POST /api/v1/orgs/{id}/collections", withScope(auth.ScopeWrite, h.CreateCollection))
+
+	// Personal API key management is only available to signed-in users.
+	v1("GET /api/v1/keys", interactive(h.ListAPIKeys))
+	v1("POST /api/v1/keys", interactive(h.CreateAPIKey))
+	v1("DELETE /api/v1/keys/{id}", interactive(h.RevokeAPIKey))
+
+	// The document describes every route above and fails to build if one is
+	// undocumented or documented but not served.
+	spec, err := newSpec(append(api, apiRoute{pattern: specPattern}))
+	if err != nil {
+		return nil, nil, err
+	}
+	mux.Handle(specPattern, spec.Handler())
+
+	return mux, spec, nil
+}
+
+// notFound answers requests that match no route, or match one only with
+// another method, with the JSON error envelope rather than the mux's
+// plain-text 404 and 405.
+func notFound(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			// The mux set r.Pattern when it dispatched the request.
+			logging.SetRoute(r.Context(), r.Pattern)
+			return
+		}
+		// The mux's own handler only sets headers and a status; run it
+		// against a scratch writer to learn which one it chose.
+		probe := &headerRecorder{header: http.Header{}}
+		handler.ServeHTTP(probe, r)
+		if probe.status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", probe.header.Get("Allow"))
+			apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed.")
+			return
+		}
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No such API route.")
+	})
+}
+
+// headerRecorder keeps the headers and status written to it and discards the
+// body.
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (h *headerRecorder) Header() http.Header         { return h.header }
+func (h *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (h *headerRecorder) WriteHeader(status int)      { h.status = status }
+
+// deprecated serves a pre-v1 alias of a route, pointing clients at its
+// successor. The alias will be removed in the next release.
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+}
+
+func securePingHandler(w http.ResponseWriter, r *http.Request) {
+	// Retrieve the user ID from the context (set by the middleware)
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Pong! You are authenticated.",
+		"userID":  userID,
+	})
+}
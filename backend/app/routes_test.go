@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+)
+
+func TestV1Routes(t *testing.T) {
+	ts := newTestServer(t)
+
+	body, contentType := uploadForm(t, "q3.txt", strings.Repeat("Revenue grew 20% in Q3. ", 100))
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/documents", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer alice")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	expectStatus(t, resp, http.StatusCreated)
+	id := decodeBody[struct{ ID string }](t, resp).ID
+	doc := "/api/v1/documents/" + id
+
+	resp = call(t, ts, http.MethodGet, doc, "alice", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := decodeBody[struct{ FileName, Role string }](t, resp); got.FileName != "q3.txt" || got.Role != "owner" {
+		t.Errorf("GET %s = %+v", doc, got)
+	}
+
+	resp = call(t, ts, http.MethodPatch, doc, "alice", map[string]any{"fileName": "Q3.txt", "tags": []string{"finance"}})
+	expectStatus(t, resp, http.StatusOK)
+	if got := decodeBody[struct{ FileName string }](t, resp); got.FileName != "Q3.txt" {
+		t.Errorf("renamed to %q", got.FileName)
+	}
+
+	resp = call(t, ts, http.MethodPost, doc+"/chat", "alice", map[string]string{"query": "How did revenue do?"})
+	expectStatus(t, resp, http.StatusOK)
+	if chat := decodeBody[struct{ Response string }](t, resp); !strings.HasPrefix(chat.Response, "answer from") {
+		t.Errorf("chat response = %q", chat.Response)
+	}
+
+	expectStatus(t, call(t, ts, http.MethodDelete, doc, "bob", nil), http.StatusNotFound)
+	expectStatus(t, call(t, ts, http.MethodDelete, doc, "alice", nil), http.StatusNoContent)
+	expectStatus(t, call(t, ts, http.MethodGet, doc, "alice", nil), http.StatusNotFound)
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	ts := newTestServer(t)
+	resp := call(t, ts, http.MethodPut, "/api/v1/documents/doc-1", "alice", nil)
+	expectStatus(t, resp, http.StatusMethodNotAllowed)
+	if allow := resp.Header.Get("Allow"); !strings.Contains(allow, http.MethodPatch) || !strings.Contains(allow, http.MethodDelete) {
+		t.Errorf("Allow = %q", allow)
+	}
+	if got := decodeBody[apierror.Response](t, resp); got.Error.Code != apierror.CodeMethodNotAllowed {
+		t.Errorf("error = %+v", got.Error)
+	}
+}
+
+func TestDeprecatedAliases(t *testing.T) {
+	ts := newTestServer(t)
+	resp := call(t, ts, http.MethodGet, "/api/documents", "alice", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Deprecation") != "true" || resp.Header.Get("Link") != `</api/v1/documents>; rel="successor-version"` {
+		t.Errorf("alias headers: Deprecation %q, Link %q", resp.Header.Get("Deprecation"), resp.Header.Get("Link"))
+	}
+
+	resp = call(t, ts, http.MethodGet, "/api/v1/documents", "alice", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Deprecation") != "" {
+		t.Error("v1 route marked deprecated")
+	}
+}
+
+func TestOnlyPreV1PathsHaveAliases(t *testing.T) {
+	ts := newTestServer(t)
+	for _, path := range []string{"/api/secure-ping", "/api/documents"} {
+		resp := call(t, ts, http.MethodGet, path, "alice", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if resp.Header.Get("Deprecation") != "true" {
+			t.Errorf("%s not marked deprecated", path)
+		}
+	}
+	// Routes added after the pre-v1 paths were only ever served under v1.
+	for _, path := range []string{"/api/keys", "/api/keys/create", "/api/quota", "/api/usage", "/api/orgs", "/api/documents/shared", "/api/documents/download?id=doc-1"} {
+		expectStatus(t, call(t, ts, http.MethodGet, path, "alice", nil), http.StatusNotFound)
+	}
+}
+
+func TestRequestLogNamesRoutePattern(t *testing.T) {
+	var logs bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	ts := newTestServer(t)
+	orgID := decodeBody[struct{ ID string }](t, call(t, ts, http.MethodPost, "/api/v1/orgs", "alice", map[string]string{"name": "Acme"})).ID
+	expectStatus(t, call(t, ts, http.MethodPost, "/api/v1/orgs/"+orgID+"/members", "alice", map[string]string{"email": "carol@example.com"}), http.StatusOK)
+	logs.Reset()
+	expectStatus(t, call(t, ts, http.MethodDelete, "/api/v1/orgs/"+orgID+"/invites/carol@example.com", "alice", nil), http.StatusOK)
+
+	if strings.Contains(logs.String(), "carol@example.com") {
+		t.Errorf("the request path was logged: %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), `"route":"DELETE /api/v1/orgs/{id}/invites/{email}"`) {
+		t.Errorf("the completion line does not name the route pattern: %s", logs.String())
+	}
+}
@@ -0,0 +1,211 @@
+// Package app assembles the HTTP API from explicit dependencies, so the same
+// server can be started from main or from tests with fakes.
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/background"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/handlers"
+	"github.com/malharg/strategic-insight-analyst/backend/health"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/metrics"
+	"github.com/malharg/strategic-insight-analyst/backend/storage"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+	"github.com/malharg/strategic-insight-analyst/backend/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/cors"
+)
+
+// Options are the dependencies a Server is built from.
+type Options struct {
+	Config *config.Config
+	// DB backs the default SQL stores. It may be nil when Stores is set.
+	DB *database.DB
+	// Stores overrides the stores built from DB, e.g. with in-memory fakes.
+	Stores   *store.Stores
+	Verifier auth.TokenVerifier
+	Storage  storage.Storage
+	AI       ai.Provider
+	// Embedder embeds chunks at ingestion. It is optional; without it
+	// chunks are stored without embeddings.
+	Embedder ai.Embedder
+}
+
+// Server is the backend API. It implements http.Handler.
+type Server struct {
+	handler http.Handler
+	metrics http.Handler
+	tasks   *background.Group
+	audit   *audit.Logger
+	ready   *health.Readiness
+}
+
+// New validates opts and wires up the routes.
+func New(opts Options) (*Server, error) {
+	if opts.Config == nil {
+		return nil, errors.New("app: Config is required")
+	}
+	if opts.Stores == nil {
+		if opts.DB == nil {
+			return nil, errors.New("app: either DB or Stores is required")
+		}
+		opts.Stores = store.New(opts.DB)
+	}
+	if opts.Verifier == nil {
+		return nil, errors.New("app: Verifier is required")
+	}
+	if opts.Storage == nil {
+		return nil, errors.New("app: Storage is required")
+	}
+	if opts.AI == nil {
+		return nil, errors.New("app: AI is required")
+	}
+	// The pool statistics are per server; the rest of the metrics are
+	// process-wide.
+	var serverMetrics []prometheus.Gatherer
+	if opts.DB != nil {
+		serverMetrics = append(serverMetrics, metrics.DBStats(opts.DB.DB, "main"))
+	}
+	metricsHandler := metrics.Handler(opts.Config.Metrics.Token, serverMetrics...)
+
+	var embedder ai.Embedder
+	if opts.Embedder != nil {
+		embedder = metrics.InstrumentEmbedder(opts.Embedder)
+	}
+
+	apiKeys := &auth.APIKeys{Keys: opts.Stores.APIKeys, Users: opts.Stores.Users}
+
+	// Store audit events spilled to disk while the database was unavailable.
+	auditLog := audit.NewLogger(opts.Stores.Audit, opts.Config.AuditFallbackFile)
+	auditLog.TrustedProxies = opts.Config.Server.TrustedProxies
+	tasks := background.NewGroup()
+	auditLog.Tasks = tasks
+	if n, err := auditLog.Replay(context.Background()); err != nil {
+		slog.Error("failed to replay audit fallback file", "path", opts.Config.AuditFallbackFile, "error", err)
+	} else if n > 0 {
+		slog.Info("replayed audit events", "count", n, "path", opts.Config.AuditFallbackFile)
+	}
+
+	h := &handlers.Handler{
+		Config:    opts.Config,
+		Users:     opts.Stores.Users,
+		Documents: opts.Stores.Documents,
+		Chunks:    opts.Stores.Chunks,
+		Chats:     opts.Stores.Chats,
+		Shares:    opts.Stores.Shares,
+		Orgs:      opts.Stores.Orgs,
+		Usage:     opts.Stores.Usage,
+		Storage:   opts.Storage,
+		AI:        metrics.InstrumentProvider(opts.AI),
+		Embedder:  embedder,
+		APIKeys:   apiKeys,
+		Quotas:    limits.NewQuotas(opts.Stores.Quotas, opts.Config.Limits),
+		Audit:     auditLog,
+		Tasks:     tasks,
+	}
+
+	// Every authenticated route is rate limited per user; chat and upload
+	// have their own, tighter buckets.
+	// The deprecated aliases share their successor's bucket.
+	chatRule := limits.Rule{Name: "chat", PerMinute: opts.Config.Limits.ChatPerMinute}
+	uploadRule := limits.Rule{Name: "upload", PerMinute: opts.Config.Limits.UploadsPerMinute}
+	limiter := limits.NewRateLimiter(
+		limits.Rule{PerMinute: opts.Config.Limits.RequestsPerMinute},
+		map[string]limits.Rule{
+			"POST /api/v1/documents/{id}/chat": chatRule,
+			"/api/chat":                        chatRule,
+			"POST /api/v1/documents":           uploadRule,
+			"/api/documents/upload":            uploadRule,
+		},
+	)
+	authenticate := auth.Middleware(opts.Verifier, apiKeys, auditLog)
+	requireAuth := func(next http.Handler) http.Handler {
+		return authenticate(annotateUser(limiter.Middleware(next)))
+	}
+
+	// Readiness checks every dependency that can report on itself; fakes
+	// that cannot are skipped.
+	ready := health.NewReadiness()
+	if opts.DB != nil {
+		ready.Add("database", health.PingFunc(opts.DB.HealthCheck))
+	}
+	for name, dep := range map[string]any{"storage": opts.Storage, "auth": opts.Verifier, "llm": opts.AI} {
+		if p, ok := dep.(health.Pinger); ok {
+			ready.Add(name, p)
+		}
+	}
+
+	// Prometheus metrics are only served on the public port behind a token;
+	// otherwise they are on the separate METRICS_ADDR listener.
+	var publicMetrics http.Handler
+	if opts.Config.Metrics.Token != "" {
+		publicMetrics = metricsHandler
+	}
+	mux, _, err := routes(h, requireAuth, ready, publicMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure CORS
+	c := cors.New(cors.Options{
+		AllowedOrigins:   opts.Config.Server.CORSOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "X-API-Key", logging.RequestIDHeader, "traceparent", "tracestate"},
+		ExposedHeaders:   []string{"Retry-After", logging.RequestIDHeader, "Deprecation", "Link"},
+		AllowCredentials: true,
+		Debug:            false,
+	})
+
+	// Each route runs under its own deadline and body size limit.
+	policies := newRoutePolicies(opts.Config.Server)
+
+	// Wrap the main router with the CORS middleware, and everything with
+	// tracing, request logging and metrics.
+	handler := logging.Middleware(metrics.Middleware(mux, c.Handler(policies.Middleware(mux, notFound(mux)))))
+	return &Server{handler: tracing.Middleware(mux, handler), metrics: metricsHandler, tasks: tasks, audit: auditLog, ready: ready}, nil
+}
+
+// annotateUser adds the authenticated user to the request's log lines. It
+// must run after auth.Middleware.
+func annotateUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := r.Context().Value(auth.UserIDKey).(string); ok {
+			logging.SetUser(r.Context(), userID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Metrics serves this server's Prometheus metrics, for mounting on a
+// separate listener. It requires the configured metrics token, if any.
+func (s *Server) Metrics() http.Handler {
+	return s.metrics
+}
+
+// Drain makes the readiness probe fail so no new traffic is routed here.
+// Call it as soon as shutdown begins.
+func (s *Server) Drain() {
+	s.ready.Drain()
+}
+
+// Shutdown records the failed sign-ins still being aggregated and waits for
+// background work started by requests, such as document summaries, to
+// finish. Call it after the HTTP server has drained.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.audit.Flush(ctx)
+	return s.tasks.Shutdown(ctx)
+}
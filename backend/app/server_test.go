@@ -0,0 +1,628 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+	"github.com/malharg/strategic-insight-analyst/backend/store/storetest"
+)
+
+// testVerifier accepts any token and treats it as the caller's user ID.
+type testVerifier struct{}
+
+func (testVerifier) Verify(ctx context.Context, token string) (*auth.Identity, error) {
+	if token == "" {
+		return nil, errors.New("empty token")
+	}
+	return &auth.Identity{UID: token, Email: token + "@example.com"}, nil
+}
+
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *memStorage) Upload(ctx context.Context, path, contentType string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = data
+	return nil
+}
+
+func (s *memStorage) Download(ctx context.Context, path string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[path]
+	if !ok {
+		return nil, errors.New("no such file")
+	}
+	return data, nil
+}
+
+func (s *memStorage) Delete(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, path)
+	return nil
+}
+
+type fakeAI struct{}
+
+func (fakeAI) GenerateInsight(ctx context.Context, chunks []string, query string) (string, ai.Usage, error) {
+	return fmt.Sprintf("answer from %d chunks", len(chunks)), ai.Usage{Model: "gemini-1.5-flash", PromptTokens: 1000, ResponseTokens: 100}, nil
+}
+
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.UnidocLicenseKey = "test"
+	cfg.AuditFallbackFile = ""
+	return cfg
+}
+
+// newTestServer serves an app built from in-memory fakes. opts may adjust
+// the options before the server is built.
+func newTestServer(t *testing.T, opts ...func(*Options)) *httptest.Server {
+	t.Helper()
+	o := Options{
+		Config:   testConfig(),
+		Stores:   storetest.New(),
+		Verifier: testVerifier{},
+		Storage:  &memStorage{files: make(map[string][]byte)},
+		AI:       fakeAI{},
+	}
+	for _, f := range opts {
+		f(&o)
+	}
+	srv, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// call sends a request as userID, or unauthenticated if userID is empty. A
+// non-nil body that is not an io.Reader is sent as JSON.
+func call(t *testing.T, ts *httptest.Server, method, path, userID string, body any) *http.Response {
+	t.Helper()
+	var r io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, contentType = bytes.NewReader(data), "application/json"
+	}
+	req, err := http.NewRequest(method, ts.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if userID != "" {
+		req.Header.Set("Authorization", "Bearer "+userID)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// uploadForm is a multipart body uploading a text file.
+func uploadForm(t *testing.T, fileName, content string) (io.Reader, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("document", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, content)
+	mw.Close()
+	return &body, mw.FormDataContentType()
+}
+
+func upload(t *testing.T, ts *httptest.Server, userID, fileName string) {
+	t.Helper()
+	body, contentType := uploadForm(t, fileName, strings.Repeat("Revenue grew 20% in Q3. ", 100))
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/documents/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+userID)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload %s: status %d", fileName, resp.StatusCode)
+	}
+}
+
+func decodeBody[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatalf("%s %s: decode: %v", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return v
+}
+
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: status %d, want %d; body %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, b)
+	}
+}
+
+func TestNewRequiresDependencies(t *testing.T) {
+	full := Options{Config: testConfig(), Stores: storetest.New(), Verifier: testVerifier{}, Storage: &memStorage{}, AI: fakeAI{}}
+	if _, err := New(full); err != nil {
+		t.Fatalf("New with every dependency: %v", err)
+	}
+	for name, drop := range map[string]func(o *Options){
+		"Config":   func(o *Options) { o.Config = nil },
+		"Stores":   func(o *Options) { o.Stores = nil },
+		"Verifier": func(o *Options) { o.Verifier = nil },
+		"Storage":  func(o *Options) { o.Storage = nil },
+		"AI":       func(o *Options) { o.AI = nil },
+	} {
+		o := full
+		drop(&o)
+		if _, err := New(o); err == nil {
+			t.Errorf("New without %s succeeded", name)
+		}
+	}
+}
+
+func TestServerWithFakes(t *testing.T) {
+	ts := newTestServer(t)
+
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/health", "", nil), http.StatusOK)
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/documents", "", nil), http.StatusUnauthorized)
+
+	upload(t, ts, "alice", "q3.txt")
+	resp := call(t, ts, http.MethodGet, "/api/documents", "alice", nil)
+	expectStatus(t, resp, http.StatusOK)
+	docs := decodeBody[[]struct{ ID, FileName string }](t, resp)
+	if len(docs) != 1 || docs[0].FileName != "q3.txt" {
+		t.Fatalf("alice's documents = %+v, want the upload", docs)
+	}
+	id := docs[0].ID
+
+	resp = call(t, ts, http.MethodPost, "/api/chat", "alice", map[string]string{"documentId": id, "query": "How did revenue do?"})
+	expectStatus(t, resp, http.StatusOK)
+	if chat := decodeBody[struct{ Response string }](t, resp); !strings.HasPrefix(chat.Response, "answer from") {
+		t.Errorf("chat response = %q", chat.Response)
+	}
+
+	// Each user lists and deletes only their own documents.
+	resp = call(t, ts, http.MethodGet, "/api/documents", "bob", nil)
+	if docs := decodeBody[[]struct{ ID string }](t, resp); len(docs) != 0 {
+		t.Errorf("bob lists %d documents, want 0", len(docs))
+	}
+	expectStatus(t, call(t, ts, http.MethodDelete, "/api/documents/delete?id="+id, "bob", nil), http.StatusNotFound)
+	expectStatus(t, call(t, ts, http.MethodDelete, "/api/documents/delete?id="+id, "alice", nil), http.StatusNoContent)
+}
+
+func TestServersAreIndependent(t *testing.T) {
+	first := newTestServer(t)
+	second := newTestServer(t)
+
+	upload(t, first, "alice", "first.txt")
+	resp := call(t, second, http.MethodGet, "/api/documents", "alice", nil)
+	if docs := decodeBody[[]struct{ ID string }](t, resp); len(docs) != 0 {
+		t.Errorf("the second server lists %d documents uploaded to the first", len(docs))
+	}
+}
+
+// callWithKey sends a request authenticated with a personal API key.
+func callWithKey(t *testing.T, ts *httptest.Server, method, path, key string, body io.Reader, contentType string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-API-Key", key)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAPIKeys(t *testing.T) {
+	ts := newTestServer(t)
+	upload(t, ts, "alice", "q3.txt")
+
+	resp := call(t, ts, http.MethodPost, "/api/v1/keys", "alice", map[string]any{"name": "reports", "scopes": []string{"read"}})
+	expectStatus(t, resp, http.StatusCreated)
+	created := decodeBody[struct{ ID, Key string }](t, resp)
+	if created.Key == "" {
+		t.Fatal("the new key was not returned")
+	}
+	expectStatus(t, call(t, ts, http.MethodPost, "/api/v1/keys", "alice", map[string]any{"name": "bad", "scopes": []string{"admin"}}), http.StatusBadRequest)
+
+	// The key can do what its scopes allow, and nothing else.
+	resp = callWithKey(t, ts, http.MethodGet, "/api/documents", created.Key, nil, "")
+	expectStatus(t, resp, http.StatusOK)
+	if docs := decodeBody[[]struct{ FileName string }](t, resp); len(docs) != 1 {
+		t.Errorf("the key lists %d documents, want alice's 1", len(docs))
+	}
+	body, contentType := uploadForm(t, "more.txt", "More text.")
+	expectStatus(t, callWithKey(t, ts, http.MethodPost, "/api/documents/upload", created.Key, body, contentType), http.StatusForbidden)
+	expectStatus(t, callWithKey(t, ts, http.MethodGet, "/api/v1/keys", created.Key, nil, ""), http.StatusForbidden)
+
+	resp = call(t, ts, http.MethodGet, "/api/v1/keys", "alice", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if keys := decodeBody[[]struct{ ID, Key string }](t, resp); len(keys) != 1 || keys[0].ID != created.ID || keys[0].Key != "" {
+		t.Errorf("listed keys = %+v, want the new key without its secret", keys)
+	}
+
+	expectStatus(t, call(t, ts, http.MethodDelete, "/api/v1/keys/"+created.ID, "bob", nil), http.StatusNotFound)
+	expectStatus(t, call(t, ts, http.MethodDelete, "/api/v1/keys/"+created.ID, "alice", nil), http.StatusNoContent)
+	expectStatus(t, callWithKey(t, ts, http.MethodGet, "/api/documents", created.Key, nil, ""), http.StatusUnauthorized)
+}
+
+func TestRateLimitsAndQuotas(t *testing.T) {
+	ts := newTestServer(t, func(o *Options) {
+		o.Config.Limits = config.LimitsConfig{RequestsPerMinute: 100, ChatPerMinute: 1, UploadsDaily: 1}
+	})
+
+	upload(t, ts, "alice", "q3.txt")
+	body, contentType := uploadForm(t, "q4.txt", "More text.")
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/documents/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer alice")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("second upload: status %d, Retry-After %q; want 429 once the daily quota is used", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	resp = call(t, ts, http.MethodGet, "/api/documents", "alice", nil)
+	id := decodeBody[[]struct{ ID string }](t, resp)[0].ID
+	chat := map[string]string{"documentId": id, "query": "How did revenue do?"}
+	expectStatus(t, call(t, ts, http.MethodPost, "/api/chat", "alice", chat), http.StatusOK)
+	expectStatus(t, call(t, ts, http.MethodPost, "/api/chat", "alice", chat), http.StatusTooManyRequests)
+
+	resp = call(t, ts, http.MethodGet, "/api/v1/quota", "alice", nil)
+	expectStatus(t, resp, http.StatusOK)
+	used := make(map[string]int64)
+	for _, q := range decodeBody[[]struct {
+		Metric, Period string
+		Used           int64
+	}](t, resp) {
+		used[q.Metric+"/"+q.Period] = q.Used
+	}
+	if used["uploads/day"] != 1 || used["llm_tokens/day"] == 0 {
+		t.Errorf("quota usage = %v, want one upload and the chat's tokens", used)
+	}
+}
+
+func TestAdminUsageRequiresAdmin(t *testing.T) {
+	ts := newTestServer(t, func(o *Options) { o.Config.AdminUIDs = []string{"root"} })
+
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/v1/admin/usage", "alice", nil), http.StatusForbidden)
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/v1/admin/usage?groupBy=user", "root", nil), http.StatusOK)
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/v1/usage", "alice", nil), http.StatusOK)
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/v1/usage", "", nil), http.StatusUnauthorized)
+}
+
+func TestAuditTrail(t *testing.T) {
+	ts := newTestServer(t, func(o *Options) { o.Config.AdminUIDs = []string{"root"} })
+	upload(t, ts, "alice", "q3.txt")
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/documents", "alice", nil), http.StatusOK)
+
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/v1/admin/audit", "alice", nil), http.StatusForbidden)
+	// Sign-ins are written in the background.
+	var actions map[string]int
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		resp := call(t, ts, http.MethodGet, "/api/v1/admin/audit?userId=alice", "root", nil)
+		expectStatus(t, resp, http.StatusOK)
+		actions = make(map[string]int)
+		for _, e := range decodeBody[[]struct{ Action string }](t, resp) {
+			actions[e.Action]++
+		}
+		if actions["auth.login"] > 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+	// alice's two requests are one sign-in.
+	if actions["auth.login"] != 1 || actions["document.upload"] != 1 {
+		t.Errorf("audit actions = %v, want one login and one upload", actions)
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	ts := newTestServer(t)
+	resp := call(t, ts, http.MethodGet, "/api/documents", "alice", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("response has no X-Request-ID")
+	}
+
+	// Requests rejected by authentication are tagged too.
+	resp = call(t, ts, http.MethodGet, "/api/documents", "", nil)
+	expectStatus(t, resp, http.StatusUnauthorized)
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("401 response has no X-Request-ID")
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	ts := newTestServer(t)
+	for _, c := range []struct {
+		name, method, path, userID string
+		status                     int
+		code                       apierror.Code
+	}{
+		{"unknown route", http.MethodGet, "/api/nothing-here", "alice", http.StatusNotFound, apierror.CodeNotFound},
+		{"no credentials", http.MethodGet, "/api/documents", "", http.StatusUnauthorized, apierror.CodeUnauthenticated},
+		{"not an admin", http.MethodGet, "/api/v1/admin/usage", "alice", http.StatusForbidden, apierror.CodeAdminRequired},
+		{"missing id", http.MethodDelete, "/api/documents/delete", "alice", http.StatusBadRequest, apierror.CodeInvalidRequest},
+	} {
+		resp := call(t, ts, c.method, c.path, c.userID, nil)
+		if resp.StatusCode != c.status {
+			t.Errorf("%s: status %d, want %d", c.name, resp.StatusCode, c.status)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q", c.name, ct)
+		}
+		body := decodeBody[apierror.Response](t, resp)
+		if body.Error.Code != c.code || body.Error.Message == "" {
+			t.Errorf("%s: error = %+v, want code %s", c.name, body.Error, c.code)
+		}
+		if id := resp.Header.Get("X-Request-ID"); body.Error.RequestID != id {
+			t.Errorf("%s: requestId %q, header %q", c.name, body.Error.RequestID, id)
+		}
+	}
+}
+
+func TestEmailSharesNeedVerifiedEmail(t *testing.T) {
+	verifier := auth.NewHMACVerifier([]byte("secret"), "", "")
+	ts := newTestServer(t, func(o *Options) { o.Verifier = verifier })
+	token := func(uid, email string, verified bool) string {
+		claims := auth.Claims{Email: email, EmailVerified: verified, RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uid,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}}
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	alice := token("alice", "alice@example.com", true)
+	body, contentType := uploadForm(t, "q3.txt", "Revenue grew 20% in Q3.")
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/documents", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+alice)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	expectStatus(t, resp, http.StatusCreated)
+	doc := decodeBody[struct{ ID string }](t, resp)
+	expectStatus(t, call(t, ts, http.MethodPost, "/api/v1/documents/"+doc.ID+"/shares", alice, map[string]string{"email": "bob@example.com", "role": "viewer"}), http.StatusOK)
+
+	// Anyone can put bob's address in a token; only a provider that has
+	// verified it gets bob's shares.
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/v1/documents/"+doc.ID, token("mallory", "bob@example.com", false), nil), http.StatusNotFound)
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/v1/documents/"+doc.ID, token("bob", "bob@example.com", true), nil), http.StatusOK)
+}
+
+func TestServersReportTheirOwnDatabase(t *testing.T) {
+	for _, maxOpen := range []int{3, 7} {
+		db, err := database.Open("sqlite://" + filepath.Join(t.TempDir(), "db.sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		db.SetMaxOpenConns(maxOpen)
+		ts := newTestServer(t, func(o *Options) {
+			o.DB, o.Stores = db, nil
+			o.Config.Metrics.Token = "metrics-token"
+		})
+		resp := call(t, ts, http.MethodGet, "/metrics", "metrics-token", nil)
+		expectStatus(t, resp, http.StatusOK)
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want := fmt.Sprintf(`go_sql_max_open_connections{db_name="main"} %d`, maxOpen); !strings.Contains(string(b), want) {
+			t.Errorf("metrics of the server with %d connections lack %q", maxOpen, want)
+		}
+	}
+}
+
+func TestMetricsRequireToken(t *testing.T) {
+	ts := newTestServer(t)
+	expectStatus(t, call(t, ts, http.MethodGet, "/metrics", "", nil), http.StatusNotFound)
+
+	ts = newTestServer(t, func(o *Options) { o.Config.Metrics.Token = "metrics-token" })
+	expectStatus(t, call(t, ts, http.MethodGet, "/metrics", "", nil), http.StatusUnauthorized)
+	// call sends the user ID as the bearer token.
+	expectStatus(t, call(t, ts, http.MethodGet, "/metrics", "metrics-token", nil), http.StatusOK)
+}
+
+func TestBodyLimits(t *testing.T) {
+	ts := newTestServer(t, func(o *Options) {
+		o.Config.Server.MaxUploadBytes = 4 << 10
+		o.Config.Server.MaxBodyBytes = 1 << 10
+	})
+
+	body, contentType := uploadForm(t, "big.txt", strings.Repeat("x", 8<<10))
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/documents/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer alice")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status %d, want 413", resp.StatusCode)
+	}
+	// The upload limit does not apply to other routes.
+	upload(t, ts, "alice", "q3.txt")
+
+	chat := map[string]string{"documentId": "doc-1", "query": strings.Repeat("why? ", 1<<10)}
+	expectStatus(t, call(t, ts, http.MethodPost, "/api/chat", "alice", chat), http.StatusRequestEntityTooLarge)
+	expectStatus(t, call(t, ts, http.MethodPost, "/api/chat", "alice", strings.NewReader("{not json")), http.StatusBadRequest)
+}
+
+// pingStorage is a memStorage that reports its health.
+type pingStorage struct {
+	*memStorage
+	err error
+}
+
+func (s pingStorage) Ping(ctx context.Context) error { return s.err }
+
+func TestHealthProbes(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		storage   error
+		wantReady int
+	}{
+		{"healthy", nil, http.StatusOK},
+		{"storage down", errors.New("bucket not found"), http.StatusServiceUnavailable},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, func(o *Options) {
+				o.Storage = pingStorage{memStorage: &memStorage{files: map[string][]byte{}}, err: tt.storage}
+			})
+			expectStatus(t, call(t, ts, http.MethodGet, "/api/health/live", "", nil), http.StatusOK)
+
+			resp := call(t, ts, http.MethodGet, "/api/health/ready", "", nil)
+			expectStatus(t, resp, tt.wantReady)
+			report := decodeBody[struct {
+				Status string
+				Checks map[string]struct{ Status string }
+			}](t, resp)
+			if len(report.Checks) != 1 || report.Checks["storage"].Status == "" {
+				t.Errorf("checks = %+v, want only storage", report.Checks)
+			}
+		})
+	}
+}
+
+func TestDrainFailsReadiness(t *testing.T) {
+	srv, err := New(Options{
+		Config:   testConfig(),
+		Stores:   storetest.New(),
+		Verifier: testVerifier{},
+		Storage:  &memStorage{files: make(map[string][]byte)},
+		AI:       fakeAI{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/health/ready", "", nil), http.StatusOK)
+	srv.Drain()
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/health/ready", "", nil), http.StatusServiceUnavailable)
+	// Requests already routed here are still served.
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/documents", "alice", nil), http.StatusOK)
+	expectStatus(t, call(t, ts, http.MethodGet, "/api/health/live", "", nil), http.StatusOK)
+}
+
+// slowChats holds every chat history write until release is closed.
+type slowChats struct {
+	store.ChatStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *slowChats) SaveExchange(ctx context.Context, documentID, userID, conversationID, query, answer string) error {
+	close(c.started)
+	<-c.release
+	return c.ChatStore.SaveExchange(ctx, documentID, userID, conversationID, query, answer)
+}
+
+func TestShutdownWaitsForChatHistory(t *testing.T) {
+	stores := storetest.New()
+	saved := stores.Chats
+	chats := &slowChats{ChatStore: saved, started: make(chan struct{}), release: make(chan struct{})}
+	stores.Chats = chats
+	srv, err := New(Options{
+		Config:   testConfig(),
+		Stores:   stores,
+		Verifier: testVerifier{},
+		Storage:  &memStorage{files: make(map[string][]byte)},
+		AI:       fakeAI{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	upload(t, ts, "alice", "q3.txt")
+	resp := call(t, ts, http.MethodGet, "/api/v1/documents", "alice", nil)
+	id := decodeBody[struct{ Documents []struct{ ID string } }](t, resp).Documents[0].ID
+
+	// The answer reaches the client before the history is saved.
+	resp = call(t, ts, http.MethodPost, "/api/v1/documents/"+id+"/chat", "alice", map[string]string{"query": "How did revenue do?"})
+	expectStatus(t, resp, http.StatusOK)
+	defer resp.Body.Close()
+	<-chats.started
+
+	// Draining the HTTP server waits for the write, since it is part of the
+	// request, so the database is not closed under it.
+	done := make(chan error, 1)
+	go func() {
+		srv.Drain()
+		done <- ts.Config.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("shutdown returned %v with chat history still being saved", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(chats.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	stores.Chats = saved
+	if msgs := storetest.Messages(stores); len(msgs) != 2 {
+		t.Errorf("saved %d messages before shutdown finished, want the exchange's 2", len(msgs))
+	}
+}
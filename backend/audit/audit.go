@@ -0,0 +1,356 @@
+// Package audit records the append-only trail of security-relevant actions:
+// who signed in, and who uploaded, downloaded, deleted, shared or queried
+// which document.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/background"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin        = "auth.login"
+	ActionLoginFailed  = "auth.login_failed"
+	ActionUpload       = "document.upload"
+	ActionDownload     = "document.download"
+	ActionDelete       = "document.delete"
+	ActionRename       = "document.rename"
+	ActionReingest     = "document.reingest"
+	ActionAccessDenied = "document.access_denied"
+	ActionShare        = "document.share"
+	ActionUnshare      = "document.unshare"
+	ActionChat         = "document.chat"
+	ActionAPIKeyCreate = "api_key.create"
+	ActionAPIKeyRevoke = "api_key.revoke"
+	ActionOrgInvite    = "org.invite"
+	ActionOrgRemove    = "org.remove_member"
+)
+
+// loginWindow is how long a sign-in from the same user, credential and client
+// counts as one login. Tokens are sent with every request, so without it each
+// API call would be logged as a login.
+const loginWindow = 12 * time.Hour
+
+// failedLoginWindow is how long failed sign-ins from one client IP are
+// aggregated. The first is recorded at once; the rest are counted and
+// recorded as one event after the window, so a client retrying bad tokens
+// cannot flood the audit log.
+const failedLoginWindow = time.Minute
+
+// writeAttempts and writeTimeout bound how hard Logger tries the database
+// before falling back to the local file.
+const (
+	writeAttempts = 3
+	writeTimeout  = 5 * time.Second
+)
+
+// Logger writes audit events. Events that cannot be stored in the database
+// are appended to FallbackPath and replayed on the next start, so a database
+// outage never drops entries silently.
+type Logger struct {
+	Store        store.AuditStore
+	FallbackPath string
+	// TrustedProxies is how many proxies in front of the server append to
+	// X-Forwarded-For; see ClientIP.
+	TrustedProxies int
+	// Tasks, if set, runs sign-in writes so they do not hold up the request.
+	Tasks *background.Group
+
+	fileMu sync.Mutex
+
+	loginMu  sync.Mutex
+	logins   map[string]time.Time
+	failures map[string]*failedLogins
+	swept    time.Time
+}
+
+// failedLogins aggregates the failed sign-ins from one IP in a window.
+type failedLogins struct {
+	start time.Time
+	// count is the failures after the first, which are not recorded yet;
+	// last is the latest of them.
+	count int
+	last  store.AuditEvent
+}
+
+// NewLogger returns a Logger that writes to s and spills to fallbackPath.
+func NewLogger(s store.AuditStore, fallbackPath string) *Logger {
+	return &Logger{Store: s, FallbackPath: fallbackPath, logins: make(map[string]time.Time), failures: make(map[string]*failedLogins)}
+}
+
+// Record logs action by the authenticated caller of r. documentID may be
+// empty; details are stored as a JSON object.
+func (l *Logger) Record(r *http.Request, action, documentID string, details map[string]string) {
+	userID, _ := r.Context().Value(auth.UserIDKey).(string)
+	l.write(r.Context(), l.newEvent(r, userID, action, documentID, details))
+}
+
+// ObserveLogin implements auth.LoginObserver. Successful sign-ins are
+// recorded once per loginWindow and client, failed ones aggregated per
+// failedLoginWindow and IP. The events are written in the background.
+func (l *Logger) ObserveLogin(r *http.Request, id *auth.Identity, err error) {
+	if err != nil {
+		l.observeFailure(r, err)
+		return
+	}
+
+	key := strings.Join([]string{id.UID, id.APIKeyID, ClientIP(r, l.TrustedProxies), r.UserAgent()}, "|")
+	now := time.Now()
+	l.loginMu.Lock()
+	if last, ok := l.logins[key]; ok && now.Sub(last) < loginWindow {
+		l.loginMu.Unlock()
+		return
+	}
+	l.logins[key] = now
+	if len(l.logins) > 10000 {
+		for k, t := range l.logins {
+			if now.Sub(t) >= loginWindow {
+				delete(l.logins, k)
+			}
+		}
+	}
+	l.loginMu.Unlock()
+
+	details := map[string]string{"method": "token"}
+	if id.APIKeyID != "" {
+		details = map[string]string{"method": "api_key", "apiKeyId": id.APIKeyID}
+	}
+	l.writeLater(r.Context(), l.newEvent(r, id.UID, ActionLogin, "", details))
+}
+
+func (l *Logger) observeFailure(r *http.Request, err error) {
+	e := l.newEvent(r, "", ActionLoginFailed, "", map[string]string{"error": err.Error()})
+	now := time.Now()
+	l.loginMu.Lock()
+	due := l.sweepFailures(now)
+	f, ok := l.failures[e.IP]
+	if ok {
+		f.count++
+		f.last = e
+	} else {
+		l.failures[e.IP] = &failedLogins{start: now}
+	}
+	l.loginMu.Unlock()
+
+	for _, d := range due {
+		l.writeLater(r.Context(), d)
+	}
+	if !ok {
+		l.writeLater(r.Context(), e)
+	}
+}
+
+// sweepFailures removes the aggregates whose window has passed, at most
+// once per window, and returns the events for their uncounted failures. The
+// caller holds loginMu.
+func (l *Logger) sweepFailures(now time.Time) []store.AuditEvent {
+	if now.Sub(l.swept) < failedLoginWindow {
+		return nil
+	}
+	l.swept = now
+	var due []store.AuditEvent
+	for ip, f := range l.failures {
+		if now.Sub(f.start) >= failedLoginWindow {
+			if f.count > 0 {
+				due = append(due, f.summary())
+			}
+			delete(l.failures, ip)
+		}
+	}
+	return due
+}
+
+// summary is the event recording the failures after the first.
+func (f *failedLogins) summary() store.AuditEvent {
+	e := f.last
+	var details map[string]string
+	json.Unmarshal([]byte(e.Details), &details)
+	if details == nil {
+		details = make(map[string]string)
+	}
+	details["count"] = strconv.Itoa(f.count)
+	details["since"] = f.start.UTC().Format(time.RFC3339)
+	b, _ := json.Marshal(details)
+	e.Details = string(b)
+	return e
+}
+
+// Flush records the failed sign-ins still being aggregated. Call it at
+// shutdown.
+func (l *Logger) Flush(ctx context.Context) {
+	l.loginMu.Lock()
+	var due []store.AuditEvent
+	for ip, f := range l.failures {
+		if f.count > 0 {
+			due = append(due, f.summary())
+		}
+		delete(l.failures, ip)
+	}
+	l.loginMu.Unlock()
+	for _, e := range due {
+		l.write(ctx, e)
+	}
+}
+
+func (l *Logger) newEvent(r *http.Request, userID, action, documentID string, details map[string]string) store.AuditEvent {
+	e := store.AuditEvent{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Action:     action,
+		DocumentID: documentID,
+		IP:         ClientIP(r, l.TrustedProxies),
+		UserAgent:  r.UserAgent(),
+		CreatedAt:  time.Now().UTC(),
+	}
+	// Drop empty fields so optional details do not clutter the entry.
+	kept := make(map[string]string, len(details))
+	for k, v := range details {
+		if v != "" {
+			kept[k] = v
+		}
+	}
+	if len(kept) > 0 {
+		b, _ := json.Marshal(kept)
+		e.Details = string(b)
+	}
+	return e
+}
+
+// writeLater writes e in the background if Tasks is set, and otherwise
+// right away.
+func (l *Logger) writeLater(ctx context.Context, e store.AuditEvent) {
+	if l.Tasks == nil {
+		l.write(ctx, e)
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	l.Tasks.Go("audit login", func(context.Context) { l.write(ctx, e) })
+}
+
+// write stores e, retrying briefly, then falls back to the local file and as
+// a last resort to the process log. The request context is detached so a
+// client hanging up cannot cancel the write.
+func (l *Logger) write(ctx context.Context, e store.AuditEvent) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt < writeAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		if err = l.Store.Append(ctx, e); err == nil {
+			return
+		}
+	}
+	logging.FromContext(ctx).Error("audit event not stored in database", "event_id", e.ID, "action", e.Action, "error", err)
+
+	if ferr := l.appendFallback(e); ferr != nil {
+		b, _ := json.Marshal(e)
+		logging.FromContext(ctx).Error("audit fallback file unavailable", "error", ferr, "event", string(b))
+	}
+}
+
+func (l *Logger) appendFallback(e store.AuditEvent) error {
+	if l.FallbackPath == "" {
+		return os.ErrInvalid
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	f, err := os.OpenFile(l.FallbackPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replay moves events from the fallback file into the database. Events that
+// still cannot be stored stay in the file. It returns how many were stored.
+func (l *Logger) Replay(ctx context.Context) (int, error) {
+	if l.FallbackPath == "" {
+		return 0, nil
+	}
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+
+	f, err := os.Open(l.FallbackPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var pending []string
+	stored := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var e store.AuditEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			slog.Error("kept unreadable line in audit fallback file", "path", l.FallbackPath, "error", err)
+			pending = append(pending, line)
+			continue
+		}
+		// Append ignores IDs already stored, so replaying twice is safe.
+		if err := l.Store.Append(ctx, e); err != nil {
+			pending = append(pending, line)
+			continue
+		}
+		stored++
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return stored, err
+	}
+
+	if len(pending) == 0 {
+		return stored, os.Remove(l.FallbackPath)
+	}
+	return stored, os.WriteFile(l.FallbackPath, []byte(strings.Join(pending, "\n")+"\n"), 0o600)
+}
+
+// ClientIP returns the caller's address. Each of the trustedProxies proxies
+// in front of the server appends the address it received the request from
+// to X-Forwarded-For, so the hop that many entries from the right is the
+// client; anything further left was sent by the client and may be forged.
+// With no trusted proxies, or too few hops, it is the connection's address.
+func ClientIP(r *http.Request, trustedProxies int) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" && trustedProxies > 0 {
+		hops := strings.Split(fwd, ",")
+		if len(hops) >= trustedProxies {
+			if hop := strings.TrimSpace(hops[len(hops)-trustedProxies]); hop != "" {
+				return hop
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,252 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/background"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+	"github.com/malharg/strategic-insight-analyst/backend/store/storetest"
+)
+
+// flakyStore fails every Append while down is set.
+type flakyStore struct {
+	store.AuditStore
+	down bool
+}
+
+func (s *flakyStore) Append(ctx context.Context, e store.AuditEvent) error {
+	if s.down {
+		return errors.New("database unavailable")
+	}
+	return s.AuditStore.Append(ctx, e)
+}
+
+func newRequest(userID string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/documents", nil)
+	r.RemoteAddr = "192.0.2.7:51234"
+	r.Header.Set("User-Agent", "test-agent")
+	if userID != "" {
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, userID))
+	}
+	return r
+}
+
+func list(t *testing.T, s store.AuditStore) []store.AuditEvent {
+	t.Helper()
+	events, err := s.List(context.Background(), store.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestRecord(t *testing.T) {
+	l := NewLogger(storetest.New().Audit, "")
+	l.Record(newRequest("alice"), ActionUpload, "doc-1", map[string]string{"fileName": "q3.pdf", "orgId": ""})
+
+	events := list(t, l.Store)
+	if len(events) != 1 {
+		t.Fatalf("stored %d events, want 1", len(events))
+	}
+	e := events[0]
+	if e.ID == "" || e.UserID != "alice" || e.Action != ActionUpload || e.DocumentID != "doc-1" ||
+		e.IP != "192.0.2.7" || e.UserAgent != "test-agent" || e.CreatedAt.IsZero() {
+		t.Errorf("event = %+v", e)
+	}
+	// Empty details are dropped.
+	if e.Details != `{"fileName":"q3.pdf"}` {
+		t.Errorf("details = %s", e.Details)
+	}
+}
+
+func TestObserveLogin(t *testing.T) {
+	l := NewLogger(storetest.New().Audit, "")
+	alice := &auth.Identity{UID: "alice"}
+	l.ObserveLogin(newRequest(""), alice, nil)
+	l.ObserveLogin(newRequest(""), alice, nil)
+	l.ObserveLogin(newRequest(""), &auth.Identity{UID: "alice", APIKeyID: "key-1"}, nil)
+	l.ObserveLogin(newRequest(""), nil, errors.New("token expired"))
+
+	counts := make(map[string]int)
+	for _, e := range list(t, l.Store) {
+		counts[e.Action]++
+	}
+	// The repeated token sign-in counts once; the API key is a separate login.
+	if counts[ActionLogin] != 2 || counts[ActionLoginFailed] != 1 {
+		t.Errorf("events by action = %v, want 2 logins and 1 failure", counts)
+	}
+}
+
+func TestObserveLoginAggregatesFailures(t *testing.T) {
+	l := NewLogger(storetest.New().Audit, "")
+	failed := func() []store.AuditEvent {
+		events, err := l.Store.List(context.Background(), store.AuditFilter{Action: ActionLoginFailed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+	for range 5 {
+		l.ObserveLogin(newRequest(""), nil, errors.New("token expired"))
+	}
+	if events := failed(); len(events) != 1 || events[0].Details != `{"error":"token expired"}` {
+		t.Fatalf("failures recorded right away = %+v, want only the first", events)
+	}
+
+	// Another client is not held back by the first one's failures.
+	other := newRequest("")
+	other.RemoteAddr = "198.51.100.4:4000"
+	l.ObserveLogin(other, nil, errors.New("bad signature"))
+	if n := len(failed()); n != 2 {
+		t.Fatalf("%d failures recorded, want 2", n)
+	}
+
+	// Once the window has passed, the next failure records the count.
+	l.loginMu.Lock()
+	for _, f := range l.failures {
+		f.start = f.start.Add(-failedLoginWindow)
+	}
+	l.swept = l.swept.Add(-failedLoginWindow)
+	l.loginMu.Unlock()
+	l.ObserveLogin(newRequest(""), nil, errors.New("token expired"))
+	events := failed()
+	if len(events) != 4 {
+		t.Fatalf("%d failures recorded after the window, want the count and a new first", len(events))
+	}
+	var counted []string
+	for _, e := range events {
+		var details map[string]string
+		json.Unmarshal([]byte(e.Details), &details)
+		if details["count"] != "" {
+			counted = append(counted, e.IP+"="+details["count"])
+		}
+	}
+	if !slices.Equal(counted, []string{"192.0.2.7=4"}) {
+		t.Errorf("aggregated failures = %v, want 4 from 192.0.2.7", counted)
+	}
+
+	// Flush records what is still being counted.
+	l.ObserveLogin(newRequest(""), nil, errors.New("token expired"))
+	l.Flush(context.Background())
+	if n := len(failed()); n != 5 {
+		t.Errorf("%d failures recorded after Flush, want 5", n)
+	}
+}
+
+// blockingStore holds every Append until release is closed.
+type blockingStore struct {
+	store.AuditStore
+	release chan struct{}
+}
+
+func (s *blockingStore) Append(ctx context.Context, e store.AuditEvent) error {
+	<-s.release
+	return s.AuditStore.Append(ctx, e)
+}
+
+func TestObserveLoginWritesInBackground(t *testing.T) {
+	s := &blockingStore{AuditStore: storetest.New().Audit, release: make(chan struct{})}
+	l := NewLogger(s, "")
+	l.Tasks = background.NewGroup()
+
+	done := make(chan struct{})
+	go func() {
+		l.ObserveLogin(newRequest(""), &auth.Identity{UID: "alice"}, nil)
+		l.ObserveLogin(newRequest(""), nil, errors.New("token expired"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ObserveLogin waited for the database")
+	}
+
+	close(s.release)
+	if err := l.Tasks.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(list(t, s)); n != 2 {
+		t.Errorf("stored %d events, want 2", n)
+	}
+}
+
+func TestFallbackAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	s := &flakyStore{AuditStore: storetest.New().Audit, down: true}
+	l := NewLogger(s, path)
+
+	l.Record(newRequest("alice"), ActionDelete, "doc-1", nil)
+	l.Record(newRequest("alice"), ActionDownload, "doc-2", nil)
+	if len(list(t, s)) != 0 {
+		t.Fatal("events stored while the database was down")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("fallback file not written: %v", err)
+	}
+
+	// Nothing is lost while the database is still down.
+	if n, err := l.Replay(context.Background()); n != 0 || err != nil {
+		t.Fatalf("Replay while down = %d, %v", n, err)
+	}
+
+	s.down = false
+	if n, err := l.Replay(context.Background()); n != 2 || err != nil {
+		t.Fatalf("Replay = %d, %v; want 2 events stored", n, err)
+	}
+	if len(list(t, s)) != 2 {
+		t.Errorf("stored %d events after replay, want 2", len(list(t, s)))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("fallback file kept after a full replay: %v", err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	for _, tt := range []struct {
+		remoteAddr, forwarded string
+		trustedProxies        int
+		want                  string
+	}{
+		{"192.0.2.7:51234", "", 1, "192.0.2.7"},
+		{"[2001:db8::1]:443", "", 1, "2001:db8::1"},
+		{"unix", "", 1, "unix"},
+		{"10.0.0.1:80", "203.0.113.5", 1, "203.0.113.5"},
+		// The client can send its own header; the load balancer appends the
+		// real address to it.
+		{"10.0.0.1:80", "198.51.100.1, 203.0.113.5", 1, "203.0.113.5"},
+		{"10.0.0.1:80", "198.51.100.1, 203.0.113.5, 10.0.0.9", 2, "203.0.113.5"},
+		{"10.0.0.1:80", "203.0.113.5", 2, "10.0.0.1"},
+		{"10.0.0.1:80", "203.0.113.5", 0, "10.0.0.1"},
+		{"10.0.0.1:80", "198.51.100.1, ", 1, "10.0.0.1"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := ClientIP(r, tt.trustedProxies); got != tt.want {
+			t.Errorf("ClientIP(%q, %q, %d) = %q, want %q", tt.remoteAddr, tt.forwarded, tt.trustedProxies, got, tt.want)
+		}
+	}
+}
+
+func TestRecordIgnoresSpoofedForwardedFor(t *testing.T) {
+	l := NewLogger(storetest.New().Audit, "")
+	l.TrustedProxies = 1
+	r := newRequest("alice")
+	r.Header.Set("X-Forwarded-For", "127.0.0.1, 203.0.113.5")
+	l.Record(r, ActionDelete, "doc-1", nil)
+	if events := list(t, l.Store); len(events) != 1 || events[0].IP != "203.0.113.5" {
+		t.Errorf("events = %+v, want the address the load balancer saw", events)
+	}
+}
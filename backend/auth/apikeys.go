@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// Scopes that can be granted to a personal API key. ID tokens carry them all.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeChat  = "chat"
+)
+
+// AllScopes lists every valid scope.
+var AllScopes = []string{ScopeRead, ScopeWrite, ScopeChat}
+
+// apiKeyPrefix marks personal API keys so they can be told apart from JWTs.
+const apiKeyPrefix = "sia_"
+
+// touchInterval limits how often last_used_at is written for a busy key.
+const touchInterval = time.Minute
+
+var ErrInvalidAPIKey = errors.New("invalid or revoked API key")
+
+// APIKeyResolver maps a raw personal API key to the identity that owns it.
+type APIKeyResolver interface {
+	Resolve(ctx context.Context, rawKey string) (*Identity, error)
+}
+
+// APIKeys issues and resolves personal API keys.
+type APIKeys struct {
+	Keys  store.APIKeyStore
+	Users store.UserStore
+}
+
+// IsAPIKey reports whether token looks like a personal API key.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateScopes rejects unknown scopes and returns them de-duplicated in
+// canonical order.
+func ValidateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if !slices.Contains(AllScopes, s) {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+	}
+	var out []string
+	for _, s := range AllScopes {
+		if slices.Contains(scopes, s) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// Create issues a new key for userID. The raw key is returned once and never
+// stored; only its hash is persisted.
+func (a *APIKeys) Create(ctx context.Context, userID, name string, scopes []string) (*store.APIKey, string, error) {
+	scopes, err := ValidateScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("could not generate API key: %w", err)
+	}
+	rawKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := store.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    rawKey[:len(apiKeyPrefix)+8],
+		Hash:      hashAPIKey(rawKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := a.Keys.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return &key, rawKey, nil
+}
+
+func (a *APIKeys) Resolve(ctx context.Context, rawKey string) (*Identity, error) {
+	key, err := a.Keys.GetByHash(ctx, hashAPIKey(rawKey))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := a.Users.Get(ctx, key.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load API key owner: %w", err)
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+		if err := a.Keys.Touch(ctx, key.ID, now); err != nil {
+			slog.WarnContext(ctx, "failed to update API key last_used_at", "api_key_id", key.ID, "error", err)
+		}
+	}
+
+	return &Identity{UID: user.ID, Email: user.Email, Scopes: key.Scopes, APIKeyID: key.ID}, nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+	"github.com/malharg/strategic-insight-analyst/backend/store/storetest"
+)
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		in      []string
+		want    []string
+		wantErr bool
+	}{
+		{in: []string{"read"}, want: []string{"read"}},
+		{in: []string{"chat", "read", "chat"}, want: []string{"read", "chat"}},
+		{in: []string{"write", "chat", "read"}, want: []string{"read", "write", "chat"}},
+		{in: nil, wantErr: true},
+		{in: []string{}, wantErr: true},
+		{in: []string{"read", "admin"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ValidateScopes(tt.in)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("ValidateScopes(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func newAPIKeys(t *testing.T) *APIKeys {
+	t.Helper()
+	stores := storetest.New()
+	if err := stores.Users.Ensure(context.Background(), store.User{ID: "alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	return &APIKeys{Keys: stores.APIKeys, Users: stores.Users}
+}
+
+func TestAPIKeysCreateAndResolve(t *testing.T) {
+	ctx := context.Background()
+	keys := newAPIKeys(t)
+
+	key, raw, err := keys.Create(ctx, "alice", "ci", []string{"chat", "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIKey(raw) || key.Prefix != raw[:len(key.Prefix)] || key.Hash == raw {
+		t.Errorf("key = %+v for raw key %q", key, raw)
+	}
+	if IsAPIKey("eyJhbGciOiJIUzI1NiJ9.e30.sig") {
+		t.Error("a JWT was taken for an API key")
+	}
+
+	id, err := keys.Resolve(ctx, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UID != "alice" || id.Email != "alice@example.com" || id.APIKeyID != key.ID {
+		t.Errorf("identity = %+v", id)
+	}
+	if !id.HasScope(ScopeRead) || !id.HasScope(ScopeChat) || id.HasScope(ScopeWrite) {
+		t.Errorf("scopes = %q, want read and chat", id.Scopes)
+	}
+	saved, _ := keys.Keys.GetByHash(ctx, key.Hash)
+	if saved.LastUsedAt == nil {
+		t.Error("Resolve did not record the key as used")
+	}
+
+	if _, err := keys.Resolve(ctx, raw+"x"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Resolve of an unknown key: %v, want ErrInvalidAPIKey", err)
+	}
+	if err := keys.Keys.Revoke(ctx, key.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Resolve(ctx, raw); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Resolve of a revoked key: %v, want ErrInvalidAPIKey", err)
+	}
+
+	if _, _, err := keys.Create(ctx, "alice", "bad", []string{"admin"}); err == nil {
+		t.Error("created a key with an unknown scope")
+	}
+}
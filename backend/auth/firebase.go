@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 
 	firebase "firebase.google.com/go/v4"
@@ -10,9 +12,13 @@ import (
 	"google.golang.org/api/option"
 )
 
-var AuthClient *auth.Client
+// FirebaseVerifier verifies Firebase ID tokens with the Admin SDK.
+type FirebaseVerifier struct {
+	client *auth.Client
+}
 
-func InitFirebaseAuth() {
+// NewFirebaseVerifier initialises the Firebase Admin SDK.
+func NewFirebaseVerifier(ctx context.Context) (*FirebaseVerifier, error) {
 	var opt option.ClientOption
 
 	// Render provides the content of a "Secret File" via an environment variable.
@@ -21,22 +27,67 @@ func InitFirebaseAuth() {
 
 	if firebaseCredentials != "" {
 		// If it exists (we're on Render), initialize from the JSON content.
-		log.Println("Initializing Firebase Auth from FIREBASE_CREDENTIALS environment variable...")
+		slog.Info("initializing Firebase Auth", "credentials", "FIREBASE_CREDENTIALS")
 		opt = option.WithCredentialsJSON([]byte(firebaseCredentials))
 	} else {
 		// Otherwise (we're running locally), initialize from the file.
-		log.Println("Initializing Firebase Auth from serviceAccountKey.json file...")
+		slog.Info("initializing Firebase Auth", "credentials", "serviceAccountKey.json")
 		opt = option.WithCredentialsFile("serviceAccountKey.json")
 	}
-	app, err := firebase.NewApp(context.Background(), nil, opt)
+	app, err := firebase.NewApp(ctx, nil, opt)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing app: %w", err)
+	}
+
+	client, err := app.Auth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Auth client: %w", err)
+	}
+
+	slog.Info("Firebase Auth client initialized")
+	return &FirebaseVerifier{client: client}, nil
+}
+
+// firebaseCertsURL publishes the keys Firebase ID tokens are signed with.
+const firebaseCertsURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+
+// Ping checks that the token signing keys can be fetched.
+func (v *FirebaseVerifier) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, firebaseCertsURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Fatalf("error initializing app: %v\n", err)
+		return fmt.Errorf("could not reach Firebase signing keys: %w", err)
 	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("firebase signing keys returned %s", resp.Status)
+	}
+	return nil
+}
 
-	AuthClient, err = app.Auth(context.Background())
+func (v *FirebaseVerifier) Verify(ctx context.Context, idToken string) (*Identity, error) {
+	token, err := v.client.VerifyIDToken(ctx, idToken)
 	if err != nil {
-		log.Fatalf("error getting Auth client: %v\n", err)
+		return nil, err
 	}
 
-	log.Println("Firebase Auth client initialized successfully.")
+	id := &Identity{UID: token.UID}
+	if email, ok := token.Claims["email"].(string); ok {
+		if verified, _ := token.Claims["email_verified"].(bool); verified {
+			id.Email = email
+		}
+	} else {
+		// Tokens from providers without an email claim: fall back to the user record.
+		userRecord, err := v.client.GetUser(ctx, token.UID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get user record: %w", err)
+		}
+		if userRecord.EmailVerified {
+			id.Email = userRecord.Email
+		}
+	}
+	return id, nil
 }
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+// Claims are the JWT claims understood by JWTVerifier and produced by Signer.
+type Claims struct {
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWTVerifier verifies self-contained JWTs. It backs both the local
+// development mode (shared secret or RSA key) and the generic OIDC mode.
+type JWTVerifier struct {
+	keyFunc  jwt.Keyfunc
+	methods  []string
+	issuer   string
+	audience string
+	// jwks is the remote key set in OIDC mode; nil for local keys.
+	jwks *keyfunc.JWKS
+}
+
+// Ping reports whether signing keys are available. Local keys always are;
+// in OIDC mode the JWKS must have loaded at least one key.
+func (v *JWTVerifier) Ping(ctx context.Context) error {
+	if v.jwks != nil && v.jwks.Len() == 0 {
+		return errors.New("no signing keys loaded from the JWKS")
+	}
+	return nil
+}
+
+// NewHMACVerifier accepts HS256 tokens signed with secret.
+func NewHMACVerifier(secret []byte, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		keyFunc:  func(*jwt.Token) (any, error) { return secret, nil },
+		methods:  []string{jwt.SigningMethodHS256.Alg()},
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// NewRSAVerifier accepts RS256 tokens signed with the private half of key.
+func NewRSAVerifier(key *rsa.PublicKey, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		keyFunc:  func(*jwt.Token) (any, error) { return key, nil },
+		methods:  []string{jwt.SigningMethodRS256.Alg()},
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (*Identity, error) {
+	var claims Claims
+	parser := jwt.NewParser(jwt.WithValidMethods(v.methods))
+	if _, err := parser.ParseWithClaims(tokenString, &claims, v.keyFunc); err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return nil, errors.New("token issuer mismatch")
+	}
+	if v.audience != "" && !claims.VerifyAudience(v.audience, true) {
+		return nil, errors.New("token audience mismatch")
+	}
+	id := &Identity{UID: claims.Subject}
+	if claims.EmailVerified {
+		id.Email = claims.Email
+	}
+	return id, nil
+}
+
+// Signer mints tokens for the local JWT mode. It exists for development and
+// tests; production tokens come from Firebase or the OIDC provider.
+type Signer struct {
+	method   jwt.SigningMethod
+	key      any
+	issuer   string
+	audience string
+}
+
+// NewSigner builds a Signer from the local JWT settings.
+func NewSigner(cfg config.AuthConfig) (*Signer, error) {
+	s := &Signer{issuer: cfg.JWTIssuer, audience: cfg.JWTAudience}
+	switch {
+	case cfg.JWTSecret != "":
+		s.method, s.key = jwt.SigningMethodHS256, []byte(cfg.JWTSecret)
+	case cfg.JWTPrivateKeyFile != "":
+		pemBytes, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read JWT private key: %w", err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse JWT private key: %w", err)
+		}
+		s.method, s.key = jwt.SigningMethodRS256, key
+	default:
+		return nil, errors.New("AUTH_JWT_SECRET or AUTH_JWT_PRIVATE_KEY_FILE must be set to mint tokens")
+	}
+	return s, nil
+}
+
+// Mint returns a signed token for uid that expires after ttl. A non-empty
+// email is marked verified.
+func (s *Signer) Mint(uid, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Email:         email,
+		EmailVerified: email != "",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uid,
+			Issuer:    s.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if s.audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.audience}
+	}
+	return jwt.NewWithClaims(s.method, claims).SignedString(s.key)
+}
+
+func newLocalVerifier(cfg config.AuthConfig) (*JWTVerifier, error) {
+	switch {
+	case cfg.JWTSecret != "":
+		return NewHMACVerifier([]byte(cfg.JWTSecret), cfg.JWTIssuer, cfg.JWTAudience), nil
+	case cfg.JWTPublicKeyFile != "":
+		pemBytes, err := os.ReadFile(cfg.JWTPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read JWT public key: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse JWT public key: %w", err)
+		}
+		return NewRSAVerifier(key, cfg.JWTIssuer, cfg.JWTAudience), nil
+	case cfg.JWTPrivateKeyFile != "":
+		pemBytes, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read JWT private key: %w", err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse JWT private key: %w", err)
+		}
+		return NewRSAVerifier(&key.PublicKey, cfg.JWTIssuer, cfg.JWTAudience), nil
+	default:
+		return nil, errors.New("AUTH_MODE=jwt requires AUTH_JWT_SECRET, AUTH_JWT_PUBLIC_KEY_FILE or AUTH_JWT_PRIVATE_KEY_FILE")
+	}
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+func TestLocalJWTRoundTrip(t *testing.T) {
+	cfg := config.AuthConfig{Mode: "jwt", JWTSecret: "secret", JWTIssuer: "sia", JWTAudience: "api"}
+	verifier, err := NewVerifier(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signer.Mint("alice", "alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := verifier.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UID != "alice" || id.Email != "alice@example.com" {
+		t.Errorf("identity = %+v", id)
+	}
+}
+
+func hmacToken(t *testing.T, secret string, claims Claims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTVerifierRejects(t *testing.T) {
+	v := NewHMACVerifier([]byte("secret"), "sia", "api")
+	now := time.Now()
+	valid := func() Claims {
+		return Claims{RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "sia",
+			Audience:  jwt.ClaimStrings{"api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		}}
+	}
+	if _, err := v.Verify(context.Background(), hmacToken(t, "secret", valid())); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	tests := map[string]string{
+		"wrong secret": hmacToken(t, "other", valid()),
+		"expired": hmacToken(t, "secret", func() Claims {
+			c := valid()
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+			return c
+		}()),
+		"no expiry": hmacToken(t, "secret", func() Claims {
+			c := valid()
+			c.ExpiresAt = nil
+			return c
+		}()),
+		"no subject": hmacToken(t, "secret", func() Claims {
+			c := valid()
+			c.Subject = ""
+			return c
+		}()),
+		"wrong issuer": hmacToken(t, "secret", func() Claims {
+			c := valid()
+			c.Issuer = "someone-else"
+			return c
+		}()),
+		"wrong audience": hmacToken(t, "secret", func() Claims {
+			c := valid()
+			c.Audience = jwt.ClaimStrings{"other-api"}
+			return c
+		}()),
+		"unsigned": func() string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return s
+		}(),
+		"garbage": "not.a.token",
+	}
+	for name, token := range tests {
+		if _, err := v.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
+
+func TestJWTVerifierIgnoresUnverifiedEmail(t *testing.T) {
+	v := NewHMACVerifier([]byte("secret"), "", "")
+	claims := Claims{Email: "bob@example.com", RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "mallory",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	id, err := v.Verify(context.Background(), hmacToken(t, "secret", claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UID != "mallory" || id.Email != "" {
+		t.Errorf("identity = %+v, want no email", id)
+	}
+
+	claims.EmailVerified = true
+	if id, _ := v.Verify(context.Background(), hmacToken(t, "secret", claims)); id == nil || id.Email != "bob@example.com" {
+		t.Errorf("identity with verified email = %+v", id)
+	}
+}
+
+// writeRSAKey writes a new RSA key pair as PEM files and returns their paths.
+func writeRSAKey(t *testing.T) (*rsa.PrivateKey, string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	private := filepath.Join(dir, "private.pem")
+	public := filepath.Join(dir, "public.pem")
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(private, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600)
+	os.WriteFile(public, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0o644)
+	return key, private, public
+}
+
+func TestLocalJWTWithRSAKeys(t *testing.T) {
+	_, private, public := writeRSAKey(t)
+	signer, err := NewSigner(config.AuthConfig{JWTPrivateKeyFile: private, JWTIssuer: "sia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signer.Mint("alice", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cfg := range map[string]config.AuthConfig{
+		"public key":  {Mode: "jwt", JWTPublicKeyFile: public, JWTIssuer: "sia"},
+		"private key": {Mode: "jwt", JWTPrivateKeyFile: private, JWTIssuer: "sia"},
+	} {
+		v, err := NewVerifier(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if id, err := v.Verify(context.Background(), token); err != nil || id.UID != "alice" {
+			t.Errorf("%s: Verify = %+v, %v", name, id, err)
+		}
+	}
+
+	// An HS256 token signed with the public key must not pass as RS256.
+	pemBytes, _ := os.ReadFile(public)
+	forged := hmacToken(t, string(pemBytes), Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject: "mallory", Issuer: "sia", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}})
+	v, _ := NewVerifier(context.Background(), config.AuthConfig{Mode: "jwt", JWTPublicKeyFile: public, JWTIssuer: "sia"})
+	if _, err := v.Verify(context.Background(), forged); err == nil {
+		t.Error("HS256 token signed with the public key was accepted")
+	}
+}
+
+func TestNewVerifierConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]config.AuthConfig{
+		"unknown mode":        {Mode: "kerberos"},
+		"jwt without key":     {Mode: "jwt"},
+		"oidc without issuer": {Mode: "oidc"},
+		"missing key file":    {Mode: "jwt", JWTPublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+	} {
+		if _, err := NewVerifier(context.Background(), cfg); err == nil {
+			t.Errorf("%s: NewVerifier succeeded", name)
+		}
+	}
+	if _, err := NewSigner(config.AuthConfig{}); err == nil {
+		t.Error("NewSigner without a key succeeded")
+	}
+}
@@ -4,36 +4,124 @@ import (
 	"context"
 	"net/http"
 	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
 )
 
 // A custom type for our context key to avoid collisions
 type contextKey string
 
-const UserIDKey contextKey = "userID"
+const (
+	UserIDKey   contextKey = "userID"
+	EmailKey    contextKey = "email"
+	IdentityKey contextKey = "identity"
+)
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
+// LoginObserver is told about every presented credential, whether it was
+// accepted (err is nil) or rejected.
+type LoginObserver interface {
+	ObserveLogin(r *http.Request, identity *Identity, err error)
+}
 
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || strings.ToLower(tokenParts[0]) != "bearer" {
-			http.Error(w, "Authorization header format must be Bearer {token}", http.StatusUnauthorized)
-			return
-		}
+// Middleware returns a middleware that only lets authenticated requests
+// through, adding the caller's identity to the request context. Callers
+// present either a bearer ID token or a personal API key, the latter as
+// "Authorization: Bearer sia_..." or in the X-API-Key header. keys may be nil
+// to disable API keys, and observer may be nil.
+func Middleware(verifier TokenVerifier, keys APIKeyResolver, observer LoginObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get("X-API-Key")
+			if token == "" {
+				authHeader := r.Header.Get("Authorization")
+				if authHeader == "" {
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthenticated, "Authorization header required")
+					return
+				}
+
+				tokenParts := strings.Split(authHeader, " ")
+				if len(tokenParts) != 2 || strings.ToLower(tokenParts[0]) != "bearer" {
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthenticated, "Authorization header format must be Bearer {token}")
+					return
+				}
+				token = tokenParts[1]
+			}
+
+			var identity *Identity
+			var err error
+			if IsAPIKey(token) {
+				if keys == nil {
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeInvalidToken, "API keys are not enabled")
+					return
+				}
+				identity, err = keys.Resolve(r.Context(), token)
+			} else {
+				identity, err = verifier.Verify(r.Context(), token)
+			}
+			if observer != nil {
+				observer.ObserveLogin(r, identity, err)
+			}
+			if err != nil {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token")
+				return
+			}
 
-		idToken := tokenParts[1]
-		token, err := AuthClient.VerifyIDToken(context.Background(), idToken)
-		if err != nil {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			// Add the caller's identity to the request context
+			ctx := context.WithValue(r.Context(), UserIDKey, identity.UID)
+			ctx = context.WithValue(ctx, EmailKey, identity.Email)
+			ctx = context.WithValue(ctx, IdentityKey, identity)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// IdentityFrom returns the identity stored by Middleware, or nil.
+func IdentityFrom(ctx context.Context) *Identity {
+	id, _ := ctx.Value(IdentityKey).(*Identity)
+	return id
+}
+
+// RequireScope rejects callers whose credentials do not grant scope. It must
+// run after Middleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := IdentityFrom(r.Context()); id == nil || !id.HasScope(scope) {
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeInsufficientScope, "This API key does not have the '"+scope+"' scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireInteractive rejects callers authenticated with an API key, for
+// routes such as key management that must only be reachable from a login.
+func RequireInteractive(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := IdentityFrom(r.Context()); id == nil || id.APIKeyID != "" {
+			apierror.Write(w, r, http.StatusForbidden, apierror.CodeInteractiveRequired, "This action requires signing in; API keys cannot be used")
 			return
 		}
-
-		// Add the UID to the request context
-		ctx := context.WithValue(r.Context(), UserIDKey, token.UID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireAdmin only lets the listed users through. It must run after
+// Middleware.
+func RequireAdmin(adminUIDs []string) func(http.Handler) http.Handler {
+	admins := make(map[string]bool, len(adminUIDs))
+	for _, uid := range adminUIDs {
+		admins[uid] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(UserIDKey).(string)
+			if !admins[userID] {
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeAdminRequired, "Administrator access required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// uidVerifier accepts any token, using it as the user ID.
+type uidVerifier struct{}
+
+func (uidVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	if token == "bad" {
+		return nil, errors.New("bad token")
+	}
+	return &Identity{UID: token, Email: token + "@example.com"}, nil
+}
+
+// whoami answers with the user ID that Middleware put in the context.
+var whoami = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Context().Value(UserIDKey).(string)))
+})
+
+func serve(t *testing.T, h http.Handler, header, value string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := newAPIKeys(t)
+	_, raw, err := keys.Create(context.Background(), "alice", "ci", []string{ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(uidVerifier{}, keys, nil)(whoami)
+
+	tests := []struct {
+		name          string
+		header, value string
+		wantStatus    int
+		wantUser      string
+	}{
+		{"ID token", "Authorization", "Bearer bob", http.StatusOK, "bob"},
+		{"lower-case scheme", "Authorization", "bearer bob", http.StatusOK, "bob"},
+		{"API key as bearer", "Authorization", "Bearer " + raw, http.StatusOK, "alice"},
+		{"API key header", "X-API-Key", raw, http.StatusOK, "alice"},
+		{"no credentials", "", "", http.StatusUnauthorized, ""},
+		{"basic auth", "Authorization", "Basic Ym9iOnB3", http.StatusUnauthorized, ""},
+		{"missing token", "Authorization", "Bearer", http.StatusUnauthorized, ""},
+		{"rejected token", "Authorization", "Bearer bad", http.StatusUnauthorized, ""},
+		{"unknown API key", "X-API-Key", "sia_unknown", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, tt.header, tt.value)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantUser != "" && w.Body.String() != tt.wantUser {
+				t.Errorf("user = %q, want %q", w.Body.String(), tt.wantUser)
+			}
+		})
+	}
+
+	t.Run("API keys disabled", func(t *testing.T) {
+		h := Middleware(uidVerifier{}, nil, nil)(whoami)
+		if w := serve(t, h, "X-API-Key", raw); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+}
+
+// loginLog remembers what Middleware reported to its LoginObserver.
+type loginLog []string
+
+func (l *loginLog) ObserveLogin(r *http.Request, identity *Identity, err error) {
+	if err != nil {
+		*l = append(*l, "failed: "+err.Error())
+		return
+	}
+	*l = append(*l, identity.UID)
+}
+
+func TestLoginObserver(t *testing.T) {
+	var observed loginLog
+	h := Middleware(uidVerifier{}, nil, &observed)(whoami)
+	serve(t, h, "Authorization", "Bearer bob")
+	serve(t, h, "Authorization", "Bearer bad")
+	serve(t, h, "", "")
+
+	// Requests without any credential are not login attempts.
+	want := loginLog{"bob", "failed: bad token"}
+	if len(observed) != len(want) || observed[0] != want[0] || observed[1] != want[1] {
+		t.Errorf("observed %q, want %q", observed, want)
+	}
+}
+
+func TestRequireScopeAndInteractive(t *testing.T) {
+	keys := newAPIKeys(t)
+	_, raw, err := keys.Create(context.Background(), "alice", "ci", []string{ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authed := func(h http.Handler) http.Handler { return Middleware(uidVerifier{}, keys, nil)(h) }
+
+	tests := []struct {
+		name       string
+		h          http.Handler
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"granted scope", authed(RequireScope(ScopeRead)(whoami)), "X-API-Key", raw, http.StatusOK},
+		{"missing scope", authed(RequireScope(ScopeWrite)(whoami)), "X-API-Key", raw, http.StatusForbidden},
+		{"ID token has every scope", authed(RequireScope(ScopeWrite)(whoami)), "Authorization", "Bearer bob", http.StatusOK},
+		{"interactive with an ID token", authed(RequireInteractive(whoami)), "Authorization", "Bearer bob", http.StatusOK},
+		{"interactive with an API key", authed(RequireInteractive(whoami)), "X-API-Key", raw, http.StatusForbidden},
+		{"scope without Middleware", RequireScope(ScopeRead)(whoami), "Authorization", "Bearer bob", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(t, tt.h, tt.header, tt.value); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	h := Middleware(uidVerifier{}, nil, nil)(RequireAdmin([]string{"root", "ops"})(whoami))
+	for user, want := range map[string]int{"root": http.StatusOK, "ops": http.StatusOK, "bob": http.StatusForbidden} {
+		if w := serve(t, h, "Authorization", "Bearer "+user); w.Code != want {
+			t.Errorf("%s: status %d, want %d", user, w.Code, want)
+		}
+	}
+	if w := serve(t, RequireAdmin(nil)(whoami), "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("no admins configured: status %d, want 403", w.Code)
+	}
+}
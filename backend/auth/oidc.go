@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// NewOIDCVerifier verifies tokens from a generic OpenID Connect provider
+// against its published JWKS. When jwksURL is empty it is discovered from the
+// issuer's /.well-known/openid-configuration document.
+func NewOIDCVerifier(ctx context.Context, issuer, jwksURL, audience string) (*JWTVerifier, error) {
+	if issuer == "" {
+		return nil, errors.New("AUTH_MODE=oidc requires OIDC_ISSUER_URL")
+	}
+	if jwksURL == "" {
+		var err error
+		if jwksURL, err = discoverJWKSURL(ctx, issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	jwks, err := keyfunc.Get(jwksURL, keyfunc.Options{
+		Ctx:               ctx,
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  5 * time.Minute,
+		RefreshUnknownKID: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not load JWKS from %s: %w", jwksURL, err)
+	}
+
+	return &JWTVerifier{
+		keyFunc: jwks.Keyfunc,
+		methods: []string{
+			jwt.SigningMethodRS256.Alg(), jwt.SigningMethodRS384.Alg(), jwt.SigningMethodRS512.Alg(),
+			jwt.SigningMethodES256.Alg(), jwt.SigningMethodES384.Alg(), jwt.SigningMethodES512.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+		},
+		issuer:   issuer,
+		audience: audience,
+		jwks:     jwks,
+	}, nil
+}
+
+func discoverJWKSURL(ctx context.Context, issuer string) (string, error) {
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch OIDC discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC discovery returned status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("could not decode OIDC discovery document: %w", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("OIDC discovery document has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
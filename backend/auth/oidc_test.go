@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// oidcProvider is a minimal OpenID provider publishing one RSA key.
+type oidcProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+}
+
+func newOIDCProvider(t *testing.T) *oidcProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &oidcProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.URL, "jwks_uri": p.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *oidcProvider) token(t *testing.T, claims Claims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = "key-1"
+	s, err := tok.SignedString(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	p := newOIDCProvider(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v, err := NewOIDCVerifier(ctx, p.URL, "", "sia")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(issuer, audience string) Claims {
+		return Claims{Email: "alice@example.com", EmailVerified: true, RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}}
+	}
+	id, err := v.Verify(ctx, p.token(t, claims(p.URL, "sia")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.UID != "alice" || id.Email != "alice@example.com" {
+		t.Errorf("identity = %+v", id)
+	}
+
+	if _, err := v.Verify(ctx, p.token(t, claims("https://evil.example.com", "sia"))); err == nil {
+		t.Error("token from another issuer accepted")
+	}
+	if _, err := v.Verify(ctx, p.token(t, claims(p.URL, "other"))); err == nil {
+		t.Error("token for another audience accepted")
+	}
+	other := newOIDCProvider(t)
+	if _, err := v.Verify(ctx, other.token(t, claims(p.URL, "sia"))); err == nil {
+		t.Error("token signed by an unpublished key accepted")
+	}
+}
+
+func TestOIDCDiscoveryErrors(t *testing.T) {
+	noKeys := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issuer": "x"}`))
+	}))
+	defer noKeys.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	for name, issuer := range map[string]string{"no jwks_uri": noKeys.URL, "no discovery document": missing.URL} {
+		if _, err := NewOIDCVerifier(context.Background(), issuer, "", ""); err == nil {
+			t.Errorf("%s: NewOIDCVerifier succeeded", name)
+		}
+	}
+}
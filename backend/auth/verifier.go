@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+// Identity is the authenticated caller extracted from a verified token.
+type Identity struct {
+	UID string
+	// Email is set only when the provider has verified it, because email
+	// shares and organization invites are granted by email address.
+	Email string
+	// Scopes restricts what the caller may do. Nil means unrestricted, which
+	// is the case for interactive ID tokens.
+	Scopes []string
+	// APIKeyID is set when the caller authenticated with a personal API key.
+	APIKeyID string
+}
+
+// HasScope reports whether the identity is allowed to act within scope.
+func (id *Identity) HasScope(scope string) bool {
+	return id.Scopes == nil || slices.Contains(id.Scopes, scope)
+}
+
+// TokenVerifier validates a bearer token and returns who it belongs to.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*Identity, error)
+}
+
+// NewVerifier builds the verifier selected by cfg.Mode.
+func NewVerifier(ctx context.Context, cfg config.AuthConfig) (TokenVerifier, error) {
+	switch cfg.Mode {
+	case "", "firebase":
+		return NewFirebaseVerifier(ctx)
+	case "jwt":
+		slog.Info("using local JWT authentication", "mode", "jwt")
+		return newLocalVerifier(cfg)
+	case "oidc":
+		return NewOIDCVerifier(ctx, cfg.OIDCIssuer, cfg.OIDCJWKSURL, cfg.OIDCAudience)
+	default:
+		return nil, fmt.Errorf("unknown AUTH_MODE %q (want firebase, jwt or oidc)", cfg.Mode)
+	}
+}
@@ -0,0 +1,82 @@
+// Package background tracks work that outlives the request that started it,
+// so it can be drained before the process exits.
+package background
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Group runs background tasks and waits for them on Shutdown.
+type Group struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	closed  bool
+	pending atomic.Int64
+}
+
+// NewGroup returns an empty Group that accepts tasks.
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in the background. fn's context is cancelled only if Shutdown
+// gives up waiting. Once Shutdown has started, fn runs synchronously instead
+// so late work is not lost.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		fn(context.Background())
+		return
+	}
+	g.wg.Add(1)
+	g.pending.Add(1)
+	g.mu.Unlock()
+
+	go func() {
+		defer g.wg.Done()
+		defer g.pending.Add(-1)
+		defer func() {
+			if p := recover(); p != nil {
+				slog.Error("background task panicked", "task", name, "panic", p)
+			}
+		}()
+		fn(g.ctx)
+	}()
+}
+
+// Pending returns the number of tasks still running.
+func (g *Group) Pending() int64 {
+	return g.pending.Load()
+}
+
+// Shutdown stops accepting new background tasks and waits for running ones
+// to finish. If ctx expires first, the tasks' context is cancelled and
+// ctx.Err() is returned.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		g.cancel()
+		return nil
+	case <-ctx.Done():
+		slog.Error("abandoning background tasks", "pending", g.Pending())
+		g.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForTasks(t *testing.T) {
+	g := NewGroup()
+	release := make(chan struct{})
+	var done atomic.Int32
+	for range 3 {
+		g.Go("slow", func(ctx context.Context) {
+			<-release
+			done.Add(1)
+		})
+	}
+	if got := g.Pending(); got != 3 {
+		t.Errorf("Pending() = %d, want 3", got)
+	}
+
+	shutdown := make(chan error)
+	go func() { shutdown <- g.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v with tasks still running", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if done.Load() != 3 || g.Pending() != 0 {
+		t.Errorf("done = %d, pending = %d after Shutdown", done.Load(), g.Pending())
+	}
+}
+
+func TestShutdownGivesUp(t *testing.T) {
+	g := NewGroup()
+	cancelled := make(chan struct{})
+	g.Go("stuck", func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want the deadline error", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("the abandoned task's context was not cancelled")
+	}
+}
+
+func TestLateTasksRunSynchronously(t *testing.T) {
+	g := NewGroup()
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ran := false
+	g.Go("late", func(ctx context.Context) {
+		if ctx.Err() != nil {
+			t.Error("late task got a cancelled context")
+		}
+		ran = true
+	})
+	if !ran {
+		t.Error("task started after Shutdown did not run before Go returned")
+	}
+}
+
+func TestPanicIsRecovered(t *testing.T) {
+	g := NewGroup()
+	g.Go("panics", func(ctx context.Context) { panic("boom") })
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if g.Pending() != 0 {
+		t.Errorf("Pending() = %d after a panicking task", g.Pending())
+	}
+}
@@ -1,43 +1,260 @@
 package config
 
 import (
-	"log"
-	"os"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Config is the effective configuration: defaults overlaid by the config
+// file, then environment variables, then command-line flags. See settings.go
+// for the name of every setting in each layer.
 type Config struct {
 	SupabaseURL      string
 	SupabaseSvcKey   string
 	GeminiAPIKey     string
 	UnidocLicenseKey string
+	DatabaseURL      string
+	Server           ServerConfig
+	AI               AIConfig
+	Storage          StorageConfig
+	Chunking         ChunkingConfig
+	Auth             AuthConfig
+	Log              LogConfig
+	Limits           LimitsConfig
+	Metrics          MetricsConfig
+	Tracing          TracingConfig
+	// AdminUIDs may use the /api/admin routes.
+	AdminUIDs []string
+	// AuditFallbackFile holds audit events the database could not store
+	// until they are replayed at the next start.
+	AuditFallbackFile string
+
+	// sources records which layer set each setting, by key.
+	sources map[string]string
 }
 
-var AppConfig *Config
+// ServerConfig holds the HTTP server settings.
+type ServerConfig struct {
+	Port string
+	// ReadTimeout and WriteTimeout apply to ordinary routes. Upload and chat
+	// get their own, longer deadlines; lists get a shorter one.
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	UploadTimeout time.Duration
+	ChatTimeout   time.Duration
+	ListTimeout   time.Duration
+	// ShutdownTimeout bounds how long a SIGTERM waits for in-flight requests
+	// and background writes. Cloud Run kills the instance 10s after SIGTERM.
+	ShutdownTimeout time.Duration
+	// DrainDelay is how long a SIGTERM keeps serving with the readiness probe
+	// failing before the listener closes, so load balancers that poll the
+	// probe stop sending traffic first. Cloud Run stops routing on SIGTERM
+	// and needs none; the delay comes on top of ShutdownTimeout.
+	DrainDelay time.Duration
+	// TrustedProxies is how many proxies in front of the server append the
+	// client address to X-Forwarded-For. Cloud Run has one, its load
+	// balancer; 0 ignores the header.
+	TrustedProxies int
+	// CORSOrigins are the browser origins allowed to call the API.
+	CORSOrigins []string
+	// MaxUploadBytes caps the upload request body; MaxBodyBytes caps the
+	// body of every other route. Larger bodies are answered with 413.
+	MaxUploadBytes int64
+	MaxBodyBytes   int64
+}
 
-func LoadConfig() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("Warning: .env file not found")
-	}
+// AIConfig selects the language model provider.
+type AIConfig struct {
+	// Provider is "gemini", the only provider so far.
+	Provider string
+	Model    string
+	// EmbeddingModel embeds chunks at ingestion, such as
+	// "text-embedding-004". Empty, the default, skips embeddings.
+	EmbeddingModel string
+	Timeout        time.Duration
+	// SummaryChunks is how many leading chunks of a new document are sent
+	// to the model to summarize it; 0 turns summaries off.
+	SummaryChunks int
+	// ContextChunks caps how many chunks chat sends with a question, picking
+	// the most relevant ones; 0 sends the whole document.
+	ContextChunks int
+}
+
+// StorageConfig selects where original uploads are kept.
+type StorageConfig struct {
+	// Provider is "supabase" (default) or "local", which keeps files under
+	// Dir and needs no Supabase project.
+	Provider string
+	Bucket   string
+	Dir      string
+}
+
+// ChunkingConfig controls how extracted text is split before it is stored.
+type ChunkingConfig struct {
+	// Size is the target chunk length in characters.
+	Size int
+	// Overlap is how many characters consecutive chunks share.
+	Overlap int
+}
+
+// AuthConfig selects and configures the bearer token verifier.
+type AuthConfig struct {
+	// Mode is one of "firebase" (default), "jwt" or "oidc".
+	Mode string
+
+	// Local JWT mode: HS256 with JWTSecret, or RS256 with an RSA key pair.
+	// The private key is only needed to mint tokens with the token command.
+	JWTSecret         string
+	JWTPublicKeyFile  string
+	JWTPrivateKeyFile string
+	JWTIssuer         string
+	JWTAudience       string
+
+	// Generic OIDC mode. The JWKS URL is discovered from the issuer when unset.
+	OIDCIssuer   string
+	OIDCJWKSURL  string
+	OIDCAudience string
+}
+
+// LogConfig controls structured logging.
+type LogConfig struct {
+	// Level is "debug", "info" (default), "warn" or "error". Document
+	// contents and user queries are only logged at debug.
+	Level string
+	// Format is "text" (default) or "json".
+	Format string
+}
+
+// MetricsConfig controls where Prometheus metrics are served. They are never
+// served on the public port without a token.
+type MetricsConfig struct {
+	// Addr is a separate listen address for /metrics, "localhost:9090" by
+	// default. Empty disables the separate listener.
+	Addr string
+	// Token, when set, is required as a bearer token and also enables
+	// /metrics on the main port.
+	Token string
+}
+
+// TracingConfig controls OpenTelemetry trace export.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318.
+	// Empty disables export.
+	Endpoint    string
+	ServiceName string
+}
+
+// LimitsConfig holds per-user request rates and usage quotas. A zero value
+// disables that limit.
+type LimitsConfig struct {
+	// Token bucket rates per user and route. Chat and upload are the
+	// expensive routes; every other route uses RequestsPerMinute.
+	RequestsPerMinute int
+	ChatPerMinute     int
+	UploadsPerMinute  int
+
+	// Quotas reset at midnight UTC and on the first of the month (UTC).
+	LLMTokensDaily   int64
+	LLMTokensMonthly int64
+	UploadsDaily     int64
+	UploadsMonthly   int64
+}
+
+// DefaultDatabaseURL is the local SQLite file used when DATABASE_URL is unset.
+const DefaultDatabaseURL = "sqlite://sia.db"
 
-	AppConfig = &Config{
-		SupabaseURL:      getEnv("SUPABASE_URL", ""),
-		SupabaseSvcKey:   getEnv("SUPABASE_SERVICE_KEY", ""),
-		GeminiAPIKey:     getEnv("GEMINI_API_KEY", ""),
-		UnidocLicenseKey: getEnv("UNIDOC_LICENSE_KEY", ""),
+// Default returns the built-in defaults, before any file, environment
+// variable or flag is applied.
+func Default() *Config {
+	return &Config{
+		DatabaseURL: DefaultDatabaseURL,
+		Server: ServerConfig{
+			Port:            "8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			UploadTimeout:   2 * time.Minute,
+			ChatTimeout:     90 * time.Second,
+			ListTimeout:     5 * time.Second,
+			ShutdownTimeout: 9 * time.Second,
+			TrustedProxies:  1,
+			CORSOrigins:     []string{"http://localhost:3000", "https://strategic-insight-analyst-ndwn.vercel.app"},
+			MaxUploadBytes:  10 << 20,
+			MaxBodyBytes:    1 << 20,
+		},
+		AI:       AIConfig{Provider: "gemini", Model: "gemini-1.5-flash", Timeout: 60 * time.Second, SummaryChunks: 4},
+		Storage:  StorageConfig{Provider: "supabase", Bucket: "documents", Dir: "uploads"},
+		Chunking: ChunkingConfig{Size: 1500, Overlap: 200},
+		Auth:     AuthConfig{Mode: "firebase", JWTIssuer: "strategic-insight-analyst"},
+		Log:      LogConfig{Level: "info", Format: "text"},
+		Limits: LimitsConfig{
+			RequestsPerMinute: 120,
+			ChatPerMinute:     10,
+			UploadsPerMinute:  5,
+			LLMTokensDaily:    200000,
+			LLMTokensMonthly:  3000000,
+			UploadsDaily:      50,
+			UploadsMonthly:    500,
+		},
+		Metrics:           MetricsConfig{Addr: "localhost:9090"},
+		Tracing:           TracingConfig{ServiceName: "strategic-insight-analyst"},
+		AuditFallbackFile: "audit-fallback.jsonl",
 	}
+}
 
-	if AppConfig.SupabaseURL == "" || AppConfig.SupabaseSvcKey == "" || AppConfig.GeminiAPIKey == "" || AppConfig.UnidocLicenseKey == "" {
-		log.Fatal("SUPABASE_URL and SUPABASE_SERVICE_KEY and GEMINI_API_KEY and UNIDOC_LICENSE_KEY must be set")
+// Load resolves the configuration from the config file, the environment (and
+// .env, if present) and the command-line flags in args, then validates it.
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		slog.Warn(".env file not found")
+	}
+	cfg, err := Resolve(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
+
+// DatabaseURL returns the configured database URL without requiring the rest
+// of the configuration, for commands that only need the database.
+func DatabaseURL() string {
+	return resolveQuietly().DatabaseURL
+}
+
+// LoadAuth returns only the authentication settings, for commands such as
+// token minting that must work without the rest of the configuration.
+func LoadAuth() AuthConfig {
+	return resolveQuietly().Auth
+}
 
+// LoadLog returns only the logging settings, so logging can be set up before
+// the rest of the configuration is validated.
+func LoadLog() LogConfig {
+	return resolveQuietly().Log
+}
+
+// resolveQuietly resolves the file and environment layers, ignoring errors;
+// Load reports them when the server starts.
+func resolveQuietly() *Config {
+	cfg, err := Resolve(nil)
+	if err != nil {
+		return Default()
+	}
+	return cfg
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
-	return fallback
+	return out
 }
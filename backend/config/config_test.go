@@ -0,0 +1,256 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate runs the test in an empty directory, so no config.yaml or .env
+// from the source tree is picked up, and writes the optional config file.
+func isolate(t *testing.T, configYAML string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if configYAML != "" {
+		if err := os.WriteFile(filepath.Join(dir, DefaultConfigFile), []byte(configYAML), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// valid returns a configuration that passes Validate.
+func valid() *Config {
+	cfg := Default()
+	cfg.SupabaseURL = "https://project.supabase.co"
+	cfg.SupabaseSvcKey = "service-key"
+	cfg.GeminiAPIKey = "gemini-key"
+	return cfg
+}
+
+func TestResolveLayers(t *testing.T) {
+	isolate(t, `
+server:
+  port: 9000
+  cors_origins: [https://a.example, https://b.example]
+log:
+  level: warn
+processing:
+  chunk_size: 1000
+limits:
+  chat_per_minute: 3
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("SHUTDOWN_TIMEOUT", "30s")
+	t.Setenv("SERVER_DRAIN_DELAY", "5s")
+
+	cfg, err := Resolve([]string{"-server.port", "9200", "-limits.uploads_daily=0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "9200" {
+		t.Errorf("port = %s, want the flag's 9200", cfg.Server.Port)
+	}
+	if cfg.Log != (LogConfig{Level: "warn", Format: "json"}) {
+		t.Errorf("log = %+v, want the file's level and the env's format", cfg.Log)
+	}
+	if !slices.Equal(cfg.Server.CORSOrigins, []string{"https://a.example", "https://b.example"}) {
+		t.Errorf("cors origins = %q", cfg.Server.CORSOrigins)
+	}
+	if cfg.Chunking != (ChunkingConfig{Size: 1000, Overlap: 200}) || cfg.Server.ShutdownTimeout != 30*time.Second || cfg.Server.DrainDelay != 5*time.Second {
+		t.Errorf("chunking = %+v, shutdown timeout = %v, drain delay = %v", cfg.Chunking, cfg.Server.ShutdownTimeout, cfg.Server.DrainDelay)
+	}
+	if cfg.Limits.ChatPerMinute != 3 || cfg.Limits.UploadsDaily != 0 || cfg.Limits.UploadsMonthly != 500 {
+		t.Errorf("limits = %+v", cfg.Limits)
+	}
+
+	for key, want := range map[string]string{
+		"server.port":             sourceFlag,
+		"log.level":               sourceFile,
+		"log.format":              sourceEnv,
+		"server.shutdown_timeout": sourceEnv,
+		"limits.uploads_daily":    sourceFlag,
+		"database.url":            sourceDefault,
+	} {
+		if got := cfg.sources[key]; got != want {
+			t.Errorf("%s came from %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestResolveConfigFileSelection(t *testing.T) {
+	isolate(t, "server:\n  port: 9000\n")
+	other := filepath.Join(t.TempDir(), "other.yaml")
+	os.WriteFile(other, []byte("server:\n  port: 9300\n"), 0o600)
+
+	t.Setenv("CONFIG_FILE", other)
+	if cfg, err := Resolve(nil); err != nil || cfg.Server.Port != "9300" {
+		t.Errorf("CONFIG_FILE: port %v, %v; want 9300", cfg, err)
+	}
+	// A file that was asked for must exist.
+	if _, err := Resolve([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("missing -config file accepted")
+	}
+	t.Setenv("CONFIG_FILE", "")
+	if cfg, err := Resolve(nil); err != nil || cfg.Server.Port != "9000" {
+		t.Errorf("default file: port %v, %v; want 9000", cfg, err)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		want []string
+	}{
+		{name: "unknown file key", yaml: "server:\n  prot: 1\n", want: []string{`unknown setting "server.prot"`}},
+		{name: "bad YAML", yaml: "server: [", want: []string{"parse config file"}},
+		{name: "bad integer", env: map[string]string{"RATE_LIMIT_PER_MINUTE": "lots"}, want: []string{"limits.requests_per_minute (RATE_LIMIT_PER_MINUTE)", `invalid integer "lots"`}},
+		{name: "bad duration", args: []string{"-ai.timeout", "soon"}, want: []string{"ai.timeout", "invalid duration"}},
+		{name: "unknown flag", args: []string{"-nope"}, want: []string{"nope"}},
+		{name: "extra argument", args: []string{"serve"}, want: []string{`unexpected argument "serve"`}},
+		// Every problem is reported at once.
+		{
+			name: "several",
+			yaml: "chunking:\n  size: 1\n",
+			env:  map[string]string{"CHUNK_SIZE": "big"},
+			want: []string{`unknown setting "chunking.size"`, "processing.chunk_size (CHUNK_SIZE)"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t, tt.yaml)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Resolve(tt.args)
+			if err == nil {
+				t.Fatal("Resolve succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		change func(*Config)
+		want   string // empty if the change is valid
+	}{
+		{"port", func(c *Config) { c.Server.Port = "http" }, "server.port (PORT)"},
+		{"port range", func(c *Config) { c.Server.Port = "70000" }, "server.port"},
+		{"zero read timeout", func(c *Config) { c.Server.ReadTimeout = 0 }, "server.read_timeout"},
+		{"negative shutdown", func(c *Config) { c.Server.ShutdownTimeout = -time.Second }, "server.shutdown_timeout"},
+		{"negative drain delay", func(c *Config) { c.Server.DrainDelay = -time.Second }, "server.drain_delay"},
+		{"zero chat timeout", func(c *Config) { c.Server.ChatTimeout = 0 }, "chat_timeout"},
+		{"zero list timeout", func(c *Config) { c.Server.ListTimeout = 0 }, "list_timeout"},
+		{"negative trusted proxies", func(c *Config) { c.Server.TrustedProxies = -1 }, "server.trusted_proxies"},
+		{"upload size", func(c *Config) { c.Server.MaxUploadBytes = 0 }, "server.max_upload_bytes"},
+		{"body size", func(c *Config) { c.Server.MaxBodyBytes = -1 }, "server.max_body_bytes"},
+		{"CORS origin", func(c *Config) { c.Server.CORSOrigins = []string{"example.com"} }, "server.cors_origins"},
+		{"CORS wildcard", func(c *Config) { c.Server.CORSOrigins = []string{"*"} }, ""},
+		{"database", func(c *Config) { c.DatabaseURL = "" }, "database.url"},
+		{"supabase key", func(c *Config) { c.SupabaseSvcKey = "" }, "storage.supabase_service_key"},
+		// Local storage needs no Supabase project.
+		{"local storage", func(c *Config) { c.Storage.Provider, c.SupabaseURL, c.SupabaseSvcKey = "local", "", "" }, ""},
+		{"local storage dir", func(c *Config) { c.Storage.Provider, c.Storage.Dir = "local", "" }, "storage.dir"},
+		{"storage provider", func(c *Config) { c.Storage.Provider = "s3" }, "storage.provider"},
+		{"gemini key", func(c *Config) { c.GeminiAPIKey = "" }, "ai.gemini_api_key"},
+		{"AI provider", func(c *Config) { c.AI.Provider = "openai" }, "ai.provider"},
+		{"chunk overlap", func(c *Config) { c.Chunking.Overlap = c.Chunking.Size }, "processing.chunk_overlap"},
+		{"chunk size", func(c *Config) { c.Chunking.Size = 0 }, "processing.chunk_size"},
+		{"negative summary chunks", func(c *Config) { c.AI.SummaryChunks = -1 }, "ai.summary_chunks"},
+		{"summaries off", func(c *Config) { c.AI.SummaryChunks = 0 }, ""},
+		{"negative context chunks", func(c *Config) { c.AI.ContextChunks = -1 }, "ai.context_chunks"},
+		{"whole document sent", func(c *Config) { c.AI.ContextChunks = 0 }, ""},
+		{"jwt without key", func(c *Config) { c.Auth.Mode = "jwt" }, "auth.jwt_secret"},
+		{"jwt with secret", func(c *Config) { c.Auth.Mode, c.Auth.JWTSecret = "jwt", "s3cret" }, ""},
+		{"oidc without issuer", func(c *Config) { c.Auth.Mode = "oidc" }, "auth.oidc_issuer"},
+		{"auth mode", func(c *Config) { c.Auth.Mode = "saml" }, "auth.mode"},
+		{"log level", func(c *Config) { c.Log.Level = "loud" }, "log.level"},
+		{"log format", func(c *Config) { c.Log.Format = "xml" }, "log.format"},
+		{"negative limit", func(c *Config) { c.Limits.UploadsDaily = -1 }, "limits"},
+		{"zero limit", func(c *Config) { c.Limits.ChatPerMinute = 0 }, ""},
+		{"metrics address", func(c *Config) { c.Metrics.Addr = "9090" }, "metrics.addr"},
+		{"metrics disabled", func(c *Config) { c.Metrics.Addr = "" }, ""},
+		{"tracing endpoint", func(c *Config) { c.Tracing.Endpoint = "localhost:4318" }, "tracing.endpoint"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("rejected: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("accepted, want an error about %s", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("error %q does not mention %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadValidates(t *testing.T) {
+	isolate(t, "")
+	t.Setenv("STORAGE_PROVIDER", "local")
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "ai.gemini_api_key") {
+		t.Errorf("Load without a Gemini key = %v", err)
+	}
+	t.Setenv("GEMINI_API_KEY", "key")
+	if _, err := Load(nil); err != nil {
+		t.Errorf("Load = %v", err)
+	}
+}
+
+func TestWriteMasksSecrets(t *testing.T) {
+	isolate(t, "")
+	t.Setenv("GEMINI_API_KEY", "gemini-secret")
+	t.Setenv("DATABASE_URL", "postgres://sia:hunter2@db:5432/sia")
+	cfg, err := Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := cfg.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	for _, secret := range []string{"gemini-secret", "hunter2"} {
+		if strings.Contains(text, secret) {
+			t.Errorf("output contains %q:\n%s", secret, text)
+		}
+	}
+	for _, want := range []string{"ai.gemini_api_key", "********", "postgres://sia:xxxxx@db:5432/sia", "env", "server.port"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output lacks %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	for in, want := range map[string][]string{
+		"":            nil,
+		"uid-1":       {"uid-1"},
+		" a, b ,,c ,": {"a", "b", "c"},
+		" , ":         nil,
+	} {
+		if got := splitList(in); !slices.Equal(got, want) {
+			t.Errorf("splitList(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
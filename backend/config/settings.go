@@ -0,0 +1,433 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when it exists and neither -config nor
+// CONFIG_FILE names another file.
+const DefaultConfigFile = "config.yaml"
+
+// Layers a setting can come from, lowest precedence first.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// setting binds one configuration value to its name in each layer: key is
+// the dotted path in the config file and the flag name, env the environment
+// variable.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	// value points at the Config field: *string, *int, *int64,
+	// *time.Duration or *[]string.
+	value any
+	// mask hides secrets when the configuration is printed.
+	mask func(string) string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "server.port", env: "PORT", usage: "HTTP listen port", value: &c.Server.Port},
+		{key: "server.read_timeout", env: "SERVER_READ_TIMEOUT", usage: "maximum time to read a request", value: &c.Server.ReadTimeout},
+		{key: "server.write_timeout", env: "SERVER_WRITE_TIMEOUT", usage: "maximum time to write a response", value: &c.Server.WriteTimeout},
+		{key: "server.upload_timeout", env: "SERVER_UPLOAD_TIMEOUT", usage: "deadline for an upload, including ingestion", value: &c.Server.UploadTimeout},
+		{key: "server.chat_timeout", env: "SERVER_CHAT_TIMEOUT", usage: "deadline for a chat request", value: &c.Server.ChatTimeout},
+		{key: "server.list_timeout", env: "SERVER_LIST_TIMEOUT", usage: "deadline for listing documents, chunks or audit events", value: &c.Server.ListTimeout},
+		{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", usage: "time allowed to drain on SIGTERM", value: &c.Server.ShutdownTimeout},
+		{key: "server.drain_delay", env: "SERVER_DRAIN_DELAY", usage: "time to keep serving with readiness failing before draining on SIGTERM", value: &c.Server.DrainDelay},
+		{key: "server.trusted_proxies", env: "TRUSTED_PROXIES", usage: "proxies in front of the server that append to X-Forwarded-For; 0 ignores the header", value: &c.Server.TrustedProxies},
+		{key: "server.cors_origins", env: "CORS_ALLOWED_ORIGINS", usage: "comma-separated browser origins allowed to call the API", value: &c.Server.CORSOrigins},
+		{key: "server.max_upload_bytes", env: "UPLOAD_MAX_BYTES", usage: "largest accepted upload request body in bytes", value: &c.Server.MaxUploadBytes},
+		{key: "server.max_body_bytes", env: "MAX_BODY_BYTES", usage: "largest accepted request body on other routes", value: &c.Server.MaxBodyBytes},
+
+		{key: "database.url", env: "DATABASE_URL", usage: "sqlite:// or postgres:// URL", value: &c.DatabaseURL, mask: maskURL},
+
+		{key: "storage.provider", env: "STORAGE_PROVIDER", usage: "supabase or local", value: &c.Storage.Provider},
+		{key: "storage.supabase_url", env: "SUPABASE_URL", usage: "Supabase project URL", value: &c.SupabaseURL},
+		{key: "storage.supabase_service_key", env: "SUPABASE_SERVICE_KEY", usage: "Supabase service role key", value: &c.SupabaseSvcKey, mask: maskSecret},
+		{key: "storage.bucket", env: "SUPABASE_BUCKET", usage: "Supabase bucket for uploads", value: &c.Storage.Bucket},
+		{key: "storage.dir", env: "STORAGE_DIR", usage: "directory for uploads with the local provider", value: &c.Storage.Dir},
+
+		{key: "ai.provider", env: "AI_PROVIDER", usage: "language model provider (gemini)", value: &c.AI.Provider},
+		{key: "ai.model", env: "AI_MODEL", usage: "model used to generate insights", value: &c.AI.Model},
+		{key: "ai.embedding_model", env: "AI_EMBEDDING_MODEL", usage: "model used to embed chunks; empty stores chunks without embeddings", value: &c.AI.EmbeddingModel},
+		{key: "ai.timeout", env: "AI_TIMEOUT", usage: "timeout for one model call", value: &c.AI.Timeout},
+		{key: "ai.context_chunks", env: "AI_CONTEXT_CHUNKS", usage: "most relevant chunks sent with a question; 0 sends every chunk", value: &c.AI.ContextChunks},
+		{key: "ai.summary_chunks", env: "AI_SUMMARY_CHUNKS", usage: "leading chunks summarized after upload; 0 disables summaries", value: &c.AI.SummaryChunks},
+		{key: "ai.gemini_api_key", env: "GEMINI_API_KEY", usage: "Gemini API key", value: &c.GeminiAPIKey, mask: maskSecret},
+
+		{key: "processing.unidoc_license_key", env: "UNIDOC_LICENSE_KEY", usage: "UniDoc key; PDF uploads are disabled without it", value: &c.UnidocLicenseKey, mask: maskSecret},
+		{key: "processing.chunk_size", env: "CHUNK_SIZE", usage: "target chunk length in characters", value: &c.Chunking.Size},
+		{key: "processing.chunk_overlap", env: "CHUNK_OVERLAP", usage: "characters shared by consecutive chunks", value: &c.Chunking.Overlap},
+
+		{key: "auth.mode", env: "AUTH_MODE", usage: "firebase, jwt or oidc", value: &c.Auth.Mode},
+		{key: "auth.jwt_secret", env: "AUTH_JWT_SECRET", usage: "HS256 secret for jwt mode", value: &c.Auth.JWTSecret, mask: maskSecret},
+		{key: "auth.jwt_public_key_file", env: "AUTH_JWT_PUBLIC_KEY_FILE", usage: "RS256 public key for jwt mode", value: &c.Auth.JWTPublicKeyFile},
+		{key: "auth.jwt_private_key_file", env: "AUTH_JWT_PRIVATE_KEY_FILE", usage: "RS256 private key for minting tokens", value: &c.Auth.JWTPrivateKeyFile},
+		{key: "auth.jwt_issuer", env: "AUTH_JWT_ISSUER", usage: "issuer of local tokens", value: &c.Auth.JWTIssuer},
+		{key: "auth.jwt_audience", env: "AUTH_JWT_AUDIENCE", usage: "audience of local tokens", value: &c.Auth.JWTAudience},
+		{key: "auth.oidc_issuer", env: "OIDC_ISSUER_URL", usage: "issuer URL for oidc mode", value: &c.Auth.OIDCIssuer},
+		{key: "auth.oidc_jwks_url", env: "OIDC_JWKS_URL", usage: "JWKS URL; discovered when empty", value: &c.Auth.OIDCJWKSURL},
+		{key: "auth.oidc_audience", env: "OIDC_AUDIENCE", usage: "expected audience in oidc mode", value: &c.Auth.OIDCAudience},
+		{key: "auth.admin_uids", env: "ADMIN_UIDS", usage: "comma-separated user IDs allowed on /api/admin", value: &c.AdminUIDs},
+
+		{key: "log.level", env: "LOG_LEVEL", usage: "debug, info, warn or error", value: &c.Log.Level},
+		{key: "log.format", env: "LOG_FORMAT", usage: "text or json", value: &c.Log.Format},
+
+		{key: "limits.requests_per_minute", env: "RATE_LIMIT_PER_MINUTE", usage: "requests per user per route per minute", value: &c.Limits.RequestsPerMinute},
+		{key: "limits.chat_per_minute", env: "RATE_LIMIT_CHAT_PER_MINUTE", usage: "chat requests per user per minute", value: &c.Limits.ChatPerMinute},
+		{key: "limits.uploads_per_minute", env: "RATE_LIMIT_UPLOAD_PER_MINUTE", usage: "uploads per user per minute", value: &c.Limits.UploadsPerMinute},
+		{key: "limits.llm_tokens_daily", env: "QUOTA_LLM_TOKENS_DAILY", usage: "LLM tokens per user per day", value: &c.Limits.LLMTokensDaily},
+		{key: "limits.llm_tokens_monthly", env: "QUOTA_LLM_TOKENS_MONTHLY", usage: "LLM tokens per user per month", value: &c.Limits.LLMTokensMonthly},
+		{key: "limits.uploads_daily", env: "QUOTA_UPLOADS_DAILY", usage: "uploads per user per day", value: &c.Limits.UploadsDaily},
+		{key: "limits.uploads_monthly", env: "QUOTA_UPLOADS_MONTHLY", usage: "uploads per user per month", value: &c.Limits.UploadsMonthly},
+
+		{key: "metrics.addr", env: "METRICS_ADDR", usage: "separate listen address for /metrics; empty disables it", value: &c.Metrics.Addr},
+		{key: "metrics.token", env: "METRICS_TOKEN", usage: "bearer token required to scrape /metrics", value: &c.Metrics.Token, mask: maskSecret},
+		{key: "tracing.endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", usage: "OTLP/HTTP collector URL; empty disables export", value: &c.Tracing.Endpoint},
+		{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", usage: "service name on exported spans", value: &c.Tracing.ServiceName},
+		{key: "audit.fallback_file", env: "AUDIT_FALLBACK_FILE", usage: "file for audit events the database could not store", value: &c.AuditFallbackFile},
+	}
+}
+
+// Resolve builds the configuration from defaults, the config file, the
+// environment and the flags in args, in increasing order of precedence. It
+// does not validate the result.
+func Resolve(args []string) (*Config, error) {
+	godotenv.Load()
+
+	cfg := Default()
+	cfg.sources = map[string]string{}
+	settings := cfg.settings()
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+		cfg.sources[s.key] = sourceDefault
+	}
+
+	// Flags are parsed first, to find -config, but applied last.
+	fs := flag.NewFlagSet("strategic-insight-analyst", flag.ContinueOnError)
+	configFile := fs.String("config", "", "config file (YAML); defaults to $CONFIG_FILE or "+DefaultConfigFile)
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		fs.Func(s.key, fmt.Sprintf("%s (env %s)", s.usage, s.env), func(v string) error {
+			flagValues[s.key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	var errs []error
+	path, required := *configFile, true
+	if path == "" {
+		path, required = os.Getenv("CONFIG_FILE"), true
+	}
+	if path == "" {
+		path, required = DefaultConfigFile, false
+	}
+	fileValues, err := readFile(path, required)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range slices.Sorted(maps.Keys(fileValues)) {
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			continue
+		}
+		errs = append(errs, cfg.apply(s, fileValues[key], sourceFile))
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			errs = append(errs, cfg.apply(s, v, sourceEnv))
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if v, ok := flagValues[f.Name]; ok {
+			errs = append(errs, cfg.apply(byKey[f.Name], v, sourceFlag))
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) apply(s setting, raw, source string) error {
+	if err := parseInto(s.value, raw); err != nil {
+		return fmt.Errorf("%s (%s): %w", s.key, s.env, err)
+	}
+	c.sources[s.key] = source
+	return nil
+}
+
+func parseInto(dst any, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch p := dst.(type) {
+	case *string:
+		*p = raw
+	case *[]string:
+		*p = splitList(raw)
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*p = n
+	case *int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*p = n
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (e.g. 15s, 2m)", raw)
+		}
+		*p = d
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %T", dst))
+	}
+	return nil
+}
+
+// readFile flattens a YAML config file into dotted keys. A missing file is
+// only an error when it was asked for explicitly.
+func readFile(path string, required bool) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	out := map[string]string{}
+	flatten("", doc, out)
+	return out, nil
+}
+
+func flatten(prefix string, node map[string]any, out map[string]string) {
+	for k, v := range node {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(key, v, out)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// Validate checks the settings of every feature that is enabled, so unused
+// features need no configuration.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("server.port (PORT) must be a port number, got %q", c.Server.Port)
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.UploadTimeout <= 0 || c.Server.ChatTimeout <= 0 || c.Server.ListTimeout <= 0 {
+		fail("server.read_timeout, write_timeout, upload_timeout, chat_timeout and list_timeout must be positive")
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		fail("server.shutdown_timeout (SHUTDOWN_TIMEOUT) must not be negative")
+	}
+	if c.Server.DrainDelay < 0 {
+		fail("server.drain_delay (SERVER_DRAIN_DELAY) must not be negative")
+	}
+	if c.Server.TrustedProxies < 0 {
+		fail("server.trusted_proxies (TRUSTED_PROXIES) must not be negative")
+	}
+	if c.Server.MaxUploadBytes <= 0 || c.Server.MaxBodyBytes <= 0 {
+		fail("server.max_upload_bytes (UPLOAD_MAX_BYTES) and server.max_body_bytes (MAX_BODY_BYTES) must be positive")
+	}
+	for _, origin := range c.Server.CORSOrigins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "") {
+			fail("server.cors_origins: %q is not an origin like https://example.com", origin)
+		}
+	}
+
+	if c.DatabaseURL == "" {
+		fail("database.url (DATABASE_URL) must be set")
+	}
+
+	switch c.Storage.Provider {
+	case "supabase":
+		if c.SupabaseURL == "" || c.SupabaseSvcKey == "" {
+			fail("storage.supabase_url (SUPABASE_URL) and storage.supabase_service_key (SUPABASE_SERVICE_KEY) are required with the supabase storage provider")
+		}
+		if c.Storage.Bucket == "" {
+			fail("storage.bucket (SUPABASE_BUCKET) must be set")
+		}
+	case "local":
+		if c.Storage.Dir == "" {
+			fail("storage.dir (STORAGE_DIR) is required with the local storage provider")
+		}
+	default:
+		fail("storage.provider (STORAGE_PROVIDER) must be supabase or local, got %q", c.Storage.Provider)
+	}
+
+	switch c.AI.Provider {
+	case "gemini":
+		if c.GeminiAPIKey == "" {
+			fail("ai.gemini_api_key (GEMINI_API_KEY) is required with the gemini provider")
+		}
+	default:
+		fail("ai.provider (AI_PROVIDER) must be gemini, got %q", c.AI.Provider)
+	}
+	if c.AI.Model == "" {
+		fail("ai.model (AI_MODEL) must be set")
+	}
+	if c.AI.Timeout <= 0 {
+		fail("ai.timeout (AI_TIMEOUT) must be positive")
+	}
+	if c.AI.SummaryChunks < 0 {
+		fail("ai.summary_chunks (AI_SUMMARY_CHUNKS) must not be negative")
+	}
+	if c.AI.ContextChunks < 0 {
+		fail("ai.context_chunks (AI_CONTEXT_CHUNKS) must not be negative")
+	}
+
+	if c.Chunking.Size <= 0 {
+		fail("processing.chunk_size (CHUNK_SIZE) must be positive")
+	} else if c.Chunking.Overlap < 0 || c.Chunking.Overlap >= c.Chunking.Size {
+		fail("processing.chunk_overlap (CHUNK_OVERLAP) must be at least 0 and less than the chunk size")
+	}
+
+	switch c.Auth.Mode {
+	case "firebase":
+	case "jwt":
+		if c.Auth.JWTSecret == "" && c.Auth.JWTPublicKeyFile == "" && c.Auth.JWTPrivateKeyFile == "" {
+			fail("auth.mode jwt needs auth.jwt_secret (AUTH_JWT_SECRET) or a key file")
+		}
+	case "oidc":
+		if c.Auth.OIDCIssuer == "" {
+			fail("auth.mode oidc needs auth.oidc_issuer (OIDC_ISSUER_URL)")
+		}
+	default:
+		fail("auth.mode (AUTH_MODE) must be firebase, jwt or oidc, got %q", c.Auth.Mode)
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		fail("log.level (LOG_LEVEL) must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		fail("log.format (LOG_FORMAT) must be text or json, got %q", c.Log.Format)
+	}
+
+	if c.Limits.RequestsPerMinute < 0 || c.Limits.ChatPerMinute < 0 || c.Limits.UploadsPerMinute < 0 ||
+		c.Limits.LLMTokensDaily < 0 || c.Limits.LLMTokensMonthly < 0 || c.Limits.UploadsDaily < 0 || c.Limits.UploadsMonthly < 0 {
+		fail("limits must not be negative (0 disables a limit)")
+	}
+
+	if c.Metrics.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Addr); err != nil {
+			fail("metrics.addr (METRICS_ADDR) must be host:port, got %q", c.Metrics.Addr)
+		}
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("tracing.endpoint (OTEL_EXPORTER_OTLP_ENDPOINT) must be a URL, got %q", c.Tracing.Endpoint)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Write prints every setting with its effective value and the layer it came
+// from. Secrets are masked.
+func (c *Config) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tENV\tVALUE\tSOURCE")
+	for _, s := range c.settings() {
+		value := formatValue(s.value)
+		if s.mask != nil && value != "" {
+			value = s.mask(value)
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = sourceDefault
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.key, s.env, value, source)
+	}
+	return tw.Flush()
+}
+
+func formatValue(v any) string {
+	switch p := v.(type) {
+	case *string:
+		return *p
+	case *[]string:
+		return strings.Join(*p, ",")
+	case *int:
+		return strconv.Itoa(*p)
+	case *int64:
+		return strconv.FormatInt(*p, 10)
+	case *time.Duration:
+		return p.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func maskSecret(string) string {
+	return "********"
+}
+
+// maskURL hides the password in a database URL but keeps the rest readable.
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
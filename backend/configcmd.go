@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+const configUsage = `Usage: strategic-insight-analyst config check [-config file] [flags]
+
+Prints the effective configuration, with secrets masked and the layer each
+value came from (default, file, env or flag), then validates it. Accepts the
+same flags as the server. Exits with status 1 if the configuration is invalid.
+`
+
+// runConfigCommand implements the "config" subcommand.
+func runConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprint(os.Stderr, configUsage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Resolve(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg.Write(os.Stdout)
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "\nconfiguration is invalid:\n%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("\nconfiguration is valid")
+}
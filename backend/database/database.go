@@ -2,74 +2,199 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
+	"os"
 
-	_ "github.com/mattn/go-sqlite3" // The SQLite driver
+	_ "github.com/jackc/pgx/v5/stdlib" // The PostgreSQL driver
+	_ "github.com/mattn/go-sqlite3"    // The SQLite driver
+	"go.opentelemetry.io/otel/trace"
 )
 
-var DB *sql.DB
+// DB wraps a connection pool with the dialect it speaks. Queries are written
+// once with "?" placeholders and rebound for the underlying database.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+	// HasVector reports whether document_chunks has a pgvector embedding column.
+	HasVector bool
+}
+
+// Open connects to the database described by databaseURL without touching the schema.
+func Open(databaseURL string) (*DB, error) {
+	dialect, driver, dsn := parseURL(databaseURL)
+	sqlDB, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return &DB{DB: sqlDB, Dialect: dialect}, nil
+}
+
+// Connect opens the database, brings its schema up to date and detects
+// pgvector.
+func Connect(databaseURL string) (*DB, error) {
+	db, err := Open(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
 
-func InitDB(filepath string) {
-	var err error
-	DB, err = sql.Open("sqlite3", filepath)
+	slog.Info("database connection established", "dialect", db.Dialect)
+
+	applied, err := Migrate(db)
 	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	slog.Info("database schema is up to date", "applied", applied)
+
+	if err := db.detectVector(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if db.HasVector {
+		slog.Info("pgvector is available; embeddings will be stored as vectors")
+	}
+	return db, nil
+}
 
-	if err = DB.Ping(); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+// InitDB is Connect for main: it exits if the database cannot be used.
+func InitDB(databaseURL string) *DB {
+	db, err := Connect(databaseURL)
+	if err != nil {
+		slog.Error("failed to initialize database", "error", err)
+		os.Exit(1)
 	}
+	return db
+}
 
-	log.Println("Database connection established.")
-	createTables()
-}
-
-func createTables() {
-	// This schema is adjusted for SQLite compatibility but respects the original structure.
-	// VARCHAR(255) becomes TEXT, TIMESTAMP becomes DATETIME, ENUM becomes TEXT with a CHECK constraint.
-	schema := `
-    CREATE TABLE IF NOT EXISTS users (
-        id TEXT PRIMARY KEY,
-        email TEXT UNIQUE NOT NULL,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-    );
-
-    CREATE TABLE IF NOT EXISTS documents (
-        id TEXT PRIMARY KEY,
-        user_id TEXT NOT NULL,
-        file_name TEXT NOT NULL,
-        storage_path TEXT NOT NULL,
-        uploaded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-    );
-
-    CREATE TABLE IF NOT EXISTS document_chunks (
-        id TEXT PRIMARY KEY,
-        document_id TEXT NOT NULL,
-        chunk_index INTEGER NOT NULL,
-        content TEXT NOT NULL,
-        embedding TEXT, -- JSONB becomes TEXT in SQLite
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
-        UNIQUE (document_id, chunk_index)
-    );
-
-    CREATE TABLE IF NOT EXISTS chat_history (
-        id TEXT PRIMARY KEY,
-        document_id TEXT NOT NULL,
-        user_id TEXT NOT NULL,
-        message_type TEXT NOT NULL CHECK(message_type IN ('user', 'ai')),
-        message_content TEXT NOT NULL,
-        timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
-        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-    );
-    `
-
-	_, err := DB.Exec(schema)
+// detectVector checks whether the pgvector migration managed to add its column.
+// The extension is optional, so its absence is not an error.
+func (db *DB) detectVector() error {
+	if db.Dialect != Postgres {
+		return nil
+	}
+	err := db.QueryRow(`SELECT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND table_name = 'document_chunks' AND column_name = 'embedding_vector'
+    )`).Scan(&db.HasVector)
 	if err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+		return fmt.Errorf("could not detect pgvector column: %w", err)
 	}
-	log.Println("Tables created successfully or already exist.")
+	return nil
+}
+
+// HealthCheck runs a trivial query, which unlike Ping also fails when the
+// database cannot serve statements.
+func (db *DB) HealthCheck(ctx context.Context) error {
+	var one int
+	return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.DB.Exec(db.Dialect.Rebind(query), args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query = db.Dialect.Rebind(query)
+	ctx, span := startSpan(ctx, db.Dialect, "db.exec", query)
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return res, err
+}
+
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.DB.Query(db.Dialect.Rebind(query), args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query = db.Dialect.Rebind(query)
+	ctx, span := startSpan(ctx, db.Dialect, "db.query", query)
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	return db.DB.QueryRow(db.Dialect.Rebind(query), args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	query = db.Dialect.Rebind(query)
+	ctx, span := startSpan(ctx, db.Dialect, "db.query", query)
+	return &Row{row: db.DB.QueryRowContext(ctx, query, args...), span: span}
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.Dialect}, nil
+}
+
+// Tx is a transaction that rebinds placeholders like DB does.
+type Tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.Rebind(query), args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query = tx.dialect.Rebind(query)
+	ctx, span := startSpan(ctx, tx.dialect, "db.exec", query)
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return res, err
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query = tx.dialect.Rebind(query)
+	ctx, span := startSpan(ctx, tx.dialect, "db.query", query)
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	query = tx.dialect.Rebind(query)
+	ctx, span := startSpan(ctx, tx.dialect, "db.query", query)
+	return &Row{row: tx.Tx.QueryRowContext(ctx, query, args...), span: span}
+}
+
+// Row is the result of QueryRowContext. Its span stays open until Scan,
+// which is where the row is read and where sql.ErrNoRows and scan errors
+// surface, so Scan must be called.
+type Row struct {
+	row  *sql.Row
+	span trace.Span
+}
+
+// Scan copies the row into dest like sql.Row.Scan and ends the span.
+func (r *Row) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	endSpan(r.span, err)
+	return err
+}
+
+// Err returns the error, if any, from running the query.
+func (r *Row) Err() error {
+	return r.row.Err()
+}
+
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return tx.Tx.PrepareContext(ctx, tx.dialect.Rebind(query))
 }
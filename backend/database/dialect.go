@@ -0,0 +1,61 @@
+// backend/database/dialect.go
+package database
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour spoken by the connected database.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// parseURL maps a DATABASE_URL onto a dialect, database/sql driver name and DSN.
+// postgres:// and postgresql:// URLs select PostgreSQL; anything else is treated
+// as a SQLite file, optionally prefixed with sqlite://.
+func parseURL(databaseURL string) (Dialect, string, string) {
+	switch {
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		return Postgres, "pgx", databaseURL
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		return SQLite, "sqlite3", strings.TrimPrefix(databaseURL, "sqlite://")
+	default:
+		return SQLite, "sqlite3", databaseURL
+	}
+}
+
+// Rebind rewrites the portable "?" placeholders used throughout the codebase
+// into the dialect's native form. Question marks inside quoted literals are
+// left untouched.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package database
+
+import "testing"
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url            string
+		dialect        Dialect
+		driver, source string
+	}{
+		{"postgres://u:p@db:5432/sia?sslmode=disable", Postgres, "pgx", "postgres://u:p@db:5432/sia?sslmode=disable"},
+		{"postgresql://db/sia", Postgres, "pgx", "postgresql://db/sia"},
+		{"sqlite://data/app.db", SQLite, "sqlite3", "data/app.db"},
+		{"./sia.db", SQLite, "sqlite3", "./sia.db"},
+	}
+	for _, tt := range tests {
+		dialect, driver, source := parseURL(tt.url)
+		if dialect != tt.dialect || driver != tt.driver || source != tt.source {
+			t.Errorf("parseURL(%q) = %s, %s, %s; want %s, %s, %s", tt.url, dialect, driver, source, tt.dialect, tt.driver, tt.source)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect     Dialect
+		query, want string
+	}{
+		{SQLite, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{Postgres, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{Postgres, "SELECT * FROM t", "SELECT * FROM t"},
+		{Postgres, "SELECT '?', \"a?\" FROM t WHERE c = ?", "SELECT '?', \"a?\" FROM t WHERE c = $1"},
+		{Postgres, "UPDATE t SET a = 'it''s?' WHERE id = ?", "UPDATE t SET a = 'it''s?' WHERE id = $1"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.Rebind(tt.query); got != tt.want {
+			t.Errorf("%s.Rebind(%q) = %q, want %q", tt.dialect, tt.query, got, tt.want)
+		}
+	}
+}
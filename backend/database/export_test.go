@@ -0,0 +1,9 @@
+package database
+
+// SetMigrations replaces the registered migrations until the returned
+// function is called.
+func SetMigrations(ms []Migration) (restore func()) {
+	saved := migrations
+	migrations = ms
+	return func() { migrations = saved }
+}
@@ -0,0 +1,733 @@
+// backend/database/migrations.go
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Migration is a single, versioned schema change. Versions must be unique and
+// increasing; once a migration has shipped its SQL must never be edited, add a
+// new migration instead.
+type Migration struct {
+	Version int
+	Name    string
+	Up      Script
+	Down    Script
+}
+
+// Script holds the SQL for one direction of a migration. Most statements are
+// portable, so Postgres falls back to the SQLite text when it is left empty.
+type Script struct {
+	SQLite   string
+	Postgres string
+}
+
+// For returns the SQL to run against the given dialect.
+func (s Script) For(d Dialect) string {
+	if d == Postgres && s.Postgres != "" {
+		return s.Postgres
+	}
+	return s.SQLite
+}
+
+// MigrationState describes whether a known migration has been applied.
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// migrations is the ordered list of every schema change the backend knows about.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		// Uses IF NOT EXISTS so databases created by the old createTables
+		// routine are adopted without changes.
+		Up: Script{
+			SQLite: `
+    CREATE TABLE IF NOT EXISTS users (
+        id TEXT PRIMARY KEY,
+        email TEXT UNIQUE NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS documents (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        file_name TEXT NOT NULL,
+        storage_path TEXT NOT NULL,
+        uploaded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS document_chunks (
+        id TEXT PRIMARY KEY,
+        document_id TEXT NOT NULL,
+        chunk_index INTEGER NOT NULL,
+        content TEXT NOT NULL,
+        embedding TEXT, -- JSONB becomes TEXT in SQLite
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+        UNIQUE (document_id, chunk_index)
+    );
+
+    CREATE TABLE IF NOT EXISTS chat_history (
+        id TEXT PRIMARY KEY,
+        document_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        message_type TEXT NOT NULL CHECK(message_type IN ('user', 'ai')),
+        message_content TEXT NOT NULL,
+        timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+    `,
+			Postgres: `
+    CREATE TABLE IF NOT EXISTS users (
+        id TEXT PRIMARY KEY,
+        email TEXT UNIQUE NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS documents (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        file_name TEXT NOT NULL,
+        storage_path TEXT NOT NULL,
+        uploaded_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS document_chunks (
+        id TEXT PRIMARY KEY,
+        document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+        chunk_index INTEGER NOT NULL,
+        content TEXT NOT NULL,
+        embedding JSONB,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (document_id, chunk_index)
+    );
+
+    CREATE TABLE IF NOT EXISTS chat_history (
+        id TEXT PRIMARY KEY,
+        document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+        user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        message_type TEXT NOT NULL CHECK(message_type IN ('user', 'ai')),
+        message_content TEXT NOT NULL,
+        timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+    `,
+		},
+		Down: Script{SQLite: `
+    DROP TABLE IF EXISTS chat_history;
+    DROP TABLE IF EXISTS document_chunks;
+    DROP TABLE IF EXISTS documents;
+    DROP TABLE IF EXISTS users;
+    `},
+	},
+	{
+		Version: 2,
+		Name:    "pgvector_embeddings",
+		// pgvector is optional: when the extension cannot be installed the
+		// migration still succeeds and embeddings stay in the JSON column.
+		Up: Script{Postgres: `
+    DO $$
+    BEGIN
+        IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+            CREATE EXTENSION IF NOT EXISTS vector;
+            ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS embedding_vector vector;
+        END IF;
+    EXCEPTION WHEN insufficient_privilege THEN
+        RAISE NOTICE 'pgvector not installed: %', SQLERRM;
+    END $$;
+    `},
+		Down: Script{Postgres: `
+    ALTER TABLE document_chunks DROP COLUMN IF EXISTS embedding_vector;
+    `},
+	},
+	{
+		Version: 3,
+		Name:    "api_keys",
+		Up: Script{
+			SQLite: `
+    CREATE TABLE api_keys (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        name TEXT NOT NULL,
+        prefix TEXT NOT NULL,
+        key_hash TEXT UNIQUE NOT NULL,
+        scopes TEXT NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        last_used_at DATETIME,
+        revoked_at DATETIME,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+    CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
+    `,
+			Postgres: `
+    CREATE TABLE api_keys (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        name TEXT NOT NULL,
+        prefix TEXT NOT NULL,
+        key_hash TEXT UNIQUE NOT NULL,
+        scopes TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        last_used_at TIMESTAMPTZ,
+        revoked_at TIMESTAMPTZ
+    );
+    CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
+    `,
+		},
+		Down: Script{SQLite: `
+    DROP TABLE IF EXISTS api_keys;
+    `},
+	},
+	{
+		Version: 4,
+		Name:    "document_shares_and_tags",
+		Up: Script{
+			SQLite: `
+    CREATE TABLE document_shares (
+        id TEXT PRIMARY KEY,
+        document_id TEXT NOT NULL,
+        grantee_user_id TEXT,
+        grantee_email TEXT,
+        role TEXT NOT NULL CHECK(role IN ('viewer', 'editor')),
+        created_by TEXT NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+        CHECK (grantee_user_id IS NOT NULL OR grantee_email IS NOT NULL)
+    );
+    CREATE UNIQUE INDEX idx_document_shares_user ON document_shares(document_id, grantee_user_id);
+    CREATE UNIQUE INDEX idx_document_shares_email ON document_shares(document_id, grantee_email);
+    CREATE INDEX idx_document_shares_grantee_user ON document_shares(grantee_user_id);
+    CREATE INDEX idx_document_shares_grantee_email ON document_shares(grantee_email);
+
+    CREATE TABLE document_tags (
+        document_id TEXT NOT NULL,
+        tag TEXT NOT NULL,
+        PRIMARY KEY (document_id, tag),
+        FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+    );
+    `,
+			Postgres: `
+    CREATE TABLE document_shares (
+        id TEXT PRIMARY KEY,
+        document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+        grantee_user_id TEXT,
+        grantee_email TEXT,
+        role TEXT NOT NULL CHECK(role IN ('viewer', 'editor')),
+        created_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        CHECK (grantee_user_id IS NOT NULL OR grantee_email IS NOT NULL)
+    );
+    CREATE UNIQUE INDEX idx_document_shares_user ON document_shares(document_id, grantee_user_id);
+    CREATE UNIQUE INDEX idx_document_shares_email ON document_shares(document_id, grantee_email);
+    CREATE INDEX idx_document_shares_grantee_user ON document_shares(grantee_user_id);
+    CREATE INDEX idx_document_shares_grantee_email ON document_shares(grantee_email);
+
+    CREATE TABLE document_tags (
+        document_id TEXT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+        tag TEXT NOT NULL,
+        PRIMARY KEY (document_id, tag)
+    );
+    `,
+		},
+		Down: Script{SQLite: `
+    DROP TABLE IF EXISTS document_tags;
+    DROP TABLE IF EXISTS document_shares;
+    `},
+	},
+	{
+		Version: 5,
+		Name:    "organizations",
+		Up: Script{
+			SQLite: `
+    CREATE TABLE organizations (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        created_by TEXT NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE organization_members (
+        org_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        role TEXT NOT NULL CHECK(role IN ('owner', 'admin', 'member')),
+        joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (org_id, user_id),
+        FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+    CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+    CREATE TABLE organization_invites (
+        id TEXT PRIMARY KEY,
+        org_id TEXT NOT NULL,
+        email TEXT NOT NULL,
+        role TEXT NOT NULL CHECK(role IN ('admin', 'member')),
+        invited_by TEXT NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+        UNIQUE (org_id, email)
+    );
+    CREATE INDEX idx_organization_invites_email ON organization_invites(email);
+
+    CREATE TABLE collections (
+        id TEXT PRIMARY KEY,
+        org_id TEXT NOT NULL,
+        name TEXT NOT NULL,
+        created_by TEXT NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+        UNIQUE (org_id, name)
+    );
+
+    ALTER TABLE documents ADD COLUMN org_id TEXT REFERENCES organizations(id);
+    ALTER TABLE documents ADD COLUMN collection_id TEXT REFERENCES collections(id);
+    CREATE INDEX idx_documents_org_id ON documents(org_id);
+    `,
+			Postgres: `
+    CREATE TABLE organizations (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        created_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE organization_members (
+        org_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+        user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        role TEXT NOT NULL CHECK(role IN ('owner', 'admin', 'member')),
+        joined_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (org_id, user_id)
+    );
+    CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+    CREATE TABLE organization_invites (
+        id TEXT PRIMARY KEY,
+        org_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+        email TEXT NOT NULL,
+        role TEXT NOT NULL CHECK(role IN ('admin', 'member')),
+        invited_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (org_id, email)
+    );
+    CREATE INDEX idx_organization_invites_email ON organization_invites(email);
+
+    CREATE TABLE collections (
+        id TEXT PRIMARY KEY,
+        org_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+        name TEXT NOT NULL,
+        created_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (org_id, name)
+    );
+
+    ALTER TABLE documents ADD COLUMN org_id TEXT REFERENCES organizations(id) ON DELETE CASCADE;
+    ALTER TABLE documents ADD COLUMN collection_id TEXT REFERENCES collections(id) ON DELETE SET NULL;
+    CREATE INDEX idx_documents_org_id ON documents(org_id);
+    `,
+		},
+		Down: Script{
+			SQLite: `
+    DROP INDEX IF EXISTS idx_documents_org_id;
+    ALTER TABLE documents DROP COLUMN collection_id;
+    ALTER TABLE documents DROP COLUMN org_id;
+    DROP TABLE IF EXISTS collections;
+    DROP TABLE IF EXISTS organization_invites;
+    DROP TABLE IF EXISTS organization_members;
+    DROP TABLE IF EXISTS organizations;
+    `,
+			Postgres: `
+    ALTER TABLE documents DROP COLUMN IF EXISTS collection_id;
+    ALTER TABLE documents DROP COLUMN IF EXISTS org_id;
+    DROP TABLE IF EXISTS collections;
+    DROP TABLE IF EXISTS organization_invites;
+    DROP TABLE IF EXISTS organization_members;
+    DROP TABLE IF EXISTS organizations;
+    `,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "quota_usage",
+		Up: Script{
+			SQLite: `
+    CREATE TABLE quota_usage (
+        user_id TEXT NOT NULL,
+        metric TEXT NOT NULL,
+        period TEXT NOT NULL,
+        amount INTEGER NOT NULL DEFAULT 0,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (user_id, metric, period)
+    );
+    `,
+			Postgres: `
+    CREATE TABLE quota_usage (
+        user_id TEXT NOT NULL,
+        metric TEXT NOT NULL,
+        period TEXT NOT NULL,
+        amount BIGINT NOT NULL DEFAULT 0,
+        updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (user_id, metric, period)
+    );
+    `,
+		},
+		Down: Script{SQLite: `DROP TABLE IF EXISTS quota_usage;`},
+	},
+	{
+		Version: 7,
+		Name:    "llm_usage",
+		// document_id has no foreign key so spend stays on record after the
+		// document is deleted.
+		Up: Script{
+			SQLite: `
+    CREATE TABLE llm_usage (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        document_id TEXT,
+        conversation_id TEXT,
+        operation TEXT NOT NULL CHECK(operation IN ('generate', 'embed')),
+        model TEXT NOT NULL,
+        prompt_tokens INTEGER NOT NULL DEFAULT 0,
+        response_tokens INTEGER NOT NULL DEFAULT 0,
+        cost_usd REAL NOT NULL DEFAULT 0,
+        day TEXT NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX idx_llm_usage_user_day ON llm_usage(user_id, day);
+    CREATE INDEX idx_llm_usage_day ON llm_usage(day);
+
+    ALTER TABLE chat_history ADD COLUMN conversation_id TEXT;
+    `,
+			Postgres: `
+    CREATE TABLE llm_usage (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        document_id TEXT,
+        conversation_id TEXT,
+        operation TEXT NOT NULL CHECK(operation IN ('generate', 'embed')),
+        model TEXT NOT NULL,
+        prompt_tokens BIGINT NOT NULL DEFAULT 0,
+        response_tokens BIGINT NOT NULL DEFAULT 0,
+        cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+        day TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX idx_llm_usage_user_day ON llm_usage(user_id, day);
+    CREATE INDEX idx_llm_usage_day ON llm_usage(day);
+
+    ALTER TABLE chat_history ADD COLUMN conversation_id TEXT;
+    `,
+		},
+		Down: Script{SQLite: `
+    ALTER TABLE chat_history DROP COLUMN conversation_id;
+    DROP TABLE IF EXISTS llm_usage;
+    `},
+	},
+	{
+		Version: 8,
+		Name:    "audit_log",
+		// The audit trail is append-only: triggers reject updates and deletes
+		// so entries cannot be rewritten through the application.
+		Up: Script{
+			SQLite: `
+    CREATE TABLE audit_log (
+        id TEXT PRIMARY KEY,
+        user_id TEXT,
+        action TEXT NOT NULL,
+        document_id TEXT,
+        ip TEXT,
+        user_agent TEXT,
+        details TEXT,
+        created_at DATETIME NOT NULL
+    );
+    CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+    CREATE INDEX idx_audit_log_user_id ON audit_log(user_id, created_at);
+    CREATE INDEX idx_audit_log_document_id ON audit_log(document_id, created_at);
+
+    CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+    BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+    CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+    BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+    `,
+			Postgres: `
+    CREATE TABLE audit_log (
+        id TEXT PRIMARY KEY,
+        user_id TEXT,
+        action TEXT NOT NULL,
+        document_id TEXT,
+        ip TEXT,
+        user_agent TEXT,
+        details JSONB,
+        created_at TIMESTAMPTZ NOT NULL
+    );
+    CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+    CREATE INDEX idx_audit_log_user_id ON audit_log(user_id, created_at);
+    CREATE INDEX idx_audit_log_document_id ON audit_log(document_id, created_at);
+
+    CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'audit_log is append-only';
+    END;
+    $$ LANGUAGE plpgsql;
+    CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+    `,
+		},
+		Down: Script{
+			SQLite: `DROP TABLE IF EXISTS audit_log;`,
+			Postgres: `
+    DROP TABLE IF EXISTS audit_log;
+    DROP FUNCTION IF EXISTS audit_log_append_only();
+    `,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "document_stats",
+		// Files ingested before this migration have unknown size and page
+		// counts, which are left at 0; their type is inferred from the name.
+		Up: Script{SQLite: `
+    ALTER TABLE documents ADD COLUMN content_type TEXT NOT NULL DEFAULT 'text/plain';
+    ALTER TABLE documents ADD COLUMN size_bytes BIGINT NOT NULL DEFAULT 0;
+    ALTER TABLE documents ADD COLUMN page_count INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE documents ADD COLUMN chunk_count INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE documents ADD COLUMN status TEXT NOT NULL DEFAULT 'ready' CHECK(status IN ('processing', 'ready', 'failed'));
+
+    UPDATE documents SET content_type = 'application/pdf' WHERE LOWER(file_name) LIKE '%.pdf';
+    UPDATE documents SET chunk_count = (SELECT COUNT(*) FROM document_chunks c WHERE c.document_id = documents.id);
+
+    CREATE INDEX idx_documents_user_uploaded ON documents(user_id, uploaded_at);
+    CREATE INDEX idx_documents_org_uploaded ON documents(org_id, uploaded_at);
+    `},
+		Down: Script{SQLite: `
+    DROP INDEX IF EXISTS idx_documents_org_uploaded;
+    DROP INDEX IF EXISTS idx_documents_user_uploaded;
+    ALTER TABLE documents DROP COLUMN status;
+    ALTER TABLE documents DROP COLUMN chunk_count;
+    ALTER TABLE documents DROP COLUMN page_count;
+    ALTER TABLE documents DROP COLUMN size_bytes;
+    ALTER TABLE documents DROP COLUMN content_type;
+    `},
+	},
+	{
+		Version: 10,
+		Name:    "document_ingestion_details",
+		// warnings is a JSON array of messages about parts of the file that
+		// could not be extracted.
+		Up: Script{
+			SQLite: `
+    ALTER TABLE documents ADD COLUMN warnings TEXT;
+    ALTER TABLE documents ADD COLUMN summary TEXT;
+    ALTER TABLE documents ADD COLUMN ingested_at DATETIME;
+    UPDATE documents SET ingested_at = uploaded_at;
+    `,
+			Postgres: `
+    ALTER TABLE documents ADD COLUMN warnings JSONB;
+    ALTER TABLE documents ADD COLUMN summary TEXT;
+    ALTER TABLE documents ADD COLUMN ingested_at TIMESTAMPTZ;
+    UPDATE documents SET ingested_at = uploaded_at;
+    `,
+		},
+		Down: Script{SQLite: `
+    ALTER TABLE documents DROP COLUMN ingested_at;
+    ALTER TABLE documents DROP COLUMN summary;
+    ALTER TABLE documents DROP COLUMN warnings;
+    `},
+	},
+	{
+		Version: 11,
+		Name:    "chunk_pages",
+		// Chunks stored before this migration have page 0, unknown.
+		Up: Script{SQLite: `
+    ALTER TABLE document_chunks ADD COLUMN page INTEGER NOT NULL DEFAULT 0;
+    `},
+		Down: Script{SQLite: `
+    ALTER TABLE document_chunks DROP COLUMN page;
+    `},
+	},
+	{
+		Version: 12,
+		Name:    "document_pipeline_version",
+		// pipeline_version records which extraction and chunking produced a
+		// document's chunks. Documents ingested before it was tracked have 0.
+		Up: Script{SQLite: `
+    ALTER TABLE documents ADD COLUMN pipeline_version INTEGER NOT NULL DEFAULT 0;
+    CREATE INDEX idx_documents_pipeline_version ON documents(pipeline_version);
+    `},
+		Down: Script{SQLite: `
+    DROP INDEX IF EXISTS idx_documents_pipeline_version;
+    ALTER TABLE documents DROP COLUMN pipeline_version;
+    `},
+	},
+	{
+		Version: 13,
+		Name:    "users_optional_email",
+		// Users signed in without an email address, e.g. with a phone number,
+		// have a NULL email, which UNIQUE allows any number of. SQLite cannot
+		// drop NOT NULL, so the table is rebuilt; foreign keys are not
+		// enforced on the connections the backend opens, so the tables that
+		// reference users are left alone. Rolling back fails while more than
+		// one user has no email, as that could not be stored before.
+		Up: Script{
+			SQLite: `
+    CREATE TABLE users_new (
+        id TEXT PRIMARY KEY,
+        email TEXT UNIQUE,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+    INSERT INTO users_new (id, email, created_at) SELECT id, NULLIF(email, ''), created_at FROM users;
+    DROP TABLE users;
+    ALTER TABLE users_new RENAME TO users;
+    `,
+			Postgres: `
+    ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+    UPDATE users SET email = NULL WHERE email = '';
+    `,
+		},
+		Down: Script{
+			SQLite: `
+    CREATE TABLE users_old (
+        id TEXT PRIMARY KEY,
+        email TEXT UNIQUE NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+    INSERT INTO users_old (id, email, created_at) SELECT id, COALESCE(email, ''), created_at FROM users;
+    DROP TABLE users;
+    ALTER TABLE users_old RENAME TO users;
+    `,
+			Postgres: `
+    UPDATE users SET email = '' WHERE email IS NULL;
+    ALTER TABLE users ALTER COLUMN email SET NOT NULL;
+    `,
+		},
+	},
+}
+
+// Migrations returns a copy of the registered migrations in version order.
+func Migrations() []Migration {
+	out := make([]Migration, len(migrations))
+	copy(out, migrations)
+	return out
+}
+
+func ensureMigrationsTable(db *DB) error {
+	timestampType := "DATETIME"
+	if db.Dialect == Postgres {
+		timestampType = "TIMESTAMPTZ"
+	}
+	_, err := db.Exec(`
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at ` + timestampType + ` DEFAULT CURRENT_TIMESTAMP
+    );`)
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func appliedMigrations(db *DB) (map[int]time.Time, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("could not query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("could not scan schema_migrations row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus reports every known migration and whether it has been applied.
+func MigrationStatus(db *DB) ([]MigrationState, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		states = append(states, MigrationState{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+	return states, nil
+}
+
+// Migrate applies every pending migration in order. Each migration runs in its
+// own transaction together with its schema_migrations bookkeeping row, so a
+// failure leaves the database at the last fully applied version.
+func Migrate(db *DB) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := runMigration(db, m.Up.For(db.Dialect), "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+			return count, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		slog.Info("applied migration", "version", m.Version, "name", m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown rolls back the most recently applied migrations, newest first.
+func MigrateDown(db *DB, steps int) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := runMigration(db, m.Down.For(db.Dialect), "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return count, fmt.Errorf("rollback of migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		slog.Info("rolled back migration", "version", m.Version, "name", m.Name)
+		count++
+	}
+	return count, nil
+}
+
+func runMigration(db *DB, script, bookkeeping string, args ...any) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback on error
+
+	if script != "" {
+		if _, err := tx.Exec(script); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
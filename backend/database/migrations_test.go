@@ -0,0 +1,118 @@
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/database/dbtest"
+)
+
+func TestSQLiteMigrations(t *testing.T) {
+	testMigrations(t, dbtest.SQLiteURL)
+}
+
+// testMigrations runs the migration tests against fresh databases from newURL.
+func testMigrations(t *testing.T, newURL func(testing.TB) string) {
+	t.Run("up and down", func(t *testing.T) { testMigrateUpAndDown(t, open(t, newURL(t))) })
+	t.Run("down steps", func(t *testing.T) { testMigrateDownSteps(t, open(t, newURL(t))) })
+	t.Run("failure", func(t *testing.T) { testFailedMigration(t, open(t, newURL(t))) })
+}
+
+func open(t *testing.T, databaseURL string) *database.DB {
+	t.Helper()
+	db, err := database.Open(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *database.DB, name string) bool {
+	t.Helper()
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	if db.Dialect == database.Postgres {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"
+	}
+	var n int
+	if err := db.QueryRow(query, name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n == 1
+}
+
+func testMigrateUpAndDown(t *testing.T, db *database.DB) {
+	all := len(database.Migrations())
+
+	states, err := database.MigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range states {
+		if s.Applied {
+			t.Errorf("migration %d applied on a fresh database", s.Version)
+		}
+	}
+
+	if n, err := database.Migrate(db); err != nil || n != all {
+		t.Fatalf("Migrate = %d, %v; want %d", n, err, all)
+	}
+	if n, err := database.Migrate(db); err != nil || n != 0 {
+		t.Fatalf("second Migrate = %d, %v; want 0", n, err)
+	}
+	states, _ = database.MigrationStatus(db)
+	for _, s := range states {
+		if !s.Applied || s.AppliedAt.IsZero() {
+			t.Errorf("migration %d not recorded as applied: %+v", s.Version, s)
+		}
+	}
+	if !tableExists(t, db, "documents") {
+		t.Fatal("documents table missing after Migrate")
+	}
+
+	if n, err := database.MigrateDown(db, all+5); err != nil || n != all {
+		t.Fatalf("MigrateDown = %d, %v; want %d", n, err, all)
+	}
+	if tableExists(t, db, "documents") {
+		t.Error("documents table still present after rolling everything back")
+	}
+	if n, err := database.Migrate(db); err != nil || n != all {
+		t.Fatalf("Migrate after rollback = %d, %v; want %d", n, err, all)
+	}
+}
+
+func testMigrateDownSteps(t *testing.T, db *database.DB) {
+	if _, err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := database.MigrateDown(db, 1); err != nil || n != 1 {
+		t.Fatalf("MigrateDown(1) = %d, %v", n, err)
+	}
+	states, _ := database.MigrationStatus(db)
+	for i, s := range states {
+		if want := i < len(states)-1; s.Applied != want {
+			t.Errorf("migration %d applied = %v, want %v", s.Version, s.Applied, want)
+		}
+	}
+}
+
+func testFailedMigration(t *testing.T, db *database.DB) {
+	broken := database.Migration{Version: 1000, Name: "broken", Up: database.Script{SQLite: "CREATE TABLE broken (id TEXT); NOT SQL"}}
+	defer database.SetMigrations(append(database.Migrations(), broken))()
+
+	n, err := database.Migrate(db)
+	if err == nil || !strings.Contains(err.Error(), "migration 1000 (broken)") {
+		t.Fatalf("Migrate error = %v, want the broken migration named", err)
+	}
+	if all := len(database.Migrations()); n != all-1 {
+		t.Errorf("applied %d migrations before the failure, want %d", n, all-1)
+	}
+	if tableExists(t, db, "broken") {
+		t.Error("the failed migration's table was kept")
+	}
+	states, _ := database.MigrationStatus(db)
+	if last := states[len(states)-1]; last.Applied {
+		t.Error("the failed migration was recorded as applied")
+	}
+}
@@ -0,0 +1,39 @@
+//go:build postgres
+
+package database_test
+
+import (
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/database/dbtest"
+)
+
+// The Postgres tests need a running server: start the one in
+// docker-compose.yml, or point TEST_DATABASE_URL at another.
+func TestPostgresMigrations(t *testing.T) {
+	testMigrations(t, dbtest.PostgresURL)
+}
+
+func TestPostgresVector(t *testing.T) {
+	db, err := database.Connect(dbtest.PostgresURL(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if !db.HasVector {
+		t.Fatal("embedding_vector was not created; the compose image ships pgvector")
+	}
+	if _, err := database.MigrateDown(db, len(database.Migrations())-1); err != nil {
+		t.Fatal(err)
+	}
+	if !tableExists(t, db, "documents") {
+		t.Fatal("rolling back to the initial schema dropped documents")
+	}
+	var columns int
+	err = db.QueryRow(`SELECT COUNT(*) FROM information_schema.columns
+        WHERE table_schema = current_schema() AND column_name = 'embedding_vector'`).Scan(&columns)
+	if err != nil || columns != 0 {
+		t.Errorf("embedding_vector columns after rollback = %d, %v; want 0", columns, err)
+	}
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/malharg/strategic-insight-analyst/backend/database")
+
+// startSpan opens a client span for one statement. Only the statement text is
+// recorded, never its arguments, which may hold user content.
+func startSpan(ctx context.Context, dialect Dialect, name, query string) (context.Context, trace.Span) {
+	system := "sqlite"
+	if dialect == Postgres {
+		system = "postgresql"
+	}
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.statement", query),
+		),
+	)
+}
+
+// endSpan records err, if any, and ends span. sql.ErrNoRows is recorded but
+// does not mark the span as failed: a lookup finding nothing is usually an
+// answer, not a fault.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, sql.ErrNoRows) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
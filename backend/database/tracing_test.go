@@ -0,0 +1,121 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database/dbtest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spans collects every span ended in this package's tests.
+var spans = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	os.Exit(m.Run())
+}
+
+func attr(s tracetest.SpanStub, key attribute.Key) string {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestStatementSpans(t *testing.T) {
+	db := open(t, dbtest.SQLiteURL(t))
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE notes (body TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	spans.Reset()
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO notes (body) VALUES (?)", "confidential text"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT body FROM notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if _, err := db.ExecContext(ctx, "INSERT INTO missing VALUES (1)"); err == nil {
+		t.Fatal("insert into a missing table succeeded")
+	}
+
+	ended := spans.GetSpans()
+	if len(ended) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(ended))
+	}
+	for i, want := range []struct{ name, statement string }{
+		{"db.exec", "INSERT INTO notes (body) VALUES (?)"},
+		{"db.query", "SELECT body FROM notes"},
+		{"db.exec", "INSERT INTO missing VALUES (1)"},
+	} {
+		s := ended[i]
+		if s.Name != want.name || attr(s, "db.statement") != want.statement || attr(s, "db.system") != "sqlite" {
+			t.Errorf("span %d = %s %v, want %s %q", i, s.Name, s.Attributes, want.name, want.statement)
+		}
+		// Arguments may be user content and are never recorded.
+		for _, kv := range s.Attributes {
+			if kv.Value.AsString() == "confidential text" {
+				t.Errorf("span %d recorded an argument", i)
+			}
+		}
+	}
+	if ended[0].Status.Code == codes.Error || ended[2].Status.Code != codes.Error || len(ended[2].Events) == 0 {
+		t.Errorf("statuses = %v / %v, want only the failed statement marked as an error", ended[0].Status, ended[2].Status)
+	}
+}
+
+func TestQueryRowSpanEndsAtScan(t *testing.T) {
+	db := open(t, dbtest.SQLiteURL(t))
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE notes (body TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO notes (body) VALUES ('text')"); err != nil {
+		t.Fatal(err)
+	}
+	spans.Reset()
+
+	var body string
+	var n int
+	row := db.QueryRowContext(ctx, "SELECT body FROM notes")
+	if len(spans.GetSpans()) != 0 {
+		t.Fatal("the span ended before the row was scanned")
+	}
+	if err := row.Scan(&body); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT body FROM notes WHERE body = 'other'").Scan(&body); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Scan of no rows = %v", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT body FROM notes").Scan(&n); err == nil {
+		t.Fatal("scanning text into an int succeeded")
+	}
+
+	ended := spans.GetSpans()
+	if len(ended) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(ended))
+	}
+	if ended[0].Status.Code == codes.Error || len(ended[0].Events) != 0 {
+		t.Errorf("found row: status %v, events %v", ended[0].Status, ended[0].Events)
+	}
+	// A missing row is recorded, but is not a failure.
+	if ended[1].Status.Code == codes.Error || len(ended[1].Events) == 0 {
+		t.Errorf("no rows: status %v, events %v; want the error recorded without failing the span", ended[1].Status, ended[1].Events)
+	}
+	if ended[2].Status.Code != codes.Error || len(ended[2].Events) == 0 {
+		t.Errorf("scan error: status %v, want an error", ended[2].Status)
+	}
+}
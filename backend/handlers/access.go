@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// authorizeDocument loads a document if the caller holds at least the required
+// role on it. Otherwise it writes the error response and returns ok=false.
+// Callers with no access at all get a 404 so document IDs are not disclosed.
+func (h *Handler) authorizeDocument(w http.ResponseWriter, r *http.Request, docID string, required store.Role) (doc *store.Document, role store.Role, ok bool) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	email, _ := r.Context().Value(auth.EmailKey).(string)
+
+	role, err := h.Shares.RoleFor(r.Context(), docID, userID, email)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && role == store.RoleNone) {
+		h.Audit.Record(r, audit.ActionAccessDenied, docID, map[string]string{"required": string(required)})
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeDocumentNotFound, "Document not found.")
+		return nil, role, false
+	}
+	if err != nil {
+		logger(r).Error("failed to resolve document access", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find document.")
+		return nil, role, false
+	}
+	if !role.Allows(required) {
+		h.Audit.Record(r, audit.ActionAccessDenied, docID, map[string]string{"required": string(required), "role": string(role)})
+		apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "You need "+string(required)+" access to this document.")
+		return nil, role, false
+	}
+
+	doc, err = h.Documents.Get(r.Context(), docID)
+	if err != nil {
+		logger(r).Error("failed to load document", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find document.")
+		return nil, role, false
+	}
+	return doc, role, true
+}
+
+// authorizeOrg checks that the caller holds at least the required role in the
+// organization. Non-members get a 404, like documents they cannot see.
+func (h *Handler) authorizeOrg(w http.ResponseWriter, r *http.Request, orgID string, required store.OrgRole) (store.OrgRole, bool) {
+	if orgID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Organization ID is required.")
+		return store.OrgRoleNone, false
+	}
+	userID := r.Context().Value(auth.UserIDKey).(string)
+
+	role, err := h.Orgs.MemberRole(r.Context(), orgID, userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && role == store.OrgRoleNone) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeOrganizationNotFound, "Organization not found.")
+		return role, false
+	}
+	if err != nil {
+		logger(r).Error("failed to resolve organization membership", "org_id", orgID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find organization.")
+		return role, false
+	}
+	if !role.Allows(required) {
+		apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "You need "+string(required)+" rights in this organization.")
+		return role, false
+	}
+	return role, true
+}
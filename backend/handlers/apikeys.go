@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+	// Scopes default to read.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type APIKeyInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	// Key is the secret itself. It is only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+func newAPIKeyInfo(k store.APIKey) APIKeyInfo {
+	return APIKeyInfo{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	email, _ := r.Context().Value(auth.EmailKey).(string)
+
+	var req CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "A key name is required.")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{auth.ScopeRead}
+	}
+	if _, err := auth.ValidateScopes(req.Scopes); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	// API keys resolve their owner's email from the users table.
+	if err := h.Users.Ensure(r.Context(), store.User{ID: userID, Email: email}); err != nil {
+		logger(r).Error("failed to upsert user", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user data.")
+		return
+	}
+
+	key, rawKey, err := h.APIKeys.Create(r.Context(), userID, req.Name, req.Scopes)
+	if err != nil {
+		logger(r).Error("failed to create API key", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create API key.")
+		return
+	}
+
+	h.Audit.Record(r, audit.ActionAPIKeyCreate, "", map[string]string{"apiKeyId": key.ID, "scopes": strings.Join(key.Scopes, ",")})
+
+	info := newAPIKeyInfo(*key)
+	info.Key = rawKey
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	keys, err := h.APIKeys.Keys.ListByUser(r.Context(), userID)
+	if err != nil {
+		logger(r).Error("failed to list API keys", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve API keys.")
+		return
+	}
+	infos := make([]APIKeyInfo, 0, len(keys))
+	for _, k := range keys {
+		infos = append(infos, newAPIKeyInfo(k))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	keyID := r.PathValue("id")
+	if keyID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "API key ID is required.")
+		return
+	}
+
+	err := h.APIKeys.Keys.Revoke(r.Context(), keyID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeAPIKeyNotFound, "API key not found or already revoked.")
+		return
+	}
+	if err != nil {
+		logger(r).Error("failed to revoke API key", "api_key_id", keyID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke API key.")
+		return
+	}
+
+	logger(r).Info("API key revoked", "api_key_id", keyID)
+	h.Audit.Record(r, audit.ActionAPIKeyRevoke, "", map[string]string{"apiKeyId": keyID})
+	w.WriteHeader(http.StatusNoContent)
+}
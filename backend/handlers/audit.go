@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+type AuditEventInfo struct {
+	ID         string          `json:"id"`
+	UserID     string          `json:"userId,omitempty"`
+	Action     string          `json:"action"`
+	DocumentID string          `json:"documentId,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"userAgent,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// Limits on how many audit events one request returns.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	maxAuditExport    = 100000
+)
+
+// auditFilter parses ?userId=&documentId=&action=&from=&to=&limit=. from and
+// to accept RFC 3339 timestamps or YYYY-MM-DD dates; a date in to includes
+// that whole day.
+func auditFilter(r *http.Request, defaultLimit, maxLimit int) (store.AuditFilter, string) {
+	q := r.URL.Query()
+	f := store.AuditFilter{
+		UserID:     q.Get("userId"),
+		DocumentID: q.Get("documentId"),
+		Action:     q.Get("action"),
+		Limit:      defaultLimit,
+	}
+	var ok bool
+	if f.From, ok = parseTime(q.Get("from"), false); !ok {
+		return f, "Invalid from; use RFC 3339 or YYYY-MM-DD."
+	}
+	if f.To, ok = parseTime(q.Get("to"), true); !ok {
+		return f, "Invalid to; use RFC 3339 or YYYY-MM-DD."
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return f, "limit must be between 1 and " + strconv.Itoa(maxLimit) + "."
+		}
+		f.Limit = n
+	}
+	return f, ""
+}
+
+// ListAuditEvents returns matching audit events, newest first.
+func (h *Handler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, problem := auditFilter(r, defaultAuditLimit, maxAuditLimit)
+	if problem != "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, problem)
+		return
+	}
+	events, err := h.Audit.Store.List(r.Context(), filter)
+	if err != nil {
+		logger(r).Error("failed to list audit events", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve audit events.")
+		return
+	}
+	infos := make([]AuditEventInfo, 0, len(events))
+	for _, e := range events {
+		info := AuditEventInfo{
+			ID:         e.ID,
+			UserID:     e.UserID,
+			Action:     e.Action,
+			DocumentID: e.DocumentID,
+			IP:         e.IP,
+			UserAgent:  e.UserAgent,
+			CreatedAt:  e.CreatedAt,
+		}
+		if e.Details != "" {
+			info.Details = json.RawMessage(e.Details)
+		}
+		infos = append(infos, info)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// ExportAuditEvents writes matching audit events as CSV. It accepts the same
+// filters as ListAuditEvents with a higher limit.
+func (h *Handler) ExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, problem := auditFilter(r, maxAuditExport, maxAuditExport)
+	if problem != "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, problem)
+		return
+	}
+	events, err := h.Audit.Store.List(r.Context(), filter)
+	if err != nil {
+		logger(r).Error("failed to export audit events", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve audit events.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-`+time.Now().UTC().Format("20060102-150405")+`.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "user_id", "action", "document_id", "ip", "user_agent", "details", "id"})
+	for _, e := range events {
+		cw.Write([]string{e.CreatedAt.UTC().Format(time.RFC3339), csvCell(e.UserID), e.Action, csvCell(e.DocumentID), csvCell(e.IP), csvCell(e.UserAgent), csvCell(e.Details), e.ID})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		logger(r).Error("failed to write audit export", "error", err)
+	}
+}
+
+// csvCell defuses text a spreadsheet would run as a formula. User agents,
+// IDs and details come from callers, so a cell starting with =, +, -, @, tab
+// or carriage return is prefixed with a quote to keep it plain text.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+func TestDownloadDocument(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice", "Revenue grew.")
+
+	w := serve(env.h.DownloadDocument, docRequest(t, http.MethodGet, "/api/v1/documents/doc-1/download", "alice", "doc-1", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "Revenue grew." {
+		t.Fatalf("status %d, body %q", w.Code, w.Body)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename=doc-1.txt` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if w := serve(env.h.DownloadDocument, docRequest(t, http.MethodGet, "/api/v1/documents/doc-1/download", "bob", "doc-1", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("other user: status %d, want 404", w.Code)
+	}
+	if w := serve(env.h.DownloadDocument, request(http.MethodGet, "/api/v1/documents//download", "alice", nil)); w.Code != http.StatusBadRequest {
+		t.Errorf("no ID: status %d, want 400", w.Code)
+	}
+
+	events, _ := env.stores.Audit.List(t.Context(), store.AuditFilter{Action: audit.ActionDownload})
+	if len(events) != 1 || events[0].UserID != "alice" || events[0].DocumentID != doc.ID {
+		t.Errorf("download events = %+v", events)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	env := newTestEnv(t)
+	env.addDocument(t, "doc-1", "alice", "Revenue grew.")
+	serve(env.h.DownloadDocument, docRequest(t, http.MethodGet, "/api/v1/documents/doc-1/download", "alice", "doc-1", nil))
+	serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete?id=doc-1", "alice", nil))
+	env.stores.Audit.Append(t.Context(), store.AuditEvent{ID: "old", UserID: "bob", Action: audit.ActionLoginFailed, CreatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+	w := serve(env.h.ListAuditEvents, request(http.MethodGet, "/api/v1/admin/audit?userId=alice", "admin", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	events := decode[[]AuditEventInfo](t, w)
+	actions := make(map[string]bool)
+	for _, e := range events {
+		actions[e.Action] = true
+		if string(e.Details) != `{"fileName":"doc-1.txt"}` {
+			t.Errorf("%s details = %s", e.Action, e.Details)
+		}
+	}
+	if len(events) != 2 || !actions[audit.ActionDownload] || !actions[audit.ActionDelete] {
+		t.Errorf("alice's events = %+v, want a download and a delete", events)
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/admin/audit?to=2026-01-01":        1,
+		"/api/v1/admin/audit?from=2026-01-02":      2,
+		"/api/v1/admin/audit?limit=1":              1,
+		"/api/v1/admin/audit?action=document.chat": 0,
+	} {
+		if got := len(decode[[]AuditEventInfo](t, serve(env.h.ListAuditEvents, request(http.MethodGet, target, "admin", nil)))); got != want {
+			t.Errorf("%s: %d events, want %d", target, got, want)
+		}
+	}
+	for _, target := range []string{"/api/v1/admin/audit?from=yesterday", "/api/v1/admin/audit?limit=0", "/api/v1/admin/audit?limit=5000"} {
+		if w := serve(env.h.ListAuditEvents, request(http.MethodGet, target, "admin", nil)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, w.Code)
+		}
+	}
+
+	w = serve(env.h.ExportAuditEvents, request(http.MethodGet, "/api/v1/admin/audit/export", "admin", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("export: status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0][0] != "timestamp" || records[3][1] != "bob" || records[3][7] != "old" {
+		t.Errorf("export = %q, want a header and three events, oldest last", records)
+	}
+}
+
+func TestExportAuditEventsDefusesFormulas(t *testing.T) {
+	env := newTestEnv(t)
+	env.stores.Audit.Append(t.Context(), store.AuditEvent{ID: "e1", UserID: "alice", Action: audit.ActionLoginFailed, UserAgent: `=HYPERLINK("https://evil.example.com")`, CreatedAt: time.Now()})
+	env.stores.Audit.Append(t.Context(), store.AuditEvent{ID: "e2", UserID: "@bob", Action: audit.ActionLoginFailed, UserAgent: "curl/8.0", CreatedAt: time.Now().Add(-time.Minute)})
+
+	w := serve(env.h.ExportAuditEvents, request(http.MethodGet, "/api/v1/admin/audit/export", "admin", nil))
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][5] != `'=HYPERLINK("https://evil.example.com")` || records[2][1] != "'@bob" || records[2][5] != "curl/8.0" {
+		t.Errorf("export = %q", records)
+	}
+
+	for in, want := range map[string]string{"": "", "plain": "plain", "+1": "'+1", "-2": "'-2", "\tx": "'\tx", "\rx": "'\rx", "a=b": "a=b"} {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,85 +1,129 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
-	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
 	"github.com/malharg/strategic-insight-analyst/backend/auth"
-	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/retrieval"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
 )
 
 // Make sure the struct definition has the correct json tags.
 type ChatRequest struct {
-	DocumentID string `json:"documentId"`
+	// DocumentID is only read on the deprecated /api/chat route; v1 takes it
+	// from the path.
+	DocumentID string `json:"documentId,omitempty"`
 	Query      string `json:"query"`
+	// ConversationID continues an earlier exchange. A new one is started
+	// when it is empty.
+	ConversationID string `json:"conversationId,omitempty"`
 }
 
-func ChatHandler(w http.ResponseWriter, r *http.Request) {
+type ChatResponse struct {
+	Response       string `json:"response"`
+	ConversationID string `json:"conversationId"`
+}
+
+func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 	// 1. Get user ID from the authentication middleware.
 	userID := r.Context().Value(auth.UserIDKey).(string)
 
 	// 2. Decode the incoming JSON request from the frontend.
 	var req ChatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
+	// The v1 route names the document in the path rather than the body.
+	if id := r.PathValue("id"); id != "" {
+		req.DocumentID = id
+	}
 
 	// 3. Add a debug log to confirm we received the data correctly.
-	log.Printf("DEBUG: Chat request received for docID: [%s], query: [%s]", req.DocumentID, req.Query)
+	logger(r).Debug("chat request received", "document_id", req.DocumentID, logging.KeyQuery, req.Query)
 
 	// 4. Check if the document ID is empty. If so, it's a client error.
 	if req.DocumentID == "" {
-		log.Println("ERROR: Received chat request with empty document ID.")
-		http.Error(w, "Document ID is required.", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Document ID is required.")
+		return
+	}
+
+	// 5. Make sure the caller may chat with this document.
+	doc, _, ok := h.authorizeDocument(w, r, req.DocumentID, store.RoleViewer)
+	if !ok {
+		return
+	}
+	// Only ready documents have chunks to answer from.
+	switch doc.Status {
+	case store.DocumentProcessing:
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Document is still being processed.")
+		return
+	case store.DocumentFailed:
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Text could not be extracted from this document; re-ingest it to try again.")
+		return
+	}
+	// Chat history references the user, who may never have uploaded anything.
+	email, _ := r.Context().Value(auth.EmailKey).(string)
+	if err := h.Users.Ensure(r.Context(), store.User{ID: userID, Email: email}); err != nil {
+		logger(r).Error("failed to upsert user", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user data.")
 		return
 	}
 
-	// 5. Generate the insight using our AI service.
-	aiResponse, err := ai.GenerateInsight(r.Context(), req.DocumentID, req.Query)
+	// 6. Load the document's chunks and generate the insight using our AI service.
+	chunks, err := h.Chunks.ListByDocument(r.Context(), req.DocumentID)
 	if err != nil {
-		log.Printf("Error generating insight: %v", err)
-		http.Error(w, "Failed to generate AI insight.", http.StatusInternalServerError)
+		logger(r).Error("failed to load chunks", "document_id", req.DocumentID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate AI insight.")
 		return
 	}
+	contents := retrieval.Select(req.Query, chunkContents(chunks), h.Config.AI.ContextChunks)
+	logger(r).Debug("selected chunks", "document_id", req.DocumentID, "chunks", len(chunks), "selected", len(contents))
 
-	// 6. Respond to the frontend first. This makes the UI feel faster.
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"response": aiResponse})
+	aiResponse, usage, err := h.AI.GenerateInsight(r.Context(), contents, req.Query)
+	if err != nil {
+		logger(r).Error("failed to generate insight", "document_id", req.DocumentID, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeLLMUnavailable, "Failed to generate AI insight.")
+		return
+	}
 
-	// 7. After responding, save the interaction to chat history in the background.
-	// This is a "fire-and-forget" operation. If it fails, it doesn't break the user experience.
-	go func() {
-		tx, err := database.DB.Begin()
-		if err != nil {
-			log.Printf("Failed to begin transaction for chat history: %v", err)
-			return
-		}
-		defer tx.Rollback() // Rollback on error
+	if req.ConversationID == "" {
+		req.ConversationID = uuid.New().String()
+	}
+	h.Audit.Record(r, audit.ActionChat, req.DocumentID, map[string]string{"conversationId": req.ConversationID})
+	h.recordUsage(r.Context(), store.LLMUsage{
+		UserID:         userID,
+		DocumentID:     req.DocumentID,
+		ConversationID: req.ConversationID,
+		Operation:      store.OperationGenerate,
+	}, usage)
 
-		// Save user message
-		_, err = tx.Exec("INSERT INTO chat_history (id, document_id, user_id, message_type, message_content) VALUES (?, ?, ?, ?, ?)",
-			uuid.New().String(), req.DocumentID, userID, "user", req.Query)
-		if err != nil {
-			log.Printf("Failed to save user message to chat history: %v", err)
-			return
-		}
+	// 7. Respond to the frontend first. This makes the UI feel faster.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChatResponse{Response: aiResponse, ConversationID: req.ConversationID})
+	http.NewResponseController(w).Flush()
 
-		// Save AI response
-		_, err = tx.Exec("INSERT INTO chat_history (id, document_id, user_id, message_type, message_content) VALUES (?, ?, ?, ?, ?)",
-			uuid.New().String(), req.DocumentID, userID, "ai", aiResponse)
-		if err != nil {
-			log.Printf("Failed to save AI response to chat history: %v", err)
-			return
-		}
+	// 8. Then save the interaction to chat history. If it fails, it doesn't
+	// break the user experience. The request is not finished until it is
+	// saved, so shutdown waits for it before the database is closed, and a
+	// client hanging up does not cancel it.
+	ctx := context.WithoutCancel(r.Context())
+	if err := h.Chats.SaveExchange(ctx, req.DocumentID, userID, req.ConversationID, req.Query, aiResponse); err != nil {
+		logger(r).Error("failed to save chat history", "error", err)
+	} else {
+		logger(r).Debug("saved chat history")
+	}
+}
 
-		if err := tx.Commit(); err != nil {
-			log.Printf("Failed to commit chat history transaction: %v", err)
-		} else {
-			log.Printf("Successfully saved chat history for docID: %s", req.DocumentID)
-		}
-	}()
+func chunkContents(chunks []store.Chunk) []string {
+	contents := make([]string, len(chunks))
+	for i, c := range chunks {
+		contents[i] = c.Content
+	}
+	return contents
 }
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/retrieval"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// Limits on how many chunks one page returns.
+const (
+	defaultChunkLimit = 50
+	maxChunkLimit     = 200
+)
+
+// previewLength is how many characters of each chunk the retrieval preview
+// shows.
+const previewLength = 200
+
+type ChunkInfo struct {
+	Index int `json:"index"`
+	// Page is the 1-based page the chunk starts on, or 0 for plain text and
+	// documents ingested before pages were recorded.
+	Page int `json:"page"`
+	// Length is in characters.
+	Length   int    `json:"length"`
+	Embedded bool   `json:"embedded"`
+	Content  string `json:"content"`
+}
+
+// ChunkPage is one page of a document's chunks.
+type ChunkPage struct {
+	Chunks []ChunkInfo `json:"chunks"`
+	Total  int         `json:"total"`
+	// NextCursor fetches the following page; it is omitted on the last one.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ListChunks pages through a document's chunks in order, for checking what
+// extraction and chunking produced. The cursor is the index of the last
+// chunk seen.
+func (h *Handler) ListChunks(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	query := r.URL.Query()
+	limit := defaultChunkLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxChunkLimit {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and "+strconv.Itoa(maxChunkLimit)+".")
+			return
+		}
+		limit = n
+	}
+	after := -1
+	if v := query.Get("cursor"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid cursor.")
+			return
+		}
+		after = n
+	}
+
+	doc, _, ok := h.authorizeDocument(w, r, docID, store.RoleViewer)
+	if !ok {
+		return
+	}
+	// One extra row tells whether there is a next page.
+	chunks, err := h.Chunks.ListAfter(r.Context(), docID, after, limit+1)
+	if err != nil {
+		logger(r).Error("failed to load chunks", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve chunks.")
+		return
+	}
+
+	page := ChunkPage{Chunks: make([]ChunkInfo, 0, min(len(chunks), limit)), Total: doc.ChunkCount}
+	if len(chunks) > limit {
+		chunks = chunks[:limit]
+		page.NextCursor = strconv.Itoa(chunks[limit-1].Index)
+	}
+	for _, c := range chunks {
+		page.Chunks = append(page.Chunks, ChunkInfo{
+			Index:    c.Index,
+			Page:     c.Page,
+			Length:   utf8.RuneCountInString(c.Content),
+			Embedded: c.Embedded,
+			Content:  c.Content,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+type RetrievalRequest struct {
+	Query string `json:"query"`
+}
+
+// RetrievedChunk is a chunk's relevance to the previewed query.
+type RetrievedChunk struct {
+	Index    int     `json:"index"`
+	Page     int     `json:"page"`
+	Length   int     `json:"length"`
+	Score    float64 `json:"score"`
+	Rank     int     `json:"rank"`
+	Selected bool    `json:"selected"`
+	// Preview is the start of the chunk's text.
+	Preview string `json:"preview"`
+}
+
+// RetrievalPreview shows which chunks chat would send with a question.
+type RetrievalPreview struct {
+	Query string `json:"query"`
+	// ContextChunks is the configured cap on chunks sent; 0 sends every
+	// chunk.
+	ContextChunks int `json:"contextChunks"`
+	Selected      int `json:"selected"`
+	// Chunks are ordered best first.
+	Chunks []RetrievedChunk `json:"chunks"`
+}
+
+// PreviewRetrieval scores every chunk of a document against a query the way
+// chat does, without calling the model, so a missing answer can be traced
+// to extraction or to retrieval.
+func (h *Handler) PreviewRetrieval(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	var req RetrievalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Query is required.")
+		return
+	}
+	if _, _, ok := h.authorizeDocument(w, r, docID, store.RoleViewer); !ok {
+		return
+	}
+	chunks, err := h.Chunks.ListByDocument(r.Context(), docID)
+	if err != nil {
+		logger(r).Error("failed to load chunks", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve chunks.")
+		return
+	}
+
+	preview := RetrievalPreview{Query: req.Query, ContextChunks: h.Config.AI.ContextChunks, Chunks: make([]RetrievedChunk, 0, len(chunks))}
+	for _, res := range retrieval.Rank(req.Query, chunkContents(chunks), h.Config.AI.ContextChunks) {
+		c := chunks[res.Index]
+		text := []rune(c.Content)
+		if len(text) > previewLength {
+			text = text[:previewLength]
+		}
+		if res.Selected {
+			preview.Selected++
+		}
+		preview.Chunks = append(preview.Chunks, RetrievedChunk{
+			Index:    c.Index,
+			Page:     c.Page,
+			Length:   utf8.RuneCountInString(c.Content),
+			Score:    res.Score,
+			Rank:     res.Rank,
+			Selected: res.Selected,
+			Preview:  string(text),
+		})
+	}
+	sort.Slice(preview.Chunks, func(i, j int) bool { return preview.Chunks[i].Rank < preview.Chunks[j].Rank })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+)
+
+// docRequest builds a request for a route with the document id in its path.
+func docRequest(t *testing.T, method, target, userID, docID string, body any) *http.Request {
+	t.Helper()
+	var r *http.Request
+	if body == nil {
+		r = request(method, target, userID, nil)
+	} else {
+		r = request(method, target, userID, jsonBody(t, body))
+	}
+	r.SetPathValue("id", docID)
+	return r
+}
+
+// withPath sets the path values a route pattern would have matched, given
+// as name, value pairs.
+func withPath(r *http.Request, pairs ...string) *http.Request {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		r.SetPathValue(pairs[i], pairs[i+1])
+	}
+	return r
+}
+
+func TestListChunks(t *testing.T) {
+	env := newTestEnv(t)
+	env.addDocument(t, "doc-1", "alice", "Revenue grew.", "Costs fell.", "Héadcount was flat.")
+
+	w := serve(env.h.ListChunks, docRequest(t, http.MethodGet, "/api/v1/documents/doc-1/chunks?limit=2", "alice", "doc-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	page := decode[ChunkPage](t, w)
+	if page.Total != 3 || len(page.Chunks) != 2 || page.NextCursor != "1" {
+		t.Fatalf("first page = %+v", page)
+	}
+	if c := page.Chunks[1]; c.Index != 1 || c.Page != 2 || c.Length != 11 || c.Content != "Costs fell." || c.Embedded {
+		t.Errorf("chunk = %+v", c)
+	}
+
+	w = serve(env.h.ListChunks, docRequest(t, http.MethodGet, "/api/v1/documents/doc-1/chunks?limit=2&cursor="+page.NextCursor, "alice", "doc-1", nil))
+	page = decode[ChunkPage](t, w)
+	if len(page.Chunks) != 1 || page.NextCursor != "" || page.Chunks[0].Index != 2 {
+		t.Errorf("last page = %+v", page)
+	}
+	// Length counts characters, not bytes.
+	if page.Chunks[0].Length != 19 {
+		t.Errorf("length = %d, want 19", page.Chunks[0].Length)
+	}
+
+	for _, tt := range []struct {
+		name, target, userID string
+		status               int
+		code                 apierror.Code
+	}{
+		{"limit too large", "/api/v1/documents/doc-1/chunks?limit=201", "alice", http.StatusBadRequest, apierror.CodeInvalidRequest},
+		{"limit zero", "/api/v1/documents/doc-1/chunks?limit=0", "alice", http.StatusBadRequest, apierror.CodeInvalidRequest},
+		{"bad cursor", "/api/v1/documents/doc-1/chunks?cursor=abc", "alice", http.StatusBadRequest, apierror.CodeInvalidRequest},
+		{"other user", "/api/v1/documents/doc-1/chunks", "bob", http.StatusNotFound, apierror.CodeDocumentNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(env.h.ListChunks, docRequest(t, http.MethodGet, tt.target, tt.userID, "doc-1", nil))
+			if w.Code != tt.status || errorCode(w) != tt.code {
+				t.Errorf("status %d, code %q; want %d, %q", w.Code, errorCode(w), tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestPreviewRetrieval(t *testing.T) {
+	env := newTestEnv(t)
+	env.h.Config.AI.ContextChunks = 1
+	env.addDocument(t, "doc-1", "alice", "The board met.", "Revenue grew "+strings.Repeat("strongly ", 40), "Costs fell.")
+
+	w := serve(env.h.PreviewRetrieval, docRequest(t, http.MethodPost, "/api/v1/documents/doc-1/retrieval", "alice", "doc-1", RetrievalRequest{Query: " revenue "}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	preview := decode[RetrievalPreview](t, w)
+	if preview.Query != "revenue" || preview.ContextChunks != 1 || preview.Selected != 1 || len(preview.Chunks) != 3 {
+		t.Fatalf("preview = %+v", preview)
+	}
+	best := preview.Chunks[0]
+	if best.Index != 1 || best.Rank != 1 || !best.Selected || best.Score <= 0 || best.Page != 2 {
+		t.Errorf("best chunk = %+v", best)
+	}
+	if len([]rune(best.Preview)) != previewLength || best.Length <= previewLength {
+		t.Errorf("preview of a long chunk has %d characters, chunk %d", len([]rune(best.Preview)), best.Length)
+	}
+	for i, c := range preview.Chunks[1:] {
+		if c.Rank != i+2 || c.Selected || c.Score != 0 {
+			t.Errorf("chunk ranked %d = %+v", i+2, c)
+		}
+	}
+
+	w = serve(env.h.PreviewRetrieval, docRequest(t, http.MethodPost, "/api/v1/documents/doc-1/retrieval", "alice", "doc-1", RetrievalRequest{Query: "  "}))
+	if w.Code != http.StatusBadRequest || errorCode(w) != apierror.CodeInvalidRequest {
+		t.Errorf("empty query: status %d, code %q", w.Code, errorCode(w))
+	}
+	w = serve(env.h.PreviewRetrieval, docRequest(t, http.MethodPost, "/api/v1/documents/doc-1/retrieval", "bob", "doc-1", RetrievalRequest{Query: "revenue"}))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("other user: status %d, want 404", w.Code)
+	}
+}
@@ -1,308 +1,699 @@
 package handlers
 
 import (
-	"bytes"
-	"database/sql"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
 	"github.com/malharg/strategic-insight-analyst/backend/auth"
-	"github.com/malharg/strategic-insight-analyst/backend/config"
-	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/ingest"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/metrics"
 	"github.com/malharg/strategic-insight-analyst/backend/processing"
-	storage_go "github.com/supabase-community/storage-go"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
 )
 
-const supabaseBucketName = "documents"
+// uploadMemoryBytes is how much of a multipart upload is held in memory.
+const uploadMemoryBytes = 10 << 20
 
-func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UploadDocument(w http.ResponseWriter, r *http.Request) {
 	// --- Step 1 & 2: Auth and File Parsing (No changes needed here) ---
 	userID := r.Context().Value(auth.UserIDKey).(string)
-	userRecord, err := auth.AuthClient.GetUser(r.Context(), userID)
-	if err != nil {
-		log.Printf("Failed to get user record from Firebase: %v", err)
-		http.Error(w, "Could not verify user.", http.StatusInternalServerError)
-		return
-	}
-	userSQL := "INSERT OR IGNORE INTO users (id, email) VALUES (?, ?)"
-	if _, err = database.DB.Exec(userSQL, userID, userRecord.Email); err != nil {
-		log.Printf("Failed to upsert user: %v", err)
-		http.Error(w, "Failed to save user data.", http.StatusInternalServerError)
+	email, _ := r.Context().Value(auth.EmailKey).(string)
+	if err := h.Users.Ensure(r.Context(), store.User{ID: userID, Email: email}); err != nil {
+		logger(r).Error("failed to upsert user", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user data.")
 		return
 	}
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "File is too large.", http.StatusBadRequest)
+	// The body itself is capped by the route's MaxBytesReader; files beyond
+	// the in-memory budget spill to temporary files.
+	if err := r.ParseMultipartForm(uploadMemoryBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeFileTooLarge, "File is too large.")
+			return
+		}
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid upload form.")
 		return
 	}
 	file, header, err := r.FormFile("document")
 	if err != nil {
-		http.Error(w, "Invalid file key 'document'.", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid file key 'document'.")
 		return
 	}
 	defer file.Close()
 
+	// Refuse files we cannot extract text from before storing anything. PDF
+	// extraction needs a UniDoc license.
+	if !processing.Supported(header.Filename) {
+		apierror.Write(w, r, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedFileType, "Only .txt and .pdf files are supported.")
+		return
+	}
+	if strings.EqualFold(filepath.Ext(header.Filename), ".pdf") && h.Config.UnidocLicenseKey == "" {
+		apierror.Write(w, r, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedFileType, "PDF uploads are not enabled on this server.")
+		return
+	}
+
+	// Documents uploaded into a workspace belong to the organization and
+	// optionally to one of its collections.
+	orgID := r.FormValue("orgId")
+	collectionID := r.FormValue("collectionId")
+	if orgID == "" && collectionID != "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "A collection requires an organization.")
+		return
+	}
+	if orgID != "" {
+		if _, ok := h.authorizeOrg(w, r, orgID, store.OrgRoleMember); !ok {
+			return
+		}
+		if collectionID != "" {
+			c, err := h.Orgs.GetCollection(r.Context(), collectionID)
+			if errors.Is(err, store.ErrNotFound) || (err == nil && c.OrgID != orgID) {
+				apierror.Write(w, r, http.StatusNotFound, apierror.CodeCollectionNotFound, "Collection not found.")
+				return
+			}
+			if err != nil {
+				logger(r).Error("failed to load collection", "collection_id", collectionID, "error", err)
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find collection.")
+				return
+			}
+		}
+	}
+
 	fileBytes, err := io.ReadAll(file)
 	if err != nil {
-		http.Error(w, "Could not read file.", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Could not read file.")
 		return
 	}
 
-	// --- Step 3: Direct HTTP Upload to Supabase (Your working fix, no changes) ---
+	// --- Step 3: Upload the original file to object storage ---
 	docID := uuid.New().String()
 	storagePath := fmt.Sprintf("%s/%s/%s", userID, docID, filepath.Base(header.Filename))
 	contentType := http.DetectContentType(fileBytes)
-	uploadURL := fmt.Sprintf("%s/storage/v1/object/%s/%s", config.AppConfig.SupabaseURL, supabaseBucketName, storagePath)
 
-	req, err := http.NewRequest("POST", uploadURL, bytes.NewReader(fileBytes))
-	if err != nil {
-		http.Error(w, "Failed to create upload request.", http.StatusInternalServerError)
+	stageStart := time.Now()
+	if err := h.Storage.Upload(r.Context(), storagePath, contentType, fileBytes); err != nil {
+		logger(r).Error("storage upload failed", "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStorageUnavailable, "Failed to upload file to cloud storage.")
+		return
+	}
+	metrics.ObserveStage(metrics.StageStorageUpload, stageStart)
+
+	logger(r).Info("file stored", "document_id", docID, "path", storagePath)
+
+	// =========================================================================
+	// START OF NEW PROCESSING & DATABASE LOGIC
+	// =========================================================================
+
+	// --- Step 4: Record the document as processing, so it is listed while
+	// ingestion runs and is marked failed if extraction does not succeed ---
+	doc := store.Document{
+		ID:           docID,
+		UserID:       userID,
+		FileName:     header.Filename,
+		StoragePath:  storagePath,
+		OrgID:        orgID,
+		CollectionID: collectionID,
+		ContentType:  processing.ContentType(header.Filename),
+		SizeBytes:    int64(len(fileBytes)),
+		Status:       store.DocumentProcessing,
+	}
+	if err := h.Documents.Create(r.Context(), doc, nil); err != nil {
+		logger(r).Error("failed to save document", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save document.")
 		return
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.AppConfig.SupabaseSvcKey))
-	req.Header.Set("Content-Type", contentType)
 
-	resp, err := http.DefaultClient.Do(req)
+	// --- Step 5: Extract text content from the file and chunk it ---
+	result, err := ingest.Process(r.Context(), fileBytes, header.Filename, h.Config.Chunking, h.embedderFor(r, userID))
 	if err != nil {
-		http.Error(w, "Failed to upload file to cloud storage.", http.StatusInternalServerError)
+		logger(r).Error("text extraction failed", "document_id", docID, "error", err)
+		if err := h.Documents.SetFailed(context.WithoutCancel(r.Context()), docID, "Text extraction failed: "+err.Error()); err != nil {
+			logger(r).Error("failed to mark document failed", "document_id", docID, "error", err)
+		}
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeExtractionFailed, "File uploaded, but failed to extract text content.")
 		return
 	}
-	defer resp.Body.Close()
+	extraction, chunks := result.Extraction, result.Chunks
+	h.followup().RecordEmbedding(r.Context(), userID, docID, result)
+	logger(r).Debug("processed file", "document_id", docID, "chars", len(extraction.Text), "pages", extraction.Pages, "chunks", len(chunks))
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		log.Printf("Direct upload failed: Status=%s, Body=%s", resp.Status, string(respBody))
-		http.Error(w, "Failed to upload file to cloud storage.", http.StatusInternalServerError)
+	if len(chunks) == 0 {
+		logger(r).Warn("no chunks generated from document", "document_id", docID)
+	}
+
+	// --- Step 6: Save all chunks and mark the document ready in a single database transaction ---
+	doc.PageCount = extraction.Pages
+	doc.Warnings = extraction.Warnings
+	doc.PipelineVersion = ingest.PipelineVersion
+	stageStart = time.Now()
+	if err := h.Documents.ReplaceChunks(r.Context(), doc, chunks); err != nil {
+		logger(r).Error("failed to save document", "document_id", docID, "error", err)
+		if err := h.Documents.SetFailed(context.WithoutCancel(r.Context()), docID, "Saving the extracted text failed."); err != nil {
+			logger(r).Error("failed to mark document failed", "document_id", docID, "error", err)
+		}
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save document.")
 		return
 	}
+	metrics.ObserveStage(metrics.StageSave, stageStart)
 
-	log.Printf("File %s uploaded successfully to path: %s", header.Filename, storagePath)
+	logger(r).Info("document saved", "document_id", docID, "chunks", len(chunks))
+
+	if err := h.Quotas.Charge(r.Context(), userID, limits.MetricUploads, 1); err != nil {
+		logger(r).Error("failed to charge upload quota", "error", err)
+	}
+	h.Audit.Record(r, audit.ActionUpload, docID, map[string]string{"fileName": header.Filename, "orgId": orgID})
+	h.summarize(r, docID, userID, chunkContents(chunks))
 
 	// =========================================================================
-	// START OF NEW PROCESSING & DATABASE LOGIC
+	// END OF NEW PROCESSING & DATABASE LOGIC
 	// =========================================================================
 
-	// --- Step 4: Extract text content from the file ---
-	textContent, err := processing.ExtractTextFromFile(fileBytes, header.Filename)
-	if err != nil {
-		log.Printf("ERROR during text extraction: %v", err)
-		http.Error(w, "File uploaded, but failed to extract text content.", http.StatusInternalServerError)
-		return
-	}
-	log.Printf("DEBUG: Extracted ~%d characters of text.", len(textContent))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(UploadResult{ID: docID, FileName: header.Filename, Chunks: len(chunks), Warnings: extraction.Warnings})
+}
 
-	// --- Step 5: Chunk the extracted text ---
-	textChunks := processing.ChunkText(textContent)
-	log.Printf("DEBUG: Document split into %d chunks.", len(textChunks))
+// UploadResult describes a document that was just ingested.
+type UploadResult struct {
+	ID       string   `json:"id"`
+	FileName string   `json:"fileName"`
+	Chunks   int      `json:"chunks"`
+	Warnings []string `json:"warnings,omitempty"`
+}
 
-	if len(textChunks) == 0 {
-		log.Println("WARN: No chunks were generated from the document. Nothing to save to chunks table.")
+// embedderFor returns the embedder for chunks ingested on userID's behalf,
+// or nil when embeddings are off or userID's token quota is used up. The
+// chunks are then saved without embeddings.
+func (h *Handler) embedderFor(r *http.Request, userID string) ai.Embedder {
+	if h.Embedder == nil {
+		return nil
 	}
+	if _, err := h.Quotas.Check(r.Context(), userID, limits.MetricLLMTokens); err != nil {
+		logger(r).Info("not embedding chunks", "reason", err)
+		return nil
+	}
+	return h.Embedder
+}
 
-	// --- Step 6: Save document metadata and all chunks in a single database transaction ---
-	ctx := r.Context()
-	tx, err := database.DB.BeginTx(ctx, nil)
-	if err != nil {
-		log.Printf("ERROR: Failed to begin database transaction: %v", err)
-		http.Error(w, "Failed to start database transaction.", http.StatusInternalServerError)
+// followup records the model calls of an ingestion as userID's usage and
+// regenerates the document's summary, charging the caller's quota.
+func (h *Handler) followup() *ingest.Followup {
+	return &ingest.Followup{AI: h.AI, Documents: h.Documents, Usage: h.Usage, Quotas: h.Quotas, SummaryChunks: h.Config.AI.SummaryChunks}
+}
+
+// summarize writes a short summary of a new or re-ingested document in the
+// background from its first chunks. The model call is charged to userID and
+// skipped once their token quota is used up. A failure only leaves the
+// summary empty.
+func (h *Handler) summarize(r *http.Request, docID, userID string, chunks []string) {
+	if h.Config.AI.SummaryChunks == 0 || len(chunks) == 0 {
 		return
 	}
-	defer tx.Rollback() // Ensures rollback on any error path
+	log := logger(r).With("document_id", docID)
+	h.Tasks.Go("summarize document", func(ctx context.Context) {
+		err := h.followup().Summarize(ctx, userID, docID, chunks)
+		switch {
+		case errors.Is(err, limits.ErrQuotaExceeded):
+			log.Info("not summarizing document", "reason", err)
+		case err != nil:
+			log.Warn("failed to summarize document", "error", err)
+		}
+	})
+}
 
-	// Insert the parent document record
-	sqlDoc := "INSERT INTO documents (id, user_id, file_name, storage_path) VALUES (?, ?, ?, ?)"
-	if _, err := tx.ExecContext(ctx, sqlDoc, docID, userID, header.Filename, storagePath); err != nil {
-		log.Printf("ERROR: Failed to insert document metadata in transaction: %v", err)
-		http.Error(w, "Failed to save document metadata.", http.StatusInternalServerError)
-		return
+type DocumentInfo struct {
+	ID           string    `json:"id"`
+	FileName     string    `json:"fileName"`
+	UploadedAt   time.Time `json:"uploadedAt"`
+	OrgID        string    `json:"orgId,omitempty"`
+	CollectionID string    `json:"collectionId,omitempty"`
+	ContentType  string    `json:"contentType"`
+	SizeBytes    int64     `json:"sizeBytes"`
+	// PageCount is 0 for plain text and for files ingested before it was
+	// recorded.
+	PageCount  int    `json:"pageCount"`
+	ChunkCount int    `json:"chunkCount"`
+	Status     string `json:"status"`
+}
+
+func documentInfo(doc *store.Document) DocumentInfo {
+	return DocumentInfo{
+		ID:           doc.ID,
+		FileName:     doc.FileName,
+		UploadedAt:   doc.UploadedAt,
+		OrgID:        doc.OrgID,
+		CollectionID: doc.CollectionID,
+		ContentType:  doc.ContentType,
+		SizeBytes:    doc.SizeBytes,
+		PageCount:    doc.PageCount,
+		ChunkCount:   doc.ChunkCount,
+		Status:       string(doc.Status),
 	}
-	log.Printf("DEBUG: Inserted document record with ID: %s", docID)
+}
 
-	// Prepare the statement for inserting chunks for efficiency
-	stmt, err := tx.PrepareContext(ctx, "INSERT INTO document_chunks (id, document_id, chunk_index, content) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		log.Printf("ERROR: Failed to prepare chunk insert statement: %v", err)
-		http.Error(w, "Failed to prepare for saving document content.", http.StatusInternalServerError)
-		return
+// DocumentPage is one page of the document list.
+type DocumentPage struct {
+	Documents []DocumentInfo `json:"documents"`
+	// Total counts every document matching the filters.
+	Total int `json:"total"`
+	// NextCursor fetches the following page; it is omitted on the last one.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Limits on how many documents one page returns.
+const (
+	defaultDocumentLimit = 50
+	maxDocumentLimit     = 200
+)
+
+// documentTypes maps the ?type= shorthands onto stored content types.
+var documentTypes = map[string]string{
+	"pdf": "application/pdf",
+	"txt": "text/plain",
+}
+
+// documentQuery parses the list filters, sort and page. Names sort
+// A-Z by default, dates and sizes newest and largest first.
+func documentQuery(r *http.Request) (store.DocumentQuery, string) {
+	query := r.URL.Query()
+	q := store.DocumentQuery{
+		OrgID:        query.Get("orgId"),
+		CollectionID: query.Get("collectionId"),
+		Tag:          strings.ToLower(strings.TrimSpace(query.Get("tag"))),
+		Sort:         store.SortByUploadedAt,
+		Limit:        defaultDocumentLimit,
 	}
-	defer stmt.Close()
 
-	var chunksInserted int
-	for i, chunk := range textChunks {
-		chunkID := uuid.New().String()
-		// Use the prepared statement to insert each chunk
-		if _, err := stmt.ExecContext(ctx, chunkID, docID, i, chunk); err != nil {
-			log.Printf("ERROR: Failed to insert chunk %d for docID %s: %v", i, docID, err)
-			http.Error(w, "Failed to save document content chunks.", http.StatusInternalServerError)
-			return // This will trigger the deferred tx.Rollback()
+	if v := query.Get("sort"); v != "" {
+		q.Sort = v
+	}
+	switch q.Sort {
+	case store.SortByName:
+	case store.SortByUploadedAt, store.SortBySize:
+		q.Desc = true
+	default:
+		return q, "sort must be name, uploadedAt or size."
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return q, "order must be asc or desc."
+	}
+
+	if v := query.Get("type"); v != "" {
+		if q.ContentType = documentTypes[strings.ToLower(v)]; q.ContentType == "" {
+			return q, "type must be pdf or txt."
 		}
-		chunksInserted++
+	}
+	switch status := store.DocumentStatus(query.Get("status")); status {
+	case "", store.DocumentProcessing, store.DocumentReady, store.DocumentFailed:
+		q.Status = status
+	default:
+		return q, "status must be processing, ready or failed."
+	}
+	var ok bool
+	if q.From, ok = parseTime(query.Get("from"), false); !ok {
+		return q, "Invalid from; use RFC 3339 or YYYY-MM-DD."
+	}
+	if q.To, ok = parseTime(query.Get("to"), true); !ok {
+		return q, "Invalid to; use RFC 3339 or YYYY-MM-DD."
 	}
 
-	// If we reach here, all inserts were successful. Commit the transaction.
-	if err := tx.Commit(); err != nil {
-		log.Printf("ERROR: Failed to commit transaction: %v", err)
-		http.Error(w, "Failed to finalize saving document.", http.StatusInternalServerError)
-		return
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDocumentLimit {
+			return q, "limit must be between 1 and " + strconv.Itoa(maxDocumentLimit) + "."
+		}
+		q.Limit = n
 	}
+	if v := query.Get("cursor"); v != "" {
+		if q.After = decodeCursor(v, q); q.After == "" {
+			return q, "Invalid cursor; it must come from a request with the same sort and order."
+		}
+	}
+	return q, ""
+}
 
-	log.Printf("SUCCESS: Committed transaction. Saved document %s and %d chunks to DB.", docID, chunksInserted)
+// A cursor names the last document of a page along with the sort it was
+// taken under, since it means nothing under another one.
+func encodeCursor(q store.DocumentQuery, lastID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix(q) + lastID))
+}
 
-	// =========================================================================
-	// END OF NEW PROCESSING & DATABASE LOGIC
-	// =========================================================================
+func decodeCursor(cursor string, q store.DocumentQuery) string {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ""
+	}
+	id, ok := strings.CutPrefix(string(raw), cursorPrefix(q))
+	if !ok {
+		return ""
+	}
+	return id
+}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("File uploaded and processed successfully!"))
+func cursorPrefix(q store.DocumentQuery) string {
+	return fmt.Sprintf("%s:%t:", q.Sort, q.Desc)
 }
 
-type DocumentInfo struct {
-	ID         string    `json:"id"`
-	FileName   string    `json:"fileName"`
-	UploadedAt time.Time `json:"uploadedAt"`
+// ListDocuments returns a page of the caller's personal documents, or of a
+// workspace's documents when ?orgId= (and optionally ?collectionId=) is
+// given, filtered by ?type=, ?tag=, ?status=, ?from= and ?to= and sorted by
+// ?sort= and ?order=. Pass the returned nextCursor as ?cursor= for the next
+// page.
+func (h *Handler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	q, problem := documentQuery(r)
+	if problem != "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, problem)
+		return
+	}
+	// One extra row tells whether there is a next page.
+	limit := q.Limit
+	q.Limit++
+	page, ok := h.listDocuments(w, r, q)
+	if !ok {
+		return
+	}
+	q.Limit = limit
+
+	more := len(page.Documents) > limit
+	if more {
+		page.Documents = page.Documents[:limit]
+	}
+	documents := make([]DocumentInfo, 0, len(page.Documents))
+	for i := range page.Documents {
+		documents = append(documents, documentInfo(&page.Documents[i]))
+	}
+	resp := DocumentPage{Documents: documents, Total: page.Total}
+	if more {
+		resp.NextCursor = encodeCursor(q, documents[len(documents)-1].ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
-func ListDocumentsHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(auth.UserIDKey).(string)
-	rows, err := database.DB.Query("SELECT id, file_name, uploaded_at FROM documents WHERE user_id = ? ORDER BY uploaded_at DESC", userID)
-	if err != nil {
-		http.Error(w, "Failed to retrieve documents.", http.StatusInternalServerError)
+// ListAllDocuments serves the deprecated /api/documents, which returns every
+// document, newest first, as a bare array.
+func (h *Handler) ListAllDocuments(w http.ResponseWriter, r *http.Request) {
+	q := store.DocumentQuery{
+		OrgID:        r.URL.Query().Get("orgId"),
+		CollectionID: r.URL.Query().Get("collectionId"),
+		Sort:         store.SortByUploadedAt,
+		Desc:         true,
+	}
+	page, ok := h.listDocuments(w, r, q)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-	documents := make([]DocumentInfo, 0)
-	for rows.Next() {
-		var doc DocumentInfo
-		if err := rows.Scan(&doc.ID, &doc.FileName, &doc.UploadedAt); err != nil {
-			http.Error(w, "Failed to process document list.", http.StatusInternalServerError)
-			return
-		}
-		documents = append(documents, doc)
+	documents := make([]DocumentInfo, 0, len(page.Documents))
+	for i := range page.Documents {
+		documents = append(documents, documentInfo(&page.Documents[i]))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(documents)
 }
 
-// Add a test endpoint for Supabase connectivity
-func TestSupabaseUploadHandler(w http.ResponseWriter, r *http.Request) {
-	storageClient := storage_go.NewClient(config.AppConfig.SupabaseURL, config.AppConfig.SupabaseSvcKey, nil)
-	testContent := []byte("test upload from TestSupabaseUploadHandler")
-	testPath := "test/test-upload.txt"
-	contentType := "text/plain"
-	_, err := storageClient.UploadFile(supabaseBucketName, testPath, bytes.NewReader(testContent), storage_go.FileOptions{
-		ContentType: &contentType,
-	})
+// listDocuments checks workspace membership and runs q for the caller.
+func (h *Handler) listDocuments(w http.ResponseWriter, r *http.Request, q store.DocumentQuery) (*store.DocumentPage, bool) {
+	q.UserID = r.Context().Value(auth.UserIDKey).(string)
+	if q.OrgID != "" {
+		if _, ok := h.authorizeOrg(w, r, q.OrgID, store.OrgRoleMember); !ok {
+			return nil, false
+		}
+	}
+	page, err := h.Documents.List(r.Context(), q)
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "The cursor's document no longer exists; start again from the first page.")
+		return nil, false
+	}
 	if err != nil {
-		log.Printf("[TestSupabaseUploadHandler] Failed: %#v", err)
-		if se, ok := err.(*storage_go.StorageError); ok {
-			log.Printf("[TestSupabaseUploadHandler] Supabase StorageError: Status=%d, Message=%s", se.Status, se.Message)
-			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(w, "Supabase StorageError: Status=%d, Message=%s", se.Status, se.Message)
+		logger(r).Error("failed to list documents", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve documents.")
+		return nil, false
+	}
+	return page, true
+}
+
+// DocumentDetail is one document with its tags, the caller's role on it and
+// what ingestion found.
+type DocumentDetail struct {
+	DocumentInfo
+	Tags []string `json:"tags"`
+	Role string   `json:"role"`
+	// Warnings list parts of the file that could not be extracted and so
+	// cannot be asked about.
+	Warnings []string `json:"warnings"`
+	// Summary is empty until it has been generated, shortly after upload.
+	Summary    string     `json:"summary,omitempty"`
+	IngestedAt *time.Time `json:"ingestedAt"`
+	// PipelineVersion identifies the extraction and chunking that produced
+	// the chunks; 0 means it predates versioning. Documents below the
+	// server's version can be re-ingested.
+	PipelineVersion int `json:"pipelineVersion"`
+}
+
+// UpdateDocumentRequest changes the fields that are set and leaves the rest.
+type UpdateDocumentRequest struct {
+	FileName *string   `json:"fileName"`
+	Tags     *[]string `json:"tags"`
+}
+
+const maxFileNameLength = 255
+
+// GetDocument returns a document the caller can view.
+func (h *Handler) GetDocument(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	doc, role, ok := h.authorizeDocument(w, r, docID, store.RoleViewer)
+	if !ok {
+		return
+	}
+	h.writeDocument(w, r, doc, role)
+}
+
+// UpdateDocument renames and/or re-tags a document. Editors and owners may
+// update.
+func (h *Handler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	var req UpdateDocumentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var name string
+	if req.FileName != nil {
+		name = strings.TrimSpace(*req.FileName)
+		if name == "" || len(name) > maxFileNameLength {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "File name must be 1-255 characters.")
+			return
+		}
+	}
+	var tags []string
+	if req.Tags != nil {
+		var ok bool
+		if tags, ok = normalizeTags(*req.Tags); !ok {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Too many tags or tag too long.")
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Upload error: %v", err)
+	}
+
+	doc, role, ok := h.authorizeDocument(w, r, docID, store.RoleEditor)
+	if !ok {
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Test upload to Supabase succeeded."))
+	if req.FileName != nil && name != doc.FileName {
+		if err := h.Documents.Rename(r.Context(), docID, name); err != nil {
+			logger(r).Error("failed to rename document", "document_id", docID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update document.")
+			return
+		}
+		h.Audit.Record(r, audit.ActionRename, docID, map[string]string{"from": doc.FileName, "to": name})
+		doc.FileName = name
+	}
+	if req.Tags != nil {
+		if err := h.Documents.SetTags(r.Context(), docID, tags); err != nil {
+			logger(r).Error("failed to set tags", "document_id", docID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update document.")
+			return
+		}
+	}
+	h.writeDocument(w, r, doc, role)
 }
 
-// Minimal upload to Supabase Storage using net/http (no SDK)
-func MinimalSupabaseUploadHandler(w http.ResponseWriter, r *http.Request) {
-	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", config.AppConfig.SupabaseURL, supabaseBucketName, "test/minimal-upload.txt")
-	content := []byte("minimal upload test")
-	req, err := http.NewRequest("POST", url, bytes.NewReader(content))
+func (h *Handler) writeDocument(w http.ResponseWriter, r *http.Request, doc *store.Document, role store.Role) {
+	tags, err := h.Documents.Tags(r.Context(), doc.ID)
 	if err != nil {
-		log.Printf("[MinimalSupabaseUploadHandler] Failed to create request: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Failed to create request: %v", err)
+		logger(r).Error("failed to load tags", "document_id", doc.ID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve document.")
 		return
 	}
-	// Set headers
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.AppConfig.SupabaseSvcKey))
-	req.Header.Set("Content-Type", "text/plain")
+	// Clients can rely on warnings being a list.
+	warnings := doc.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DocumentDetail{
+		DocumentInfo:    documentInfo(doc),
+		Tags:            tags,
+		Role:            string(role),
+		Warnings:        warnings,
+		Summary:         doc.Summary,
+		IngestedAt:      doc.IngestedAt,
+		PipelineVersion: doc.PipelineVersion,
+	})
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Printf("[MinimalSupabaseUploadHandler] Request error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "Request error: %v", err)
+func (h *Handler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	// 1. Get the document ID from the request path, e.g.
+	// DELETE /api/v1/documents/some-uuid, or from ?id= on the deprecated
+	// /api/documents/delete.
+	docID := r.PathValue("id")
+	if docID == "" {
+		docID = r.URL.Query().Get("id")
+	}
+	if docID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Document ID is required.")
 		return
 	}
-	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
-	log.Printf("[MinimalSupabaseUploadHandler] Status: %s, Body: %s", resp.Status, string(respBody))
-	w.WriteHeader(resp.StatusCode)
-	fmt.Fprintf(w, "Status: %s\nBody: %s", resp.Status, string(respBody))
-}
 
-func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
-	// 1. Get user ID from context.
-	userID := r.Context().Value(auth.UserIDKey).(string)
+	logger(r).Debug("deleting document", "document_id", docID)
 
-	// 2. Get the document ID from the request. We'll pass it as a query parameter.
-	// e.g., /api/documents/delete?id=some-uuid
-	docID := r.URL.Query().Get("id")
-	if docID == "" {
-		http.Error(w, "Document ID is required.", http.StatusBadRequest)
+	// 2. Find the document in the DB to get its storage_path and verify ownership.
+	doc, _, ok := h.authorizeDocument(w, r, docID, store.RoleOwner)
+	if !ok {
 		return
 	}
+	storagePath := doc.StoragePath
+
+	// 3. Delete the file from object storage.
+	if err := h.Storage.Delete(r.Context(), storagePath); err != nil {
+		// Log the error but continue to try deleting from DB.
+		logger(r).Warn("failed to delete file from storage; deleting database record anyway", "document_id", docID, "error", err)
+	} else {
+		logger(r).Debug("deleted file from storage", "path", storagePath)
+	}
 
-	log.Printf("Attempting to delete document ID: %s for user: %s", docID, userID)
+	// 4. Delete the document record from our database.
 
-	// 3. Find the document in the DB to get its storage_path and verify ownership.
-	var storagePath string
-	err := database.DB.QueryRow("SELECT storage_path FROM documents WHERE id = ? AND user_id = ?", docID, userID).Scan(&storagePath)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Document not found or you do not have permission to delete it.", http.StatusNotFound)
-			return
-		}
-		log.Printf("Error finding document to delete: %v", err)
-		http.Error(w, "Failed to find document.", http.StatusInternalServerError)
+	if err := h.Documents.Delete(r.Context(), docID); err != nil {
+		logger(r).Error("failed to delete document", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete document metadata.")
 		return
 	}
 
-	// 4. Delete the file from Supabase storage (using your direct HTTP method)
-	deleteURL := fmt.Sprintf("%s/storage/v1/object/%s/%s", config.AppConfig.SupabaseURL, supabaseBucketName, storagePath)
-	req, err := http.NewRequest("DELETE", deleteURL, nil)
-	if err != nil {
-		http.Error(w, "Failed to create delete request.", http.StatusInternalServerError)
+	logger(r).Info("document deleted", "document_id", docID)
+	h.Audit.Record(r, audit.ActionDelete, docID, map[string]string{"fileName": doc.FileName})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DownloadDocument streams the original uploaded file to anyone who can view
+// the document.
+func (h *Handler) DownloadDocument(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	if docID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Document ID is required.")
+		return
+	}
+	doc, _, ok := h.authorizeDocument(w, r, docID, store.RoleViewer)
+	if !ok {
 		return
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.AppConfig.SupabaseSvcKey))
 
-	resp, err := http.DefaultClient.Do(req)
+	data, err := h.Storage.Download(r.Context(), doc.StoragePath)
 	if err != nil {
-		// Log the error but continue to try deleting from DB.
-		log.Printf("Error deleting file from storage, but continuing to delete DB record. Error: %v", err)
-	} else {
-		defer resp.Body.Close()
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			respBody, _ := io.ReadAll(resp.Body)
-			log.Printf("Storage delete failed with status %s: %s", resp.Status, string(respBody))
-		} else {
-			log.Printf("Successfully deleted file from storage: %s", storagePath)
-		}
+		logger(r).Error("storage download failed", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStorageUnavailable, "Failed to download file.")
+		return
 	}
+	h.Audit.Record(r, audit.ActionDownload, docID, map[string]string{"fileName": doc.FileName})
 
-	// 5. Delete the document record from our database.
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
 
-	_, err = database.DB.Exec("DELETE FROM documents WHERE id = ?", docID)
-	if err != nil {
-		log.Printf("Failed to delete document from database: %v", err)
-		http.Error(w, "Failed to delete document metadata.", http.StatusInternalServerError)
+// ReingestResult describes a document that was run through the current
+// pipeline again.
+type ReingestResult struct {
+	ID              string `json:"id"`
+	Chunks          int    `json:"chunks"`
+	PreviousChunks  int    `json:"previousChunks"`
+	PipelineVersion int    `json:"pipelineVersion"`
+	// Embedded is false when the chunks were saved without embeddings,
+	// because none are configured or embedding failed.
+	Embedded bool     `json:"embedded"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ReingestDocument re-extracts, re-chunks and re-embeds a document from its
+// stored original, so improvements to the pipeline reach files uploaded
+// before them. The new chunks replace the old ones atomically. The summary
+// is then regenerated, charged to the caller.
+func (h *Handler) ReingestDocument(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	doc, _, ok := h.authorizeDocument(w, r, docID, store.RoleEditor)
+	if !ok {
+		return
+	}
+	if strings.EqualFold(filepath.Ext(doc.StoragePath), ".pdf") && h.Config.UnidocLicenseKey == "" {
+		apierror.Write(w, r, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedFileType, "PDF processing is not enabled on this server.")
 		return
 	}
 
-	log.Printf("Successfully deleted document record from DB: %s", docID)
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Document deleted successfully."))
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	result, err := ingest.Reingest(r.Context(), h.Storage, h.Documents, h.Config.Chunking, h.embedderFor(r, userID), doc)
+	switch {
+	case errors.Is(err, ingest.ErrDownload):
+		logger(r).Error("storage download failed", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStorageUnavailable, "Failed to download file.")
+		return
+	case errors.Is(err, ingest.ErrExtract):
+		logger(r).Error("text extraction failed", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeExtractionFailed, "Failed to extract text content.")
+		return
+	case errors.Is(err, store.ErrNotFound):
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeDocumentNotFound, "Document not found.")
+		return
+	case err != nil:
+		logger(r).Error("failed to save re-ingested document", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save document.")
+		return
+	}
+
+	h.followup().RecordEmbedding(r.Context(), userID, docID, result)
+	logger(r).Info("document re-ingested", "document_id", docID, "chunks", len(result.Chunks), "previous_chunks", doc.ChunkCount)
+	h.Audit.Record(r, audit.ActionReingest, docID, map[string]string{
+		"fromVersion": strconv.Itoa(doc.PipelineVersion),
+		"toVersion":   strconv.Itoa(ingest.PipelineVersion),
+	})
+	h.summarize(r, docID, userID, chunkContents(result.Chunks))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReingestResult{
+		ID:              docID,
+		Chunks:          len(result.Chunks),
+		PreviousChunks:  doc.ChunkCount,
+		PipelineVersion: ingest.PipelineVersion,
+		Embedded:        result.Embedded,
+		Warnings:        result.Extraction.Warnings,
+	})
 }
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/background"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/storage"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// Handler carries the dependencies shared by the HTTP handlers. Construct it
+// once and register its methods on the router.
+type Handler struct {
+	Config    *config.Config
+	Users     store.UserStore
+	Documents store.DocumentStore
+	Chunks    store.ChunkStore
+	Chats     store.ChatStore
+	Shares    store.ShareStore
+	Orgs      store.OrgStore
+	Usage     store.UsageStore
+	Storage   storage.Storage
+	AI        ai.Provider
+	// Embedder embeds chunks at ingestion; nil stores them without
+	// embeddings.
+	Embedder ai.Embedder
+	APIKeys  *auth.APIKeys
+	Quotas   *limits.Quotas
+	Audit    *audit.Logger
+	// Tasks runs work that continues after the response is sent.
+	Tasks *background.Group
+}
+
+// logger returns the request-scoped logger, tagged with the request ID, route
+// and caller.
+func logger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context())
+}
+
+// decodeJSON reads the JSON request body into v. It answers 413 when the body
+// is over the route's size limit and 400 when it is malformed, and reports
+// whether decoding succeeded.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &tooLarge):
+		apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, "Request body too large")
+	default:
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+	}
+	return false
+}
+
+// parseTime parses an RFC 3339 timestamp or a YYYY-MM-DD date. A date ending
+// a range (end) means the start of the next day, so the range includes it.
+// An empty value is the zero time.
+func parseTime(v string, end bool) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
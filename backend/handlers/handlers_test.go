@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/background"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/ingest"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+	"github.com/malharg/strategic-insight-analyst/backend/store/storetest"
+)
+
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *memStorage) Upload(ctx context.Context, path, contentType string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = data
+	return nil
+}
+
+func (s *memStorage) Download(ctx context.Context, path string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[path]
+	if !ok {
+		return nil, errors.New("no such file")
+	}
+	return data, nil
+}
+
+func (s *memStorage) Delete(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, path)
+	return nil
+}
+
+func (s *memStorage) has(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[path]
+	return ok
+}
+
+// fakeAI answers with the number of chunks it was given and embeds each
+// text as its length.
+type fakeAI struct{}
+
+func (fakeAI) GenerateInsight(ctx context.Context, chunks []string, query string) (string, ai.Usage, error) {
+	return fmt.Sprintf("answer from %d chunks", len(chunks)), ai.Usage{Model: "gemini-1.5-flash", PromptTokens: 1000, ResponseTokens: 100}, nil
+}
+
+func (fakeAI) Embed(ctx context.Context, texts []string) ([][]float32, ai.Usage, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, t := range texts {
+		embeddings[i] = []float32{float32(len(t))}
+	}
+	return embeddings, ai.Usage{Model: "text-embedding-004", PromptTokens: 10}, nil
+}
+
+type testEnv struct {
+	h      *Handler
+	stores *store.Stores
+	files  *memStorage
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	stores := storetest.New()
+	files := &memStorage{files: make(map[string][]byte)}
+	cfg := config.Default()
+	cfg.UnidocLicenseKey = "test"
+	h := &Handler{
+		Config:    cfg,
+		Users:     stores.Users,
+		Documents: stores.Documents,
+		Chunks:    stores.Chunks,
+		Chats:     stores.Chats,
+		Shares:    stores.Shares,
+		Orgs:      stores.Orgs,
+		Usage:     stores.Usage,
+		Storage:   files,
+		AI:        fakeAI{},
+		Embedder:  fakeAI{},
+		Quotas:    limits.NewQuotas(stores.Quotas, cfg.Limits),
+		Audit:     audit.NewLogger(stores.Audit, ""),
+		Tasks:     background.NewGroup(),
+	}
+	return &testEnv{h: h, stores: stores, files: files}
+}
+
+func (env *testEnv) addDocument(t *testing.T, id, userID string, chunks ...string) store.Document {
+	t.Helper()
+	ctx := context.Background()
+	if err := env.stores.Users.Ensure(ctx, store.User{ID: userID, Email: userID + "@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	doc := store.Document{ID: id, UserID: userID, FileName: id + ".txt", StoragePath: userID + "/" + id + ".txt"}
+	saved := make([]store.Chunk, len(chunks))
+	for i, c := range chunks {
+		saved[i] = store.Chunk{Content: c, Page: i + 1}
+	}
+	if err := env.stores.Documents.Create(ctx, doc, saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.files.Upload(ctx, doc.StoragePath, "text/plain", []byte(strings.Join(chunks, " "))); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// documents returns userID's personal documents, newest first.
+func (env *testEnv) documents(t *testing.T, userID string) []store.Document {
+	t.Helper()
+	page, err := env.stores.Documents.List(context.Background(), store.DocumentQuery{UserID: userID, Sort: store.SortByUploadedAt, Desc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page.Documents
+}
+
+// request builds a request authenticated as userID, whose email is
+// userID@example.com.
+func request(method, target, userID string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	ctx := context.WithValue(r.Context(), auth.UserIDKey, userID)
+	ctx = context.WithValue(ctx, auth.EmailKey, userID+"@example.com")
+	return r.WithContext(ctx)
+}
+
+func jsonBody(t *testing.T, v any) io.Reader {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(b)
+}
+
+// uploadRequest builds a multipart upload of one file as userID.
+func uploadRequest(t *testing.T, userID, fileName, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("document", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, content)
+	mw.Close()
+	r := request(http.MethodPost, "/api/documents/upload", userID, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func serve(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// errorCode returns the code from an error envelope, or "" if the body is not
+// one.
+func errorCode(w *httptest.ResponseRecorder) apierror.Code {
+	var body apierror.Response
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return body.Error.Code
+}
+
+func TestUploadDocument(t *testing.T) {
+	env := newTestEnv(t)
+	w := serve(env.h.UploadDocument, uploadRequest(t, "alice", "q3.txt", strings.Repeat("Revenue grew 20% in Q3. ", 200)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	docs := env.documents(t, "alice")
+	if len(docs) != 1 || docs[0].FileName != "q3.txt" {
+		t.Fatalf("saved documents = %+v", docs)
+	}
+	doc := docs[0]
+	if doc.ContentType != "text/plain" || doc.SizeBytes != int64(len("Revenue grew 20% in Q3. ")*200) || doc.ChunkCount < 2 || doc.Status != store.DocumentReady {
+		t.Errorf("document stats = %+v", doc)
+	}
+	if !strings.HasPrefix(doc.StoragePath, "alice/"+doc.ID+"/") || !env.files.has(doc.StoragePath) {
+		t.Errorf("original not stored at %q", doc.StoragePath)
+	}
+	if chunks, _ := env.stores.Chunks.ListByDocument(context.Background(), doc.ID); len(chunks) < 2 || !chunks[0].Embedded {
+		t.Errorf("saved chunks = %+v, want the text split into several embedded ones", chunks)
+	}
+	env.h.Tasks.Shutdown(context.Background())
+	ops := map[string]int{}
+	for _, u := range storetest.Usage(env.stores) {
+		ops[u.Operation]++
+	}
+	if ops[store.OperationEmbed] != 1 || ops[store.OperationGenerate] != 1 {
+		t.Errorf("recorded usage %v, want one embed and one summary call", ops)
+	}
+	if u, err := env.stores.Users.Get(context.Background(), "alice"); err != nil || u.Email != "alice@example.com" {
+		t.Errorf("uploader not recorded: %+v, %v", u, err)
+	}
+}
+
+// countingEmbedder is fakeAI counting its Embed calls.
+type countingEmbedder struct {
+	fakeAI
+	calls int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, ai.Usage, error) {
+	e.calls++
+	return e.fakeAI.Embed(ctx, texts)
+}
+
+func TestUploadSkipsEmbeddingOverQuota(t *testing.T) {
+	env := newTestEnv(t)
+	embedder := &countingEmbedder{}
+	env.h.Embedder = embedder
+	if err := env.h.Quotas.Charge(t.Context(), "alice", limits.MetricLLMTokens, env.h.Config.Limits.LLMTokensDaily); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(env.h.UploadDocument, uploadRequest(t, "alice", "q3.txt", "Revenue grew."))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	env.h.Tasks.Shutdown(t.Context())
+	if embedder.calls != 0 {
+		t.Errorf("embedder called %d times over quota", embedder.calls)
+	}
+	if chunks, _ := env.stores.Chunks.ListByDocument(t.Context(), decode[UploadResult](t, w).ID); len(chunks) != 1 || chunks[0].Embedded {
+		t.Errorf("saved chunks = %+v, want one without an embedding", chunks)
+	}
+}
+
+func TestUploadRejectsUnsupportedFiles(t *testing.T) {
+	env := newTestEnv(t)
+	w := serve(env.h.UploadDocument, uploadRequest(t, "alice", "slides.pptx", "not text"))
+	if w.Code != http.StatusUnsupportedMediaType || errorCode(w) != apierror.CodeUnsupportedFileType {
+		t.Fatalf("status %d, code %q; want 415 %s", w.Code, errorCode(w), apierror.CodeUnsupportedFileType)
+	}
+	if docs := env.documents(t, "alice"); len(docs) != 0 {
+		t.Errorf("saved %d documents for an unsupported file", len(docs))
+	}
+}
+
+func TestUploadMarksFailedExtraction(t *testing.T) {
+	env := newTestEnv(t)
+	w := serve(env.h.UploadDocument, uploadRequest(t, "alice", "broken.pdf", "%PDF-1.4 not really a PDF"))
+	if w.Code != http.StatusInternalServerError || errorCode(w) != apierror.CodeExtractionFailed {
+		t.Fatalf("status %d, code %q; want 500 %s", w.Code, errorCode(w), apierror.CodeExtractionFailed)
+	}
+	docs := env.documents(t, "alice")
+	if len(docs) != 1 || docs[0].Status != store.DocumentFailed || len(docs[0].Warnings) != 1 || docs[0].IngestedAt != nil {
+		t.Fatalf("saved documents = %+v, want one failed document", docs)
+	}
+}
+
+func TestListDocumentsShowsOnlyOwnDocuments(t *testing.T) {
+	env := newTestEnv(t)
+	env.addDocument(t, "mine", "alice")
+	env.addDocument(t, "theirs", "bob")
+
+	w := serve(env.h.ListDocuments, request(http.MethodGet, "/api/v1/documents", "alice", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var page DocumentPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || len(page.Documents) != 1 || page.Documents[0].ID != "mine" || page.Documents[0].FileName != "mine.txt" || page.NextCursor != "" {
+		t.Errorf("page = %+v, want only alice's document", page)
+	}
+
+	w = serve(env.h.ListDocuments, request(http.MethodGet, "/api/v1/documents", "carol", nil))
+	if body := strings.TrimSpace(w.Body.String()); body != `{"documents":[],"total":0}` {
+		t.Errorf("empty page = %s", body)
+	}
+
+	// The deprecated list keeps its bare array.
+	w = serve(env.h.ListAllDocuments, request(http.MethodGet, "/api/documents", "alice", nil))
+	var docs []DocumentInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].ID != "mine" {
+		t.Errorf("documents = %+v, want only alice's", docs)
+	}
+	w = serve(env.h.ListAllDocuments, request(http.MethodGet, "/api/documents", "carol", nil))
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Errorf("empty list = %s, want []", body)
+	}
+}
+
+func TestListDocumentsPages(t *testing.T) {
+	env := newTestEnv(t)
+	for _, id := range []string{"c", "a", "e", "b", "d"} {
+		env.addDocument(t, id, "alice")
+	}
+	env.stores.Documents.SetTags(context.Background(), "b", []string{"q3"})
+
+	list := func(query string) (int, DocumentPage) {
+		w := serve(env.h.ListDocuments, request(http.MethodGet, "/api/v1/documents?"+query, "alice", nil))
+		var page DocumentPage
+		json.Unmarshal(w.Body.Bytes(), &page)
+		return w.Code, page
+	}
+
+	var names []string
+	query := "sort=name&limit=2"
+	for pages := 0; ; pages++ {
+		code, page := list(query)
+		if code != http.StatusOK || page.Total != 5 {
+			t.Fatalf("%s: status %d, page %+v", query, code, page)
+		}
+		for _, d := range page.Documents {
+			names = append(names, d.FileName)
+		}
+		if page.NextCursor == "" {
+			if pages != 2 {
+				t.Errorf("%d pages, want 3", pages+1)
+			}
+			break
+		}
+		query = "sort=name&limit=2&cursor=" + page.NextCursor
+	}
+	if want := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"}; !slices.Equal(names, want) {
+		t.Errorf("paged names = %v, want %v", names, want)
+	}
+
+	if _, page := list("sort=name&order=desc&limit=1"); len(page.Documents) != 1 || page.Documents[0].ID != "e" {
+		t.Errorf("last by name = %+v", page.Documents)
+	}
+	if _, page := list("tag=Q3"); page.Total != 1 || page.Documents[0].ID != "b" {
+		t.Errorf("tagged = %+v", page)
+	}
+	if _, page := list("type=pdf"); page.Total != 0 {
+		t.Errorf("PDFs = %+v, want none", page)
+	}
+
+	_, first := list("sort=name&limit=1")
+	for _, query := range []string{
+		"sort=colour",
+		"order=sideways",
+		"type=docx",
+		"status=lost",
+		"from=yesterday",
+		"limit=0",
+		"limit=201",
+		"cursor=not-a-cursor",
+		// A cursor only continues the sort it came from.
+		"sort=size&limit=1&cursor=" + first.NextCursor,
+	} {
+		if code, _ := list(query); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, code)
+		}
+	}
+
+	// A cursor whose document was deleted cannot be continued.
+	env.stores.Documents.Delete(context.Background(), "a")
+	if code, _ := list("sort=name&limit=1&cursor=" + first.NextCursor); code != http.StatusBadRequest {
+		t.Errorf("cursor of a deleted document: status %d, want 400", code)
+	}
+}
+
+func TestDeleteDocument(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice", "chunk")
+
+	w := serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete?id=doc-1", "bob", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("deleting another user's document: status %d, want 404", w.Code)
+	}
+	w = serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete", "alice", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("delete without an id: status %d, want 400", w.Code)
+	}
+
+	w = serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete?id=doc-1", "alice", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if _, err := env.stores.Documents.Get(context.Background(), doc.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("document still stored: %v", err)
+	}
+	if chunks, _ := env.stores.Chunks.ListByDocument(context.Background(), doc.ID); len(chunks) != 0 {
+		t.Errorf("%d chunks left", len(chunks))
+	}
+	if env.files.has(doc.StoragePath) {
+		t.Error("original file left in storage")
+	}
+}
+
+func TestReingestDocument(t *testing.T) {
+	env := newTestEnv(t)
+	env.addDocument(t, "doc-1", "alice", "Revenue grew.", "Costs fell.")
+	env.h.Documents.SetSummary(context.Background(), "doc-1", "Stale summary.")
+
+	w := serve(env.h.ReingestDocument, docRequest(t, http.MethodPost, "/api/v1/documents/doc-1/reingest", "alice", "doc-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	res := decode[ReingestResult](t, w)
+	// The stored original is the chunks joined, short enough for one chunk.
+	if res.ID != "doc-1" || res.Chunks != 1 || res.PreviousChunks != 2 || res.PipelineVersion != ingest.PipelineVersion || !res.Embedded {
+		t.Errorf("result = %+v", res)
+	}
+	env.h.Tasks.Shutdown(context.Background())
+	doc, _ := env.stores.Documents.Get(context.Background(), "doc-1")
+	if doc.ChunkCount != 1 || doc.PipelineVersion != ingest.PipelineVersion || doc.Summary != "answer from 1 chunks" {
+		t.Errorf("document after re-ingest = %+v", doc)
+	}
+	if events, _ := env.stores.Audit.List(context.Background(), store.AuditFilter{Action: audit.ActionReingest}); len(events) != 1 || events[0].DocumentID != "doc-1" {
+		t.Errorf("reingest audit events = %+v", events)
+	}
+
+	env.share(t, "doc-1", "bob", store.RoleViewer)
+	w = serve(env.h.ReingestDocument, docRequest(t, http.MethodPost, "/api/v1/documents/doc-1/reingest", "bob", "doc-1", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("viewer: status %d, want 403", w.Code)
+	}
+	w = serve(env.h.ReingestDocument, docRequest(t, http.MethodPost, "/api/v1/documents/doc-1/reingest", "carol", "doc-1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("stranger: status %d, want 404", w.Code)
+	}
+
+	env.files.Delete(context.Background(), doc.StoragePath)
+	w = serve(env.h.ReingestDocument, docRequest(t, http.MethodPost, "/api/v1/documents/doc-1/reingest", "alice", "doc-1", nil))
+	if w.Code != http.StatusBadGateway || errorCode(w) != apierror.CodeStorageUnavailable {
+		t.Errorf("missing original: status %d, code %q", w.Code, errorCode(w))
+	}
+}
+
+func TestChat(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice", "Revenue grew.", "Costs fell.")
+
+	w := serve(env.h.Chat, request(http.MethodPost, "/api/chat", "alice", jsonBody(t, ChatRequest{DocumentID: doc.ID, Query: "How did we do?"})))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct{ Response string }
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Response != "answer from 2 chunks" {
+		t.Errorf("response = %q", resp.Response)
+	}
+
+	if msgs := storetest.Messages(env.stores); len(msgs) != 2 || msgs[0].Content != "How did we do?" || msgs[1].Content != "answer from 2 chunks" {
+		t.Errorf("saved messages = %+v", msgs)
+	}
+}
+
+func TestChatSendsOnlyContextChunks(t *testing.T) {
+	env := newTestEnv(t)
+	env.h.Config.AI.ContextChunks = 1
+	doc := env.addDocument(t, "doc-1", "alice", "Revenue grew.", "Costs fell.", "Headcount was flat.")
+
+	w := serve(env.h.Chat, request(http.MethodPost, "/api/chat", "alice", jsonBody(t, ChatRequest{DocumentID: doc.ID, Query: "What happened to revenue?"})))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct{ Response string }
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Response != "answer from 1 chunks" {
+		t.Errorf("response = %q", resp.Response)
+	}
+	env.h.Tasks.Shutdown(context.Background())
+}
+
+func TestChatRefusesDocumentsNotReady(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	for _, status := range []store.DocumentStatus{store.DocumentProcessing, store.DocumentFailed} {
+		doc := store.Document{ID: "doc-" + string(status), UserID: "alice", FileName: "a.pdf", StoragePath: "alice/a.pdf", Status: status}
+		if err := env.stores.Documents.Create(ctx, doc, nil); err != nil {
+			t.Fatal(err)
+		}
+		w := serve(env.h.Chat, request(http.MethodPost, "/api/chat", "alice", jsonBody(t, ChatRequest{DocumentID: doc.ID, Query: "What happened?"})))
+		if w.Code != http.StatusConflict || errorCode(w) != apierror.CodeConflict {
+			t.Errorf("%s: status %d, code %q; want 409 %s", status, w.Code, errorCode(w), apierror.CodeConflict)
+		}
+	}
+}
+
+func TestChatRejectsBadRequests(t *testing.T) {
+	env := newTestEnv(t)
+	for name, body := range map[string]string{
+		"not JSON":       "not an object",
+		"no document id": `{"query": "What happened?"}`,
+	} {
+		w := serve(env.h.Chat, request(http.MethodPost, "/api/chat", "alice", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"name":"ci"}`, http.StatusOK},
+		{`{"name":`, http.StatusBadRequest},
+		{`{"name":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Body = http.MaxBytesReader(w, io.NopCloser(strings.NewReader(tt.body)), 32)
+		var v struct{ Name string }
+		if decodeJSON(w, r, &v) {
+			w.WriteHeader(http.StatusOK)
+		}
+		if w.Code != tt.want {
+			t.Errorf("body %q: status %d, want %d", tt.body, w.Code, tt.want)
+		}
+	}
+}
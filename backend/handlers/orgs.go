@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// NameRequest creates an organization or a collection.
+type NameRequest struct {
+	Name string `json:"name"`
+}
+
+// InviteRequest adds a member, or invites them by email if they have not
+// signed up yet. Role defaults to member.
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
+}
+
+type OrgInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type MemberInfo struct {
+	UserID   string    `json:"userId"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+type InviteInfo struct {
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invitedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type OrgMembersResponse struct {
+	Members []MemberInfo `json:"members"`
+	Invites []InviteInfo `json:"invites"`
+}
+
+type CollectionInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (h *Handler) CreateOrg(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	email, _ := r.Context().Value(auth.EmailKey).(string)
+
+	var req NameRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Organization name must be 1-100 characters.")
+		return
+	}
+
+	if err := h.Users.Ensure(r.Context(), store.User{ID: userID, Email: email}); err != nil {
+		logger(r).Error("failed to upsert user", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user data.")
+		return
+	}
+
+	org := store.Organization{ID: uuid.New().String(), Name: req.Name, CreatedBy: userID, CreatedAt: time.Now()}
+	if err := h.Orgs.Create(r.Context(), org, userID); err != nil {
+		logger(r).Error("failed to create organization", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create organization.")
+		return
+	}
+
+	logger(r).Info("organization created", "org_id", org.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(OrgInfo{ID: org.ID, Name: org.Name, Role: string(store.OrgRoleOwner), CreatedAt: org.CreatedAt})
+}
+
+// ListOrgs lists the caller's organizations. Invites sent to the caller's
+// email before they signed up are claimed here.
+func (h *Handler) ListOrgs(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	email, _ := r.Context().Value(auth.EmailKey).(string)
+
+	if email != "" {
+		if err := h.Users.Ensure(r.Context(), store.User{ID: userID, Email: email}); err != nil {
+			logger(r).Error("failed to upsert user", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user data.")
+			return
+		}
+		if n, err := h.Orgs.ClaimInvites(r.Context(), userID, email); err != nil {
+			logger(r).Error("failed to claim invites", "error", err)
+		} else if n > 0 {
+			logger(r).Info("joined organizations from pending invites", "count", n)
+		}
+	}
+
+	memberships, err := h.Orgs.ListForUser(r.Context(), userID)
+	if err != nil {
+		logger(r).Error("failed to list organizations", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve organizations.")
+		return
+	}
+	infos := make([]OrgInfo, 0, len(memberships))
+	for _, m := range memberships {
+		infos = append(infos, OrgInfo{ID: m.ID, Name: m.Name, Role: string(m.Role), CreatedAt: m.CreatedAt})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+func (h *Handler) ListOrgMembers(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("id")
+	if _, ok := h.authorizeOrg(w, r, orgID, store.OrgRoleMember); !ok {
+		return
+	}
+	h.writeMembers(w, r, orgID)
+}
+
+func (h *Handler) writeMembers(w http.ResponseWriter, r *http.Request, orgID string) {
+	members, err := h.Orgs.ListMembers(r.Context(), orgID)
+	if err != nil {
+		logger(r).Error("failed to list members", "org_id", orgID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve members.")
+		return
+	}
+	invites, err := h.Orgs.ListInvites(r.Context(), orgID)
+	if err != nil {
+		logger(r).Error("failed to list invites", "org_id", orgID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve members.")
+		return
+	}
+
+	resp := OrgMembersResponse{
+		Members: make([]MemberInfo, 0, len(members)),
+		Invites: make([]InviteInfo, 0, len(invites)),
+	}
+	for _, m := range members {
+		resp.Members = append(resp.Members, MemberInfo{UserID: m.UserID, Email: m.Email, Role: string(m.Role), JoinedAt: m.JoinedAt})
+	}
+	for _, inv := range invites {
+		resp.Invites = append(resp.Invites, InviteInfo{Email: inv.Email, Role: string(inv.Role), InvitedBy: inv.InvitedBy, CreatedAt: inv.CreatedAt})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// InviteMember adds an existing user to the organization straight away and
+// records a pending invite for anyone who has not signed up yet.
+func (h *Handler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	orgID := r.PathValue("id")
+
+	var req InviteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if !strings.Contains(req.Email, "@") {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid email address.")
+		return
+	}
+	role := store.OrgRole(req.Role)
+	if role == store.OrgRoleNone {
+		role = store.OrgRoleMember
+	}
+	if role != store.OrgRoleMember && role != store.OrgRoleAdmin {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Role must be 'member' or 'admin'.")
+		return
+	}
+
+	if _, ok := h.authorizeOrg(w, r, orgID, store.OrgRoleAdmin); !ok {
+		return
+	}
+
+	user, err := h.Users.GetByEmail(r.Context(), req.Email)
+	switch {
+	case err == nil:
+		existing, err := h.Orgs.MemberRole(r.Context(), orgID, user.ID)
+		if err != nil {
+			logger(r).Error("failed to look up membership", "org_id", orgID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to add member.")
+			return
+		}
+		if existing == store.OrgRoleOwner {
+			apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "That user is an owner of this organization.")
+			return
+		}
+		if err := h.Orgs.AddMember(r.Context(), orgID, user.ID, role); err != nil {
+			logger(r).Error("failed to add member", "org_id", orgID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to add member.")
+			return
+		}
+		logger(r).Info("member added", "org_id", orgID, "member_id", user.ID, "role", role)
+		h.Audit.Record(r, audit.ActionOrgInvite, "", map[string]string{"orgId": orgID, "userId": user.ID, "role": string(role)})
+	case errors.Is(err, store.ErrNotFound):
+		invite := store.Invite{ID: uuid.New().String(), OrgID: orgID, Email: req.Email, Role: role, InvitedBy: userID}
+		if err := h.Orgs.Invite(r.Context(), invite); err != nil {
+			logger(r).Error("failed to create invite", "org_id", orgID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to invite member.")
+			return
+		}
+		logger(r).Info("pending invite created", "org_id", orgID, "role", role)
+		h.Audit.Record(r, audit.ActionOrgInvite, "", map[string]string{"orgId": orgID, "email": req.Email, "role": string(role)})
+	default:
+		logger(r).Error("failed to look up user by email", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to invite member.")
+		return
+	}
+
+	h.writeMembers(w, r, orgID)
+}
+
+// RemoveMember removes a member (userId) or withdraws a pending invite
+// (email). Admins cannot remove owners, and an organization always keeps at
+// least one owner.
+func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	orgID := r.PathValue("id")
+	memberID := r.PathValue("userId")
+	email := r.PathValue("email")
+	if (memberID == "") == (email == "") {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Provide exactly one of userId or email.")
+		return
+	}
+
+	callerRole, ok := h.authorizeOrg(w, r, orgID, store.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if email != "" {
+		err := h.Orgs.RevokeInvite(r.Context(), orgID, email)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.CodeInviteNotFound, "Invite not found.")
+			return
+		}
+		if err != nil {
+			logger(r).Error("failed to revoke invite", "org_id", orgID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke invite.")
+			return
+		}
+		h.writeMembers(w, r, orgID)
+		return
+	}
+
+	targetRole, err := h.Orgs.MemberRole(r.Context(), orgID, memberID)
+	if err != nil {
+		logger(r).Error("failed to look up membership", "org_id", orgID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to remove member.")
+		return
+	}
+	if targetRole == store.OrgRoleNone {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeMemberNotFound, "Member not found.")
+		return
+	}
+	if targetRole == store.OrgRoleOwner {
+		if callerRole != store.OrgRoleOwner {
+			apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Only owners can remove an owner.")
+			return
+		}
+		owners, err := h.Orgs.CountOwners(r.Context(), orgID)
+		if err != nil {
+			logger(r).Error("failed to count owners", "org_id", orgID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to remove member.")
+			return
+		}
+		if owners <= 1 {
+			apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "An organization must keep at least one owner.")
+			return
+		}
+	}
+
+	if err := h.Orgs.RemoveMember(r.Context(), orgID, memberID); err != nil {
+		logger(r).Error("failed to remove member", "org_id", orgID, "member_id", memberID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to remove member.")
+		return
+	}
+	logger(r).Info("member removed", "org_id", orgID, "member_id", memberID)
+	h.Audit.Record(r, audit.ActionOrgRemove, "", map[string]string{"orgId": orgID, "userId": memberID})
+	h.writeMembers(w, r, orgID)
+}
+
+func (h *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	orgID := r.PathValue("id")
+
+	var req NameRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Collection name must be 1-100 characters.")
+		return
+	}
+
+	if _, ok := h.authorizeOrg(w, r, orgID, store.OrgRoleMember); !ok {
+		return
+	}
+
+	c := store.Collection{ID: uuid.New().String(), OrgID: orgID, Name: req.Name, CreatedBy: userID, CreatedAt: time.Now()}
+	if err := h.Orgs.CreateCollection(r.Context(), c); err != nil {
+		// The (org_id, name) unique constraint is the only expected failure.
+		logger(r).Warn("failed to create collection", "org_id", orgID, "error", err)
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Failed to create collection. Does one with that name already exist?")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CollectionInfo{ID: c.ID, Name: c.Name, CreatedBy: c.CreatedBy, CreatedAt: c.CreatedAt})
+}
+
+func (h *Handler) ListCollections(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("id")
+	if _, ok := h.authorizeOrg(w, r, orgID, store.OrgRoleMember); !ok {
+		return
+	}
+	collections, err := h.Orgs.ListCollections(r.Context(), orgID)
+	if err != nil {
+		logger(r).Error("failed to list collections", "org_id", orgID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve collections.")
+		return
+	}
+	infos := make([]CollectionInfo, 0, len(collections))
+	for _, c := range collections {
+		infos = append(infos, CollectionInfo{ID: c.ID, Name: c.Name, CreatedBy: c.CreatedBy, CreatedAt: c.CreatedAt})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// createOrg creates an organization owned by userID and returns its ID.
+func (env *testEnv) createOrg(t *testing.T, userID, name string) string {
+	t.Helper()
+	w := serve(env.h.CreateOrg, request(http.MethodPost, "/api/v1/orgs", userID, jsonBody(t, map[string]string{"name": name})))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create org: status %d; body %s", w.Code, w.Body)
+	}
+	return decode[OrgInfo](t, w).ID
+}
+
+func (env *testEnv) invite(t *testing.T, orgID, userID, email, role string) int {
+	t.Helper()
+	r := docRequest(t, http.MethodPost, "/api/v1/orgs/"+orgID+"/members", userID, orgID, map[string]string{"email": email, "role": role})
+	return serve(env.h.InviteMember, r).Code
+}
+
+func (env *testEnv) removeMember(t *testing.T, orgID, userID, memberID string) int {
+	t.Helper()
+	return serve(env.h.RemoveMember, withPath(request(http.MethodDelete, "/api/v1/orgs/"+orgID+"/members/"+memberID, userID, nil), "id", orgID, "userId", memberID)).Code
+}
+
+func (env *testEnv) ensureUser(t *testing.T, userID string) {
+	t.Helper()
+	if err := env.stores.Users.Ensure(context.Background(), store.User{ID: userID, Email: userID + "@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// workspaceUpload uploads a text file as userID into the organization and
+// optional collection.
+func workspaceUpload(t *testing.T, userID, orgID, collectionID string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("orgId", orgID)
+	if collectionID != "" {
+		mw.WriteField("collectionId", collectionID)
+	}
+	fw, err := mw.CreateFormFile("document", "okr.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "Objectives and key results for the quarter.")
+	mw.Close()
+	r := request(http.MethodPost, "/api/documents/upload", userID, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestOrgRoles(t *testing.T) {
+	env := newTestEnv(t)
+	for _, id := range []string{"bob", "erin", "mallory"} {
+		env.ensureUser(t, id)
+	}
+	orgID := env.createOrg(t, "alice", "Acme")
+
+	if code := env.invite(t, orgID, "alice", "bob@example.com", "member"); code != http.StatusOK {
+		t.Fatalf("invite bob: status %d", code)
+	}
+	if code := env.invite(t, orgID, "alice", "Erin@Example.com", "admin"); code != http.StatusOK {
+		t.Fatalf("invite erin: status %d", code)
+	}
+	if code := env.invite(t, orgID, "alice", "x@example.com", "owner"); code != http.StatusBadRequest {
+		t.Errorf("invite as owner: status %d, want 400", code)
+	}
+
+	// Members cannot manage the organization, and outsiders cannot see it.
+	if code := env.invite(t, orgID, "bob", "carol@example.com", "member"); code != http.StatusForbidden {
+		t.Errorf("member invites: status %d, want 403", code)
+	}
+	if code := env.removeMember(t, orgID, "bob", "erin"); code != http.StatusForbidden {
+		t.Errorf("member removes an admin: status %d, want 403", code)
+	}
+	for _, h := range []http.HandlerFunc{env.h.ListOrgMembers, env.h.ListCollections} {
+		if w := serve(h, docRequest(t, http.MethodGet, "/api/v1/orgs/"+orgID+"/members", "mallory", orgID, nil)); w.Code != http.StatusNotFound {
+			t.Errorf("outsider lists the organization: status %d, want 404", w.Code)
+		}
+	}
+	if code := env.invite(t, orgID, "mallory", "carol@example.com", "member"); code != http.StatusNotFound {
+		t.Errorf("outsider invites: status %d, want 404", code)
+	}
+
+	// Admins manage members but not owners, and the last owner stays.
+	if code := env.invite(t, orgID, "erin", "alice@example.com", "member"); code != http.StatusConflict {
+		t.Errorf("admin demotes the owner: status %d, want 409", code)
+	}
+	if code := env.removeMember(t, orgID, "erin", "alice"); code != http.StatusForbidden {
+		t.Errorf("admin removes the owner: status %d, want 403", code)
+	}
+	if code := env.removeMember(t, orgID, "alice", "alice"); code != http.StatusConflict {
+		t.Errorf("the last owner leaves: status %d, want 409", code)
+	}
+
+	// Workspace documents: members upload and edit, admins also delete.
+	if w := serve(env.h.UploadDocument, workspaceUpload(t, "mallory", orgID, "")); w.Code != http.StatusNotFound {
+		t.Errorf("outsider uploads into the workspace: status %d, want 404", w.Code)
+	}
+	if w := serve(env.h.UploadDocument, workspaceUpload(t, "alice", orgID, "")); w.Code != http.StatusCreated {
+		t.Fatalf("owner uploads into the workspace: status %d; body %s", w.Code, w.Body)
+	}
+	docs := decode[DocumentPage](t, serve(env.h.ListDocuments, request(http.MethodGet, "/api/v1/documents?orgId="+orgID, "bob", nil))).Documents
+	if len(docs) != 1 || docs[0].OrgID != orgID {
+		t.Fatalf("workspace documents = %+v", docs)
+	}
+	if personal := decode[DocumentPage](t, serve(env.h.ListDocuments, request(http.MethodGet, "/api/v1/documents", "alice", nil))); personal.Total != 0 {
+		t.Errorf("workspace document listed as personal: %+v", personal)
+	}
+	docID := docs[0].ID
+	retag := func(userID string) int {
+		return serve(env.h.SetDocumentTags, docRequest(t, http.MethodPut, "/api/v1/documents/"+docID+"/tags", userID, docID, TagsRequest{Tags: []string{"okr"}})).Code
+	}
+	if code := retag("bob"); code != http.StatusOK {
+		t.Errorf("member re-tags: status %d", code)
+	}
+	if code := retag("mallory"); code != http.StatusNotFound {
+		t.Errorf("outsider re-tags: status %d, want 404", code)
+	}
+	if w := serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete?id="+docID, "bob", nil)); w.Code != http.StatusForbidden {
+		t.Errorf("member deletes: status %d, want 403", w.Code)
+	}
+
+	// Removed members lose access to the workspace's documents.
+	if code := env.removeMember(t, orgID, "erin", "bob"); code != http.StatusOK {
+		t.Fatalf("admin removes a member: status %d", code)
+	}
+	if code := retag("bob"); code != http.StatusNotFound {
+		t.Errorf("removed member re-tags: status %d, want 404", code)
+	}
+	if w := serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete?id="+docID, "erin", nil)); w.Code != http.StatusNoContent {
+		t.Errorf("admin deletes: status %d", w.Code)
+	}
+}
+
+func TestOrgInvitesAreClaimedOnSignIn(t *testing.T) {
+	env := newTestEnv(t)
+	orgID := env.createOrg(t, "alice", "Acme")
+	if code := env.invite(t, orgID, "alice", "dave@example.com", "member"); code != http.StatusOK {
+		t.Fatalf("invite: status %d", code)
+	}
+	members := decode[OrgMembersResponse](t, serve(env.h.ListOrgMembers, docRequest(t, http.MethodGet, "/api/v1/orgs/"+orgID+"/members", "alice", orgID, nil)))
+	if len(members.Members) != 1 || len(members.Invites) != 1 || members.Invites[0].Email != "dave@example.com" {
+		t.Fatalf("members = %+v, want alice and a pending invite", members)
+	}
+
+	orgs := decode[[]OrgInfo](t, serve(env.h.ListOrgs, request(http.MethodGet, "/api/v1/orgs", "dave", nil)))
+	if len(orgs) != 1 || orgs[0].ID != orgID || orgs[0].Role != "member" {
+		t.Errorf("dave's organizations = %+v, want Acme as a member", orgs)
+	}
+	members = decode[OrgMembersResponse](t, serve(env.h.ListOrgMembers, docRequest(t, http.MethodGet, "/api/v1/orgs/"+orgID+"/members", "dave", orgID, nil)))
+	if len(members.Members) != 2 || len(members.Invites) != 0 {
+		t.Errorf("members after claiming = %+v", members)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	env := newTestEnv(t)
+	orgID := env.createOrg(t, "alice", "Acme")
+	create := func(userID, name string) int {
+		return serve(env.h.CreateCollection, docRequest(t, http.MethodPost, "/api/v1/orgs/"+orgID+"/collections", userID, orgID, map[string]string{"name": name})).Code
+	}
+	if code := create("alice", "Board"); code != http.StatusCreated {
+		t.Fatalf("create collection: status %d", code)
+	}
+	if code := create("mallory", "Leaks"); code != http.StatusNotFound {
+		t.Errorf("outsider creates a collection: status %d, want 404", code)
+	}
+	if code := create("alice", ""); code != http.StatusBadRequest {
+		t.Errorf("unnamed collection: status %d, want 400", code)
+	}
+	collections := decode[[]CollectionInfo](t, serve(env.h.ListCollections, docRequest(t, http.MethodGet, "/api/v1/orgs/"+orgID+"/collections", "alice", orgID, nil)))
+	if len(collections) != 1 || collections[0].Name != "Board" {
+		t.Errorf("collections = %+v", collections)
+	}
+
+	// A collection from another organization is not found.
+	otherID := env.createOrg(t, "alice", "Other")
+	r := workspaceUpload(t, "alice", otherID, collections[0].ID)
+	if w := serve(env.h.UploadDocument, r); w.Code != http.StatusNotFound {
+		t.Errorf("upload into another organization's collection: status %d, want 404", w.Code)
+	}
+}
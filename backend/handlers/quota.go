@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+)
+
+// GetQuota reports the caller's usage and remaining quota for the current
+// day and month.
+func (h *Handler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+
+	quotas := make([]limits.QuotaStatus, 0, 4)
+	for _, metric := range []string{limits.MetricLLMTokens, limits.MetricUploads} {
+		statuses, err := h.Quotas.Status(r.Context(), userID, metric)
+		if err != nil {
+			logger(r).Error("failed to load quota", "metric", metric, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve quota.")
+			return
+		}
+		quotas = append(quotas, statuses...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quotas)
+}
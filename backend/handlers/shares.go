@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+type ShareRequest struct {
+	// Exactly one of UserID or Email identifies the grantee.
+	UserID string `json:"userId,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Role   string `json:"role"`
+}
+
+type ShareInfo struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SharedDocumentInfo struct {
+	DocumentInfo
+	OwnerID string `json:"ownerId"`
+	Role    string `json:"role"`
+}
+
+func (h *Handler) ShareDocument(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	docID := r.PathValue("id")
+
+	var req ShareRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.UserID = strings.TrimSpace(req.UserID)
+	req.Email = strings.TrimSpace(req.Email)
+	if (req.UserID == "") == (req.Email == "") {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Provide exactly one of userId or email.")
+		return
+	}
+	if req.Email != "" && !strings.Contains(req.Email, "@") {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid email address.")
+		return
+	}
+	role := store.Role(req.Role)
+	if role != store.RoleViewer && role != store.RoleEditor {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Role must be 'viewer' or 'editor'.")
+		return
+	}
+	if req.UserID == userID {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "You already own this document.")
+		return
+	}
+
+	if _, _, ok := h.authorizeDocument(w, r, docID, store.RoleOwner); !ok {
+		return
+	}
+
+	share := store.Share{
+		ID:            uuid.New().String(),
+		DocumentID:    docID,
+		GranteeUserID: req.UserID,
+		GranteeEmail:  req.Email,
+		Role:          role,
+		CreatedBy:     userID,
+	}
+	if err := h.Shares.Grant(r.Context(), share); err != nil {
+		logger(r).Error("failed to share document", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to share document.")
+		return
+	}
+
+	logger(r).Info("document shared", "document_id", docID, "role", role)
+	h.Audit.Record(r, audit.ActionShare, docID, map[string]string{"userId": req.UserID, "email": req.Email, "role": string(role)})
+	h.writeShares(w, r, docID)
+}
+
+func (h *Handler) ListShares(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	if docID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Document ID is required.")
+		return
+	}
+	if _, _, ok := h.authorizeDocument(w, r, docID, store.RoleOwner); !ok {
+		return
+	}
+	h.writeShares(w, r, docID)
+}
+
+func (h *Handler) writeShares(w http.ResponseWriter, r *http.Request, docID string) {
+	shares, err := h.Shares.ListByDocument(r.Context(), docID)
+	if err != nil {
+		logger(r).Error("failed to list shares", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve shares.")
+		return
+	}
+	infos := make([]ShareInfo, 0, len(shares))
+	for _, s := range shares {
+		infos = append(infos, ShareInfo{
+			ID:        s.ID,
+			UserID:    s.GranteeUserID,
+			Email:     s.GranteeEmail,
+			Role:      string(s.Role),
+			CreatedBy: s.CreatedBy,
+			CreatedAt: s.CreatedAt,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+func (h *Handler) UnshareDocument(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	shareID := r.PathValue("shareId")
+	if docID == "" || shareID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Document ID and share ID are required.")
+		return
+	}
+	if _, _, ok := h.authorizeDocument(w, r, docID, store.RoleOwner); !ok {
+		return
+	}
+
+	err := h.Shares.Revoke(r.Context(), docID, shareID)
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeShareNotFound, "Share not found.")
+		return
+	}
+	if err != nil {
+		logger(r).Error("failed to revoke share", "document_id", docID, "share_id", shareID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke share.")
+		return
+	}
+	h.Audit.Record(r, audit.ActionUnshare, docID, map[string]string{"shareId": shareID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSharedDocuments lists documents other users have shared with the caller.
+func (h *Handler) ListSharedDocuments(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	email, _ := r.Context().Value(auth.EmailKey).(string)
+
+	docs, err := h.Shares.ListSharedWith(r.Context(), userID, email)
+	if err != nil {
+		logger(r).Error("failed to list shared documents", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve documents.")
+		return
+	}
+	infos := make([]SharedDocumentInfo, 0, len(docs))
+	for _, d := range docs {
+		infos = append(infos, SharedDocumentInfo{
+			DocumentInfo: documentInfo(&d.Document),
+			OwnerID:      d.UserID,
+			Role:         string(d.Role),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
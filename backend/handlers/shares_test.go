@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/audit"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+func (env *testEnv) share(t *testing.T, docID, userID string, role store.Role) {
+	t.Helper()
+	share := store.Share{ID: "share-" + userID, DocumentID: docID, GranteeUserID: userID, Role: role, CreatedBy: "alice"}
+	if err := env.stores.Shares.Grant(context.Background(), share); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	return v
+}
+
+func TestShareRoles(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice", "Revenue grew.")
+	env.share(t, doc.ID, "viewer", store.RoleViewer)
+	env.share(t, doc.ID, "editor", store.RoleEditor)
+
+	chat := func(userID string) int {
+		return serve(env.h.Chat, request(http.MethodPost, "/api/chat", userID, jsonBody(t, ChatRequest{DocumentID: doc.ID, Query: "Growth?"}))).Code
+	}
+	retag := func(userID string) int {
+		return serve(env.h.SetDocumentTags, docRequest(t, http.MethodPut, "/api/v1/documents/"+doc.ID+"/tags", userID, doc.ID, TagsRequest{Tags: []string{"q3"}})).Code
+	}
+	share := func(userID string, req ShareRequest) *httptest.ResponseRecorder {
+		return serve(env.h.ShareDocument, docRequest(t, http.MethodPost, "/api/v1/documents/"+doc.ID+"/shares", userID, doc.ID, req))
+	}
+	remove := func(userID string) int {
+		return serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete?id="+doc.ID, userID, nil)).Code
+	}
+
+	// Viewers can read and chat, but not edit or share.
+	if code := chat("viewer"); code != http.StatusOK {
+		t.Errorf("viewer chat: status %d", code)
+	}
+	if code := retag("viewer"); code != http.StatusForbidden {
+		t.Errorf("viewer re-tag: status %d, want 403", code)
+	}
+	if w := share("viewer", ShareRequest{UserID: "carol", Role: "viewer"}); w.Code != http.StatusForbidden {
+		t.Errorf("viewer share: status %d, want 403", w.Code)
+	}
+
+	// Editors can re-tag, but not share or delete.
+	if code := retag("editor"); code != http.StatusOK {
+		t.Errorf("editor re-tag: status %d", code)
+	}
+	if w := share("editor", ShareRequest{UserID: "carol", Role: "viewer"}); w.Code != http.StatusForbidden {
+		t.Errorf("editor share: status %d, want 403", w.Code)
+	}
+	if code := remove("editor"); code != http.StatusForbidden {
+		t.Errorf("editor delete: status %d, want 403", code)
+	}
+
+	// Only the owner shares, and owner is not a role that can be granted.
+	w := share("alice", ShareRequest{Email: "Carol@Example.com", Role: "viewer"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("owner share: status %d; body %s", w.Code, w.Body)
+	}
+	if shares := decode[[]ShareInfo](t, w); len(shares) != 3 || shares[2].Email != "carol@example.com" {
+		t.Errorf("shares = %+v, want carol's email grant added", shares)
+	}
+	for name, req := range map[string]ShareRequest{
+		"owner role":      {UserID: "carol", Role: "owner"},
+		"no grantee":      {Role: "viewer"},
+		"both grantees":   {UserID: "carol", Email: "carol@example.com", Role: "viewer"},
+		"bad email":       {Email: "carol", Role: "viewer"},
+		"with themselves": {UserID: "alice", Role: "viewer"},
+	} {
+		if w := share("alice", req); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+	if code := chat("carol"); code != http.StatusOK {
+		t.Errorf("chat through an email share: status %d", code)
+	}
+
+	// The shared list reports each document with the caller's role.
+	shared := decode[[]SharedDocumentInfo](t, serve(env.h.ListSharedDocuments, request(http.MethodGet, "/api/v1/documents/shared", "editor", nil)))
+	if len(shared) != 1 || shared[0].ID != doc.ID || shared[0].Role != "editor" || shared[0].OwnerID != "alice" {
+		t.Fatalf("editor's shared documents = %+v", shared)
+	}
+	if shared[0].ChunkCount != 1 || shared[0].Status != string(store.DocumentReady) {
+		t.Errorf("shared document stats = %+v", shared[0].DocumentInfo)
+	}
+
+	// Revoking a share takes the access away.
+	w = serve(env.h.UnshareDocument, withPath(request(http.MethodDelete, "/api/v1/documents/"+doc.ID+"/shares/share-viewer", "alice", nil), "id", doc.ID, "shareId", "share-viewer"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unshare: status %d; body %s", w.Code, w.Body)
+	}
+	if code := chat("viewer"); code != http.StatusNotFound {
+		t.Errorf("chat after unshare: status %d, want 404", code)
+	}
+	if code := remove("alice"); code != http.StatusNoContent {
+		t.Errorf("owner delete: status %d", code)
+	}
+}
+
+func TestOtherUsersDocumentIsNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice", "Revenue grew.")
+
+	for name, serveAs := range map[string]func(docID string) *httptest.ResponseRecorder{
+		"chat": func(docID string) *httptest.ResponseRecorder {
+			return serve(env.h.Chat, request(http.MethodPost, "/api/chat", "mallory", jsonBody(t, ChatRequest{DocumentID: docID, Query: "Growth?"})))
+		},
+		"delete": func(docID string) *httptest.ResponseRecorder {
+			return serve(env.h.DeleteDocument, request(http.MethodDelete, "/api/documents/delete?id="+docID, "mallory", nil))
+		},
+		"shares": func(docID string) *httptest.ResponseRecorder {
+			return serve(env.h.ListShares, docRequest(t, http.MethodGet, "/api/v1/documents/"+docID+"/shares", "mallory", docID, nil))
+		},
+		"tags": func(docID string) *httptest.ResponseRecorder {
+			return serve(env.h.GetDocumentTags, docRequest(t, http.MethodGet, "/api/v1/documents/"+docID+"/tags", "mallory", docID, nil))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Someone else's document looks the same as one that does not exist.
+			for _, docID := range []string{doc.ID, "missing"} {
+				if w := serveAs(docID); w.Code != http.StatusNotFound || errorCode(w) != apierror.CodeDocumentNotFound {
+					t.Errorf("%s: status %d, code %q; want 404 %s", docID, w.Code, errorCode(w), apierror.CodeDocumentNotFound)
+				}
+			}
+		})
+	}
+	if _, err := env.stores.Documents.Get(context.Background(), doc.ID); err != nil {
+		t.Errorf("document gone after another user's requests: %v", err)
+	}
+}
+
+func TestDocumentTags(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice")
+
+	w := serve(env.h.SetDocumentTags, docRequest(t, http.MethodPut, "/api/v1/documents/"+doc.ID+"/tags", "alice", doc.ID, TagsRequest{Tags: []string{" Q3 ", "finance", "q3", ""}}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d; body %s", w.Code, w.Body)
+	}
+	if got := decode[TagsRequest](t, w).Tags; !slices.Equal(got, []string{"finance", "q3"}) {
+		t.Errorf("tags = %q, want finance and q3", got)
+	}
+
+	tooMany := make([]string, maxTagsPerDocument+1)
+	for i := range tooMany {
+		tooMany[i] = string(rune('a' + i))
+	}
+	w = serve(env.h.SetDocumentTags, docRequest(t, http.MethodPut, "/api/v1/documents/"+doc.ID+"/tags", "alice", doc.ID, TagsRequest{Tags: tooMany}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("too many tags: status %d, want 400", w.Code)
+	}
+}
+
+func TestGetAndUpdateDocument(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice", "Revenue grew.")
+	env.share(t, doc.ID, "viewer", store.RoleViewer)
+	env.share(t, doc.ID, "editor", store.RoleEditor)
+
+	// The v1 routes name the document in the path.
+	get := func(userID string) *httptest.ResponseRecorder {
+		r := request(http.MethodGet, "/api/v1/documents/"+doc.ID, userID, nil)
+		r.SetPathValue("id", doc.ID)
+		return serve(env.h.GetDocument, r)
+	}
+	update := func(userID string, req UpdateDocumentRequest) *httptest.ResponseRecorder {
+		r := request(http.MethodPatch, "/api/v1/documents/"+doc.ID, userID, jsonBody(t, req))
+		r.SetPathValue("id", doc.ID)
+		return serve(env.h.UpdateDocument, r)
+	}
+
+	w := get("viewer")
+	if w.Code != http.StatusOK {
+		t.Fatalf("viewer get: status %d; body %s", w.Code, w.Body)
+	}
+	if got := decode[DocumentDetail](t, w); got.ID != doc.ID || got.Role != string(store.RoleViewer) || got.Tags == nil || got.Warnings == nil || got.IngestedAt == nil {
+		t.Errorf("viewer sees %+v", got)
+	}
+	if w := get("mallory"); w.Code != http.StatusNotFound {
+		t.Errorf("stranger get: status %d, want 404", w.Code)
+	}
+
+	name, tags := "  Q3 report.txt ", []string{"Q3", "finance"}
+	if w := update("viewer", UpdateDocumentRequest{FileName: &name}); w.Code != http.StatusForbidden {
+		t.Errorf("viewer rename: status %d, want 403", w.Code)
+	}
+	w = update("editor", UpdateDocumentRequest{FileName: &name, Tags: &tags})
+	if w.Code != http.StatusOK {
+		t.Fatalf("editor update: status %d; body %s", w.Code, w.Body)
+	}
+	if got := decode[DocumentDetail](t, w); got.FileName != "Q3 report.txt" || !slices.Equal(got.Tags, []string{"finance", "q3"}) {
+		t.Errorf("after update = %+v", got)
+	}
+	events, _ := env.stores.Audit.List(t.Context(), store.AuditFilter{Action: audit.ActionRename})
+	if len(events) != 1 || events[0].UserID != "editor" {
+		t.Errorf("rename events = %+v", events)
+	}
+
+	// Leaving a field out leaves it alone.
+	untagged := []string{}
+	w = update("alice", UpdateDocumentRequest{Tags: &untagged})
+	if got := decode[DocumentDetail](t, w); got.FileName != "Q3 report.txt" || len(got.Tags) != 0 {
+		t.Errorf("after clearing tags = %+v", got)
+	}
+
+	blank := " "
+	if w := update("alice", UpdateDocumentRequest{FileName: &blank}); w.Code != http.StatusBadRequest {
+		t.Errorf("blank name: status %d, want 400", w.Code)
+	}
+}
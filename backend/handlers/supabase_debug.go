@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	storage_go "github.com/supabase-community/storage-go"
+)
+
+// Add a test endpoint for Supabase connectivity
+func (h *Handler) TestSupabaseUpload(w http.ResponseWriter, r *http.Request) {
+	storageClient := storage_go.NewClient(h.Config.SupabaseURL, h.Config.SupabaseSvcKey, nil)
+	testContent := []byte("test upload from TestSupabaseUploadHandler")
+	testPath := "test/test-upload.txt"
+	contentType := "text/plain"
+	_, err := storageClient.UploadFile(h.Config.Storage.Bucket, testPath, bytes.NewReader(testContent), storage_go.FileOptions{
+		ContentType: &contentType,
+	})
+	if err != nil {
+		logger(r).Error("test upload failed", "error", err)
+		if se, ok := err.(*storage_go.StorageError); ok {
+			logger(r).Error("supabase storage error", "status", se.Status, "message", se.Message)
+			apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStorageUnavailable, fmt.Sprintf("Supabase StorageError: Status=%d, Message=%s", se.Status, se.Message))
+			return
+		}
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStorageUnavailable, fmt.Sprintf("Upload error: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Test upload to Supabase succeeded."})
+}
+
+// Minimal upload to Supabase Storage using net/http (no SDK)
+func (h *Handler) MinimalSupabaseUpload(w http.ResponseWriter, r *http.Request) {
+	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", h.Config.SupabaseURL, h.Config.Storage.Bucket, "test/minimal-upload.txt")
+	content := []byte("minimal upload test")
+	req, err := http.NewRequest("POST", url, bytes.NewReader(content))
+	if err != nil {
+		logger(r).Error("failed to create minimal upload request", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to create request: %v", err))
+		return
+	}
+	// Set headers
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", h.Config.SupabaseSvcKey))
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger(r).Error("minimal upload request failed", "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStorageUnavailable, fmt.Sprintf("Request error: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	logger(r).Info("minimal upload finished", "status", resp.Status, "body", string(respBody))
+	if resp.StatusCode >= 300 {
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeStorageUnavailable, fmt.Sprintf("Status: %s\nBody: %s", resp.Status, string(respBody)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": resp.Status, "body": string(respBody)})
+}
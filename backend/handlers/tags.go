@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+const (
+	maxTagsPerDocument = 20
+	maxTagLength       = 40
+)
+
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// normalizeTags lower-cases, trims and de-duplicates tags.
+func normalizeTags(tags []string) ([]string, bool) {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || slices.Contains(out, t) {
+			continue
+		}
+		if len(t) > maxTagLength {
+			return nil, false
+		}
+		out = append(out, t)
+	}
+	return out, len(out) <= maxTagsPerDocument
+}
+
+func (h *Handler) GetDocumentTags(w http.ResponseWriter, r *http.Request) {
+	docID := r.PathValue("id")
+	if docID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Document ID is required.")
+		return
+	}
+	if _, _, ok := h.authorizeDocument(w, r, docID, store.RoleViewer); !ok {
+		return
+	}
+	h.writeTags(w, r, docID)
+}
+
+// SetDocumentTags replaces a document's tags. Editors and owners may re-tag.
+func (h *Handler) SetDocumentTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	docID := r.PathValue("id")
+	if docID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Document ID is required.")
+		return
+	}
+
+	var req TagsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	tags, ok := normalizeTags(req.Tags)
+	if !ok {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Too many tags or tag too long.")
+		return
+	}
+
+	if _, _, ok := h.authorizeDocument(w, r, docID, store.RoleEditor); !ok {
+		return
+	}
+	if err := h.Documents.SetTags(r.Context(), docID, tags); err != nil {
+		logger(r).Error("failed to set tags", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save tags.")
+		return
+	}
+	h.writeTags(w, r, docID)
+}
+
+func (h *Handler) writeTags(w http.ResponseWriter, r *http.Request, docID string) {
+	tags, err := h.Documents.Tags(r.Context(), docID)
+	if err != nil {
+		logger(r).Error("failed to load tags", "document_id", docID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve tags.")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TagsRequest{Tags: tags})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// recordUsage stores the tokens and estimated cost of a model call and
+// charges them against the caller's LLM token quota. Failures are logged;
+// the user already has their answer.
+func (h *Handler) recordUsage(ctx context.Context, record store.LLMUsage, usage ai.Usage) {
+	h.followup().Record(ctx, record, usage)
+}
+
+type UsageRow struct {
+	Key            string  `json:"key"`
+	Calls          int64   `json:"calls"`
+	PromptTokens   int64   `json:"promptTokens"`
+	ResponseTokens int64   `json:"responseTokens"`
+	TotalTokens    int64   `json:"totalTokens"`
+	CostUSD        float64 `json:"costUsd"`
+}
+
+type UsageReport struct {
+	GroupBy string     `json:"groupBy"`
+	From    string     `json:"from"`
+	To      string     `json:"to"`
+	Rows    []UsageRow `json:"rows"`
+	Total   UsageRow   `json:"total"`
+}
+
+// GetUsage reports the caller's LLM usage and estimated cost, grouped by
+// ?groupBy=day (default), model, document or conversation over ?from= and
+// ?to= (YYYY-MM-DD, default the last 30 days).
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	h.writeUsageReport(w, r, userID, false)
+}
+
+// GetAllUsage is the admin view of GetUsage across every user. It also
+// accepts ?groupBy=user and can be narrowed with ?userId=.
+func (h *Handler) GetAllUsage(w http.ResponseWriter, r *http.Request) {
+	h.writeUsageReport(w, r, r.URL.Query().Get("userId"), true)
+}
+
+func (h *Handler) writeUsageReport(w http.ResponseWriter, r *http.Request, userID string, admin bool) {
+	q := r.URL.Query()
+	groupBy := q.Get("groupBy")
+	if groupBy == "" {
+		groupBy = store.GroupByDay
+	}
+	switch groupBy {
+	case store.GroupByDay, store.GroupByModel, store.GroupByDocument, store.GroupByConversation:
+	case store.GroupByUser:
+		if !admin {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "groupBy must be day, model, document or conversation.")
+			return
+		}
+	default:
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "groupBy must be day, model, document, conversation or user.")
+		return
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -29)
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid "+p.name+" date; use YYYY-MM-DD.")
+				return
+			}
+			*p.dst = t
+		}
+	}
+	filter := store.UsageFilter{UserID: userID, From: from.Format("2006-01-02"), To: to.Format("2006-01-02")}
+
+	summaries, err := h.Usage.Summarize(r.Context(), filter, groupBy)
+	if err != nil {
+		logger(r).Error("failed to summarize usage", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve usage.")
+		return
+	}
+
+	report := UsageReport{GroupBy: groupBy, From: filter.From, To: filter.To, Rows: make([]UsageRow, 0, len(summaries)), Total: UsageRow{Key: "total"}}
+	for _, s := range summaries {
+		row := UsageRow{
+			Key:            s.Key,
+			Calls:          s.Calls,
+			PromptTokens:   s.PromptTokens,
+			ResponseTokens: s.ResponseTokens,
+			TotalTokens:    s.PromptTokens + s.ResponseTokens,
+			CostUSD:        s.CostUSD,
+		}
+		report.Rows = append(report.Rows, row)
+		report.Total.Calls += row.Calls
+		report.Total.PromptTokens += row.PromptTokens
+		report.Total.ResponseTokens += row.ResponseTokens
+		report.Total.TotalTokens += row.TotalTokens
+		report.Total.CostUSD += row.CostUSD
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
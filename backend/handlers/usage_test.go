@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+	"github.com/malharg/strategic-insight-analyst/backend/store/storetest"
+)
+
+func TestChatRecordsUsage(t *testing.T) {
+	env := newTestEnv(t)
+	doc := env.addDocument(t, "doc-1", "alice", "Revenue grew.")
+
+	w := serve(env.h.Chat, request(http.MethodPost, "/api/chat", "alice", jsonBody(t, ChatRequest{DocumentID: doc.ID, Query: "Growth?"})))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	conversationID := decode[map[string]string](t, w)["conversationId"]
+	if conversationID == "" {
+		t.Fatal("no conversation ID returned")
+	}
+	// A follow-up continues the same conversation.
+	w = serve(env.h.Chat, request(http.MethodPost, "/api/chat", "alice", jsonBody(t, ChatRequest{DocumentID: doc.ID, Query: "Why?", ConversationID: conversationID})))
+	if got := decode[map[string]string](t, w)["conversationId"]; got != conversationID {
+		t.Errorf("follow-up conversation = %q, want %q", got, conversationID)
+	}
+
+	usage := storetest.Usage(env.stores)
+	if len(usage) != 2 {
+		t.Fatalf("recorded %d model calls, want 2", len(usage))
+	}
+	want := ai.EstimateCost(ai.Usage{Model: "gemini-1.5-flash", PromptTokens: 1000, ResponseTokens: 100})
+	for _, u := range usage {
+		if u.UserID != "alice" || u.DocumentID != doc.ID || u.ConversationID != conversationID || u.Operation != store.OperationGenerate ||
+			u.Model != "gemini-1.5-flash" || u.PromptTokens != 1000 || u.ResponseTokens != 100 || u.CostUSD != want {
+			t.Errorf("usage = %+v", u)
+		}
+	}
+	statuses, err := env.h.Quotas.Status(t.Context(), "alice", limits.MetricLLMTokens)
+	if err != nil || statuses[0].Used != 2200 {
+		t.Errorf("quota status = %+v, %v; want the reported tokens charged", statuses, err)
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	env := newTestEnv(t)
+	for _, u := range []store.LLMUsage{
+		{UserID: "alice", Model: "gemini-1.5-flash", PromptTokens: 100, ResponseTokens: 10, CostUSD: 0.25},
+		{UserID: "alice", Model: "gemini-1.5-pro", PromptTokens: 200, ResponseTokens: 20, CostUSD: 0.5},
+		{UserID: "bob", Model: "gemini-1.5-flash", PromptTokens: 1000, ResponseTokens: 100, CostUSD: 1},
+	} {
+		env.stores.Usage.Record(t.Context(), u)
+	}
+
+	w := serve(env.h.GetUsage, request(http.MethodGet, "/api/v1/usage?groupBy=model", "alice", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	report := decode[UsageReport](t, w)
+	if len(report.Rows) != 2 || report.Rows[0].Key != "gemini-1.5-flash" || report.Rows[0].TotalTokens != 110 {
+		t.Errorf("rows = %+v, want alice's two models", report.Rows)
+	}
+	if report.Total.Calls != 2 || report.Total.TotalTokens != 330 || report.Total.CostUSD != 0.75 {
+		t.Errorf("total = %+v", report.Total)
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/usage":                                  http.StatusOK,
+		"/api/v1/usage?groupBy=user":                     http.StatusBadRequest,
+		"/api/v1/usage?groupBy=nonsense":                 http.StatusBadRequest,
+		"/api/v1/usage?from=2026-13-01":                  http.StatusBadRequest,
+		"/api/v1/usage?from=2026-10-01&to=2026-10-31":    http.StatusOK,
+		"/api/v1/usage?groupBy=conversation&to=tomorrow": http.StatusBadRequest,
+	} {
+		if w := serve(env.h.GetUsage, request(http.MethodGet, target, "alice", nil)); w.Code != want {
+			t.Errorf("%s: status %d, want %d", target, w.Code, want)
+		}
+	}
+
+	// The admin view covers everyone and can group by user.
+	report = decode[UsageReport](t, serve(env.h.GetAllUsage, request(http.MethodGet, "/api/v1/admin/usage?groupBy=user", "admin", nil)))
+	if len(report.Rows) != 2 || report.Rows[1].Key != "bob" || report.Total.Calls != 3 {
+		t.Errorf("admin report = %+v", report)
+	}
+	report = decode[UsageReport](t, serve(env.h.GetAllUsage, request(http.MethodGet, "/api/v1/admin/usage?groupBy=user&userId=bob", "admin", nil)))
+	if len(report.Rows) != 1 || report.Rows[0].Key != "bob" {
+		t.Errorf("admin report for bob = %+v", report)
+	}
+}
+
+func TestUploadSummarizes(t *testing.T) {
+	env := newTestEnv(t)
+	env.h.Embedder = nil // only the summary should be charged
+	w := serve(env.h.UploadDocument, uploadRequest(t, "alice", "q3.txt", strings.Repeat("Revenue grew 20% in Q3. ", 200)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	id := decode[UploadResult](t, w).ID
+	if err := env.h.Tasks.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake model reports how many chunks it was sent.
+	if doc, _ := env.stores.Documents.Get(t.Context(), id); doc.Summary != "answer from 4 chunks" {
+		t.Errorf("summary = %q, want one from the first 4 chunks", doc.Summary)
+	}
+	usage := storetest.Usage(env.stores)
+	if len(usage) != 1 || usage[0].UserID != "alice" || usage[0].DocumentID != id || usage[0].Operation != store.OperationGenerate {
+		t.Errorf("usage = %+v, want the summary charged to the uploader", usage)
+	}
+}
+
+func TestUploadSkipsSummary(t *testing.T) {
+	for name, setup := range map[string]func(env *testEnv){
+		"summaries off": func(env *testEnv) { env.h.Config.AI.SummaryChunks = 0 },
+		"quota used up": func(env *testEnv) {
+			if err := env.h.Quotas.Charge(t.Context(), "alice", limits.MetricLLMTokens, env.h.Config.Limits.LLMTokensDaily); err != nil {
+				t.Fatal(err)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.h.Embedder = nil
+			setup(env)
+			w := serve(env.h.UploadDocument, uploadRequest(t, "alice", "q3.txt", "Revenue grew."))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			env.h.Tasks.Shutdown(t.Context())
+
+			if doc, _ := env.stores.Documents.Get(t.Context(), decode[UploadResult](t, w).ID); doc.Summary != "" {
+				t.Errorf("summary = %q, want none", doc.Summary)
+			}
+			if usage := storetest.Usage(env.stores); len(usage) != 0 {
+				t.Errorf("usage = %+v, want no model call", usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Package health serves liveness and readiness probes. Readiness runs cheap
+// checks against each external dependency and caches the result briefly so
+// frequent probes do not load them.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+)
+
+// Pinger is implemented by dependencies that can report whether they are
+// usable. Ping must be cheap: it runs on every uncached readiness probe.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PingFunc adapts a function to Pinger.
+type PingFunc func(ctx context.Context) error
+
+func (f PingFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+// Status values reported for the service and for each check.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckResult is the outcome of one dependency check. Only the status is
+// served: the probe is public, and error text can name hosts, buckets or
+// account details. Failures are logged with their error and latency instead.
+type CheckResult struct {
+	Status  string        `json:"status"`
+	Latency time.Duration `json:"-"`
+	Err     error         `json:"-"`
+}
+
+// Report is the readiness response body.
+type Report struct {
+	Status    string                 `json:"status"`
+	CheckedAt time.Time              `json:"checkedAt"`
+	Checks    map[string]CheckResult `json:"checks"`
+}
+
+// Readiness runs the registered checks concurrently, each under Timeout,
+// and reuses the report for TTL.
+type Readiness struct {
+	Timeout time.Duration
+	TTL     time.Duration
+
+	checks   map[string]Pinger
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	cached *Report
+}
+
+// NewReadiness returns a Readiness with no checks, a 2s per-check timeout and
+// a 10s cache.
+func NewReadiness() *Readiness {
+	return &Readiness{Timeout: 2 * time.Second, TTL: 10 * time.Second, checks: map[string]Pinger{}}
+}
+
+// Add registers a check under name. It must be called before serving.
+func (rd *Readiness) Add(name string, p Pinger) {
+	rd.checks[name] = p
+}
+
+// Drain makes every later probe report not ready, so load balancers stop
+// routing to an instance that is shutting down.
+func (rd *Readiness) Drain() {
+	rd.draining.Store(true)
+}
+
+// Check returns the cached report, or runs the checks if it has expired.
+func (rd *Readiness) Check(ctx context.Context) *Report {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if rd.cached != nil && time.Since(rd.cached.CheckedAt) < rd.TTL {
+		return rd.cached
+	}
+
+	report := &Report{Status: StatusOK, CheckedAt: time.Now().UTC(), Checks: make(map[string]CheckResult, len(rd.checks))}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for name, p := range rd.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := run(ctx, p, rd.Timeout)
+			if result.Err != nil {
+				logging.FromContext(ctx).Warn("readiness check failed", "check", name, "latency_ms", result.Latency.Milliseconds(), "error", result.Err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	rd.cached = report
+	return report
+}
+
+func run(ctx context.Context, p Pinger, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.Ping(ctx)
+	result := CheckResult{Status: StatusOK, Latency: time.Since(start), Err: err}
+	if err != nil {
+		result.Status = StatusFail
+	}
+	return result
+}
+
+// ReadyHandler answers 200 with the report when every check passes and 503
+// otherwise.
+func (rd *Readiness) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if rd.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": StatusFail, "reason": "shutting down"})
+		return
+	}
+
+	// Checks run detached from the probe's own deadline so a cancelled probe
+	// does not cache failures.
+	report := rd.Check(context.WithoutCancel(r.Context()))
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// LiveHandler reports that the process is up and serving. It checks no
+// dependencies, so a failing database never gets the instance restarted.
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": StatusOK})
+}
@@ -0,0 +1,139 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counter is a check that counts its calls and returns err.
+type counter struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (c *counter) Ping(ctx context.Context) error {
+	c.calls.Add(1)
+	return c.err
+}
+
+func probe(t *testing.T, rd *Readiness) (int, Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	rd.ReadyHandler(w, httptest.NewRequest(http.MethodGet, "/api/health/ready", nil))
+	var report Report
+	json.Unmarshal(w.Body.Bytes(), &report)
+	return w.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	db, llm := &counter{}, &counter{err: errors.New("quota exhausted")}
+	rd := NewReadiness()
+	rd.Add("database", db)
+	rd.Add("llm", llm)
+
+	code, report := probe(t, rd)
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail {
+		t.Errorf("status %d %q, want 503 while a check fails", code, report.Status)
+	}
+	if report.Checks["database"].Status != StatusOK || report.Checks["llm"].Status != StatusFail {
+		t.Errorf("checks = %+v", report.Checks)
+	}
+
+	llm.err = nil
+	rd.cached = nil
+	if code, report := probe(t, rd); code != http.StatusOK || report.Status != StatusOK {
+		t.Errorf("status %d %q, want 200 once every check passes", code, report.Status)
+	}
+}
+
+func TestReadinessHidesErrors(t *testing.T) {
+	var logs bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	rd := NewReadiness()
+	rd.Add("storage", PingFunc(func(ctx context.Context) error {
+		return errors.New("bucket acme-private-docs: invalid service key")
+	}))
+	w := httptest.NewRecorder()
+	rd.ReadyHandler(w, httptest.NewRequest(http.MethodGet, "/api/health/ready", nil))
+
+	if strings.Contains(w.Body.String(), "acme-private-docs") {
+		t.Errorf("response leaks the error: %s", w.Body)
+	}
+	var body struct{ Checks map[string]map[string]any }
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if got := body.Checks["storage"]; len(got) != 1 || got["status"] != StatusFail {
+		t.Errorf("storage check = %v, want only its status", got)
+	}
+	if !strings.Contains(logs.String(), "acme-private-docs") || !strings.Contains(logs.String(), `"check":"storage"`) {
+		t.Errorf("the error was not logged: %s", logs.String())
+	}
+}
+
+func TestReadinessCachesResults(t *testing.T) {
+	db := &counter{}
+	rd := NewReadiness()
+	rd.Add("database", db)
+
+	for range 5 {
+		probe(t, rd)
+	}
+	if n := db.calls.Load(); n != 1 {
+		t.Errorf("check ran %d times within the TTL, want 1", n)
+	}
+
+	rd.TTL = 0
+	probe(t, rd)
+	if n := db.calls.Load(); n != 2 {
+		t.Errorf("check ran %d times after the TTL, want 2", n)
+	}
+}
+
+func TestReadinessTimesOutSlowChecks(t *testing.T) {
+	rd := NewReadiness()
+	rd.Timeout = 20 * time.Millisecond
+	rd.Add("storage", PingFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	start := time.Now()
+	code, report := probe(t, rd)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("probe took %v", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || report.Checks["storage"].Status != StatusFail {
+		t.Errorf("status %d, checks %+v; want the slow check failed", code, report.Checks)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	rd := NewReadiness()
+	rd.Add("database", &counter{})
+	if code, _ := probe(t, rd); code != http.StatusOK {
+		t.Fatalf("status %d before draining", code)
+	}
+	rd.Drain()
+	if code, report := probe(t, rd); code != http.StatusServiceUnavailable || report.Status != StatusFail {
+		t.Errorf("status %d %q after Drain, want 503 even with a cached success", code, report.Status)
+	}
+}
+
+func TestLiveHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	LiveHandler(w, httptest.NewRequest(http.MethodGet, "/api/health/live", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}`+"\n" {
+		t.Errorf("live = %d %q", w.Code, w.Body)
+	}
+}
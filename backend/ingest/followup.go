@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+const summaryQuery = "Summarize this document in three to five sentences for someone deciding whether to read it. Name its subject, its main findings and any figures that stand out."
+
+// Followup does what comes after a document's chunks are saved: the model
+// calls are recorded as usage, and the summary is regenerated from the new
+// chunks. Uploads, the reingest route and the reingest command share it, so
+// their calls are accounted for alike.
+type Followup struct {
+	AI        ai.Provider
+	Documents store.DocumentStore
+	Usage     store.UsageStore
+	// Quotas, if set, is charged for every call and checked before a
+	// summary is generated. The reingest command leaves it nil, so its calls
+	// are recorded without counting against anyone's quota.
+	Quotas *limits.Quotas
+	// SummaryChunks is how many leading chunks are summarized; 0 turns
+	// summaries off.
+	SummaryChunks int
+}
+
+// Record stores the tokens and estimated cost of a model call and charges
+// them to record.UserID. Failures are logged; the call has already been
+// made.
+func (f *Followup) Record(ctx context.Context, record store.LLMUsage, usage ai.Usage) {
+	record.Model = usage.Model
+	record.PromptTokens = usage.PromptTokens
+	record.ResponseTokens = usage.ResponseTokens
+	record.CostUSD = ai.EstimateCost(usage)
+	if err := f.Usage.Record(ctx, record); err != nil {
+		logging.FromContext(ctx).Error("failed to record LLM usage", "document_id", record.DocumentID, "error", err)
+	}
+	if f.Quotas == nil {
+		return
+	}
+	if err := f.Quotas.Charge(ctx, record.UserID, limits.MetricLLMTokens, usage.TotalTokens()); err != nil {
+		logging.FromContext(ctx).Error("failed to charge LLM quota", "error", err)
+	}
+}
+
+// RecordEmbedding records what embedding res's chunks cost, if an embedder
+// was called.
+func (f *Followup) RecordEmbedding(ctx context.Context, userID, docID string, res *Result) {
+	if res.EmbedUsage.Model == "" {
+		return
+	}
+	f.Record(ctx, store.LLMUsage{UserID: userID, DocumentID: docID, Operation: store.OperationEmbed}, res.EmbedUsage)
+}
+
+// Summarize asks the model for a short summary of docID from its first
+// SummaryChunks chunks, charges the call to userID and saves the summary.
+// It does nothing when summaries are off or there are no chunks, and
+// returns limits.ErrQuotaExceeded without calling the model once userID's
+// token quota is used up. A document deleted meanwhile is not an error.
+func (f *Followup) Summarize(ctx context.Context, userID, docID string, chunks []string) error {
+	n := min(f.SummaryChunks, len(chunks))
+	if n <= 0 {
+		return nil
+	}
+	if f.Quotas != nil {
+		if _, err := f.Quotas.Check(ctx, userID, limits.MetricLLMTokens); err != nil {
+			return err
+		}
+	}
+	summary, usage, err := f.AI.GenerateInsight(ctx, chunks[:n], summaryQuery)
+	if err != nil {
+		return err
+	}
+	f.Record(ctx, store.LLMUsage{UserID: userID, DocumentID: docID, Operation: store.OperationGenerate}, usage)
+	if err := f.Documents.SetSummary(ctx, docID, strings.TrimSpace(summary)); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package ingest turns an original file into the chunks stored for a
+// document. Uploads and re-ingestion share it, so re-processing a file
+// yields what uploading it again would.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/metrics"
+	"github.com/malharg/strategic-insight-analyst/backend/processing"
+	"github.com/malharg/strategic-insight-analyst/backend/storage"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// PipelineVersion identifies what extraction and chunking produce. Bump it
+// whenever a change to either would give existing files different chunks,
+// then run the reingest command to bring stored documents up to date.
+// Documents ingested before versions were recorded have 0.
+const PipelineVersion = 1
+
+// Errors wrapped by Reingest, telling which stage failed.
+var (
+	ErrDownload = errors.New("could not download original file")
+	ErrExtract  = errors.New("could not extract text")
+)
+
+// Result is a file run through the pipeline.
+type Result struct {
+	Extraction *processing.Extraction
+	Chunks     []store.Chunk
+	// EmbedUsage is what embedding the chunks cost; its model is empty when
+	// no embedder was called.
+	EmbedUsage ai.Usage
+	// Embedded reports whether the chunks carry embeddings.
+	Embedded bool
+}
+
+// Process extracts the text of a file, chunks it and, given an embedder,
+// embeds the chunks. The file name selects the extractor. Embedding is best
+// effort: if it fails the chunks are returned without embeddings and the
+// failure is logged.
+func Process(ctx context.Context, data []byte, fileName string, chunking config.ChunkingConfig, embedder ai.Embedder) (*Result, error) {
+	start := time.Now()
+	ext, err := processing.Extract(ctx, data, fileName)
+	if err != nil {
+		return nil, err
+	}
+	metrics.ObserveStage(metrics.StageExtract, start)
+
+	start = time.Now()
+	texts := processing.ChunkPages(ext, chunking.Size, chunking.Overlap)
+	metrics.ObserveStage(metrics.StageChunk, start)
+	metrics.ObserveChunks(len(texts))
+
+	res := &Result{Extraction: ext, Chunks: make([]store.Chunk, len(texts))}
+	contents := make([]string, len(texts))
+	for i, c := range texts {
+		res.Chunks[i] = store.Chunk{Content: c.Text, Page: c.Page}
+		contents[i] = c.Text
+	}
+	if embedder == nil || len(contents) == 0 {
+		return res, nil
+	}
+
+	start = time.Now()
+	embeddings, usage, err := embedder.Embed(ctx, contents)
+	res.EmbedUsage = usage
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to embed chunks; saving them without embeddings", "chunks", len(contents), "error", err)
+		return res, nil
+	}
+	metrics.ObserveStage(metrics.StageEmbed, start)
+	for i := range res.Chunks {
+		res.Chunks[i].Embedding = embeddings[i]
+	}
+	res.Embedded = true
+	return res, nil
+}
+
+// Reingest fetches doc's original file from storage, runs it through the
+// current pipeline and swaps the new chunks and their embeddings in for the
+// old ones in one transaction. Until then the document keeps answering from
+// its old chunks, and on failure it is left as it was. The swap clears the
+// summary, which described the old chunks.
+func Reingest(ctx context.Context, files storage.Storage, docs store.DocumentStore, chunking config.ChunkingConfig, embedder ai.Embedder, doc *store.Document) (*Result, error) {
+	data, err := files.Download(ctx, doc.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDownload, err)
+	}
+	// The stored file keeps its original name, whereas the document may
+	// have been renamed since.
+	fileName := filepath.Base(doc.StoragePath)
+	res, err := Process(ctx, data, fileName, chunking, embedder)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExtract, err)
+	}
+
+	updated := *doc
+	updated.ContentType = processing.ContentType(fileName)
+	updated.SizeBytes = int64(len(data))
+	updated.PageCount = res.Extraction.Pages
+	updated.Warnings = res.Extraction.Warnings
+	updated.PipelineVersion = PipelineVersion
+	start := time.Now()
+	if err := docs.ReplaceChunks(ctx, updated, res.Chunks); err != nil {
+		return nil, err
+	}
+	metrics.ObserveStage(metrics.StageSave, start)
+	return res, nil
+}
@@ -0,0 +1,194 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/ingest"
+	"github.com/malharg/strategic-insight-analyst/backend/limits"
+	"github.com/malharg/strategic-insight-analyst/backend/storage"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+	"github.com/malharg/strategic-insight-analyst/backend/store/storetest"
+)
+
+var chunking = config.ChunkingConfig{Size: 100, Overlap: 0}
+
+// fakeEmbedder embeds each text as its length, or fails with err.
+type fakeEmbedder struct{ err error }
+
+func (e fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, ai.Usage, error) {
+	usage := ai.Usage{Model: "fake-embed", PromptTokens: int64(len(texts))}
+	if e.err != nil {
+		return nil, usage, e.err
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, t := range texts {
+		embeddings[i] = []float32{float32(len(t))}
+	}
+	return embeddings, usage, nil
+}
+
+func TestProcess(t *testing.T) {
+	text := []byte(strings.Repeat("Revenue grew. ", 20)) // 280 characters
+
+	for _, tt := range []struct {
+		name     string
+		embedder ai.Embedder
+		embedded bool
+		model    string
+	}{
+		{"without embedder", nil, false, ""},
+		{"embedded", fakeEmbedder{}, true, "fake-embed"},
+		{"embedding failure keeps the chunks", fakeEmbedder{err: errors.New("quota exceeded")}, false, "fake-embed"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ingest.Process(context.Background(), text, "q3.txt", chunking, tt.embedder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Chunks) != 3 || res.Chunks[0].Content != string(text[:100]) || res.Extraction.Text != string(text) {
+				t.Fatalf("chunks = %+v", res.Chunks)
+			}
+			if res.Embedded != tt.embedded || res.EmbedUsage.Model != tt.model {
+				t.Errorf("embedded %v with usage %+v; want %v, model %q", res.Embedded, res.EmbedUsage, tt.embedded, tt.model)
+			}
+			for i, c := range res.Chunks {
+				if (c.Embedding != nil) != tt.embedded {
+					t.Errorf("chunk %d embedding = %v", i, c.Embedding)
+				}
+			}
+		})
+	}
+
+	if _, err := ingest.Process(context.Background(), text, "deck.pptx", chunking, nil); err == nil {
+		t.Error("processing an unsupported file succeeded")
+	}
+}
+
+func TestReingest(t *testing.T) {
+	ctx := context.Background()
+	stores := storetest.New()
+	files := storage.NewLocal(t.TempDir())
+	doc := store.Document{ID: "doc-1", UserID: "alice", FileName: "Renamed report", StoragePath: "alice/doc-1/q3.txt"}
+	if err := stores.Documents.Create(ctx, doc, []store.Chunk{{Content: "Old chunk."}}); err != nil {
+		t.Fatal(err)
+	}
+	stores.Documents.SetSummary(ctx, doc.ID, "About the old chunk.")
+	if err := files.Upload(ctx, doc.StoragePath, "text/plain", []byte(strings.Repeat("a", 150))); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ingest.Reingest(ctx, files, stores.Documents, chunking, fakeEmbedder{}, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Chunks) != 2 || !res.Embedded {
+		t.Errorf("result = %+v", res)
+	}
+	got, _ := stores.Documents.Get(ctx, doc.ID)
+	// The stored file's name, not the display name, picks the extractor.
+	if got.ChunkCount != 2 || got.SizeBytes != 150 || got.ContentType != "text/plain" || got.PipelineVersion != ingest.PipelineVersion || got.Summary != "" || got.FileName != "Renamed report" {
+		t.Errorf("document after Reingest = %+v", got)
+	}
+	if chunks, _ := stores.Chunks.ListByDocument(ctx, doc.ID); len(chunks) != 2 || !chunks[1].Embedded {
+		t.Errorf("chunks after Reingest = %+v", chunks)
+	}
+
+	missing := doc
+	missing.StoragePath = "alice/doc-1/gone.txt"
+	if _, err := ingest.Reingest(ctx, files, stores.Documents, chunking, nil, &missing); !errors.Is(err, ingest.ErrDownload) {
+		t.Errorf("missing original: %v, want ErrDownload", err)
+	}
+
+	unsupported := doc
+	unsupported.StoragePath = "alice/doc-1/deck.pptx"
+	files.Upload(ctx, unsupported.StoragePath, "application/octet-stream", []byte("slides"))
+	if _, err := ingest.Reingest(ctx, files, stores.Documents, chunking, nil, &unsupported); !errors.Is(err, ingest.ErrExtract) {
+		t.Errorf("unsupported original: %v, want ErrExtract", err)
+	}
+	if chunks, _ := stores.Chunks.ListByDocument(ctx, doc.ID); len(chunks) != 2 {
+		t.Errorf("a failed re-ingest changed the chunks: %+v", chunks)
+	}
+}
+
+// summaryAI joins the chunks it was asked to summarize.
+type summaryAI struct{}
+
+func (summaryAI) GenerateInsight(ctx context.Context, chunks []string, query string) (string, ai.Usage, error) {
+	return "  " + strings.Join(chunks, "+") + "\n", ai.Usage{Model: "fake"}, nil
+}
+
+func TestFollowupSummarize(t *testing.T) {
+	ctx := context.Background()
+	stores := storetest.New()
+	doc := store.Document{ID: "doc-1", UserID: "alice", FileName: "a.txt", StoragePath: "alice/doc-1/a.txt"}
+	if err := stores.Documents.Create(ctx, doc, []store.Chunk{{Content: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	f := &ingest.Followup{AI: summaryAI{}, Documents: stores.Documents, Usage: stores.Usage, SummaryChunks: 2}
+
+	if err := f.Summarize(ctx, "alice", doc.ID, []string{"a", "b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := stores.Documents.Get(ctx, doc.ID); got.Summary != "a+b" {
+		t.Errorf("summary = %q, want one of the first 2 chunks, trimmed", got.Summary)
+	}
+	if usage := storetest.Usage(stores); len(usage) != 1 || usage[0].UserID != "alice" || usage[0].DocumentID != doc.ID || usage[0].Operation != store.OperationGenerate || usage[0].Model != "fake" {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	if err := f.Summarize(ctx, "alice", doc.ID, []string{"z"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := stores.Documents.Get(ctx, doc.ID); got.Summary != "z" {
+		t.Errorf("summary of fewer chunks than SummaryChunks = %q", got.Summary)
+	}
+	if err := f.Summarize(ctx, "alice", "deleted", []string{"a"}); err != nil {
+		t.Errorf("summarizing a deleted document: %v", err)
+	}
+
+	// With quotas, a used-up quota skips the call and each call is charged.
+	quotas := limits.NewQuotas(stores.Quotas, config.LimitsConfig{LLMTokensDaily: 10})
+	f.Quotas = quotas
+	if err := quotas.Charge(ctx, "bob", limits.MetricLLMTokens, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Summarize(ctx, "bob", doc.ID, []string{"b"}); !errors.Is(err, limits.ErrQuotaExceeded) {
+		t.Errorf("over quota: %v, want ErrQuotaExceeded", err)
+	}
+	if n := len(storetest.Usage(stores)); n != 3 {
+		t.Errorf("%d usage records, want no call over quota", n)
+	}
+
+	f.SummaryChunks = 0
+	if err := f.Summarize(ctx, "alice", doc.ID, []string{"q"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := stores.Documents.Get(ctx, doc.ID); got.Summary != "z" {
+		t.Errorf("summaries off changed the summary to %q", got.Summary)
+	}
+}
+
+func TestFollowupRecordEmbedding(t *testing.T) {
+	ctx := context.Background()
+	stores := storetest.New()
+	quotas := limits.NewQuotas(stores.Quotas, config.LimitsConfig{LLMTokensDaily: 100})
+	f := &ingest.Followup{Usage: stores.Usage, Quotas: quotas}
+
+	f.RecordEmbedding(ctx, "alice", "doc-1", &ingest.Result{})
+	f.RecordEmbedding(ctx, "alice", "doc-1", &ingest.Result{EmbedUsage: ai.Usage{Model: "fake-embed", PromptTokens: 40}})
+	if usage := storetest.Usage(stores); len(usage) != 1 || usage[0].Operation != store.OperationEmbed || usage[0].PromptTokens != 40 {
+		t.Errorf("usage = %+v, want only the call that was made", usage)
+	}
+	statuses, err := quotas.Status(ctx, "alice", limits.MetricLLMTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].Used != 40 {
+		t.Errorf("charged %d tokens, want 40", statuses[0].Used)
+	}
+}
@@ -0,0 +1,153 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// Metrics with quotas.
+const (
+	MetricLLMTokens = "llm_tokens"
+	MetricUploads   = "uploads"
+)
+
+// ErrQuotaExceeded is returned by Check when a quota is used up.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Limit caps a metric per UTC day and per UTC month. Zero means unlimited.
+type Limit struct {
+	Daily   int64
+	Monthly int64
+}
+
+// QuotaStatus is the state of one metric for one period. Limit and Remaining
+// are nil when the period is unlimited.
+type QuotaStatus struct {
+	Metric    string    `json:"metric"`
+	Period    string    `json:"period"` // "day" or "month"
+	Limit     *int64    `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining *int64    `json:"remaining"`
+	ResetsAt  time.Time `json:"resetsAt"`
+}
+
+// Quotas checks and records usage against daily and monthly limits. Totals
+// are kept in the database so they survive restarts.
+type Quotas struct {
+	Store  store.QuotaStore
+	Limits map[string]Limit
+	now    func() time.Time
+}
+
+// NewQuotas returns quotas for LLM tokens and uploads configured by cfg.
+func NewQuotas(s store.QuotaStore, cfg config.LimitsConfig) *Quotas {
+	return &Quotas{
+		Store: s,
+		Limits: map[string]Limit{
+			MetricLLMTokens: {Daily: cfg.LLMTokensDaily, Monthly: cfg.LLMTokensMonthly},
+			MetricUploads:   {Daily: cfg.UploadsDaily, Monthly: cfg.UploadsMonthly},
+		},
+		now: time.Now,
+	}
+}
+
+type period struct {
+	name     string
+	key      string
+	limit    int64
+	resetsAt time.Time
+}
+
+func (q *Quotas) periods(metric string) []period {
+	now := q.now().UTC()
+	limit := q.Limits[metric]
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return []period{
+		{name: "day", key: day.Format("2006-01-02"), limit: limit.Daily, resetsAt: day.AddDate(0, 0, 1)},
+		{name: "month", key: month.Format("2006-01"), limit: limit.Monthly, resetsAt: month.AddDate(0, 1, 0)},
+	}
+}
+
+// Status reports usage of metric for the current day and month.
+func (q *Quotas) Status(ctx context.Context, userID, metric string) ([]QuotaStatus, error) {
+	var statuses []QuotaStatus
+	for _, p := range q.periods(metric) {
+		used, err := q.Store.Used(ctx, userID, metric, p.key)
+		if err != nil {
+			return nil, err
+		}
+		s := QuotaStatus{Metric: metric, Period: p.name, Used: used, ResetsAt: p.resetsAt}
+		if p.limit > 0 {
+			limit, remaining := p.limit, max(p.limit-used, 0)
+			s.Limit, s.Remaining = &limit, &remaining
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Check returns ErrQuotaExceeded, and the time until the exhausted period
+// resets, if the user has no quota left for metric.
+func (q *Quotas) Check(ctx context.Context, userID, metric string) (time.Duration, error) {
+	statuses, err := q.Status(ctx, userID, metric)
+	if err != nil {
+		return 0, err
+	}
+	// Report the longest wait when both the day and the month are used up.
+	var wait time.Duration
+	for _, s := range statuses {
+		if s.Remaining != nil && *s.Remaining == 0 {
+			wait = max(wait, s.ResetsAt.Sub(q.now()))
+		}
+	}
+	if wait > 0 {
+		return wait, fmt.Errorf("%w: %s", ErrQuotaExceeded, metric)
+	}
+	return 0, nil
+}
+
+// Charge records amount of metric against the user's day and month. Usage is
+// recorded even past the limit; the next Check rejects.
+func (q *Quotas) Charge(ctx context.Context, userID, metric string, amount int64) error {
+	if amount <= 0 {
+		return nil
+	}
+	periods := q.periods(metric)
+	keys := make([]string, len(periods))
+	for i, p := range periods {
+		keys[i] = p.key
+	}
+	return q.Store.Add(ctx, userID, metric, keys, amount)
+}
+
+// Require rejects requests with 429 once the caller's quota for metric is
+// used up. It must run after auth.Middleware.
+func (q *Quotas) Require(metric string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(auth.UserIDKey).(string)
+			wait, err := q.Check(r.Context(), userID, metric)
+			if errors.Is(err, ErrQuotaExceeded) {
+				w.Header().Set("Retry-After", retryAfterSeconds(wait))
+				apierror.Write(w, r, http.StatusTooManyRequests, apierror.CodeQuotaExceeded, "Usage quota exceeded. See /api/quota for details.")
+				return
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).Error("failed to check quota", "metric", metric, "error", err)
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check usage quota.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/store/storetest"
+)
+
+func newTestQuotas(cfg config.LimitsConfig) (*Quotas, *clock) {
+	c := &clock{t: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	q := NewQuotas(storetest.New().Quotas, cfg)
+	q.now = c.now
+	return q, c
+}
+
+func TestQuotaCheckAndCharge(t *testing.T) {
+	ctx := context.Background()
+	q, c := newTestQuotas(config.LimitsConfig{LLMTokensDaily: 100, LLMTokensMonthly: 250})
+
+	if _, err := q.Check(ctx, "alice", MetricLLMTokens); err != nil {
+		t.Fatalf("Check before any usage: %v", err)
+	}
+	// Usage is charged even past the limit; the next check rejects.
+	if err := q.Charge(ctx, "alice", MetricLLMTokens, 120); err != nil {
+		t.Fatal(err)
+	}
+	wait, err := q.Check(ctx, "alice", MetricLLMTokens)
+	if !errors.Is(err, ErrQuotaExceeded) || wait != 12*time.Hour {
+		t.Fatalf("Check after the daily limit = %v, %v; want ErrQuotaExceeded until midnight", wait, err)
+	}
+	if _, err := q.Check(ctx, "bob", MetricLLMTokens); err != nil {
+		t.Errorf("bob was charged for alice's usage: %v", err)
+	}
+	if _, err := q.Check(ctx, "alice", MetricUploads); err != nil {
+		t.Errorf("uploads were charged for tokens: %v", err)
+	}
+
+	statuses, err := q.Status(ctx, "alice", MetricLLMTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, month := statuses[0], statuses[1]
+	if day.Period != "day" || day.Used != 120 || *day.Limit != 100 || *day.Remaining != 0 {
+		t.Errorf("day = %+v", day)
+	}
+	if month.Period != "month" || month.Used != 120 || *month.Remaining != 130 || !month.ResetsAt.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("month = %+v", month)
+	}
+
+	// A new day starts a fresh daily quota but keeps the month's total.
+	c.advance(24 * time.Hour)
+	if _, err := q.Check(ctx, "alice", MetricLLMTokens); err != nil {
+		t.Fatalf("Check the next day: %v", err)
+	}
+	q.Charge(ctx, "alice", MetricLLMTokens, 90)
+	q.Charge(ctx, "alice", MetricLLMTokens, 50)
+	// Both periods are used up; the longer wait is reported.
+	wait, err = q.Check(ctx, "alice", MetricLLMTokens)
+	if !errors.Is(err, ErrQuotaExceeded) || wait != time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC).Sub(c.now()) {
+		t.Errorf("Check with both periods used up = %v, %v; want the wait until the month resets", wait, err)
+	}
+}
+
+func TestUnlimitedQuota(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQuotas(config.LimitsConfig{})
+	q.Charge(ctx, "alice", MetricUploads, 1_000_000)
+	if _, err := q.Check(ctx, "alice", MetricUploads); err != nil {
+		t.Errorf("Check with no limits: %v", err)
+	}
+	statuses, _ := q.Status(ctx, "alice", MetricUploads)
+	for _, s := range statuses {
+		if s.Limit != nil || s.Remaining != nil || s.Used != 1_000_000 {
+			t.Errorf("unlimited status = %+v", s)
+		}
+	}
+}
+
+func TestRequireQuota(t *testing.T) {
+	q, _ := newTestQuotas(config.LimitsConfig{UploadsDaily: 1})
+	h := q.Require(MetricUploads)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q.Charge(r.Context(), "alice", MetricUploads, 1)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/documents/upload", nil)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, "alice"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve(); w.Code != http.StatusOK {
+		t.Fatalf("first upload: status %d", w.Code)
+	}
+	w := serve()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "43200" {
+		t.Errorf("second upload: status %d, Retry-After %q; want 429 until midnight", w.Code, w.Header().Get("Retry-After"))
+	}
+}
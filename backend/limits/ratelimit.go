@@ -0,0 +1,132 @@
+// Package limits enforces per-user request rates and usage quotas.
+package limits
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+)
+
+// Rule is a token bucket that refills PerMinute tokens a minute and holds at
+// most a minute's worth. A zero PerMinute disables the limit.
+type Rule struct {
+	// Name, when set, gives every route using the rule one shared bucket per
+	// user, e.g. a route and its deprecated alias. Otherwise each route has
+	// its own.
+	Name      string
+	PerMinute int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter keeps one token bucket per user and route in memory. Buckets
+// are cheap to lose: a restart only hands everyone a full bucket.
+type RateLimiter struct {
+	// Default applies to routes without an entry in Routes.
+	Default Rule
+	// Routes maps a mux pattern, such as "POST /api/v1/documents", to its
+	// rule.
+	Routes map[string]Rule
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter returns a limiter using def for every route not in routes.
+func NewRateLimiter(def Rule, routes map[string]Rule) *RateLimiter {
+	return &RateLimiter{Default: def, Routes: routes, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (l *RateLimiter) rule(route string) Rule {
+	if rule, ok := l.Routes[route]; ok {
+		return rule
+	}
+	return l.Default
+}
+
+// Allow takes a token from the bucket for key. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *RateLimiter) Allow(key string, rule Rule) (bool, time.Duration) {
+	if rule.PerMinute <= 0 {
+		return true, 0
+	}
+	capacity := float64(rule.PerMinute)
+	perSecond := capacity / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have been idle for long enough to be full again,
+// which is every bucket idle for a minute or more.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware limits authenticated requests per user and route. It must run
+// after auth.Middleware.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(auth.UserIDKey).(string)
+		// Limit by pattern, so /api/v1/documents/{id} is one route however
+		// many documents there are.
+		route := r.Pattern
+		if route == "" {
+			route = r.URL.Path
+		}
+		rule := l.rule(route)
+		bucket := route
+		if rule.Name != "" {
+			bucket = rule.Name
+		}
+
+		if ok, wait := l.Allow(userID+" "+bucket, rule); !ok {
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rule.PerMinute))
+			apierror.Write(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "Rate limit exceeded. Please slow down.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// retryAfterSeconds rounds up so clients never retry too early.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
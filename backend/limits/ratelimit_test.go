@@ -0,0 +1,153 @@
+package limits
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+)
+
+// clock is a fake time source that only moves when told to.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(def Rule, routes map[string]Rule) (*RateLimiter, *clock) {
+	c := &clock{t: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(def, routes)
+	l.now = c.now
+	return l, c
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, c := newTestLimiter(Rule{}, nil)
+	rule := Rule{PerMinute: 3}
+
+	// A new bucket holds a minute's worth of tokens.
+	for i := range 3 {
+		if ok, _ := l.Allow("alice", rule); !ok {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+	ok, wait := l.Allow("alice", rule)
+	if ok || wait != 20*time.Second {
+		t.Fatalf("fourth request = %v, wait %v; want rejected with a 20s wait", ok, wait)
+	}
+
+	// Other keys have their own bucket.
+	if ok, _ := l.Allow("bob", rule); !ok {
+		t.Error("bob was limited by alice's requests")
+	}
+
+	// Tokens refill at PerMinute a minute.
+	c.advance(10 * time.Second)
+	if ok, wait := l.Allow("alice", rule); ok || wait != 10*time.Second {
+		t.Errorf("after 10s = %v, wait %v; want rejected with a 10s wait", ok, wait)
+	}
+	c.advance(10 * time.Second)
+	if ok, _ := l.Allow("alice", rule); !ok {
+		t.Error("rejected after a token refilled")
+	}
+
+	// The bucket never holds more than a minute's worth.
+	c.advance(time.Hour)
+	for range 3 {
+		l.Allow("alice", rule)
+	}
+	if ok, _ := l.Allow("alice", rule); ok {
+		t.Error("an idle bucket filled beyond its capacity")
+	}
+}
+
+func TestZeroRuleIsUnlimited(t *testing.T) {
+	l, _ := newTestLimiter(Rule{}, nil)
+	for range 1000 {
+		if ok, _ := l.Allow("alice", Rule{}); !ok {
+			t.Fatal("a disabled rule rejected a request")
+		}
+	}
+}
+
+func TestSweepDropsIdleBuckets(t *testing.T) {
+	l, c := newTestLimiter(Rule{}, nil)
+	l.Allow("alice", Rule{PerMinute: 1})
+	c.advance(2 * time.Minute)
+	l.Allow("bob", Rule{PerMinute: 1})
+	if _, ok := l.buckets["alice"]; ok || len(l.buckets) != 1 {
+		t.Errorf("buckets after sweeping = %v, want only bob's", l.buckets)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	l, _ := newTestLimiter(Rule{PerMinute: 5}, map[string]Rule{"/api/chat": {PerMinute: 1}})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(userID, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, userID))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("alice", "/api/chat"); w.Code != http.StatusOK {
+		t.Fatalf("first chat: status %d", w.Code)
+	}
+	w := serve("alice", "/api/chat")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" || w.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("second chat: status %d, headers %v; want 429 with Retry-After 60", w.Code, w.Header())
+	}
+	// Routes and users are limited separately.
+	if w := serve("alice", "/api/documents"); w.Code != http.StatusOK {
+		t.Errorf("documents after chat: status %d", w.Code)
+	}
+	if w := serve("bob", "/api/chat"); w.Code != http.StatusOK {
+		t.Errorf("bob's chat: status %d", w.Code)
+	}
+}
+
+func TestRateLimitByPattern(t *testing.T) {
+	chat := Rule{Name: "chat", PerMinute: 1}
+	l, _ := newTestLimiter(Rule{PerMinute: 5}, map[string]Rule{
+		"POST /api/v1/documents/{id}/chat": chat,
+		"/api/chat":                        chat,
+	})
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("POST /api/v1/documents/{id}/chat", l.Middleware(ok))
+	mux.Handle("/api/chat", l.Middleware(ok))
+	serve := func(path string) int {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, "alice"))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve("/api/v1/documents/doc-1/chat"); code != http.StatusOK {
+		t.Fatalf("first chat: status %d", code)
+	}
+	// Another document is the same route, and the alias shares the bucket.
+	if code := serve("/api/v1/documents/doc-2/chat"); code != http.StatusTooManyRequests {
+		t.Errorf("chat about another document: status %d, want 429", code)
+	}
+	if code := serve("/api/chat"); code != http.StatusTooManyRequests {
+		t.Errorf("chat through the alias: status %d, want 429", code)
+	}
+}
+
+func TestRetryAfterRoundsUp(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                       "0",
+		time.Millisecond:        "1",
+		time.Second:             "1",
+		1500 * time.Millisecond: "2",
+	} {
+		if got := retryAfterSeconds(d); got != want {
+			t.Errorf("retryAfterSeconds(%v) = %s, want %s", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package logging configures structured logging with log/slog and carries a
+// per-request logger through the request context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sensitive attribute keys. Their values are document contents or what users
+// typed, so they are replaced with a length marker unless debug logging is
+// explicitly enabled.
+const (
+	KeyQuery   = "query"
+	KeyAnswer  = "answer"
+	KeyContent = "content"
+	KeyPrompt  = "prompt"
+)
+
+var sensitiveKeys = map[string]bool{KeyQuery: true, KeyAnswer: true, KeyContent: true, KeyPrompt: true}
+
+// ParseLevel parses "debug", "info", "warn" or "error".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// New returns a logger writing to w in the configured format and level.
+func New(w io.Writer, cfg config.LogConfig) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactor(level <= slog.LevelDebug)}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (want text or json)", cfg.Format)
+	}
+	return slog.New(handler), nil
+}
+
+// Setup installs the configured logger as the slog and log default, so code
+// still using the log package ends up in the same stream.
+func Setup(cfg config.LogConfig) error {
+	logger, err := New(os.Stderr, cfg)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// redactor hides sensitive attribute values unless debug is enabled.
+func redactor(debug bool) func(groups []string, a slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if debug || !sensitiveKeys[a.Key] {
+			return a
+		}
+		return slog.String(a.Key, fmt.Sprintf("[redacted %d chars]", len(a.Value.String())))
+	}
+}
+
+// Fatal logs msg at error level and exits, for startup failures.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// FromContext returns the request logger stored by Middleware, or the default
+// logger outside a request. Lines logged inside a trace carry its trace ID.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		logger = logger.With("request_id", info.id, "method", info.method)
+		if uid := info.userID.Load(); uid != nil {
+			logger = logger.With("user_id", *uid)
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
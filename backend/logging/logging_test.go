@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{
+		"debug":  slog.LevelDebug,
+		"INFO":   slog.LevelInfo,
+		" warn ": slog.LevelWarn,
+		"error":  slog.LevelError,
+	} {
+		if got, err := ParseLevel(in); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel accepted an unknown level")
+	}
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LogConfig{Level: "warn", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "n", 1)
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("output %q is not one JSON line: %v", buf.String(), err)
+	}
+	if line["msg"] != "shown" || line["n"] != float64(1) {
+		t.Errorf("logged %v", line)
+	}
+
+	if _, err := New(&buf, config.LogConfig{Level: "info", Format: "xml"}); err == nil {
+		t.Error("New accepted an unknown format")
+	}
+	if _, err := New(&buf, config.LogConfig{Level: "loud"}); err == nil {
+		t.Error("New accepted an unknown level")
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	for _, tt := range []struct {
+		level    string
+		redacted bool
+	}{
+		{"info", true},
+		{"debug", false},
+	} {
+		var buf bytes.Buffer
+		logger, err := New(&buf, config.LogConfig{Level: tt.level})
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("chat", KeyQuery, "what is our churn?", KeyAnswer, "12%", "document_id", "doc-1")
+
+		out := buf.String()
+		if strings.Contains(out, "churn") == tt.redacted || strings.Contains(out, "12%") == tt.redacted {
+			t.Errorf("level %s: output %q, redacted = %v", tt.level, out, !tt.redacted)
+		}
+		if tt.redacted && !strings.Contains(out, `query="[redacted 18 chars]"`) {
+			t.Errorf("level %s: output %q lacks the length marker", tt.level, out)
+		}
+		if !strings.Contains(out, "document_id=doc-1") {
+			t.Errorf("level %s: output %q lost an ordinary attribute", tt.level, out)
+		}
+	}
+}
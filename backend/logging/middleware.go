@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+type contextKey string
+
+const requestInfoKey contextKey = "requestInfo"
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits which incoming request IDs are trusted, so callers
+// cannot inject arbitrary text into the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// unmatchedRoute is logged for requests that no route handled.
+const unmatchedRoute = "unmatched"
+
+type requestInfo struct {
+	id     string
+	method string
+	// route is set by SetRoute once the mux has picked a pattern. Paths can
+	// carry IDs and email addresses, so they are not logged.
+	route  string
+	userID atomic.Pointer[string]
+}
+
+// Middleware assigns each request an ID, echoed in the X-Request-ID header,
+// makes a request-scoped logger available through FromContext and logs one
+// line per request when it completes, naming the route set by SetRoute.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		info := &requestInfo{id: id, method: r.Method, route: unmatchedRoute}
+		w.Header().Set(RequestIDHeader, id)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		ctx := context.WithValue(r.Context(), requestInfoKey, info)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		}
+		FromContext(ctx).Log(ctx, level, "request completed",
+			"route", info.route,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+// SetUser records the authenticated user on the request so later log lines,
+// including the completion line, carry the user ID.
+func SetUser(ctx context.Context, userID string) {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		info.userID.Store(&userID)
+	}
+}
+
+// SetRoute records the mux pattern that served the request, read from
+// r.Pattern after the mux has run, for the completion line.
+func SetRoute(ctx context.Context, pattern string) {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok && pattern != "" {
+		info.route = pattern
+	}
+}
+
+// RequestID returns the current request's ID, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
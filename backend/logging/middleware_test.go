@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureLogs makes the default logger write JSON lines to the returned
+// buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, b := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var line map[string]any
+		if err := json.Unmarshal(b, &line); err != nil {
+			t.Fatalf("bad log line %q: %v", b, err)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func TestMiddleware(t *testing.T) {
+	buf := captureLogs(t)
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetUser(r.Context(), "alice")
+		SetRoute(r.Context(), "POST /api/v1/documents/{id}/chat")
+		FromContext(r.Context()).Info("handling", "request_id_seen", RequestID(r.Context()))
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("upstream failed"))
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/documents/doc-1/chat", nil)
+	r.Header.Set(RequestIDHeader, "trace-123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if got := w.Header().Get(RequestIDHeader); got != "trace-123" {
+		t.Errorf("echoed request ID %q, want the caller's", got)
+	}
+	logged := lines(t, buf)
+	if len(logged) != 2 {
+		t.Fatalf("logged %d lines, want 2: %s", len(logged), buf)
+	}
+	if l := logged[0]; l["request_id"] != "trace-123" || l["request_id_seen"] != "trace-123" || l["user_id"] != "alice" || l["method"] != "POST" {
+		t.Errorf("handler line = %v", l)
+	}
+	done := logged[1]
+	if done["msg"] != "request completed" || done["level"] != "ERROR" || done["status"] != float64(http.StatusBadGateway) ||
+		done["bytes"] != float64(len("upstream failed")) || done["user_id"] != "alice" || done["route"] != "POST /api/v1/documents/{id}/chat" {
+		t.Errorf("completion line = %v", done)
+	}
+}
+
+func TestUnmatchedRouteHidesPath(t *testing.T) {
+	buf := captureLogs(t)
+	h := Middleware(http.NotFoundHandler())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/orgs/o1/invites/carol@example.com", nil))
+	if strings.Contains(buf.String(), "carol@example.com") {
+		t.Errorf("the path was logged: %s", buf)
+	}
+	if logged := lines(t, buf); len(logged) != 1 || logged[0]["route"] != unmatchedRoute {
+		t.Errorf("logged %v, want one line with route %q", logged, unmatchedRoute)
+	}
+}
+
+func TestRequestIDIsGeneratedWhenMissingOrInvalid(t *testing.T) {
+	captureLogs(t)
+	var seen []string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, RequestID(r.Context()))
+	}))
+	for _, incoming := range []string{"", "line\nbreak", string(bytes.Repeat([]byte("a"), 65))} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if incoming != "" {
+			r.Header.Set(RequestIDHeader, incoming)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if id := w.Header().Get(RequestIDHeader); id == "" || id == incoming || !validRequestID.MatchString(id) {
+			t.Errorf("incoming %q: request ID %q", incoming, id)
+		}
+	}
+	if len(seen) != 3 || seen[0] == seen[1] {
+		t.Errorf("request IDs seen by the handler = %q, want distinct IDs", seen)
+	}
+}
+
+func TestOutsideRequest(t *testing.T) {
+	ctx := context.Background()
+	if RequestID(ctx) != "" {
+		t.Error("RequestID outside a request is not empty")
+	}
+	SetUser(ctx, "alice") // must not panic
+	if FromContext(ctx) != slog.Default() {
+		t.Error("FromContext outside a request is not the default logger")
+	}
+}
+
+func TestFromContextAddsTraceID(t *testing.T) {
+	buf := captureLogs(t)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	FromContext(ctx).Info("traced")
+	FromContext(context.Background()).Info("untraced")
+	logged := lines(t, buf)
+	if logged[0]["trace_id"] != traceID.String() {
+		t.Errorf("traced line = %v, want trace_id %s", logged[0], traceID)
+	}
+	if _, ok := logged[1]["trace_id"]; ok {
+		t.Errorf("untraced line = %v has a trace_id", logged[1])
+	}
+}
@@ -1,105 +1,186 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	"github.com/malharg/strategic-insight-analyst/backend/app"
 	"github.com/malharg/strategic-insight-analyst/backend/auth"
 	"github.com/malharg/strategic-insight-analyst/backend/config"
 	"github.com/malharg/strategic-insight-analyst/backend/database"
-	"github.com/malharg/strategic-insight-analyst/backend/handlers"
-	"github.com/rs/cors"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/storage"
+	"github.com/malharg/strategic-insight-analyst/backend/tracing"
 	"github.com/unidoc/unipdf/v3/common/license"
 )
 
 func main() {
-	config.LoadConfig()
-	err := license.SetMeteredKey(config.AppConfig.UnidocLicenseKey)
-	if err != nil {
-		log.Fatalf("FATAL: Failed to set UniDoc license key: %v", err)
+	if err := logging.Setup(config.LoadLog()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	log.Println("UniDoc license key set successfully.")
-	database.InitDB("./sia.db")
-	auth.InitFirebaseAuth()
-
-	// Main router
-	mux := http.NewServeMux()
 
-	// --- Public Routes ---
-	mux.HandleFunc("/api/health", healthCheckHandler)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrateCommand(os.Args[2:])
+			return
+		case "token":
+			runTokenCommand(os.Args[2:])
+			return
+		case "config":
+			runConfigCommand(os.Args[2:])
+			return
+		case "openapi":
+			runOpenAPICommand(os.Args[2:])
+			return
+		case "reingest":
+			runReingestCommand(os.Args[2:])
+			return
+		}
+	}
 
-	//  public route for testing Supabase upload
-	mux.HandleFunc("/api/test-supabase-upload", handlers.TestSupabaseUploadHandler)
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	}
+	setupPDF(cfg)
 
-	// public route for minimal direct Supabase upload (no SDK)
-	mux.HandleFunc("/api/minimal-supabase-upload", handlers.MinimalSupabaseUploadHandler)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logging.Fatal("failed to initialize tracing", "error", err)
+	}
 
-	// --- Protected Routes ---
-	// Handler for the secure ping test
-	securePingHandler := http.HandlerFunc(securePingHandlerFunc)
-	mux.Handle("/api/secure-ping", auth.AuthMiddleware(securePingHandler))
+	db := database.InitDB(cfg.DatabaseURL)
 
-	// ADDED: Handler for document uploads. It's also protected by the auth middleware.
-	uploadHandler := http.HandlerFunc(handlers.UploadDocumentHandler)
-	mux.Handle("/api/documents/upload", auth.AuthMiddleware(uploadHandler))
+	verifier, err := auth.NewVerifier(context.Background(), cfg.Auth)
+	if err != nil {
+		logging.Fatal("failed to initialize authentication", "error", err)
+	}
 
-	// chat handler for handling user chats
-	chatHandler := http.HandlerFunc(handlers.ChatHandler)
-	mux.Handle("/api/chat", auth.AuthMiddleware(chatHandler))
+	gemini := newGemini(cfg)
+	srv, err := app.New(app.Options{
+		Config:   cfg,
+		DB:       db,
+		Verifier: verifier,
+		Storage:  newStorage(cfg),
+		AI:       gemini,
+		Embedder: newEmbedder(cfg, gemini),
+	})
+	if err != nil {
+		logging.Fatal("failed to build server", "error", err)
+	}
 
-	//doc handler route
-	listDocsHandler := http.HandlerFunc(handlers.ListDocumentsHandler)
-	mux.Handle("/api/documents", auth.AuthMiddleware(listDocsHandler))
+	// Cloud Run sends SIGTERM before stopping an instance; Ctrl-C sends SIGINT.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var metricsServer *http.Server
+	if cfg.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", srv.Metrics())
+		metricsServer = &http.Server{Addr: cfg.Metrics.Addr, Handler: mux}
+		go func() {
+			slog.Info("metrics server starting", "addr", cfg.Metrics.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("metrics server stopped", "error", err)
+			}
+		}()
+	}
 
-	//  new route for deleting documents
-	deleteDocHandler := http.HandlerFunc(handlers.DeleteDocumentHandler)
-	mux.Handle("/api/documents/delete", auth.AuthMiddleware(deleteDocHandler))
+	slog.Info("backend server starting", "port", cfg.Server.Port)
 
-	// Configure CORS
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000", "https://strategic-insight-analyst-ndwn.vercel.app"}, // Your frontend URL
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
-		AllowCredentials: true,
-		Debug:            false,
-	})
+	server := &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      srv,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+	}
 
-	// Wrap the main router with the CORS middleware
-	handler := c.Handler(mux)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" // Default for local
+	select {
+	case err := <-serveErr:
+		logging.Fatal("server stopped", "error", err)
+	case <-ctx.Done():
 	}
-	log.Printf("Backend server starting on port %s\n", port)
-
-	// Use the CORS-wrapped handler
-	server := &http.Server{
-		Addr:         ":" + port,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second, // Increased timeout slightly for uploads
-		WriteTimeout: 15 * time.Second,
+	stop()
+
+	// Fail the readiness probe and keep serving for the drain delay, so load
+	// balancers stop routing here. Then stop accepting connections and let
+	// in-flight requests, including uploads being ingested, finish. Then wait
+	// for background work such as document summaries, flush traces and close
+	// the database, all within one deadline.
+	slog.Info("shutting down", "drain_delay", cfg.Server.DrainDelay, "timeout", cfg.Server.ShutdownTimeout)
+	srv.Drain()
+	time.Sleep(cfg.Server.DrainDelay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain in-flight requests", "error", err)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain background tasks", "error", err)
+	}
+	if metricsServer != nil {
+		metricsServer.Shutdown(shutdownCtx)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
 	}
+	if err := db.Close(); err != nil {
+		slog.Error("failed to close database", "error", err)
+	}
+	slog.Info("shutdown complete")
+}
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal(err)
+// setupPDF registers the UniDoc license. UniDoc is only needed for PDFs;
+// without a key PDF uploads are refused.
+func setupPDF(cfg *config.Config) {
+	if cfg.UnidocLicenseKey != "" {
+		if err := license.SetMeteredKey(cfg.UnidocLicenseKey); err != nil {
+			logging.Fatal("failed to set UniDoc license key", "error", err)
+		}
+		slog.Info("UniDoc license key set")
+	} else {
+		slog.Warn("UNIDOC_LICENSE_KEY is not set; PDF uploads are disabled")
 	}
 }
 
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+// newGemini returns the configured Gemini client.
+func newGemini(cfg *config.Config) *ai.Gemini {
+	gemini := ai.NewGemini(cfg.GeminiAPIKey, cfg.AI.Model, cfg.AI.Timeout)
+	gemini.EmbeddingModel = cfg.AI.EmbeddingModel
+	return gemini
 }
 
-func securePingHandlerFunc(w http.ResponseWriter, r *http.Request) {
-	// Retrieve the user ID from the context (set by the middleware)
-	userID := r.Context().Value(auth.UserIDKey).(string)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Pong! You are authenticated.",
-		"userID":  userID,
-	})
+// newEmbedder returns gemini if an embedding model is configured, or nil to
+// store chunks without embeddings.
+func newEmbedder(cfg *config.Config, gemini *ai.Gemini) ai.Embedder {
+	if cfg.AI.EmbeddingModel == "" {
+		return nil
+	}
+	return gemini
+}
+
+// newStorage returns the object store selected by the configuration.
+func newStorage(cfg *config.Config) storage.Storage {
+	if cfg.Storage.Provider == "local" {
+		return storage.NewLocal(cfg.Storage.Dir)
+	}
+	return storage.NewSupabase(cfg.SupabaseURL, cfg.SupabaseSvcKey, cfg.Storage.Bucket)
 }
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+)
+
+// unknownModel labels failed calls that did not report a model.
+const unknownModel = "unknown"
+
+// InstrumentProvider wraps p so every call records latency, token counts and
+// errors by model.
+func InstrumentProvider(p ai.Provider) ai.Provider {
+	return &instrumentedProvider{next: p}
+}
+
+type instrumentedProvider struct {
+	next ai.Provider
+}
+
+func (p *instrumentedProvider) GenerateInsight(ctx context.Context, chunks []string, userQuery string) (string, ai.Usage, error) {
+	start := time.Now()
+	answer, usage, err := p.next.GenerateInsight(ctx, chunks, userQuery)
+
+	model := usage.Model
+	if model == "" {
+		model = unknownModel
+	}
+	llmDuration.WithLabelValues(model).Observe(time.Since(start).Seconds())
+	if err != nil {
+		llmErrors.WithLabelValues(model).Inc()
+		return answer, usage, err
+	}
+	llmTokens.WithLabelValues(model, "prompt").Add(float64(usage.PromptTokens))
+	llmTokens.WithLabelValues(model, "response").Add(float64(usage.ResponseTokens))
+	return answer, usage, nil
+}
+
+// InstrumentEmbedder wraps e so every call records latency, token counts and
+// errors by model, like InstrumentProvider.
+func InstrumentEmbedder(e ai.Embedder) ai.Embedder {
+	return &instrumentedEmbedder{next: e}
+}
+
+type instrumentedEmbedder struct {
+	next ai.Embedder
+}
+
+func (e *instrumentedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, ai.Usage, error) {
+	start := time.Now()
+	embeddings, usage, err := e.next.Embed(ctx, texts)
+
+	model := usage.Model
+	if model == "" {
+		model = unknownModel
+	}
+	llmDuration.WithLabelValues(model).Observe(time.Since(start).Seconds())
+	if err != nil {
+		llmErrors.WithLabelValues(model).Inc()
+		return embeddings, usage, err
+	}
+	llmTokens.WithLabelValues(model, "prompt").Add(float64(usage.PromptTokens))
+	return embeddings, usage, nil
+}
@@ -0,0 +1,127 @@
+// Package metrics exposes Prometheus metrics for HTTP traffic, document
+// ingestion, LLM calls and the database connection pool.
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/apierror"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "sia"
+
+// Ingestion stages timed by ObserveStage.
+const (
+	StageStorageUpload = "storage_upload"
+	StageExtract       = "extract"
+	StageChunk         = "chunk"
+	StageEmbed         = "embed"
+	StageSave          = "save"
+)
+
+// Registry holds every collector served by Handler. A private registry keeps
+// the output limited to what this package registers.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method"})
+
+	ingestStage = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ingestion_stage_duration_seconds",
+		Help:      "Time spent in each document ingestion stage.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 40},
+	}, []string{"stage"})
+
+	documentChunks = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "document_chunks",
+		Help:      "Number of chunks produced per ingested document.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 13),
+	})
+
+	llmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "LLM call latency by model.",
+		Buckets:   []float64{.25, .5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"model"})
+
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "LLM tokens by model and kind (prompt or response).",
+	}, []string{"model", "kind"})
+
+	llmErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_errors_total",
+		Help:      "Failed LLM calls by model.",
+	}, []string{"model"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		ingestStage, documentChunks,
+		llmDuration, llmTokens, llmErrors,
+	)
+}
+
+// DBStats returns a registry exporting the connection pool statistics of db
+// under the given name. Each server keeps its own and serves it alongside
+// Registry, so servers on different databases each report their own pool.
+func DBStats(db *sql.DB, name string) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewDBStatsCollector(db, name))
+	return reg
+}
+
+// ObserveStage records how long an ingestion stage took since start.
+func ObserveStage(stage string, start time.Time) {
+	ingestStage.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
+// ObserveChunks records the number of chunks a document was split into.
+func ObserveChunks(n int) {
+	documentChunks.Observe(float64(n))
+}
+
+// Handler serves the metrics in Registry and extra in the Prometheus
+// exposition format. When token is set, requests must carry it as a bearer
+// token.
+func Handler(token string, extra ...prometheus.Gatherer) http.Handler {
+	gatherers := append(prometheus.Gatherers{Registry}, extra...)
+	h := promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeInvalidToken, "Unauthorized")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/ai"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// scrape returns every sample served by Handler, keyed by the series as it
+// appears in the exposition format, e.g. `sia_llm_errors_total{model="m"}`.
+func scrape(t *testing.T) map[string]float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Handler("").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape: status %d", w.Code)
+	}
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad sample %q", line)
+		}
+		samples[line[:i]] = v
+	}
+	return samples
+}
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/documents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := Middleware(mux, mux)
+
+	const (
+		matched   = `sia_http_requests_total{method="GET",route="/api/documents/{id}",status="418"}`
+		unmatched = `sia_http_requests_total{method="GET",route="unmatched",status="404"}`
+	)
+	before := scrape(t)
+	for _, path := range []string{"/api/documents/a", "/api/documents/b", "/nope/1", "/nope/2"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	after := scrape(t)
+
+	// Requests are labelled by pattern, so distinct paths share a series.
+	if got := after[matched] - before[matched]; got != 2 {
+		t.Errorf("%s grew by %v, want 2", matched, got)
+	}
+	if got := after[unmatched] - before[unmatched]; got != 2 {
+		t.Errorf("%s grew by %v, want 2", unmatched, got)
+	}
+	for series := range after {
+		if strings.Contains(series, "/nope/") || strings.Contains(series, "/api/documents/a") {
+			t.Errorf("raw path leaked into a label: %s", series)
+		}
+	}
+}
+
+type stubProvider struct {
+	usage ai.Usage
+	err   error
+}
+
+func (p stubProvider) GenerateInsight(ctx context.Context, chunks []string, query string) (string, ai.Usage, error) {
+	return "answer", p.usage, p.err
+}
+
+func TestInstrumentProvider(t *testing.T) {
+	const model = "test-model"
+	before := scrape(t)
+
+	ok := InstrumentProvider(stubProvider{usage: ai.Usage{Model: model, PromptTokens: 30, ResponseTokens: 5}})
+	if answer, usage, err := ok.GenerateInsight(context.Background(), nil, "q"); answer != "answer" || usage.PromptTokens != 30 || err != nil {
+		t.Fatalf("GenerateInsight = %q, %+v, %v; want the wrapped result", answer, usage, err)
+	}
+	failing := InstrumentProvider(stubProvider{err: errors.New("quota exceeded")})
+	if _, _, err := failing.GenerateInsight(context.Background(), nil, "q"); err == nil {
+		t.Fatal("error not passed through")
+	}
+	after := scrape(t)
+
+	for series, want := range map[string]float64{
+		`sia_llm_tokens_total{kind="prompt",model="test-model"}`:     30,
+		`sia_llm_tokens_total{kind="response",model="test-model"}`:   5,
+		`sia_llm_request_duration_seconds_count{model="test-model"}`: 1,
+		`sia_llm_errors_total{model="unknown"}`:                      1,
+		`sia_llm_errors_total{model="test-model"}`:                   0,
+	} {
+		if got := after[series] - before[series]; got != want {
+			t.Errorf("%s grew by %v, want %v", series, got, want)
+		}
+	}
+}
+
+func TestObserveIngestion(t *testing.T) {
+	before := scrape(t)
+	ObserveStage(StageExtract, time.Now().Add(-time.Second))
+	ObserveChunks(12)
+	after := scrape(t)
+
+	for series, want := range map[string]float64{
+		`sia_ingestion_stage_duration_seconds_count{stage="extract"}`:           1,
+		`sia_ingestion_stage_duration_seconds_bucket{stage="extract",le="0.5"}`: 0,
+		`sia_document_chunks_count`:                                             1,
+		`sia_document_chunks_bucket{le="16"}`:                                   1,
+	} {
+		if got := after[series] - before[series]; got != want {
+			t.Errorf("%s grew by %v, want %v", series, got, want)
+		}
+	}
+}
+
+func TestDBStats(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(4)
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	Handler("", DBStats(db, "test")).ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `go_sql_max_open_connections{db_name="test"} 4`) {
+		t.Error("pool statistics not exported")
+	}
+	if _, ok := scrape(t)[`go_sql_max_open_connections{db_name="test"}`]; ok {
+		t.Error("pool statistics registered process-wide")
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	h := Handler("s3cret")
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("Authorization %q: status %d, want %d", header, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+)
+
+const migrateUsage = `Usage: strategic-insight-analyst migrate <command> [flags]
+
+Commands:
+  status          Show every migration and whether it has been applied
+  up              Apply all pending migrations
+  down [-steps N] Roll back the last N applied migrations (default 1)
+`
+
+// runMigrateCommand implements the "migrate" subcommand.
+func runMigrateCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	db, err := database.Open(config.DatabaseURL())
+	if err != nil {
+		logging.Fatal("failed to connect to database", "error", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		states, err := database.MigrationStatus(db)
+		if err != nil {
+			logging.Fatal("failed to read migration status", "error", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range states {
+			status, appliedAt := "pending", "-"
+			if s.Applied {
+				status, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+		}
+		tw.Flush()
+
+	case "up":
+		n, err := database.Migrate(db)
+		if err != nil {
+			logging.Fatal("failed to migrate database", "error", err)
+		}
+		fmt.Printf("Applied %d migration(s).\n", n)
+
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "number of migrations to roll back")
+		fs.Parse(args[1:])
+		if *steps < 1 {
+			logging.Fatal("-steps must be at least 1")
+		}
+		n, err := database.MigrateDown(db, *steps)
+		if err != nil {
+			logging.Fatal("failed to roll back database", "error", err)
+		}
+		fmt.Printf("Rolled back %d migration(s).\n", n)
+
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
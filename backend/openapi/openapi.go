@@ -0,0 +1,296 @@
+// Package openapi builds an OpenAPI 3 document for the API. Schemas are
+// derived from the Go types the handlers decode and encode, so they follow
+// the code; operations are described by the caller.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation describes one route for the document.
+type Operation struct {
+	Summary string
+	Tag     string
+	// Query lists the query parameters. Path parameters are taken from the
+	// route pattern.
+	Query []Param
+	// Request is a value of the JSON request body type, or nil.
+	Request any
+	// Multipart names the file field of a multipart/form-data body, with
+	// Query listing its other form fields.
+	Multipart string
+	// Status is the success status, 200 if zero.
+	Status int
+	// Response is a value of the JSON success body type, or nil for none.
+	Response any
+	// ContentType overrides application/json for non-JSON success bodies
+	// such as file downloads.
+	ContentType string
+	// Errors lists the error statuses the route can answer with, besides the
+	// ones every authenticated route can.
+	Errors []int
+	// Public routes need no credentials.
+	Public bool
+	// Deprecated routes are kept for old clients; aliases are always
+	// deprecated.
+	Deprecated bool
+}
+
+// Param is a query or form parameter.
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Document is the subset of an OpenAPI 3.0 document this package emits.
+type Document struct {
+	OpenAPI    string                    `json:"openapi"`
+	Info       Info                      `json:"info"`
+	Paths      map[string]map[string]*Op `json:"paths"`
+	Components Components                `json:"components"`
+	Security   []map[string][]string     `json:"security"`
+	Tags       []map[string]string       `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Op is an operation as it appears in the document.
+type Op struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *Body                 `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Body struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Builder accumulates operations and the schemas they reference.
+type Builder struct {
+	doc      *Document
+	schemas  *schemaGen
+	errorRef *Schema
+}
+
+// New returns a builder for a document with the given title and version.
+func New(title, version string) *Builder {
+	b := &Builder{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version},
+			Paths:   map[string]map[string]*Op{},
+			Components: Components{
+				Schemas: map[string]*Schema{},
+				SecuritySchemes: map[string]*SecurityScheme{
+					"bearer": {Type: "http", Scheme: "bearer"},
+					"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+				},
+			},
+			Security: []map[string][]string{{"bearer": {}}, {"apiKey": {}}},
+		},
+	}
+	b.schemas = &schemaGen{components: b.doc.Components.Schemas, names: map[reflect.Type]string{}}
+	return b
+}
+
+// Name sets the component name used for the type of v instead of its Go
+// name.
+func (b *Builder) Name(v any, name string) {
+	b.schemas.names[reflect.TypeOf(v)] = name
+}
+
+// Errors sets the body of every error response to the type of v, and lists
+// codes as the values of its "code" field.
+func (b *Builder) Errors(v any, codes []string) {
+	b.errorRef = b.schemas.schemaFor(v)
+	var mark func(s *Schema)
+	mark = func(s *Schema) {
+		if s.Ref != "" {
+			s = b.schemas.resolve(s)
+		}
+		for name, prop := range s.Properties {
+			if name == "code" {
+				prop.Enum = codes
+			} else {
+				mark(prop)
+			}
+		}
+	}
+	mark(b.errorRef)
+}
+
+var wildcard = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// Add documents the route registered under pattern, a "METHOD /path" mux
+// pattern. Aliases are older paths for the same operation; their path
+// parameters become query parameters and they are marked deprecated.
+func (b *Builder) Add(pattern string, op Operation, aliases ...string) {
+	method, path, _ := strings.Cut(pattern, " ")
+	method = strings.ToLower(method)
+	b.add(method, path, path, op)
+	for _, alias := range aliases {
+		// An alias may be bound to the method too.
+		if _, p, ok := strings.Cut(alias, " "); ok {
+			alias = p
+		}
+		b.add(method, alias, path, op)
+	}
+}
+
+func (b *Builder) add(method, path, successor string, op Operation) {
+	alias := path != successor
+	o := &Op{
+		OperationID: operationID(method, path),
+		Summary:     op.Summary,
+		Deprecated:  alias || op.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+	if op.Public {
+		o.Security = []map[string][]string{}
+	}
+
+	in := "path"
+	if alias {
+		in = "query"
+	}
+	for _, m := range wildcard.FindAllStringSubmatch(successor, -1) {
+		o.Parameters = append(o.Parameters, &Parameter{Name: m[1], In: in, Required: !alias, Schema: &Schema{Type: "string"}})
+	}
+	if op.Multipart == "" {
+		for _, q := range op.Query {
+			o.Parameters = append(o.Parameters, &Parameter{Name: q.Name, In: "query", Description: q.Description, Required: q.Required, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	switch {
+	case op.Multipart != "":
+		form := &Schema{Type: "object", Properties: map[string]*Schema{op.Multipart: {Type: "string", Format: "binary"}}, Required: []string{op.Multipart}}
+		for _, q := range op.Query {
+			form.Properties[q.Name] = &Schema{Type: "string", Description: q.Description}
+		}
+		o.RequestBody = &Body{Required: true, Content: map[string]*MediaType{"multipart/form-data": {Schema: form}}}
+	case op.Request != nil:
+		o.RequestBody = &Body{Required: true, Content: map[string]*MediaType{"application/json": {Schema: b.schemas.schemaFor(op.Request)}}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	switch {
+	case op.ContentType != "":
+		success.Content = map[string]*MediaType{op.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+	case op.Response != nil:
+		success.Content = map[string]*MediaType{"application/json": {Schema: b.schemas.schemaFor(op.Response)}}
+	}
+	o.Responses[strconv.Itoa(status)] = success
+
+	errs := append([]int{}, op.Errors...)
+	if !op.Public {
+		errs = append(errs, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests)
+	}
+	errs = append(errs, http.StatusInternalServerError)
+	for _, code := range errs {
+		o.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     map[string]*MediaType{"application/json": {Schema: b.errorRef}},
+		}
+	}
+
+	if b.doc.Paths[path] == nil {
+		b.doc.Paths[path] = map[string]*Op{}
+	}
+	b.doc.Paths[path][method] = o
+}
+
+// operationID turns "get /api/v1/documents/{id}/chat" into
+// "getApiV1DocumentsIdChat".
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+// Document returns the built document.
+func (b *Builder) Document() *Document {
+	tags := map[string]bool{}
+	for _, ops := range b.doc.Paths {
+		for _, op := range ops {
+			for _, t := range op.Tags {
+				tags[t] = true
+			}
+		}
+	}
+	b.doc.Tags = nil
+	names := make([]string, 0, len(tags))
+	for t := range tags {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, t := range names {
+		b.doc.Tags = append(b.doc.Tags, map[string]string{"name": t})
+	}
+	return b.doc
+}
+
+// Handler serves the document as JSON. Operations added afterwards are not
+// included.
+func (b *Builder) Handler() http.HandlerFunc {
+	doc := b.Document()
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}
+}
@@ -0,0 +1,139 @@
+package openapi
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type item struct {
+	ID      string    `json:"id"`
+	Note    string    `json:"note,omitempty"`
+	Parent  *item     `json:"parent"`
+	Created time.Time `json:"createdAt"`
+	Skipped string    `json:"-"`
+	hidden  string
+}
+
+type page struct {
+	Items  []item         `json:"items"`
+	Counts map[string]int `json:"counts"`
+}
+
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func testBuilder() *Builder {
+	b := New("Test", "1")
+	b.Errors(apiError{}, []string{"not_found", "internal_error"})
+	b.Name(page{}, "ItemPage")
+	return b
+}
+
+func TestSchemas(t *testing.T) {
+	b := testBuilder()
+	b.Add("GET /api/v1/items", Operation{Response: page{}})
+	doc := b.Document()
+
+	pageSchema := doc.Components.Schemas["ItemPage"]
+	if pageSchema == nil || pageSchema.Properties["items"].Items.Ref != refPrefix+"item" {
+		t.Fatalf("ItemPage = %+v", pageSchema)
+	}
+	if counts := pageSchema.Properties["counts"]; counts.Type != "object" || counts.AdditionalProperties.Type != "integer" {
+		t.Errorf("counts = %+v, want a map of integers", counts)
+	}
+
+	s := doc.Components.Schemas["item"]
+	if s == nil {
+		t.Fatal("no schema for item")
+	}
+	if got := slices.Sorted(maps.Keys(s.Properties)); !slices.Equal(got, []string{"createdAt", "id", "note", "parent"}) {
+		t.Errorf("item properties = %v", got)
+	}
+	// omitempty and pointer fields are optional; recursion ends in a $ref.
+	if !slices.Equal(s.Required, []string{"id", "createdAt"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+	if s.Properties["parent"].Ref != refPrefix+"item" {
+		t.Errorf("parent = %+v", s.Properties["parent"])
+	}
+	if f := s.Properties["createdAt"]; f.Type != "string" || f.Format != "date-time" {
+		t.Errorf("createdAt = %+v", f)
+	}
+
+	errSchema := doc.Components.Schemas["apiError"]
+	if code := errSchema.Properties["error"].Properties["code"]; !slices.Equal(code.Enum, []string{"not_found", "internal_error"}) {
+		t.Errorf("error code enum = %v", code.Enum)
+	}
+}
+
+func TestOperations(t *testing.T) {
+	b := testBuilder()
+	b.Add("DELETE /api/v1/items/{id}", Operation{Tag: "items", Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}}, "/api/items/delete")
+	b.Add("POST /api/v1/items", Operation{Tag: "items"}, "POST /api/items/create")
+	b.Add("GET /api/health", Operation{Public: true})
+	doc := b.Document()
+
+	op := doc.Paths["/api/v1/items/{id}"]["delete"]
+	if op == nil {
+		t.Fatal("operation missing")
+	}
+	if op.OperationID != "deleteApiV1ItemsId" || op.Deprecated {
+		t.Errorf("operation = %+v", op)
+	}
+	if p := op.Parameters[0]; p.Name != "id" || p.In != "path" || !p.Required {
+		t.Errorf("parameter = %+v", p)
+	}
+	for _, status := range []string{"204", "404", "401", "403", "429", "500"} {
+		if op.Responses[status] == nil {
+			t.Errorf("response %s not documented", status)
+		}
+	}
+	if op.Responses["204"].Content != nil {
+		t.Error("204 has a body")
+	}
+
+	// The alias takes the path parameter from the query.
+	alias := doc.Paths["/api/items/delete"]["delete"]
+	if alias == nil || !alias.Deprecated || alias.Parameters[0].In != "query" || alias.Parameters[0].Required {
+		t.Errorf("alias = %+v", alias)
+	}
+
+	// An alias bound to a method is documented under its path alone.
+	if doc.Paths["/api/items/create"]["post"] == nil || doc.Paths["POST /api/items/create"] != nil {
+		t.Errorf("method-bound alias documented as %v", doc.Paths)
+	}
+
+	health := doc.Paths["/api/health"]["get"]
+	if health.Security == nil || len(health.Security) != 0 || health.Responses["401"] != nil {
+		t.Errorf("public operation = %+v", health)
+	}
+	if len(doc.Tags) != 1 || doc.Tags[0]["name"] != "items" {
+		t.Errorf("tags = %v", doc.Tags)
+	}
+}
+
+func TestTypeScript(t *testing.T) {
+	b := testBuilder()
+	b.Add("GET /api/v1/items", Operation{Response: page{}})
+	ts := string(b.Document().TypeScript())
+
+	for _, want := range []string{
+		"export interface item {\n  createdAt: string;\n  id: string;\n  note?: string;\n  parent?: item;\n}",
+		"counts: Record<string, number>;",
+		"items: item[];",
+		"code: \"not_found\" | \"internal_error\";",
+		"getApiV1Items: {\n    path: \"/api/v1/items\";\n    method: \"GET\";\n    request: never;\n    response: ItemPage;\n  };",
+	} {
+		if !strings.Contains(ts, want) {
+			t.Errorf("TypeScript lacks %q:\n%s", want, ts)
+		}
+	}
+}
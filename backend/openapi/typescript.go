@@ -0,0 +1,128 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TypeScript renders the document's component schemas as TypeScript
+// declarations, plus an Operations map from each operation ID to its path,
+// method, request and response types, for the frontend client.
+func (d *Document) TypeScript() []byte {
+	var sb strings.Builder
+	sb.WriteString("// Code generated by `go run . openapi -ts`. DO NOT EDIT.\n")
+
+	names := make([]string, 0, len(d.Components.Schemas))
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := d.Components.Schemas[name]
+		if s.Type == "object" && s.AdditionalProperties == nil {
+			fmt.Fprintf(&sb, "\nexport interface %s %s\n", name, tsType(s, ""))
+		} else {
+			fmt.Fprintf(&sb, "\nexport type %s = %s;\n", name, tsType(s, ""))
+		}
+	}
+
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	sb.WriteString("\nexport interface Operations {\n")
+	for _, path := range paths {
+		methods := make([]string, 0, len(d.Paths[path]))
+		for method := range d.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			op := d.Paths[path][method]
+			request := "never"
+			if op.RequestBody != nil {
+				if mt := op.RequestBody.Content["application/json"]; mt != nil {
+					request = tsType(mt.Schema, "    ")
+				} else {
+					request = "FormData"
+				}
+			}
+			response := "null"
+			for status, resp := range op.Responses {
+				if code, _ := strconv.Atoi(status); code < 200 || code > 299 {
+					continue
+				}
+				if mt := resp.Content["application/json"]; mt != nil {
+					response = tsType(mt.Schema, "    ")
+				} else if len(resp.Content) > 0 {
+					response = "string"
+				}
+			}
+			fmt.Fprintf(&sb, "  %s: {\n    path: %q;\n    method: %q;\n    request: %s;\n    response: %s;\n  };\n",
+				op.OperationID, path, strings.ToUpper(method), request, response)
+		}
+	}
+	sb.WriteString("}\n")
+	return []byte(sb.String())
+}
+
+// tsType renders s as a TypeScript type, indenting object members one level
+// deeper than indent.
+func tsType(s *Schema, indent string) string {
+	var t string
+	switch {
+	case s.Ref != "":
+		t = strings.TrimPrefix(s.Ref, refPrefix)
+	case len(s.Enum) > 0:
+		quoted := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			quoted[i] = strconv.Quote(v)
+		}
+		t = strings.Join(quoted, " | ")
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" || s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		t = tsType(s.Items, indent) + "[]"
+		if strings.Contains(t, " | ") {
+			t = "(" + strings.TrimSuffix(t, "[]") + ")[]"
+		}
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		t = "Record<string, " + tsType(s.AdditionalProperties, indent) + ">"
+	case s.Type == "object" && len(s.Properties) > 0:
+		required := map[string]bool{}
+		for _, name := range s.Required {
+			required[name] = true
+		}
+		props := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			props = append(props, name)
+		}
+		sort.Strings(props)
+		var sb strings.Builder
+		sb.WriteString("{\n")
+		for _, name := range props {
+			opt := "?"
+			if required[name] {
+				opt = ""
+			}
+			fmt.Fprintf(&sb, "%s  %s%s: %s;\n", indent, name, opt, tsType(s.Properties[name], indent+"  "))
+		}
+		sb.WriteString(indent + "}")
+		t = sb.String()
+	case s.Type == "object":
+		t = "Record<string, unknown>"
+	default:
+		t = "unknown"
+	}
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/malharg/strategic-insight-analyst/backend/app"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+)
+
+// runOpenAPICommand implements the "openapi" subcommand. It prints the
+// OpenAPI document the server would serve at /api/openapi.json, or with -ts
+// the TypeScript types the frontend uses. With -check it instead compares
+// the output against a committed copy and exits with status 1 if they
+// differ, so CI catches handlers drifting from the spec.
+func runOpenAPICommand(args []string) {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	ts := fs.Bool("ts", false, "print TypeScript types instead of JSON")
+	check := fs.String("check", "", "compare against this file instead of printing")
+	fs.Parse(args)
+
+	doc, err := app.OpenAPI(config.Default())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var buf bytes.Buffer
+	if *ts {
+		buf.Write(doc.TypeScript())
+	} else {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if *check == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	committed, err := os.ReadFile(*check)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	flags := ""
+	if *ts {
+		flags = "-ts "
+	}
+	if !bytes.Equal(committed, buf.Bytes()) {
+		fmt.Fprintf(os.Stderr, "%s is out of date; regenerate it with: go run . openapi %s> %s\n", *check, flags, *check)
+		os.Exit(1)
+	}
+	fmt.Printf("%s is up to date\n", *check)
+}
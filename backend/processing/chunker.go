@@ -1,39 +1,69 @@
 package processing
 
-const (
-	// The target size for each text chunk in characters.
-	ChunkSize = 1500
-	// The number of characters to overlap between chunks to maintain context.
-	ChunkOverlap = 200
-)
-
-// ChunkText splits a large text into smaller, overlapping chunks.
-func ChunkText(text string) []string {
+// Chunk is a piece of extracted text and the page it starts on.
+type Chunk struct {
+	Text string
+	// Page is 1-based, or 0 for formats without pages.
+	Page int
+}
+
+// ChunkText splits a large text into chunks of about size characters, each
+// sharing overlap characters with the previous one to maintain context.
+func ChunkText(text string, size, overlap int) []string {
+	chunks, _ := chunk(text, size, overlap)
+	return chunks
+}
+
+// ChunkPages chunks ext.Text like ChunkText and notes the page each chunk
+// starts on.
+func ChunkPages(ext *Extraction, size, overlap int) []Chunk {
+	texts, starts := chunk(ext.Text, size, overlap)
+	chunks := make([]Chunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = Chunk{Text: text}
+		// The page is the last one starting at or before the chunk.
+		for p, pageStart := range ext.PageStarts {
+			if pageStart > starts[i] {
+				break
+			}
+			chunks[i].Page = p + 1
+		}
+	}
+	return chunks
+}
+
+// chunk returns the chunks of text and the rune offset each starts at.
+func chunk(text string, size, overlap int) ([]string, []int) {
 	// If the text is smaller than the chunk size, return it as a single chunk.
-	if len(text) <= ChunkSize {
-		return []string{text}
+	if size <= 0 || len(text) <= size {
+		return []string{text}, []int{0}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
 	}
 
 	var chunks []string
+	var starts []int
 	// Use runes to correctly handle multi-byte characters (like emojis or other languages).
 	runes := []rune(text)
 
 	for i := 0; i < len(runes); {
-		end := i + ChunkSize
+		end := i + size
 		if end > len(runes) {
 			end = len(runes)
 		}
 
 		chunks = append(chunks, string(runes[i:end]))
+		starts = append(starts, i)
 
 		// Move the starting point for the next chunk.
-		i += ChunkSize - ChunkOverlap
+		i += size - overlap
 
 		// A safety check to prevent creating a tiny, mostly-overlapping final chunk.
-		if i+ChunkOverlap >= len(runes) {
+		if i+overlap >= len(runes) {
 			break
 		}
 	}
 
-	return chunks
+	return chunks, starts
 }
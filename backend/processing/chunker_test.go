@@ -0,0 +1,79 @@
+package processing
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("abcdefghij", 10) // 100 characters
+
+	for _, tt := range []struct {
+		name          string
+		text          string
+		size, overlap int
+		want          int // number of chunks
+	}{
+		{"short text", "short", 10, 2, 1},
+		{"exact size", text, 100, 10, 1},
+		{"no overlap", text, 25, 0, 4},
+		{"overlap", text, 30, 10, 5},
+		{"overlap too large is ignored", text, 25, 25, 4},
+		{"unusable size keeps the text whole", text, 0, 0, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := ChunkText(tt.text, tt.size, tt.overlap)
+			if len(chunks) != tt.want {
+				t.Fatalf("got %d chunks, want %d: %q", len(chunks), tt.want, chunks)
+			}
+			for i, c := range chunks {
+				if tt.size > 0 && utf8.RuneCountInString(c) > tt.size {
+					t.Errorf("chunk %d has %d characters, more than %d", i, utf8.RuneCountInString(c), tt.size)
+				}
+			}
+			if !strings.HasPrefix(tt.text, chunks[0]) || !strings.HasSuffix(tt.text, chunks[len(chunks)-1]) {
+				t.Error("chunks do not cover the text")
+			}
+		})
+	}
+
+	// Consecutive chunks share overlap characters.
+	chunks := ChunkText(text, 30, 10)
+	if chunks[0][20:] != chunks[1][:10] {
+		t.Errorf("chunks %q and %q do not overlap by 10", chunks[0], chunks[1])
+	}
+
+	// Multi-byte characters are never split.
+	for _, c := range ChunkText(strings.Repeat("é€😀", 20), 7, 2) {
+		if !utf8.ValidString(c) {
+			t.Errorf("chunk %q is not valid UTF-8", c)
+		}
+	}
+}
+
+func TestChunkPages(t *testing.T) {
+	// Three pages of 10, 15 and 5 characters.
+	ext := &Extraction{Text: strings.Repeat("a", 10) + strings.Repeat("b", 15) + strings.Repeat("c", 5), PageStarts: []int{0, 10, 25}}
+	chunks := ChunkPages(ext, 8, 2)
+	var pages []int
+	for _, c := range chunks {
+		pages = append(pages, c.Page)
+	}
+	// Chunks start at 0, 6, 12, 18 and 24.
+	want := []int{1, 1, 2, 2, 2}
+	if !slices.Equal(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+	if texts := ChunkText(ext.Text, 8, 2); len(texts) != len(chunks) || texts[1] != chunks[1].Text {
+		t.Errorf("ChunkPages text differs from ChunkText: %q vs %+v", texts, chunks)
+	}
+
+	// Plain text has no pages.
+	for _, c := range ChunkPages(&Extraction{Text: "no pages here"}, 5, 0) {
+		if c.Page != 0 {
+			t.Errorf("chunk %+v of plain text has a page", c)
+		}
+	}
+}
@@ -3,15 +3,22 @@ package processing
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	/*"github.com/malharg/strategic-insight-analyst/backend/config"
 	"github.com/unidoc/unipdf/v3/common/license"*/
 	"github.com/unidoc/unipdf/v3/extractor"
 	"github.com/unidoc/unipdf/v3/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 /*func init() {
@@ -24,61 +31,128 @@ import (
 	log.Println("UniDoc license key set successfully.")
 }*/
 
-// ExtractTextFromFile uses UniDoc for PDFs.
-func ExtractTextFromFile(fileBytes []byte, fileName string) (string, error) {
+var tracer = otel.Tracer("github.com/malharg/strategic-insight-analyst/backend/processing")
+
+// ErrUnsupportedFileType is returned for files whose extension has no
+// extractor.
+var ErrUnsupportedFileType = errors.New("unsupported file type")
+
+// Supported reports whether text can be extracted from fileName, judging by
+// its extension.
+func Supported(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".txt", ".pdf":
+		return true
+	}
+	return false
+}
+
+// ContentType returns the MIME type of a supported file, judging by its
+// extension.
+func ContentType(fileName string) string {
+	if strings.EqualFold(filepath.Ext(fileName), ".pdf") {
+		return "application/pdf"
+	}
+	return "text/plain"
+}
+
+// Extraction is the text of a file and what was learned extracting it.
+type Extraction struct {
+	Text string
+	// Pages is 0 for formats without pages.
+	Pages int
+	// Warnings describe parts of the file that were skipped, such as PDF
+	// pages whose text could not be extracted.
+	Warnings []string
+	// PageStarts holds the rune offset in Text at which each page begins,
+	// for formats with pages.
+	PageStarts []int
+}
+
+// Extract returns the text of a file. It uses UniDoc for PDFs.
+func Extract(ctx context.Context, fileBytes []byte, fileName string) (ext *Extraction, err error) {
 	extension := strings.ToLower(filepath.Ext(fileName))
 
+	ctx, span := tracer.Start(ctx, "processing.ExtractText", trace.WithAttributes(
+		attribute.String("file.extension", extension),
+		attribute.Int("file.size_bytes", len(fileBytes)),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Int("text.chars", len(ext.Text)))
+		}
+		span.End()
+	}()
+
 	switch extension {
 	case ".txt":
-		return string(fileBytes), nil
+		ext = &Extraction{Text: string(fileBytes)}
 	case ".pdf":
-		return extractTextFromPDF(fileBytes)
+		if ext, err = extractTextFromPDF(ctx, fileBytes); err != nil {
+			return nil, err
+		}
 	default:
-		return "", fmt.Errorf("unsupported file type: %s", extension)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, extension)
+	}
+	if strings.TrimSpace(ext.Text) == "" {
+		ext.Warnings = append(ext.Warnings, "No text was extracted; the file may be empty or contain only images.")
 	}
+	span.SetAttributes(attribute.Int("extract.warnings", len(ext.Warnings)))
+	return ext, nil
 }
 
 // extractTextFromPDF uses the UniDoc library.
-func extractTextFromPDF(fileBytes []byte) (string, error) {
+func extractTextFromPDF(ctx context.Context, fileBytes []byte) (*Extraction, error) {
 	// Create a new PDF reader from the file bytes.
 	pdfReader, err := model.NewPdfReader(bytes.NewReader(fileBytes))
 	if err != nil {
-		log.Printf("ERROR: UniDoc failed to create PDF reader: %v", err)
-		return "", err
+		slog.Error("UniDoc failed to create PDF reader", "error", err)
+		return nil, err
 	}
 
 	// Get the total number of pages in the PDF.
 	numPages, err := pdfReader.GetNumPages()
 	if err != nil {
-		log.Printf("ERROR: UniDoc failed to get page count: %v", err)
-		return "", err
+		slog.Error("UniDoc failed to get page count", "error", err)
+		return nil, err
 	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("pdf.pages", numPages))
 
 	// Extract text from all pages and concatenate.
 	var extractedText strings.Builder
+	var warnings []string
+	pageStarts := make([]int, 0, numPages)
+	offset := 0
 	for i := 1; i <= numPages; i++ {
+		pageStarts = append(pageStarts, offset)
 		page, err := pdfReader.GetPage(i)
 		if err != nil {
-			log.Printf("ERROR: UniDoc failed to get page %d: %v", i, err)
-			return "", err
+			slog.Warn("UniDoc failed to get page", "page", i, "error", err)
+			return nil, err
 		}
 
 		ex, err := extractor.New(page)
 		if err != nil {
-			log.Printf("ERROR: UniDoc failed to create extractor for page %d: %v", i, err)
-			return "", err
+			slog.Warn("UniDoc failed to create extractor", "page", i, "error", err)
+			return nil, err
 		}
 
 		text, err := ex.ExtractText()
 		if err != nil {
-			log.Printf("ERROR: UniDoc failed to extract text from page %d: %v", i, err)
-			// Continue to the next page even if one fails
+			slog.Warn("UniDoc failed to extract text", "page", i, "error", err)
+			// Continue to the next page even if one fails, but tell the
+			// user which pages are missing.
+			warnings = append(warnings, fmt.Sprintf("Page %d: text could not be extracted (%v).", i, err))
 			continue
 		}
 
 		extractedText.WriteString(text)
 		extractedText.WriteString("\n\n") // Add a separator between pages
+		offset += utf8.RuneCountInString(text) + 2
 	}
 
-	return extractedText.String(), nil
+	return &Extraction{Text: extractedText.String(), Pages: numPages, Warnings: warnings, PageStarts: pageStarts}, nil
 }
@@ -0,0 +1,46 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSupported(t *testing.T) {
+	for name, want := range map[string]bool{
+		"notes.txt":   true,
+		"REPORT.PDF":  true,
+		"slides.pptx": false,
+		"txt":         false,
+		"":            false,
+	} {
+		if got := Supported(name); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	for name, want := range map[string]string{
+		"notes.txt":  "text/plain",
+		"REPORT.PDF": "application/pdf",
+	} {
+		if got := ContentType(name); got != want {
+			t.Errorf("ContentType(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	ext, err := Extract(context.Background(), []byte("Revenue grew."), "q3.txt")
+	if err != nil || ext.Text != "Revenue grew." || ext.Pages != 0 || ext.Warnings != nil {
+		t.Errorf("text file = %+v, %v", ext, err)
+	}
+	ext, err = Extract(context.Background(), []byte(" \n\t"), "blank.txt")
+	if err != nil || len(ext.Warnings) != 1 {
+		t.Errorf("blank file = %+v, %v; want a warning", ext, err)
+	}
+	if _, err := Extract(context.Background(), []byte("x"), "slides.pptx"); !errors.Is(err, ErrUnsupportedFileType) {
+		t.Errorf("unsupported file: err = %v, want ErrUnsupportedFileType", err)
+	}
+}
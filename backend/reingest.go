@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/ingest"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// runReingestCommand implements the "reingest" subcommand. It runs stored
+// documents through the current extraction, chunking and embedding pipeline
+// again, by default those ingested by an older pipeline version. Each
+// document's chunks are swapped in one transaction, so the server can keep
+// serving while it runs, and its summary is regenerated. Model calls are
+// recorded as the owner's usage but not charged to their quota. A failure
+// is reported and the run moves on; the exit status is 1 if any document
+// failed.
+func runReingestCommand(args []string) {
+	fs := flag.NewFlagSet("reingest", flag.ExitOnError)
+	all := fs.Bool("all", false, "re-ingest every document, including those on the current pipeline version, e.g. after changing the chunk size")
+	id := fs.String("document", "", "re-ingest only the document with this ID")
+	limit := fs.Int("limit", 0, "re-ingest at most this many documents (0 means no limit)")
+	dryRun := fs.Bool("dry-run", false, "list the documents that would be re-ingested without changing them")
+	fs.Parse(args)
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	}
+	setupPDF(cfg)
+
+	db := database.InitDB(cfg.DatabaseURL)
+	defer db.Close()
+	stores := store.New(db)
+	docs := stores.Documents
+	files := newStorage(cfg)
+	gemini := newGemini(cfg)
+	embedder := newEmbedder(cfg, gemini)
+	// No quota: the calls are recorded as the owners' usage but not charged.
+	followup := &ingest.Followup{AI: gemini, Documents: docs, Usage: stores.Usage, SummaryChunks: cfg.AI.SummaryChunks}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var todo []store.Document
+	if *id != "" {
+		doc, err := docs.Get(ctx, *id)
+		if err != nil {
+			logging.Fatal("failed to load document", "document_id", *id, "error", err)
+		}
+		todo = append(todo, *doc)
+	} else {
+		below := ingest.PipelineVersion
+		if *all {
+			below++
+		}
+		todo, err = docs.ListStale(ctx, below, *limit)
+		if err != nil {
+			logging.Fatal("failed to list documents", "error", err)
+		}
+	}
+
+	var done, failed, skipped int
+	for _, doc := range todo {
+		if ctx.Err() != nil {
+			break
+		}
+		if strings.EqualFold(filepath.Ext(doc.StoragePath), ".pdf") && cfg.UnidocLicenseKey == "" {
+			fmt.Printf("%s\t%s\tskipped: PDF processing needs UNIDOC_LICENSE_KEY\n", doc.ID, doc.FileName)
+			skipped++
+			continue
+		}
+		if *dryRun {
+			fmt.Printf("%s\t%s\tversion %d, %d chunks\n", doc.ID, doc.FileName, doc.PipelineVersion, doc.ChunkCount)
+			continue
+		}
+		res, err := ingest.Reingest(ctx, files, docs, cfg.Chunking, embedder, &doc)
+		if err != nil {
+			fmt.Printf("%s\t%s\tfailed: %v\n", doc.ID, doc.FileName, err)
+			failed++
+			continue
+		}
+		followup.RecordEmbedding(ctx, doc.UserID, doc.ID, res)
+		embedded := ""
+		if !res.Embedded {
+			embedded = ", not embedded"
+		}
+		fmt.Printf("%s\t%s\t%d -> %d chunks%s\n", doc.ID, doc.FileName, doc.ChunkCount, len(res.Chunks), embedded)
+		done++
+
+		contents := make([]string, len(res.Chunks))
+		for i, c := range res.Chunks {
+			contents[i] = c.Content
+		}
+		if err := followup.Summarize(ctx, doc.UserID, doc.ID, contents); err != nil {
+			slog.Warn("failed to regenerate summary", "document_id", doc.ID, "error", err)
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%d document(s) would be re-ingested to pipeline version %d.\n", len(todo)-skipped, ingest.PipelineVersion)
+		return
+	}
+	fmt.Printf("Re-ingested %d document(s) to pipeline version %d; %d failed, %d skipped.\n", done, ingest.PipelineVersion, failed, skipped)
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted; run the command again to finish.")
+		os.Exit(1)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
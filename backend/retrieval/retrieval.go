@@ -0,0 +1,122 @@
+// Package retrieval picks the chunks of a document that are sent to the
+// model with a question. Chat and the retrieval preview share it, so the
+// preview shows exactly what chat would send.
+package retrieval
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: k1 dampens repeated terms, b normalizes for chunk length.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Result is one chunk's relevance to a query.
+type Result struct {
+	// Index is the chunk's position in the slice passed to Rank.
+	Index int
+	// Score is the chunk's BM25 score; 0 means it shares no terms with the
+	// query.
+	Score float64
+	// Rank is 1 for the best-scoring chunk. Ties keep document order.
+	Rank int
+	// Selected chunks are sent to the model.
+	Selected bool
+}
+
+// Rank scores every chunk against query. When limit is 0 or the document
+// has no more than limit chunks, every chunk is selected; otherwise the
+// limit best-scoring ones are. Results are in chunk order.
+func Rank(query string, chunks []string, limit int) []Result {
+	results := make([]Result, len(chunks))
+	terms := tokenize(query)
+
+	docs := make([]map[string]int, len(chunks))
+	lengths := make([]int, len(chunks))
+	df := map[string]int{}
+	total := 0
+	for i, c := range chunks {
+		tf := map[string]int{}
+		for _, t := range tokenize(c) {
+			tf[t]++
+			lengths[i]++
+		}
+		for t := range tf {
+			df[t]++
+		}
+		docs[i] = tf
+		total += lengths[i]
+	}
+	avg := 1.0
+	if len(chunks) > 0 && total > 0 {
+		avg = float64(total) / float64(len(chunks))
+	}
+
+	n := float64(len(chunks))
+	for i := range chunks {
+		var score float64
+		for _, t := range terms {
+			f := float64(docs[i][t])
+			if f == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			score += idf * f * (k1 + 1) / (f + k1*(1-b+b*float64(lengths[i])/avg))
+		}
+		results[i] = Result{Index: i, Score: score}
+	}
+
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool { return results[order[x]].Score > results[order[y]].Score })
+	for rank, i := range order {
+		results[i].Rank = rank + 1
+		results[i].Selected = limit <= 0 || len(chunks) <= limit || rank < limit
+	}
+	return results
+}
+
+// Select returns the chunks Rank selects, in document order.
+func Select(query string, chunks []string, limit int) []string {
+	if limit <= 0 || len(chunks) <= limit {
+		return chunks
+	}
+	selected := make([]string, 0, limit)
+	for _, r := range Rank(query, chunks, limit) {
+		if r.Selected {
+			selected = append(selected, chunks[r.Index])
+		}
+	}
+	return selected
+}
+
+// stopWords are common English words whose matches carry little signal.
+var stopWords = map[string]bool{
+	"about": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"did": true, "do": true, "does": true, "for": true, "from": true, "how": true, "in": true, "is": true,
+	"it": true, "its": true, "many": true, "much": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "were": true, "what": true, "which": true, "who": true,
+	"why": true, "with": true,
+}
+
+// tokenize lowercases text and splits it into words, dropping one-letter
+// words and stop words.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if len([]rune(w)) > 1 && !stopWords[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
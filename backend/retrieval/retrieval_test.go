@@ -0,0 +1,113 @@
+package retrieval
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRank(t *testing.T) {
+	chunks := []string{
+		"The board met in March.",
+		"Revenue grew 12% while revenue from services doubled.",
+		"Costs fell because revenue-linked bonuses were cut.",
+		"Headcount was flat.",
+	}
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		// ranks is each chunk's expected rank, in chunk order.
+		ranks    []int
+		selected []bool
+	}{
+		{"best match first", "revenue", 2, []int{3, 1, 2, 4}, []bool{false, true, true, false}},
+		{"rarer term outweighs common one", "revenue costs", 1, []int{3, 2, 1, 4}, []bool{false, false, true, false}},
+		{"stop words ignored", "what is the revenue", 1, []int{3, 1, 2, 4}, []bool{false, true, false, false}},
+		{"empty query", "", 2, []int{1, 2, 3, 4}, []bool{true, true, false, false}},
+		{"no matching terms", "dividends", 1, []int{1, 2, 3, 4}, []bool{true, false, false, false}},
+		{"limit 0 selects all", "revenue", 0, []int{3, 1, 2, 4}, []bool{true, true, true, true}},
+		{"limit above chunk count", "revenue", 10, []int{3, 1, 2, 4}, []bool{true, true, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Rank(tt.query, chunks, tt.limit)
+			var ranks []int
+			var selected []bool
+			for i, r := range results {
+				if r.Index != i {
+					t.Errorf("result %d has index %d", i, r.Index)
+				}
+				ranks = append(ranks, r.Rank)
+				selected = append(selected, r.Selected)
+			}
+			if !slices.Equal(ranks, tt.ranks) {
+				t.Errorf("ranks = %v, want %v", ranks, tt.ranks)
+			}
+			if !slices.Equal(selected, tt.selected) {
+				t.Errorf("selected = %v, want %v", selected, tt.selected)
+			}
+		})
+	}
+}
+
+func TestRankTiesKeepDocumentOrder(t *testing.T) {
+	chunks := []string{"Costs fell.", "Revenue rose.", "Costs grew.", "Revenue fell."}
+	results := Rank("revenue", chunks, 1)
+	if results[1].Score != results[3].Score {
+		t.Fatalf("scores %v and %v should tie", results[1].Score, results[3].Score)
+	}
+	var ranks []int
+	for _, r := range results {
+		ranks = append(ranks, r.Rank)
+	}
+	if want := []int{3, 1, 4, 2}; !slices.Equal(ranks, want) {
+		t.Errorf("ranks = %v, want %v", ranks, want)
+	}
+	if !results[1].Selected || results[3].Selected {
+		t.Errorf("the earlier of two tied chunks should be selected: %+v", results)
+	}
+}
+
+func TestRankScores(t *testing.T) {
+	results := Rank("revenue", []string{"revenue", "costs"}, 0)
+	if results[0].Score <= 0 {
+		t.Errorf("matching chunk scored %v", results[0].Score)
+	}
+	if results[1].Score != 0 {
+		t.Errorf("chunk without the term scored %v", results[1].Score)
+	}
+	if got := Rank("revenue", nil, 1); len(got) != 0 {
+		t.Errorf("no chunks ranked %v", got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	chunks := []string{"Intro.", "Costs fell.", "Revenue grew.", "Revenue and costs."}
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{"keeps document order", "revenue costs", 2, []string{"Costs fell.", "Revenue and costs."}},
+		{"single best chunk", "revenue", 1, []string{"Revenue grew."}},
+		{"empty query takes leading chunks", "", 2, []string{"Intro.", "Costs fell."}},
+		{"context chunks 0 sends everything", "revenue", 0, chunks},
+		{"limit equal to chunk count", "revenue", 4, chunks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Select(tt.query, chunks, tt.limit); !slices.Equal(got, tt.want) {
+				t.Errorf("Select = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("What was Q3's EBITDA, in €? A 12-month view.")
+	want := []string{"q3", "ebitda", "12", "month", "view"}
+	if !slices.Equal(got, want) {
+		t.Errorf("tokenize = %q, want %q", got, want)
+	}
+}
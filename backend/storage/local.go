@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps uploads on the local filesystem under Dir. It is meant for
+// development without a Supabase project.
+type Local struct {
+	Dir string
+}
+
+// NewLocal returns a Local store rooted at dir.
+func NewLocal(dir string) *Local {
+	return &Local{Dir: dir}
+}
+
+// resolve maps an object path onto a file under Dir. Cleaning the path as if
+// it were rooted keeps ".." elements from escaping Dir.
+func (l *Local) resolve(path string) (string, error) {
+	clean := filepath.Clean(string(filepath.Separator) + filepath.FromSlash(path))
+	if clean == string(filepath.Separator) {
+		return "", fmt.Errorf("invalid object path %q", path)
+	}
+	return filepath.Join(l.Dir, clean), nil
+}
+
+func (l *Local) Upload(ctx context.Context, path, contentType string, data []byte) error {
+	file, err := l.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+		return fmt.Errorf("could not create upload directory: %w", err)
+	}
+	return os.WriteFile(file, data, 0o640)
+}
+
+func (l *Local) Download(ctx context.Context, path string) ([]byte, error) {
+	file, err := l.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(file)
+}
+
+func (l *Local) Delete(ctx context.Context, path string) error {
+	file, err := l.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Ping checks that the upload directory exists or can be created.
+func (l *Local) Ping(ctx context.Context) error {
+	return os.MkdirAll(l.Dir, 0o750)
+}
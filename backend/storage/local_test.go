@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	l := NewLocal(filepath.Join(dir, "uploads"))
+
+	if err := l.Upload(ctx, "alice/doc-1/q3.txt", "text/plain", []byte("Revenue grew.")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := l.Download(ctx, "alice/doc-1/q3.txt"); err != nil || string(data) != "Revenue grew." {
+		t.Fatalf("Download = %q, %v", data, err)
+	}
+	if err := l.Delete(ctx, "alice/doc-1/q3.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Download(ctx, "alice/doc-1/q3.txt"); !os.IsNotExist(err) {
+		t.Errorf("Download after Delete = %v, want not found", err)
+	}
+	// Deleting twice is not an error.
+	if err := l.Delete(ctx, "alice/doc-1/q3.txt"); err != nil {
+		t.Errorf("second Delete = %v", err)
+	}
+}
+
+func TestLocalStaysInsideDir(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	l := NewLocal(filepath.Join(dir, "uploads"))
+
+	if err := l.Upload(ctx, "../../escape.txt", "text/plain", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "uploads", "escape.txt")); err != nil {
+		t.Errorf("upload with .. not kept under Dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("upload escaped Dir")
+	}
+	for _, path := range []string{"", "/", ".."} {
+		if err := l.Upload(ctx, path, "text/plain", []byte("x")); err == nil {
+			t.Errorf("Upload(%q) succeeded", path)
+		}
+	}
+}
+
+func TestLocalPing(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewLocal(filepath.Join(dir, "uploads", "nested")).Ping(context.Background()); err != nil {
+		t.Errorf("Ping = %v, want the directory created", err)
+	}
+	// A file where the directory should be cannot hold uploads.
+	blocked := filepath.Join(dir, "file")
+	os.WriteFile(blocked, nil, 0o600)
+	if err := NewLocal(blocked).Ping(context.Background()); err == nil {
+		t.Error("Ping succeeded with a file in place of the directory")
+	}
+}
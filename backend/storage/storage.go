@@ -0,0 +1,11 @@
+// Package storage abstracts the object store that holds original uploads.
+package storage
+
+import "context"
+
+// Storage stores, retrieves and removes the original uploaded files.
+type Storage interface {
+	Upload(ctx context.Context, path, contentType string, data []byte) error
+	Download(ctx context.Context, path string) ([]byte, error)
+	Delete(ctx context.Context, path string) error
+}
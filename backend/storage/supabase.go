@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/malharg/strategic-insight-analyst/backend/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/malharg/strategic-insight-analyst/backend/storage")
+
+// Supabase talks to Supabase Storage over its REST API directly; the SDK's
+// upload path proved unreliable, so no client library is involved.
+type Supabase struct {
+	URL        string
+	ServiceKey string
+	Bucket     string
+	Client     *http.Client
+}
+
+// NewSupabase returns a Supabase store for bucket. Its requests are traced
+// and carry the caller's trace context.
+func NewSupabase(url, serviceKey, bucket string) *Supabase {
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	return &Supabase{URL: url, ServiceKey: serviceKey, Bucket: bucket, Client: client}
+}
+
+func (s *Supabase) objectURL(path string) string {
+	return fmt.Sprintf("%s/storage/v1/object/%s/%s", s.URL, s.Bucket, path)
+}
+
+// startSpan opens a span covering one storage operation on path.
+func (s *Supabase) startSpan(ctx context.Context, op, path string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "storage."+op, trace.WithAttributes(
+		attribute.String("storage.bucket", s.Bucket),
+		attribute.String("storage.path", path),
+	))
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *Supabase) Upload(ctx context.Context, path, contentType string, data []byte) (err error) {
+	ctx, span := s.startSpan(ctx, "Upload", path)
+	span.SetAttributes(attribute.Int("storage.size_bytes", len(data)))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.objectURL(path), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	return s.do(req)
+}
+
+func (s *Supabase) Download(ctx context.Context, path string) (_ []byte, err error) {
+	ctx, span := s.startSpan(ctx, "Download", path)
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(path), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create download request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.ServiceKey))
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read object: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("supabase storage returned %s: %s", resp.Status, string(body))
+	}
+	return body, nil
+}
+
+func (s *Supabase) Delete(ctx context.Context, path string) (err error) {
+	ctx, span := s.startSpan(ctx, "Delete", path)
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(path), nil)
+	if err != nil {
+		return fmt.Errorf("could not create delete request: %w", err)
+	}
+	return s.do(req)
+}
+
+// Ping checks that the bucket exists and the service key is accepted.
+func (s *Supabase) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("%s/storage/v1/bucket/%s", s.URL, s.Bucket)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("could not create bucket request: %w", err)
+	}
+	return s.do(req)
+}
+
+func (s *Supabase) do(req *http.Request) error {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.ServiceKey))
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("supabase storage returned %s: %s", resp.Status, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSupabasePing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer service-key" {
+			http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/storage/v1/bucket/documents" {
+			http.Error(w, `{"error":"Bucket not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id":"documents"}`))
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		key, bucket string
+		ok          bool
+	}{
+		{"service-key", "documents", true},
+		{"revoked", "documents", false},
+		{"service-key", "missing", false},
+	} {
+		s := NewSupabase(srv.URL, tt.key, tt.bucket)
+		if err := s.Ping(context.Background()); (err == nil) != tt.ok {
+			t.Errorf("key %s, bucket %s: Ping = %v", tt.key, tt.bucket, err)
+		}
+	}
+}
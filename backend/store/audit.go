@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type auditStore struct {
+	db *database.DB
+}
+
+func (s *auditStore) Append(ctx context.Context, e AuditEvent) error {
+	_, err := s.db.ExecContext(ctx, `
+    INSERT INTO audit_log (id, user_id, action, document_id, ip, user_agent, details, created_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    ON CONFLICT (id) DO NOTHING`,
+		e.ID, nullable(e.UserID), e.Action, nullable(e.DocumentID), nullable(e.IP), nullable(e.UserAgent), nullable(e.Details), e.CreatedAt.UTC())
+	return err
+}
+
+func (s *auditStore) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	var where []string
+	var args []any
+	if f.UserID != "" {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.DocumentID != "" {
+		where = append(where, "document_id = ?")
+		args = append(args, f.DocumentID)
+	}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.To.UTC())
+	}
+
+	query := "SELECT id, user_id, action, document_id, ip, user_agent, details, created_at FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]AuditEvent, 0)
+	for rows.Next() {
+		var e AuditEvent
+		var userID, documentID, ip, userAgent, details sql.NullString
+		if err := rows.Scan(&e.ID, &userID, &e.Action, &documentID, &ip, &userAgent, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.UserID, e.DocumentID, e.IP, e.UserAgent, e.Details = userID.String, documentID.String, ip.String, userAgent.String, details.String
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
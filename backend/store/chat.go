@@ -0,0 +1,30 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type chatStore struct {
+	db *database.DB
+}
+
+func (s *chatStore) SaveExchange(ctx context.Context, documentID, userID, conversationID, query, answer string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback on error
+
+	const insert = "INSERT INTO chat_history (id, document_id, user_id, conversation_id, message_type, message_content) VALUES (?, ?, ?, ?, ?, ?)"
+	if _, err := tx.ExecContext(ctx, insert, uuid.New().String(), documentID, userID, nullable(conversationID), MessageTypeUser, query); err != nil {
+		return fmt.Errorf("could not save user message: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, insert, uuid.New().String(), documentID, userID, nullable(conversationID), MessageTypeAI, answer); err != nil {
+		return fmt.Errorf("could not save AI response: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type chunkStore struct {
+	db *database.DB
+}
+
+// chunkColumns selects a chunk and whether it has an embedding in either
+// the JSON or the pgvector column.
+func (s *chunkStore) chunkColumns() string {
+	embedded := "embedding IS NOT NULL"
+	if s.db.HasVector {
+		embedded += " OR embedding_vector IS NOT NULL"
+	}
+	return "id, document_id, chunk_index, page, content, CASE WHEN " + embedded + " THEN 1 ELSE 0 END"
+}
+
+func (s *chunkStore) ListByDocument(ctx context.Context, documentID string) ([]Chunk, error) {
+	return s.list(ctx, "SELECT "+s.chunkColumns()+" FROM document_chunks WHERE document_id = ? ORDER BY chunk_index ASC", documentID)
+}
+
+func (s *chunkStore) ListAfter(ctx context.Context, documentID string, after, limit int) ([]Chunk, error) {
+	return s.list(ctx, "SELECT "+s.chunkColumns()+" FROM document_chunks WHERE document_id = ? AND chunk_index > ? ORDER BY chunk_index ASC LIMIT ?", documentID, after, limit)
+}
+
+func (s *chunkStore) list(ctx context.Context, query string, args ...any) ([]Chunk, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []Chunk
+	for rows.Next() {
+		var c Chunk
+		var embedded int
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.Index, &c.Page, &c.Content, &embedded); err != nil {
+			return nil, err
+		}
+		c.Embedded = embedded == 1
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
@@ -0,0 +1,395 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type documentStore struct {
+	db *database.DB
+}
+
+func (s *documentStore) Create(ctx context.Context, doc Document, chunks []Chunk) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Ensures rollback on any error path
+
+	sqlDoc := `
+    INSERT INTO documents (id, user_id, file_name, storage_path, org_id, collection_id, content_type, size_bytes, page_count, chunk_count, status, warnings, ingested_at, pipeline_version)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	warnings, err := encodeWarnings(doc.Warnings)
+	if err != nil {
+		return err
+	}
+	status := doc.Status
+	if status == "" {
+		status = DocumentReady
+	}
+	var ingestedAt any
+	if status == DocumentReady {
+		ingestedAt = time.Now().UTC()
+	}
+	if _, err := tx.ExecContext(ctx, sqlDoc, doc.ID, doc.UserID, doc.FileName, doc.StoragePath, nullable(doc.OrgID), nullable(doc.CollectionID),
+		doc.ContentType, doc.SizeBytes, doc.PageCount, len(chunks), status, warnings, ingestedAt, doc.PipelineVersion); err != nil {
+		return fmt.Errorf("could not insert document: %w", err)
+	}
+
+	if err := insertChunks(ctx, tx, s.db.HasVector, doc.ID, chunks); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit document: %w", err)
+	}
+	return nil
+}
+
+func (s *documentStore) ReplaceChunks(ctx context.Context, doc Document, chunks []Chunk) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	warnings, err := encodeWarnings(doc.Warnings)
+	if err != nil {
+		return err
+	}
+	// Updating the document first locks its row, so concurrent writers of
+	// the same document take turns instead of interleaving their chunks.
+	res, err := tx.ExecContext(ctx, `
+    UPDATE documents SET content_type = ?, size_bytes = ?, page_count = ?, chunk_count = ?, status = ?, warnings = ?, summary = NULL, ingested_at = ?, pipeline_version = ?
+    WHERE id = ?`,
+		doc.ContentType, doc.SizeBytes, doc.PageCount, len(chunks), DocumentReady, warnings, time.Now().UTC(), doc.PipelineVersion, doc.ID)
+	if err != nil {
+		return fmt.Errorf("could not update document: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM document_chunks WHERE document_id = ?", doc.ID); err != nil {
+		return fmt.Errorf("could not delete chunks: %w", err)
+	}
+	if err := insertChunks(ctx, tx, s.db.HasVector, doc.ID, chunks); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit document: %w", err)
+	}
+	return nil
+}
+
+// insertChunks saves chunks with their embeddings, which go into the
+// pgvector column as well when the database has one.
+func insertChunks(ctx context.Context, tx *database.Tx, hasVector bool, documentID string, chunks []Chunk) error {
+	query := "INSERT INTO document_chunks (id, document_id, chunk_index, page, content, embedding) VALUES (?, ?, ?, ?, ?, ?)"
+	if hasVector {
+		query = "INSERT INTO document_chunks (id, document_id, chunk_index, page, content, embedding, embedding_vector) VALUES (?, ?, ?, ?, ?, ?, CAST(? AS vector))"
+	}
+	// Prepare the statement once for efficiency
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("could not prepare chunk insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, chunk := range chunks {
+		// A JSON array is also pgvector's text format.
+		var embedding any
+		if chunk.Embedding != nil {
+			b, err := json.Marshal(chunk.Embedding)
+			if err != nil {
+				return fmt.Errorf("could not encode embedding of chunk %d: %w", i, err)
+			}
+			embedding = string(b)
+		}
+		args := []any{uuid.New().String(), documentID, i, chunk.Page, chunk.Content, embedding}
+		if hasVector {
+			args = append(args, embedding)
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("could not insert chunk %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// encodeWarnings stores warnings as a JSON array, or NULL if there are none.
+func encodeWarnings(warnings []string) (any, error) {
+	if len(warnings) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(warnings)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode warnings: %w", err)
+	}
+	return string(b), nil
+}
+
+const documentColumns = "id, user_id, file_name, storage_path, uploaded_at, org_id, collection_id, content_type, size_bytes, page_count, chunk_count, status, warnings, summary, ingested_at, pipeline_version"
+
+// qualifiedDocumentColumns is documentColumns prefixed with a table alias,
+// for queries that join documents to another table.
+func qualifiedDocumentColumns(alias string) string {
+	return alias + "." + strings.ReplaceAll(documentColumns, ", ", ", "+alias+".")
+}
+
+// scanDocument scans documentColumns, followed by any extra columns the
+// query selects into extra.
+func scanDocument(row rowScanner, extra ...any) (*Document, error) {
+	var d Document
+	var orgID, collectionID, warnings, summary sql.NullString
+	var ingestedAt sql.NullTime
+	dest := []any{&d.ID, &d.UserID, &d.FileName, &d.StoragePath, &d.UploadedAt, &orgID, &collectionID,
+		&d.ContentType, &d.SizeBytes, &d.PageCount, &d.ChunkCount, &d.Status, &warnings, &summary, &ingestedAt, &d.PipelineVersion}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	d.OrgID, d.CollectionID, d.Summary = orgID.String, collectionID.String, summary.String
+	if warnings.Valid {
+		if err := json.Unmarshal([]byte(warnings.String), &d.Warnings); err != nil {
+			return nil, fmt.Errorf("could not decode warnings of document %s: %w", d.ID, err)
+		}
+	}
+	if ingestedAt.Valid {
+		d.IngestedAt = &ingestedAt.Time
+	}
+	return &d, nil
+}
+
+func (s *documentStore) Get(ctx context.Context, id string) (*Document, error) {
+	d, err := scanDocument(s.db.QueryRowContext(ctx, "SELECT "+documentColumns+" FROM documents WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+func (s *documentStore) ListStale(ctx context.Context, version, limit int) ([]Document, error) {
+	query := "SELECT " + documentColumns + " FROM documents WHERE pipeline_version < ? ORDER BY uploaded_at, id"
+	args := []any{version}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *d)
+	}
+	return docs, rows.Err()
+}
+
+// documentSortColumns maps each sort onto the expression ordered by. Names
+// sort case-insensitively.
+var documentSortColumns = map[string]string{
+	SortByName:       "LOWER(file_name)",
+	SortByUploadedAt: "uploaded_at",
+	SortBySize:       "size_bytes",
+}
+
+func (s *documentStore) List(ctx context.Context, q DocumentQuery) (*DocumentPage, error) {
+	column, ok := documentSortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("cannot sort documents by %q", q.Sort)
+	}
+
+	var where []string
+	var args []any
+	if q.OrgID != "" {
+		where = append(where, "org_id = ?")
+		args = append(args, q.OrgID)
+		if q.CollectionID != "" {
+			where = append(where, "collection_id = ?")
+			args = append(args, q.CollectionID)
+		}
+	} else {
+		where = append(where, "user_id = ? AND org_id IS NULL")
+		args = append(args, q.UserID)
+	}
+	if q.ContentType != "" {
+		where = append(where, "content_type = ?")
+		args = append(args, q.ContentType)
+	}
+	if q.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM document_tags t WHERE t.document_id = documents.id AND t.tag = ?)")
+		args = append(args, q.Tag)
+	}
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, q.Status)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "uploaded_at >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "uploaded_at < ?")
+		args = append(args, q.To.UTC())
+	}
+	filter := " WHERE " + strings.Join(where, " AND ")
+
+	page := &DocumentPage{}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM documents"+filter, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	// Pages continue from the last document seen rather than an offset, so
+	// uploads and deletes between requests do not shift them. The cursor
+	// row is compared by its stored values, which keeps timestamps in the
+	// database's own format.
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.After != "" {
+		var exists int
+		err := s.db.QueryRowContext(ctx, "SELECT 1 FROM documents WHERE id = ?", q.After).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		filter += fmt.Sprintf(" AND (%s, id) %s (SELECT %s, id FROM documents WHERE id = ?)", column, cmp, column)
+		args = append(args, q.After)
+	}
+	query := "SELECT " + documentColumns + " FROM documents" + filter + " ORDER BY " + column + " " + dir + ", id " + dir
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page.Documents = make([]Document, 0)
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Documents = append(page.Documents, *d)
+	}
+	return page, rows.Err()
+}
+
+func (s *documentStore) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SQLite does not enforce ON DELETE CASCADE unless foreign keys are
+	// switched on, so remove dependent rows explicitly.
+	for _, q := range []string{
+		"DELETE FROM document_tags WHERE document_id = ?",
+		"DELETE FROM document_shares WHERE document_id = ?",
+		"DELETE FROM chat_history WHERE document_id = ?",
+		"DELETE FROM document_chunks WHERE document_id = ?",
+		"DELETE FROM documents WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *documentStore) Rename(ctx context.Context, id, fileName string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE documents SET file_name = ? WHERE id = ?", fileName, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *documentStore) SetFailed(ctx context.Context, id, reason string) error {
+	warnings, err := encodeWarnings([]string{reason})
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE documents SET status = ?, warnings = ? WHERE id = ?", DocumentFailed, warnings, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *documentStore) SetSummary(ctx context.Context, id, summary string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE documents SET summary = ? WHERE id = ?", nullable(summary), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *documentStore) Tags(ctx context.Context, id string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT tag FROM document_tags WHERE document_id = ? ORDER BY tag", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *documentStore) SetTags(ctx context.Context, id string, tags []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM document_tags WHERE document_id = ?", id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO document_tags (document_id, tag) VALUES (?, ?) ON CONFLICT DO NOTHING", id, tag); err != nil {
+			return fmt.Errorf("could not insert tag %q: %w", tag, err)
+		}
+	}
+	return tx.Commit()
+}
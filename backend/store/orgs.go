@@ -0,0 +1,242 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type orgStore struct {
+	db *database.DB
+}
+
+func (s *orgStore) Create(ctx context.Context, org Organization, ownerID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO organizations (id, name, created_by) VALUES (?, ?, ?)", org.ID, org.Name, ownerID); err != nil {
+		return fmt.Errorf("could not insert organization: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO organization_members (org_id, user_id, role) VALUES (?, ?, ?)", org.ID, ownerID, string(OrgRoleOwner)); err != nil {
+		return fmt.Errorf("could not insert owner membership: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *orgStore) ListForUser(ctx context.Context, userID string) ([]Membership, error) {
+	rows, err := s.db.QueryContext(ctx, `
+    SELECT o.id, o.name, o.created_by, o.created_at, m.role
+    FROM organization_members m
+    JOIN organizations o ON o.id = m.org_id
+    WHERE m.user_id = ?
+    ORDER BY o.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := make([]Membership, 0)
+	for rows.Next() {
+		var m Membership
+		var role string
+		if err := rows.Scan(&m.ID, &m.Name, &m.CreatedBy, &m.CreatedAt, &role); err != nil {
+			return nil, err
+		}
+		m.Role = OrgRole(role)
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+func (s *orgStore) MemberRole(ctx context.Context, orgID, userID string) (OrgRole, error) {
+	var exists int
+	err := s.db.QueryRowContext(ctx, "SELECT 1 FROM organizations WHERE id = ?", orgID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrgRoleNone, ErrNotFound
+	}
+	if err != nil {
+		return OrgRoleNone, err
+	}
+
+	var role string
+	err = s.db.QueryRowContext(ctx, "SELECT role FROM organization_members WHERE org_id = ? AND user_id = ?", orgID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrgRoleNone, nil
+	}
+	return OrgRole(role), err
+}
+
+func (s *orgStore) ListMembers(ctx context.Context, orgID string) ([]Member, error) {
+	rows, err := s.db.QueryContext(ctx, `
+    SELECT m.user_id, COALESCE(u.email, ''), m.role, m.joined_at
+    FROM organization_members m
+    JOIN users u ON u.id = m.user_id
+    WHERE m.org_id = ?
+    ORDER BY m.joined_at`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]Member, 0)
+	for rows.Next() {
+		var m Member
+		var role string
+		if err := rows.Scan(&m.UserID, &m.Email, &role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		m.Role = OrgRole(role)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *orgStore) AddMember(ctx context.Context, orgID, userID string, role OrgRole) error {
+	_, err := s.db.ExecContext(ctx, `
+    INSERT INTO organization_members (org_id, user_id, role) VALUES (?, ?, ?)
+    ON CONFLICT (org_id, user_id) DO UPDATE SET role = excluded.role`,
+		orgID, userID, string(role))
+	return err
+}
+
+func (s *orgStore) RemoveMember(ctx context.Context, orgID, userID string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM organization_members WHERE org_id = ? AND user_id = ?", orgID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *orgStore) CountOwners(ctx context.Context, orgID string) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM organization_members WHERE org_id = ? AND role = ?", orgID, string(OrgRoleOwner)).Scan(&n)
+	return n, err
+}
+
+func (s *orgStore) Invite(ctx context.Context, invite Invite) error {
+	_, err := s.db.ExecContext(ctx, `
+    INSERT INTO organization_invites (id, org_id, email, role, invited_by) VALUES (?, ?, ?, ?, ?)
+    ON CONFLICT (org_id, email) DO UPDATE SET role = excluded.role, invited_by = excluded.invited_by`,
+		invite.ID, invite.OrgID, strings.ToLower(invite.Email), string(invite.Role), invite.InvitedBy)
+	return err
+}
+
+func (s *orgStore) ListInvites(ctx context.Context, orgID string) ([]Invite, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, org_id, email, role, invited_by, created_at FROM organization_invites WHERE org_id = ? ORDER BY created_at", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := make([]Invite, 0)
+	for rows.Next() {
+		var inv Invite
+		var role string
+		if err := rows.Scan(&inv.ID, &inv.OrgID, &inv.Email, &role, &inv.InvitedBy, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		inv.Role = OrgRole(role)
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+func (s *orgStore) RevokeInvite(ctx context.Context, orgID, email string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM organization_invites WHERE org_id = ? AND email = ?", orgID, strings.ToLower(email))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *orgStore) ClaimInvites(ctx context.Context, userID, email string) (int, error) {
+	if email == "" {
+		return 0, nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT org_id, role FROM organization_invites WHERE email = ?", strings.ToLower(email))
+	if err != nil {
+		return 0, err
+	}
+	type pending struct{ orgID, role string }
+	var invites []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.orgID, &p.role); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		invites = append(invites, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range invites {
+		// An existing membership keeps its role; the invite is simply consumed.
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO organization_members (org_id, user_id, role) VALUES (?, ?, ?) ON CONFLICT (org_id, user_id) DO NOTHING",
+			p.orgID, userID, p.role); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM organization_invites WHERE email = ?", strings.ToLower(email)); err != nil {
+		return 0, err
+	}
+	return len(invites), tx.Commit()
+}
+
+func (s *orgStore) CreateCollection(ctx context.Context, c Collection) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO collections (id, org_id, name, created_by) VALUES (?, ?, ?, ?)", c.ID, c.OrgID, c.Name, c.CreatedBy)
+	return err
+}
+
+func (s *orgStore) GetCollection(ctx context.Context, id string) (*Collection, error) {
+	var c Collection
+	err := s.db.QueryRowContext(ctx, "SELECT id, org_id, name, created_by, created_at FROM collections WHERE id = ?", id).
+		Scan(&c.ID, &c.OrgID, &c.Name, &c.CreatedBy, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *orgStore) ListCollections(ctx context.Context, orgID string) ([]Collection, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, org_id, name, created_by, created_at FROM collections WHERE org_id = ? ORDER BY name", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := make([]Collection, 0)
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.ID, &c.OrgID, &c.Name, &c.CreatedBy, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
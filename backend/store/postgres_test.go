@@ -0,0 +1,15 @@
+//go:build postgres
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database/dbtest"
+)
+
+// TestPostgres runs the store tests against PostgreSQL, by default the
+// container from docker-compose.yml.
+func TestPostgres(t *testing.T) {
+	testStores(t, dbtest.PostgresURL)
+}
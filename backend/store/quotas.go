@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type quotaStore struct {
+	db *database.DB
+}
+
+func (s *quotaStore) Add(ctx context.Context, userID, metric string, periods []string, amount int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, period := range periods {
+		_, err := tx.ExecContext(ctx, `
+    INSERT INTO quota_usage (user_id, metric, period, amount) VALUES (?, ?, ?, ?)
+    ON CONFLICT (user_id, metric, period)
+    DO UPDATE SET amount = quota_usage.amount + excluded.amount, updated_at = CURRENT_TIMESTAMP`,
+			userID, metric, period, amount)
+		if err != nil {
+			return fmt.Errorf("could not record %s usage: %w", metric, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *quotaStore) Used(ctx context.Context, userID, metric, period string) (int64, error) {
+	var amount int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT amount FROM quota_usage WHERE user_id = ? AND metric = ? AND period = ?",
+		userID, metric, period).Scan(&amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return amount, err
+}
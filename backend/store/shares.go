@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type shareStore struct {
+	db *database.DB
+}
+
+// nullable stores empty strings as NULL so the grantee unique indexes only
+// apply to the identifier a share was actually created with.
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (s *shareStore) Grant(ctx context.Context, share Share) error {
+	share.GranteeEmail = strings.ToLower(share.GranteeEmail)
+
+	column, value := "grantee_user_id", share.GranteeUserID
+	if value == "" {
+		column, value = "grantee_email", share.GranteeEmail
+	}
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE document_shares SET role = ? WHERE document_id = ? AND "+column+" = ?",
+		string(share.Role), share.DocumentID, value)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO document_shares (id, document_id, grantee_user_id, grantee_email, role, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+		share.ID, share.DocumentID, nullable(share.GranteeUserID), nullable(share.GranteeEmail), string(share.Role), share.CreatedBy)
+	return err
+}
+
+func (s *shareStore) ListByDocument(ctx context.Context, documentID string) ([]Share, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, document_id, grantee_user_id, grantee_email, role, created_by, created_at FROM document_shares WHERE document_id = ? ORDER BY created_at",
+		documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]Share, 0)
+	for rows.Next() {
+		var sh Share
+		var userID, email sql.NullString
+		var role string
+		if err := rows.Scan(&sh.ID, &sh.DocumentID, &userID, &email, &role, &sh.CreatedBy, &sh.CreatedAt); err != nil {
+			return nil, err
+		}
+		sh.GranteeUserID, sh.GranteeEmail, sh.Role = userID.String, email.String, Role(role)
+		shares = append(shares, sh)
+	}
+	return shares, rows.Err()
+}
+
+func (s *shareStore) Revoke(ctx context.Context, documentID, shareID string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM document_shares WHERE id = ? AND document_id = ?", shareID, documentID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *shareStore) ListSharedWith(ctx context.Context, userID, email string) ([]SharedDocument, error) {
+	rows, err := s.db.QueryContext(ctx, `
+    SELECT `+qualifiedDocumentColumns("d")+`, s.role
+    FROM document_shares s
+    JOIN documents d ON d.id = s.document_id
+    WHERE (s.grantee_user_id = ? OR s.grantee_email = ?) AND d.user_id <> ?
+    ORDER BY d.uploaded_at DESC`,
+		userID, strings.ToLower(email), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// A user can hold both an ID and an email grant; keep the stronger one.
+	byID := make(map[string]int)
+	docs := make([]SharedDocument, 0)
+	for rows.Next() {
+		var role string
+		doc, err := scanDocument(rows, &role)
+		if err != nil {
+			return nil, err
+		}
+		d := SharedDocument{Document: *doc, Role: Role(role)}
+		if i, ok := byID[d.ID]; ok {
+			if d.Role.Allows(docs[i].Role) {
+				docs[i].Role = d.Role
+			}
+			continue
+		}
+		byID[d.ID] = len(docs)
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+func (s *shareStore) RoleFor(ctx context.Context, documentID, userID, email string) (Role, error) {
+	var ownerID string
+	var orgID sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT user_id, org_id FROM documents WHERE id = ?", documentID).Scan(&ownerID, &orgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RoleNone, ErrNotFound
+	}
+	if err != nil {
+		return RoleNone, err
+	}
+
+	best := RoleNone
+	if orgID.Valid {
+		// Workspace documents follow membership: an uploader who leaves the
+		// organization loses access like everyone else.
+		var orgRole string
+		err := s.db.QueryRowContext(ctx, "SELECT role FROM organization_members WHERE org_id = ? AND user_id = ?", orgID.String, userID).Scan(&orgRole)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return RoleNone, err
+		}
+		if orgRole != "" {
+			best = OrgRole(orgRole).DocumentRole()
+			if ownerID == userID {
+				best = RoleOwner
+			}
+		}
+	} else if ownerID == userID {
+		return RoleOwner, nil
+	}
+	if best == RoleOwner {
+		return best, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT role FROM document_shares WHERE document_id = ? AND (grantee_user_id = ? OR grantee_email = ?)",
+		documentID, userID, strings.ToLower(email))
+	if err != nil {
+		return RoleNone, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return RoleNone, err
+		}
+		if r := Role(role); r.Allows(best) {
+			best = r
+		}
+	}
+	return best, rows.Err()
+}
@@ -0,0 +1,467 @@
+// Package store is the repository layer. Each store owns the SQL for one
+// aggregate and is consumed through an interface, so handlers never touch the
+// database directly.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+// ErrNotFound is returned when a lookup matches no row.
+var ErrNotFound = errors.New("store: not found")
+
+type User struct {
+	ID        string
+	Email     string
+	CreatedAt time.Time
+}
+
+type Document struct {
+	ID          string
+	UserID      string
+	FileName    string
+	StoragePath string
+	UploadedAt  time.Time
+	// OrgID and CollectionID are empty for personal documents.
+	OrgID        string
+	CollectionID string
+	ContentType  string
+	SizeBytes    int64
+	// PageCount is 0 for formats without pages.
+	PageCount  int
+	ChunkCount int
+	Status     DocumentStatus
+	// Warnings describe parts of the file that could not be extracted.
+	Warnings []string
+	// Summary is written by a background task after ingestion and is empty
+	// until then.
+	Summary string
+	// IngestedAt is when the chunks were saved, nil while processing.
+	IngestedAt *time.Time
+	// PipelineVersion identifies the extraction and chunking that produced
+	// the chunks, 0 if it was not recorded.
+	PipelineVersion int
+}
+
+// DocumentStatus is where a document is in ingestion.
+type DocumentStatus string
+
+const (
+	DocumentProcessing DocumentStatus = "processing"
+	DocumentReady      DocumentStatus = "ready"
+	DocumentFailed     DocumentStatus = "failed"
+)
+
+// Columns documents can be sorted by.
+const (
+	SortByName       = "name"
+	SortByUploadedAt = "uploadedAt"
+	SortBySize       = "size"
+)
+
+// DocumentQuery selects a page of documents: a workspace's when OrgID is
+// set, otherwise UserID's personal ones. Zero filter fields match
+// everything.
+type DocumentQuery struct {
+	UserID       string
+	OrgID        string
+	CollectionID string
+	ContentType  string
+	Tag          string
+	Status       DocumentStatus
+	From         time.Time
+	To           time.Time
+
+	Sort string
+	Desc bool
+	// After is the ID of the last document on the previous page, or empty
+	// for the first page.
+	After string
+	// Limit is the page size; 0 returns every match.
+	Limit int
+}
+
+// DocumentPage is one page of a DocumentQuery. Total counts every match,
+// not just this page.
+type DocumentPage struct {
+	Documents []Document
+	Total     int
+}
+
+type Chunk struct {
+	ID         string
+	DocumentID string
+	Index      int
+	// Page is the 1-based page the chunk starts on, or 0 if unknown.
+	Page    int
+	Content string
+	// Embedded reports whether an embedding is stored for the chunk.
+	Embedded bool
+	// Embedding is the vector saved with a new chunk, or nil to save it
+	// without one. It is not loaded back.
+	Embedding []float32
+}
+
+// Message types stored in chat_history.message_type.
+const (
+	MessageTypeUser = "user"
+	MessageTypeAI   = "ai"
+)
+
+type ChatMessage struct {
+	ID         string
+	DocumentID string
+	UserID     string
+	Type       string
+	Content    string
+	Timestamp  time.Time
+}
+
+type UserStore interface {
+	// Ensure records the user if they are not known yet. An empty Email
+	// means the user has none.
+	Ensure(ctx context.Context, u User) error
+	Get(ctx context.Context, id string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+}
+
+type DocumentStore interface {
+	// Create saves the document and its chunks in a single transaction.
+	// Only the chunks' Content, Page and Embedding are used; they are
+	// numbered in order. The document gets doc.Status, or ready if it is
+	// empty; IngestedAt is set only when it is ready.
+	Create(ctx context.Context, doc Document, chunks []Chunk) error
+	Get(ctx context.Context, id string) (*Document, error)
+	// List returns a page of matching documents ordered by q.Sort, with ties
+	// broken by ID. It returns ErrNotFound if q.After no longer exists.
+	List(ctx context.Context, q DocumentQuery) (*DocumentPage, error)
+	// Delete removes the document together with its chunks, chat history,
+	// shares and tags.
+	Delete(ctx context.Context, id string) error
+	// Rename changes the document's display name; the stored file keeps its
+	// original path.
+	Rename(ctx context.Context, id, fileName string) error
+	Tags(ctx context.Context, id string) ([]string, error)
+	// SetTags replaces the document's tags.
+	SetTags(ctx context.Context, id string, tags []string) error
+	SetSummary(ctx context.Context, id, summary string) error
+	// ReplaceChunks replaces the document's chunks and the fields derived
+	// from its file (ContentType, SizeBytes, PageCount, Warnings and
+	// PipelineVersion) in a single transaction and marks it ready, so
+	// readers see either the old chunks or the new ones. The summary, which
+	// described the old chunks, is cleared. Only the chunks' Content, Page
+	// and Embedding are used.
+	ReplaceChunks(ctx context.Context, doc Document, chunks []Chunk) error
+	// SetFailed marks the document failed, recording why as its only
+	// warning. Its chunks are left alone.
+	SetFailed(ctx context.Context, id, reason string) error
+	// ListStale returns up to limit documents whose pipeline version is
+	// below version, oldest upload first. A limit of 0 returns them all.
+	ListStale(ctx context.Context, version, limit int) ([]Document, error)
+}
+
+type ChunkStore interface {
+	ListByDocument(ctx context.Context, documentID string) ([]Chunk, error)
+	// ListAfter returns up to limit chunks whose index is greater than
+	// after, in order. Pass -1 for the first page.
+	ListAfter(ctx context.Context, documentID string, after, limit int) ([]Chunk, error)
+}
+
+type ChatStore interface {
+	// SaveExchange stores a user question and the AI answer atomically.
+	// conversationID groups the exchanges of one chat session.
+	SaveExchange(ctx context.Context, documentID, userID, conversationID, query, answer string) error
+}
+
+// APIKey is a personal API key. Only the SHA-256 hash of the secret is stored.
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+type APIKeyStore interface {
+	Create(ctx context.Context, key APIKey) error
+	ListByUser(ctx context.Context, userID string) ([]APIKey, error)
+	// GetByHash returns the key with the given hash, including revoked keys.
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	// Revoke marks the user's key as revoked. It returns ErrNotFound if the
+	// key does not exist, belongs to someone else or is already revoked.
+	Revoke(ctx context.Context, id, userID string) error
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// Role is a caller's level of access to a document. Each role includes the
+// permissions of the roles below it.
+type Role string
+
+const (
+	RoleNone   Role = ""
+	RoleViewer Role = "viewer" // read and chat
+	RoleEditor Role = "editor" // re-tag and re-ingest
+	RoleOwner  Role = "owner"  // delete and manage sharing
+)
+
+var roleRank = map[Role]int{RoleNone: 0, RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Allows reports whether r grants at least the access of required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// Share grants a user, identified by ID or email, access to a document.
+type Share struct {
+	ID            string
+	DocumentID    string
+	GranteeUserID string
+	GranteeEmail  string
+	Role          Role
+	CreatedBy     string
+	CreatedAt     time.Time
+}
+
+// SharedDocument is a document someone else shared with the caller.
+type SharedDocument struct {
+	Document
+	Role Role
+}
+
+type ShareStore interface {
+	// Grant creates the share, or updates the role of an existing share for
+	// the same grantee.
+	Grant(ctx context.Context, share Share) error
+	ListByDocument(ctx context.Context, documentID string) ([]Share, error)
+	Revoke(ctx context.Context, documentID, shareID string) error
+	// ListSharedWith returns documents shared with the user by ID or email.
+	ListSharedWith(ctx context.Context, userID, email string) ([]SharedDocument, error)
+	// RoleFor resolves the caller's role on a document from ownership,
+	// workspace membership and shares. It returns ErrNotFound if the
+	// document does not exist.
+	RoleFor(ctx context.Context, documentID, userID, email string) (Role, error)
+}
+
+// OrgRole is a member's role within an organization.
+type OrgRole string
+
+const (
+	OrgRoleNone   OrgRole = ""
+	OrgRoleMember OrgRole = "member"
+	OrgRoleAdmin  OrgRole = "admin" // manages members and invites
+	OrgRoleOwner  OrgRole = "owner"
+)
+
+var orgRoleRank = map[OrgRole]int{OrgRoleNone: 0, OrgRoleMember: 1, OrgRoleAdmin: 2, OrgRoleOwner: 3}
+
+// Allows reports whether r grants at least the access of required.
+func (r OrgRole) Allows(required OrgRole) bool {
+	return orgRoleRank[r] >= orgRoleRank[required]
+}
+
+// DocumentRole is the access an organization role grants on the
+// workspace's documents: admins and owners manage them, members edit.
+func (r OrgRole) DocumentRole() Role {
+	switch r {
+	case OrgRoleOwner, OrgRoleAdmin:
+		return RoleOwner
+	case OrgRoleMember:
+		return RoleEditor
+	default:
+		return RoleNone
+	}
+}
+
+type Organization struct {
+	ID        string
+	Name      string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Membership is an organization as seen by one of its members.
+type Membership struct {
+	Organization
+	Role OrgRole
+}
+
+type Member struct {
+	UserID   string
+	Email    string
+	Role     OrgRole
+	JoinedAt time.Time
+}
+
+// Invite lets someone who has not signed up yet join an organization. It is
+// claimed the next time a user with that email lists their organizations.
+type Invite struct {
+	ID        string
+	OrgID     string
+	Email     string
+	Role      OrgRole
+	InvitedBy string
+	CreatedAt time.Time
+}
+
+type Collection struct {
+	ID        string
+	OrgID     string
+	Name      string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+type OrgStore interface {
+	// Create saves the organization with ownerID as its first owner.
+	Create(ctx context.Context, org Organization, ownerID string) error
+	ListForUser(ctx context.Context, userID string) ([]Membership, error)
+	// MemberRole returns OrgRoleNone for non-members and ErrNotFound if the
+	// organization does not exist.
+	MemberRole(ctx context.Context, orgID, userID string) (OrgRole, error)
+	ListMembers(ctx context.Context, orgID string) ([]Member, error)
+	// AddMember adds the user or changes their role.
+	AddMember(ctx context.Context, orgID, userID string, role OrgRole) error
+	RemoveMember(ctx context.Context, orgID, userID string) error
+	CountOwners(ctx context.Context, orgID string) (int, error)
+
+	// Invite creates the invite or updates the role of an existing one.
+	Invite(ctx context.Context, invite Invite) error
+	ListInvites(ctx context.Context, orgID string) ([]Invite, error)
+	RevokeInvite(ctx context.Context, orgID, email string) error
+	// ClaimInvites turns pending invites for email into memberships.
+	ClaimInvites(ctx context.Context, userID, email string) (int, error)
+
+	CreateCollection(ctx context.Context, c Collection) error
+	GetCollection(ctx context.Context, id string) (*Collection, error)
+	ListCollections(ctx context.Context, orgID string) ([]Collection, error)
+}
+
+// QuotaStore keeps running usage totals per user, metric and period, e.g.
+// "llm_tokens" for "2026-10-19" or "2026-10".
+type QuotaStore interface {
+	// Add increments the user's counter for each period by amount.
+	Add(ctx context.Context, userID, metric string, periods []string, amount int64) error
+	// Used returns the total for one period, or 0 if nothing was recorded.
+	Used(ctx context.Context, userID, metric, period string) (int64, error)
+}
+
+// Model call operations recorded in LLMUsage.
+const (
+	OperationGenerate = "generate"
+	OperationEmbed    = "embed"
+)
+
+// LLMUsage records the tokens and estimated cost of one model call.
+type LLMUsage struct {
+	ID             string
+	UserID         string
+	DocumentID     string
+	ConversationID string
+	Operation      string
+	Model          string
+	PromptTokens   int64
+	ResponseTokens int64
+	CostUSD        float64
+	CreatedAt      time.Time
+}
+
+// Dimensions usage can be grouped by.
+const (
+	GroupByDay          = "day"
+	GroupByModel        = "model"
+	GroupByDocument     = "document"
+	GroupByConversation = "conversation"
+	GroupByUser         = "user"
+)
+
+// UsageFilter selects usage records. From and To are inclusive UTC days in
+// YYYY-MM-DD form; an empty UserID matches every user.
+type UsageFilter struct {
+	UserID string
+	From   string
+	To     string
+}
+
+// UsageSummary aggregates the usage records sharing one Key.
+type UsageSummary struct {
+	Key            string
+	Calls          int64
+	PromptTokens   int64
+	ResponseTokens int64
+	CostUSD        float64
+}
+
+type UsageStore interface {
+	Record(ctx context.Context, u LLMUsage) error
+	// Summarize aggregates matching records by one of the GroupBy values.
+	Summarize(ctx context.Context, f UsageFilter, groupBy string) ([]UsageSummary, error)
+}
+
+// AuditEvent is one entry in the append-only audit trail. Details is a JSON
+// object with action-specific fields.
+type AuditEvent struct {
+	ID         string
+	UserID     string
+	Action     string
+	DocumentID string
+	IP         string
+	UserAgent  string
+	Details    string
+	CreatedAt  time.Time
+}
+
+// AuditFilter selects audit events. Zero fields match everything.
+type AuditFilter struct {
+	UserID     string
+	DocumentID string
+	Action     string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+type AuditStore interface {
+	// Append inserts an event. Appending an ID that is already stored is a
+	// no-op, so retried writes cannot duplicate entries.
+	Append(ctx context.Context, e AuditEvent) error
+	// List returns matching events, newest first.
+	List(ctx context.Context, f AuditFilter) ([]AuditEvent, error)
+}
+
+// Stores bundles every repository backed by the same database.
+type Stores struct {
+	Users     UserStore
+	Documents DocumentStore
+	Chunks    ChunkStore
+	Chats     ChatStore
+	APIKeys   APIKeyStore
+	Shares    ShareStore
+	Orgs      OrgStore
+	Quotas    QuotaStore
+	Usage     UsageStore
+	Audit     AuditStore
+}
+
+// New returns SQL-backed stores for db.
+func New(db *database.DB) *Stores {
+	return &Stores{
+		Users:     &userStore{db: db},
+		Documents: &documentStore{db: db},
+		Chunks:    &chunkStore{db: db},
+		Chats:     &chatStore{db: db},
+		APIKeys:   &apiKeyStore{db: db},
+		Shares:    &shareStore{db: db},
+		Orgs:      &orgStore{db: db},
+		Quotas:    &quotaStore{db: db},
+		Usage:     &usageStore{db: db},
+		Audit:     &auditStore{db: db},
+	}
+}
@@ -0,0 +1,826 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+	"github.com/malharg/strategic-insight-analyst/backend/database/dbtest"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+func TestSQLite(t *testing.T) {
+	testStores(t, dbtest.SQLiteURL)
+}
+
+// testStores runs the store tests, each against a fresh database from newURL.
+func testStores(t *testing.T, newURL func(testing.TB) string) {
+	tests := []struct {
+		name string
+		test func(*testing.T, *database.DB)
+	}{
+		{"users", testUsers},
+		{"documents", testDocuments},
+		{"pagination", testPagination},
+		{"chats", testChats},
+		{"api keys", testAPIKeys},
+		{"sharing", testSharing},
+		{"organizations", testOrgs},
+		{"quotas", testQuotas},
+		{"usage", testUsage},
+		{"audit", testAudit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.Connect(newURL(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			tt.test(t, db)
+		})
+	}
+}
+
+func count(t *testing.T, db *database.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func testUsers(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	if err := s.Users.Ensure(ctx, store.User{ID: "u1", Email: "first@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Users.Ensure(ctx, store.User{ID: "u1", Email: "second@example.com"}); err != nil {
+		t.Fatalf("Ensure of a known user: %v", err)
+	}
+	u, err := s.Users.Get(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Email != "first@example.com" || u.CreatedAt.IsZero() {
+		t.Errorf("Get = %+v, want the first email kept", u)
+	}
+
+	// Users without an email, e.g. signed in by phone, do not collide.
+	for _, id := range []string{"phone1", "phone2"} {
+		if err := s.Users.Ensure(ctx, store.User{ID: id}); err != nil {
+			t.Fatalf("Ensure of %s without an email: %v", id, err)
+		}
+		if u, err := s.Users.Get(ctx, id); err != nil || u.Email != "" {
+			t.Errorf("Get(%s) = %+v, %v", id, u, err)
+		}
+	}
+	if _, err := s.Users.GetByEmail(ctx, ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetByEmail of no email: %v, want ErrNotFound", err)
+	}
+	if err := s.Users.Ensure(ctx, store.User{ID: "u2", Email: "first@example.com"}); err == nil {
+		t.Error("Ensure of another user with a taken email succeeded")
+	}
+	if _, err := s.Users.Get(ctx, "nobody"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get of an unknown user: %v, want ErrNotFound", err)
+	}
+	if u, err := s.Users.GetByEmail(ctx, "First@Example.com"); err != nil || u.ID != "u1" {
+		t.Errorf("GetByEmail = %+v, %v; want u1 regardless of case", u, err)
+	}
+	if _, err := s.Users.GetByEmail(ctx, "nobody@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetByEmail of an unknown email: %v, want ErrNotFound", err)
+	}
+}
+
+func testDocuments(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	for _, id := range []string{"owner", "other"} {
+		if err := s.Users.Ensure(ctx, store.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	doc := store.Document{ID: "doc-1", UserID: "owner", FileName: "q3.txt", StoragePath: "owner/doc-1/q3.txt", ContentType: "text/plain", SizeBytes: 42,
+		Warnings: []string{"Page 2: text could not be extracted."}}
+	if err := s.Documents.Create(ctx, doc, []store.Chunk{{Content: "Revenue grew.", Page: 1, Embedding: []float32{0.25, -1}}, {Content: "Costs fell.", Page: 1}, {Content: "Margins widened.", Page: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Documents.Create(ctx, store.Document{ID: "doc-2", UserID: "other", FileName: "b.txt", StoragePath: "other/doc-2/b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Documents.Get(ctx, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != doc.UserID || got.FileName != doc.FileName || got.StoragePath != doc.StoragePath || got.UploadedAt.IsZero() {
+		t.Errorf("Get = %+v", got)
+	}
+	if _, err := s.Documents.Get(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get of a missing document: %v, want ErrNotFound", err)
+	}
+
+	chunks, err := s.Chunks.ListByDocument(ctx, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	for i, c := range chunks {
+		if c.Index != i || c.DocumentID != doc.ID || c.ID == "" || c.Embedded != (i == 0) {
+			t.Errorf("chunk %d = %+v", i, c)
+		}
+	}
+	if chunks[2].Content != "Margins widened." || chunks[2].Page != 3 {
+		t.Errorf("last chunk = %+v", chunks[2])
+	}
+	var raw string
+	if err := db.QueryRowContext(ctx, "SELECT embedding FROM document_chunks WHERE document_id = ? AND chunk_index = 0", doc.ID).Scan(&raw); err != nil || raw != "[0.25,-1]" {
+		t.Errorf("stored embedding = %q, %v", raw, err)
+	}
+	if db.HasVector {
+		if n := count(t, db, "SELECT COUNT(*) FROM document_chunks WHERE document_id = ? AND embedding_vector IS NOT NULL", doc.ID); n != 1 {
+			t.Errorf("%d chunks have a pgvector embedding, want 1", n)
+		}
+	}
+	if page, err := s.Chunks.ListAfter(ctx, doc.ID, -1, 2); err != nil || len(page) != 2 || page[0].Index != 0 || page[1].Index != 1 {
+		t.Errorf("ListAfter(-1, 2) = %+v, %v", page, err)
+	}
+	if page, err := s.Chunks.ListAfter(ctx, doc.ID, 1, 10); err != nil || len(page) != 1 || page[0].Content != "Margins widened." {
+		t.Errorf("ListAfter(1, 10) = %+v, %v", page, err)
+	}
+	if page, err := s.Chunks.ListAfter(ctx, doc.ID, 2, 10); err != nil || len(page) != 0 {
+		t.Errorf("ListAfter past the last chunk = %+v, %v", page, err)
+	}
+
+	if got.ChunkCount != 3 || got.Status != store.DocumentReady || got.ContentType != "text/plain" || got.SizeBytes != 42 {
+		t.Errorf("document stats = %+v", got)
+	}
+	if !slices.Equal(got.Warnings, doc.Warnings) || got.IngestedAt == nil || got.Summary != "" {
+		t.Errorf("ingestion details = %+v", got)
+	}
+	if other, _ := s.Documents.Get(ctx, "doc-2"); other.Warnings != nil {
+		t.Errorf("warnings of a clean document = %#v, want nil", other.Warnings)
+	}
+
+	page, err := s.Documents.List(ctx, store.DocumentQuery{UserID: "owner", Sort: store.SortByUploadedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || len(page.Documents) != 1 || page.Documents[0].ID != doc.ID {
+		t.Errorf("List(owner) = %+v, want only doc-1", page)
+	}
+	if page, err := s.Documents.List(ctx, store.DocumentQuery{UserID: "nobody", Sort: store.SortByUploadedAt}); err != nil || page.Documents == nil || len(page.Documents) != 0 {
+		t.Errorf("List(nobody) = %#v, %v; want an empty page", page, err)
+	}
+	if _, err := s.Documents.List(ctx, store.DocumentQuery{UserID: "owner", Sort: "colour"}); err == nil {
+		t.Error("List with an unknown sort succeeded")
+	}
+
+	// A failed create must not leave chunks behind.
+	if err := s.Documents.Create(ctx, doc, []store.Chunk{{Content: "Duplicate."}}); err == nil {
+		t.Fatal("creating a document with a duplicate id succeeded")
+	}
+	if n := count(t, db, "SELECT COUNT(*) FROM document_chunks WHERE document_id = ?", doc.ID); n != 3 {
+		t.Errorf("%d chunks after the failed create, want 3", n)
+	}
+
+	// Uploads are recorded as processing, then either get their chunks or
+	// fail.
+	pending := store.Document{ID: "doc-3", UserID: "owner", FileName: "c.pdf", StoragePath: "owner/doc-3/c.pdf", Status: store.DocumentProcessing}
+	if err := s.Documents.Create(ctx, pending, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Documents.Get(ctx, pending.ID); got.Status != store.DocumentProcessing || got.IngestedAt != nil {
+		t.Errorf("processing document = %+v", got)
+	}
+	if err := s.Documents.SetFailed(ctx, pending.ID, "Text extraction failed."); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Documents.Get(ctx, pending.ID); got.Status != store.DocumentFailed || !slices.Equal(got.Warnings, []string{"Text extraction failed."}) || got.IngestedAt != nil {
+		t.Errorf("failed document = %+v", got)
+	}
+	if err := s.Documents.ReplaceChunks(ctx, pending, []store.Chunk{{Content: "Recovered."}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Documents.Get(ctx, pending.ID); got.Status != store.DocumentReady || got.Warnings != nil || got.IngestedAt == nil || got.ChunkCount != 1 {
+		t.Errorf("document ready after ReplaceChunks = %+v", got)
+	}
+	if err := s.Documents.Delete(ctx, pending.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Documents.Rename(ctx, doc.ID, "Q3 report.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Documents.Get(ctx, doc.ID); got.FileName != "Q3 report.txt" || got.StoragePath != doc.StoragePath {
+		t.Errorf("after Rename = %+v, want the new name and the old path", got)
+	}
+	if err := s.Documents.SetSummary(ctx, doc.ID, "Revenue up, costs down."); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Documents.Get(ctx, doc.ID); got.Summary != "Revenue up, costs down." {
+		t.Errorf("Summary = %q", got.Summary)
+	}
+
+	if stale, err := s.Documents.ListStale(ctx, 1, 0); err != nil || len(stale) != 2 || stale[0].ID != doc.ID || stale[1].ID != "doc-2" {
+		t.Errorf("ListStale(1) = %+v, %v; want both documents, oldest first", stale, err)
+	}
+	if stale, err := s.Documents.ListStale(ctx, 1, 1); err != nil || len(stale) != 1 {
+		t.Errorf("ListStale(1, limit 1) = %+v, %v", stale, err)
+	}
+	// Re-ingesting swaps the chunks and clears the summary they produced.
+	reingested := *got
+	reingested.PipelineVersion, reingested.PageCount, reingested.Warnings = 1, 2, nil
+	if err := s.Documents.ReplaceChunks(ctx, reingested, []store.Chunk{{Content: "All of it.", Page: 2, Embedding: []float32{1}}}); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := s.Documents.Get(ctx, doc.ID)
+	if after.ChunkCount != 1 || after.PageCount != 2 || after.Summary != "" || after.PipelineVersion != 1 || after.Warnings != nil || after.FileName != "Q3 report.txt" || after.IngestedAt == nil {
+		t.Errorf("after ReplaceChunks = %+v", after)
+	}
+	if saved, _ := s.Chunks.ListByDocument(ctx, doc.ID); len(saved) != 1 || saved[0].Content != "All of it." || saved[0].Index != 0 || !saved[0].Embedded {
+		t.Errorf("chunks after ReplaceChunks = %+v", saved)
+	}
+	if stale, err := s.Documents.ListStale(ctx, 1, 0); err != nil || len(stale) != 1 || stale[0].ID != "doc-2" {
+		t.Errorf("ListStale(1) after re-ingesting = %+v, %v; want only doc-2", stale, err)
+	}
+
+	if err := s.Documents.SetTags(ctx, doc.ID, []string{"q3", "finance"}); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := s.Documents.Tags(ctx, doc.ID); err != nil || !slices.Equal(tags, []string{"finance", "q3"}) {
+		t.Errorf("Tags = %q, %v; want them sorted", tags, err)
+	}
+	if err := s.Documents.SetTags(ctx, doc.ID, []string{"q3"}); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ := s.Documents.Tags(ctx, doc.ID); !slices.Equal(tags, []string{"q3"}) {
+		t.Errorf("Tags after replacing = %q", tags)
+	}
+	for _, f := range []struct {
+		name        string
+		match, miss store.DocumentQuery
+	}{
+		{"tag", store.DocumentQuery{Tag: "q3"}, store.DocumentQuery{Tag: "finance"}},
+		{"content type", store.DocumentQuery{ContentType: "text/plain"}, store.DocumentQuery{ContentType: "application/pdf"}},
+		{"status", store.DocumentQuery{Status: store.DocumentReady}, store.DocumentQuery{Status: store.DocumentFailed}},
+	} {
+		for q, want := range map[store.DocumentQuery]int{f.match: 1, f.miss: 0} {
+			q.UserID, q.Sort = "owner", store.SortByName
+			if page, err := s.Documents.List(ctx, q); err != nil || page.Total != want {
+				t.Errorf("List by %s %+v = %+v, %v; want %d", f.name, q, page, err, want)
+			}
+		}
+	}
+	if err := s.Shares.Grant(ctx, store.Share{ID: "share-1", DocumentID: doc.ID, GranteeUserID: "other", Role: store.RoleViewer, CreatedBy: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Chats.SaveExchange(ctx, doc.ID, "owner", "conv-1", "How did revenue do?", "It grew."); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Documents.Delete(ctx, doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Documents.Get(ctx, doc.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Documents.Rename(ctx, doc.ID, "x"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Rename after Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Documents.SetSummary(ctx, doc.ID, "x"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SetSummary after Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Documents.ReplaceChunks(ctx, reingested, nil); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("ReplaceChunks after Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Documents.SetFailed(ctx, doc.ID, "x"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SetFailed after Delete: %v, want ErrNotFound", err)
+	}
+	if n := count(t, db, "SELECT COUNT(*) FROM document_chunks WHERE document_id = ?", doc.ID); n != 0 {
+		t.Errorf("%d chunks left after Delete", n)
+	}
+	for _, table := range []string{"chat_history", "document_tags", "document_shares"} {
+		if n := count(t, db, "SELECT COUNT(*) FROM "+table+" WHERE document_id = ?", doc.ID); n != 0 {
+			t.Errorf("%d rows left in %s after Delete", n, table)
+		}
+	}
+	if _, err := s.Documents.Get(ctx, "doc-2"); err != nil {
+		t.Errorf("Delete removed another document: %v", err)
+	}
+}
+
+// testPagination checks that following After through every page of a sort
+// yields the same documents, in the same order, as one unpaged query.
+func testPagination(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	if err := s.Users.Ensure(ctx, store.User{ID: "pager", Email: "pager@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"beta.txt", "Alpha.txt", "delta.pdf", "Charlie.txt", "echo.txt"}
+	for i, name := range names {
+		doc := store.Document{ID: fmt.Sprintf("page-%d", i), UserID: "pager", FileName: name, StoragePath: "pager/" + name, ContentType: "text/plain", SizeBytes: int64(100 * (5 - i))}
+		if err := s.Documents.Create(ctx, doc, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []string{store.SortByName, store.SortByUploadedAt, store.SortBySize} {
+		for _, desc := range []bool{false, true} {
+			q := store.DocumentQuery{UserID: "pager", Sort: sort, Desc: desc}
+			all, err := s.Documents.List(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if all.Total != len(names) || len(all.Documents) != len(names) {
+				t.Fatalf("%s desc=%v: %d of %d documents", sort, desc, len(all.Documents), all.Total)
+			}
+
+			var paged []string
+			q.Limit = 2
+			for {
+				page, err := s.Documents.List(ctx, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.Total != len(names) {
+					t.Errorf("%s desc=%v: page total %d", sort, desc, page.Total)
+				}
+				for _, d := range page.Documents {
+					paged = append(paged, d.ID)
+				}
+				if len(page.Documents) < q.Limit {
+					break
+				}
+				q.After = page.Documents[len(page.Documents)-1].ID
+			}
+			var want []string
+			for _, d := range all.Documents {
+				want = append(want, d.ID)
+			}
+			if !slices.Equal(paged, want) {
+				t.Errorf("%s desc=%v: pages %v, want %v", sort, desc, paged, want)
+			}
+		}
+	}
+
+	byName, _ := s.Documents.List(ctx, store.DocumentQuery{UserID: "pager", Sort: store.SortByName})
+	var order []string
+	for _, d := range byName.Documents {
+		order = append(order, d.FileName)
+	}
+	if want := []string{"Alpha.txt", "beta.txt", "Charlie.txt", "delta.pdf", "echo.txt"}; !slices.Equal(order, want) {
+		t.Errorf("by name: %v, want %v", order, want)
+	}
+	bySize, _ := s.Documents.List(ctx, store.DocumentQuery{UserID: "pager", Sort: store.SortBySize, Desc: true, Limit: 1})
+	if len(bySize.Documents) != 1 || bySize.Documents[0].ID != "page-0" {
+		t.Errorf("largest document = %+v, want page-0", bySize.Documents)
+	}
+
+	tomorrow := time.Now().Add(24 * time.Hour)
+	if page, err := s.Documents.List(ctx, store.DocumentQuery{UserID: "pager", Sort: store.SortByName, From: tomorrow}); err != nil || page.Total != 0 {
+		t.Errorf("documents uploaded from tomorrow: %+v, %v", page, err)
+	}
+	if _, err := s.Documents.List(ctx, store.DocumentQuery{UserID: "pager", Sort: store.SortByName, After: "gone"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("List after a deleted document: %v, want ErrNotFound", err)
+	}
+}
+
+func testChats(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	if err := s.Users.Ensure(ctx, store.User{ID: "owner", Email: "owner@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Documents.Create(ctx, store.Document{ID: "doc-1", UserID: "owner", FileName: "a.txt", StoragePath: "owner/a.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Chats.SaveExchange(ctx, "doc-1", "owner", "conv-1", "Question?", "Answer."); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT message_type, message_content FROM chat_history WHERE document_id = ? AND user_id = ? AND conversation_id = ? ORDER BY message_type DESC", "doc-1", "owner", "conv-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var typ, content string
+		if err := rows.Scan(&typ, &content); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, typ+": "+content)
+	}
+	if want := []string{"user: Question?", "ai: Answer."}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("saved messages = %q, want %q", got, want)
+	}
+}
+
+func testAPIKeys(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	if err := s.Users.Ensure(ctx, store.User{ID: "owner", Email: "owner@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	key := store.APIKey{ID: "key-1", UserID: "owner", Name: "ci", Prefix: "sia_abcdefgh", Hash: "hash-1", Scopes: []string{"read", "chat"}}
+	if err := s.APIKeys.Create(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.APIKeys.GetByHash(ctx, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != key.ID || got.Name != "ci" || !slices.Equal(got.Scopes, key.Scopes) || got.CreatedAt.IsZero() || got.LastUsedAt != nil || got.RevokedAt != nil {
+		t.Errorf("GetByHash = %+v", got)
+	}
+	if _, err := s.APIKeys.GetByHash(ctx, "unknown"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetByHash of an unknown hash: %v, want ErrNotFound", err)
+	}
+
+	used := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := s.APIKeys.Touch(ctx, key.ID, used); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.APIKeys.GetByHash(ctx, "hash-1"); got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) {
+		t.Errorf("last used = %v, want %v", got.LastUsedAt, used)
+	}
+
+	if err := s.APIKeys.Revoke(ctx, key.ID, "someone-else"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("revoking another user's key: %v, want ErrNotFound", err)
+	}
+	if err := s.APIKeys.Revoke(ctx, key.ID, "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.APIKeys.Revoke(ctx, key.ID, "owner"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("revoking twice: %v, want ErrNotFound", err)
+	}
+	keys, err := s.APIKeys.ListByUser(ctx, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("keys after revoking = %+v, want the revoked key listed", keys)
+	}
+	if keys, _ := s.APIKeys.ListByUser(ctx, "nobody"); keys == nil || len(keys) != 0 {
+		t.Errorf("ListByUser(nobody) = %#v, want an empty list", keys)
+	}
+}
+
+func testSharing(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	for _, id := range []string{"sharer", "viewer", "stranger"} {
+		if err := s.Users.Ensure(ctx, store.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doc := store.Document{ID: "shared-doc", UserID: "sharer", FileName: "plan.txt", StoragePath: "sharer/plan.txt", ContentType: "text/plain", SizeBytes: 9}
+	if err := s.Documents.Create(ctx, doc, []store.Chunk{{Content: "The plan."}}); err != nil {
+		t.Fatal(err)
+	}
+
+	grants := []store.Share{
+		{ID: "share-1", DocumentID: doc.ID, GranteeUserID: "viewer", Role: store.RoleViewer, CreatedBy: "sharer"},
+		{ID: "share-2", DocumentID: doc.ID, GranteeEmail: "Viewer@Example.com", Role: store.RoleEditor, CreatedBy: "sharer"},
+		// Granting the same user again changes the role instead of adding a share.
+		{ID: "share-3", DocumentID: doc.ID, GranteeUserID: "viewer", Role: store.RoleViewer, CreatedBy: "sharer"},
+	}
+	for _, sh := range grants {
+		if err := s.Shares.Grant(ctx, sh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shares, err := s.Shares.ListByDocument(ctx, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 2 || shares[0].ID != "share-1" || shares[1].GranteeEmail != "viewer@example.com" || shares[1].CreatedAt.IsZero() {
+		t.Errorf("shares = %+v, want one by ID and one lower-cased email", shares)
+	}
+
+	// The stronger of the ID and email grants wins.
+	for userID, want := range map[string]store.Role{"sharer": store.RoleOwner, "viewer": store.RoleEditor, "stranger": store.RoleNone} {
+		if role, err := s.Shares.RoleFor(ctx, doc.ID, userID, userID+"@example.com"); err != nil || role != want {
+			t.Errorf("RoleFor(%s) = %q, %v; want %q", userID, role, err, want)
+		}
+	}
+	if role, err := s.Shares.RoleFor(ctx, doc.ID, "viewer", ""); err != nil || role != store.RoleViewer {
+		t.Errorf("RoleFor without an email = %q, %v; want viewer", role, err)
+	}
+	if _, err := s.Shares.RoleFor(ctx, "missing", "viewer", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RoleFor a missing document: %v, want ErrNotFound", err)
+	}
+
+	shared, err := s.Shares.ListSharedWith(ctx, "viewer", "viewer@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared) != 1 || shared[0].ID != doc.ID || shared[0].Role != store.RoleEditor {
+		t.Fatalf("shared with viewer = %+v", shared)
+	}
+	if d := shared[0]; d.ChunkCount != 1 || d.ContentType != "text/plain" || d.SizeBytes != 9 || d.Status != store.DocumentReady || d.IngestedAt == nil {
+		t.Errorf("shared document stats = %+v", d)
+	}
+	if shared, _ := s.Shares.ListSharedWith(ctx, "sharer", "sharer@example.com"); len(shared) != 0 {
+		t.Errorf("the owner's own document is listed as shared: %+v", shared)
+	}
+
+	if err := s.Shares.Revoke(ctx, doc.ID, "share-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shares.Revoke(ctx, doc.ID, "share-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Revoke: %v, want ErrNotFound", err)
+	}
+	if err := s.Shares.Revoke(ctx, "other-doc", "share-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Revoke through another document: %v, want ErrNotFound", err)
+	}
+	if role, _ := s.Shares.RoleFor(ctx, doc.ID, "viewer", "viewer@example.com"); role != store.RoleViewer {
+		t.Errorf("role after revoking the email grant = %q, want viewer", role)
+	}
+}
+
+func testOrgs(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	for _, id := range []string{"owner", "member", "invitee", "outsider"} {
+		if err := s.Users.Ensure(ctx, store.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Orgs.Create(ctx, store.Organization{ID: "org-1", Name: "Acme"}, "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Orgs.AddMember(ctx, "org-1", "member", store.OrgRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	// Adding an existing member changes their role.
+	if err := s.Orgs.AddMember(ctx, "org-1", "member", store.OrgRoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	for userID, want := range map[string]store.OrgRole{"owner": store.OrgRoleOwner, "member": store.OrgRoleMember, "outsider": store.OrgRoleNone} {
+		if role, err := s.Orgs.MemberRole(ctx, "org-1", userID); err != nil || role != want {
+			t.Errorf("MemberRole(%s) = %q, %v; want %q", userID, role, err, want)
+		}
+	}
+	if _, err := s.Orgs.MemberRole(ctx, "missing", "owner"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("MemberRole of a missing organization: %v, want ErrNotFound", err)
+	}
+	members, err := s.Orgs.ListMembers(ctx, "org-1")
+	if err != nil || len(members) != 2 {
+		t.Fatalf("ListMembers = %+v, %v; want 2 members", members, err)
+	}
+	for _, m := range members {
+		if m.Email != m.UserID+"@example.com" || m.JoinedAt.IsZero() {
+			t.Errorf("member = %+v", m)
+		}
+	}
+	if n, err := s.Orgs.CountOwners(ctx, "org-1"); err != nil || n != 1 {
+		t.Errorf("CountOwners = %d, %v; want 1", n, err)
+	}
+	if orgs, err := s.Orgs.ListForUser(ctx, "member"); err != nil || len(orgs) != 1 || orgs[0].Name != "Acme" || orgs[0].Role != store.OrgRoleMember {
+		t.Errorf("ListForUser(member) = %+v, %v", orgs, err)
+	}
+
+	// Invites are claimed once, by email, whatever its case.
+	if err := s.Orgs.Invite(ctx, store.Invite{ID: "inv-1", OrgID: "org-1", Email: "Invitee@Example.com", Role: store.OrgRoleMember, InvitedBy: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Orgs.Invite(ctx, store.Invite{ID: "inv-2", OrgID: "org-1", Email: "invitee@example.com", Role: store.OrgRoleAdmin, InvitedBy: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if invites, err := s.Orgs.ListInvites(ctx, "org-1"); err != nil || len(invites) != 1 || invites[0].Role != store.OrgRoleAdmin {
+		t.Errorf("ListInvites = %+v, %v; want one admin invite", invites, err)
+	}
+	if n, err := s.Orgs.ClaimInvites(ctx, "invitee", "INVITEE@example.com"); err != nil || n != 1 {
+		t.Errorf("ClaimInvites = %d, %v; want 1", n, err)
+	}
+	if n, _ := s.Orgs.ClaimInvites(ctx, "invitee", "invitee@example.com"); n != 0 {
+		t.Errorf("claimed %d invites twice", n)
+	}
+	if role, _ := s.Orgs.MemberRole(ctx, "org-1", "invitee"); role != store.OrgRoleAdmin {
+		t.Errorf("invitee role = %q, want admin", role)
+	}
+	if err := s.Orgs.Invite(ctx, store.Invite{ID: "inv-3", OrgID: "org-1", Email: "later@example.com", Role: store.OrgRoleMember, InvitedBy: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Orgs.RevokeInvite(ctx, "org-1", "Later@example.com"); err != nil {
+		t.Errorf("RevokeInvite: %v", err)
+	}
+	if err := s.Orgs.RevokeInvite(ctx, "org-1", "later@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second RevokeInvite: %v, want ErrNotFound", err)
+	}
+
+	// Workspace documents follow membership rather than the uploader.
+	if err := s.Orgs.CreateCollection(ctx, store.Collection{ID: "col-1", OrgID: "org-1", Name: "Board", CreatedBy: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := s.Orgs.GetCollection(ctx, "col-1"); err != nil || c.OrgID != "org-1" || c.Name != "Board" {
+		t.Errorf("GetCollection = %+v, %v", c, err)
+	}
+	if _, err := s.Orgs.GetCollection(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetCollection of a missing collection: %v, want ErrNotFound", err)
+	}
+	docs := []store.Document{
+		{ID: "org-doc", UserID: "member", OrgID: "org-1", CollectionID: "col-1", FileName: "okr.txt", StoragePath: "member/okr.txt"},
+		{ID: "loose-doc", UserID: "owner", OrgID: "org-1", FileName: "misc.txt", StoragePath: "owner/misc.txt"},
+		{ID: "own-doc", UserID: "member", FileName: "mine.txt", StoragePath: "member/mine.txt"},
+	}
+	for _, d := range docs {
+		if err := s.Documents.Create(ctx, d, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := s.Documents.List(ctx, store.DocumentQuery{OrgID: "org-1", Sort: store.SortByName}); err != nil || got.Total != 2 {
+		t.Errorf("List(org-1) = %+v, %v; want both workspace documents", got, err)
+	}
+	if got, _ := s.Documents.List(ctx, store.DocumentQuery{OrgID: "org-1", CollectionID: "col-1", Sort: store.SortByName}); len(got.Documents) != 1 || got.Documents[0].ID != "org-doc" || got.Documents[0].CollectionID != "col-1" {
+		t.Errorf("List(col-1) = %+v", got)
+	}
+	if got, _ := s.Documents.List(ctx, store.DocumentQuery{UserID: "member", Sort: store.SortByName}); len(got.Documents) != 1 || got.Documents[0].ID != "own-doc" {
+		t.Errorf("List(member) = %+v, want only the personal document", got)
+	}
+	for userID, want := range map[string]store.Role{"member": store.RoleOwner, "invitee": store.RoleOwner, "owner": store.RoleOwner, "outsider": store.RoleNone} {
+		if role, err := s.Shares.RoleFor(ctx, "org-doc", userID, ""); err != nil || role != want {
+			t.Errorf("RoleFor(org-doc, %s) = %q, %v; want %q", userID, role, err, want)
+		}
+	}
+	if role, _ := s.Shares.RoleFor(ctx, "loose-doc", "member", ""); role != store.RoleEditor {
+		t.Errorf("member's role on another member's workspace document = %q, want editor", role)
+	}
+	if err := s.Orgs.RemoveMember(ctx, "org-1", "member"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Orgs.RemoveMember(ctx, "org-1", "member"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second RemoveMember: %v, want ErrNotFound", err)
+	}
+	if role, _ := s.Shares.RoleFor(ctx, "org-doc", "member", ""); role != store.RoleNone {
+		t.Errorf("uploader's role after leaving = %q, want none", role)
+	}
+}
+
+func testQuotas(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	if n, err := s.Quotas.Used(ctx, "alice", "uploads", "2026-10"); err != nil || n != 0 {
+		t.Errorf("Used before any usage = %d, %v; want 0", n, err)
+	}
+	for _, amount := range []int64{3, 4} {
+		if err := s.Quotas.Add(ctx, "alice", "uploads", []string{"2026-10-19", "2026-10"}, amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Quotas.Add(ctx, "alice", "uploads", []string{"2026-10-20", "2026-10"}, 5); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		userID, metric, period string
+		want                   int64
+	}{
+		{"alice", "uploads", "2026-10-19", 7},
+		{"alice", "uploads", "2026-10-20", 5},
+		{"alice", "uploads", "2026-10", 12},
+		{"alice", "llm_tokens", "2026-10", 0},
+		{"bob", "uploads", "2026-10", 0},
+	} {
+		if n, err := s.Quotas.Used(ctx, tt.userID, tt.metric, tt.period); err != nil || n != tt.want {
+			t.Errorf("Used(%s, %s, %s) = %d, %v; want %d", tt.userID, tt.metric, tt.period, n, err, tt.want)
+		}
+	}
+}
+
+func testUsage(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
+	records := []store.LLMUsage{
+		{UserID: "alice", DocumentID: "doc-1", ConversationID: "conv-1", Operation: store.OperationGenerate, Model: "gemini-1.5-flash", PromptTokens: 100, ResponseTokens: 10, CostUSD: 0.5, CreatedAt: day(1)},
+		{UserID: "alice", DocumentID: "doc-1", ConversationID: "conv-1", Operation: store.OperationGenerate, Model: "gemini-1.5-flash", PromptTokens: 200, ResponseTokens: 20, CostUSD: 1, CreatedAt: day(2)},
+		{UserID: "alice", Operation: store.OperationEmbed, Model: "text-embedding-004", PromptTokens: 50, CreatedAt: day(2)},
+		{UserID: "bob", DocumentID: "doc-2", Operation: store.OperationGenerate, Model: "gemini-1.5-pro", PromptTokens: 1000, ResponseTokens: 100, CostUSD: 2, CreatedAt: day(3)},
+	}
+	for _, u := range records {
+		if err := s.Usage.Record(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filter  store.UsageFilter
+		groupBy string
+		want    []store.UsageSummary
+	}{
+		{"alice by day", store.UsageFilter{UserID: "alice"}, store.GroupByDay, []store.UsageSummary{
+			{Key: "2026-10-01", Calls: 1, PromptTokens: 100, ResponseTokens: 10, CostUSD: 0.5},
+			{Key: "2026-10-02", Calls: 2, PromptTokens: 250, ResponseTokens: 20, CostUSD: 1},
+		}},
+		{"alice by model", store.UsageFilter{UserID: "alice"}, store.GroupByModel, []store.UsageSummary{
+			{Key: "gemini-1.5-flash", Calls: 2, PromptTokens: 300, ResponseTokens: 30, CostUSD: 1.5},
+			{Key: "text-embedding-004", Calls: 1, PromptTokens: 50},
+		}},
+		// Calls without a conversation are grouped under an empty key.
+		{"alice by conversation", store.UsageFilter{UserID: "alice"}, store.GroupByConversation, []store.UsageSummary{
+			{Key: "", Calls: 1, PromptTokens: 50},
+			{Key: "conv-1", Calls: 2, PromptTokens: 300, ResponseTokens: 30, CostUSD: 1.5},
+		}},
+		{"everyone by user in range", store.UsageFilter{From: "2026-10-02", To: "2026-10-03"}, store.GroupByUser, []store.UsageSummary{
+			{Key: "alice", Calls: 2, PromptTokens: 250, ResponseTokens: 20, CostUSD: 1},
+			{Key: "bob", Calls: 1, PromptTokens: 1000, ResponseTokens: 100, CostUSD: 2},
+		}},
+		{"nothing in range", store.UsageFilter{From: "2026-11-01"}, store.GroupByDocument, []store.UsageSummary{}},
+	}
+	for _, tt := range tests {
+		got, err := s.Usage.Summarize(ctx, tt.filter, tt.groupBy)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := s.Usage.Summarize(ctx, store.UsageFilter{}, "prompt_tokens; DROP TABLE llm_usage"); err == nil {
+		t.Error("Summarize accepted an unknown grouping")
+	}
+}
+
+func testAudit(t *testing.T, db *database.DB) {
+	ctx := context.Background()
+	s := store.New(db)
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []store.AuditEvent{
+		{ID: "e1", UserID: "alice", Action: "document.upload", DocumentID: "d1", IP: "10.0.0.1", UserAgent: "curl", Details: `{"fileName":"q3.pdf"}`, CreatedAt: base},
+		{ID: "e2", UserID: "alice", Action: "document.download", DocumentID: "d1", CreatedAt: base.Add(time.Hour)},
+		{ID: "e3", Action: "auth.login_failed", IP: "10.0.0.2", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "e4", UserID: "bob", Action: "document.download", DocumentID: "d2", CreatedAt: base.Add(24 * time.Hour)},
+	}
+	for _, e := range events {
+		if err := s.Audit.Append(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Appending an ID again, e.g. on replay, keeps the original entry.
+	if err := s.Audit.Append(ctx, store.AuditEvent{ID: "e1", Action: "document.delete", CreatedAt: base}); err != nil {
+		t.Fatalf("re-append: %v", err)
+	}
+
+	all, err := s.Audit.List(ctx, store.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || all[0].ID != "e4" || all[3].ID != "e1" {
+		t.Fatalf("List = %+v, want all four events newest first", all)
+	}
+	if e := all[3]; e.Action != "document.upload" || e.UserID != "alice" || e.IP != "10.0.0.1" || e.UserAgent != "curl" ||
+		e.Details == "" || !e.CreatedAt.Equal(base) {
+		t.Errorf("first event = %+v", e)
+	}
+
+	ids := func(events []store.AuditEvent) []string {
+		var ids []string
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	for _, tt := range []struct {
+		name   string
+		filter store.AuditFilter
+		want   []string
+	}{
+		{"by user", store.AuditFilter{UserID: "alice"}, []string{"e2", "e1"}},
+		{"by document", store.AuditFilter{DocumentID: "d2"}, []string{"e4"}},
+		{"by action", store.AuditFilter{Action: "document.download"}, []string{"e4", "e2"}},
+		{"time range", store.AuditFilter{From: base.Add(time.Hour), To: base.Add(24 * time.Hour)}, []string{"e3", "e2"}},
+		{"limit", store.AuditFilter{Limit: 2}, []string{"e4", "e3"}},
+		{"no match", store.AuditFilter{UserID: "carol"}, nil},
+	} {
+		got, err := s.Audit.List(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !slices.Equal(ids(got), tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+
+	// The log is append-only.
+	if _, err := db.Exec("UPDATE audit_log SET action = 'x' WHERE id = 'e1'"); err == nil {
+		t.Error("update of an audit entry succeeded")
+	}
+	if _, err := db.Exec("DELETE FROM audit_log WHERE id = 'e1'"); err == nil {
+		t.Error("delete of an audit entry succeeded")
+	}
+}
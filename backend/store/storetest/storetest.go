@@ -0,0 +1,832 @@
+// Package storetest provides in-memory implementations of the store
+// interfaces, for testing handlers and the server without a database. They
+// follow the documented behaviour of the SQL stores, including ErrNotFound
+// and ordering, but make no attempt at their performance.
+package storetest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/store"
+)
+
+// New returns a fresh set of in-memory stores sharing one dataset, so that
+// e.g. deleting a document also removes its shares.
+func New() *store.Stores {
+	d := &data{
+		users:       make(map[string]store.User),
+		documents:   make(map[string]store.Document),
+		chunks:      make(map[string][]store.Chunk),
+		tags:        make(map[string][]string),
+		apiKeys:     make(map[string]store.APIKey),
+		orgs:        make(map[string]store.Organization),
+		members:     make(map[string]map[string]store.Member),
+		collections: make(map[string]store.Collection),
+		quotas:      make(map[string]int64),
+		audit:       make(map[string]store.AuditEvent),
+	}
+	return &store.Stores{
+		Users:     &users{d},
+		Documents: &documents{d},
+		Chunks:    &chunks{d},
+		Chats:     &chats{d},
+		APIKeys:   &apiKeys{d},
+		Shares:    &shares{d},
+		Orgs:      &orgs{d},
+		Quotas:    &quotas{d},
+		Usage:     &usage{d},
+		Audit:     &audit{d},
+	}
+}
+
+// Messages returns the chat history saved through s.Chats, oldest first. s
+// must have been returned by New.
+func Messages(s *store.Stores) []store.ChatMessage {
+	c := s.Chats.(*chats)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.messages)
+}
+
+// Usage returns the model calls recorded through s.Usage, oldest first. s
+// must have been returned by New.
+func Usage(s *store.Stores) []store.LLMUsage {
+	u := s.Usage.(*usage)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.Clone(u.usage)
+}
+
+// data is the dataset behind one New. A single lock keeps every store
+// consistent with the others.
+type data struct {
+	mu sync.Mutex
+
+	users       map[string]store.User
+	documents   map[string]store.Document
+	chunks      map[string][]store.Chunk
+	tags        map[string][]string
+	messages    []store.ChatMessage
+	apiKeys     map[string]store.APIKey
+	shares      []store.Share
+	orgs        map[string]store.Organization
+	members     map[string]map[string]store.Member // by org, then user
+	invites     []store.Invite
+	collections map[string]store.Collection
+	quotas      map[string]int64
+	usage       []store.LLMUsage
+	audit       map[string]store.AuditEvent
+}
+
+// now returns the current time in UTC, as the SQL stores save it.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+type users struct{ *data }
+
+func (s *users) Ensure(ctx context.Context, u store.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.ID]; ok {
+		return nil
+	}
+	for _, other := range s.users {
+		if u.Email != "" && other.Email == u.Email {
+			return fmt.Errorf("could not insert user: duplicate email %s", u.Email)
+		}
+	}
+	u.CreatedAt = now()
+	s.users[u.ID] = u
+	return nil
+}
+
+func (s *users) Get(ctx context.Context, id string) (*store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (s *users) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if email != "" && strings.EqualFold(u.Email, email) {
+			return &u, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+type documents struct{ *data }
+
+// copyChunks numbers chunks for documentID the way the SQL stores save them.
+func copyChunks(documentID string, chunks []store.Chunk) []store.Chunk {
+	saved := make([]store.Chunk, len(chunks))
+	for i, c := range chunks {
+		saved[i] = store.Chunk{
+			ID:         uuid.New().String(),
+			DocumentID: documentID,
+			Index:      i,
+			Page:       c.Page,
+			Content:    c.Content,
+			Embedded:   c.Embedding != nil,
+		}
+	}
+	return saved
+}
+
+func (s *documents) Create(ctx context.Context, doc store.Document, chunks []store.Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.documents[doc.ID]; ok {
+		return fmt.Errorf("could not insert document: duplicate id %s", doc.ID)
+	}
+	if doc.Status == "" {
+		doc.Status = store.DocumentReady
+	}
+	doc.UploadedAt = now()
+	doc.IngestedAt = nil
+	if doc.Status == store.DocumentReady {
+		t := now()
+		doc.IngestedAt = &t
+	}
+	doc.ChunkCount = len(chunks)
+	doc.Summary = ""
+	doc.Warnings = slices.Clone(doc.Warnings)
+	s.documents[doc.ID] = doc
+	s.chunks[doc.ID] = copyChunks(doc.ID, chunks)
+	return nil
+}
+
+func (s *documents) Get(ctx context.Context, id string) (*store.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.documents[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &d, nil
+}
+
+// compareDocuments orders a and b by the given sort, ties broken by ID.
+func compareDocuments(sort string, a, b store.Document) int {
+	var c int
+	switch sort {
+	case store.SortByName:
+		c = cmp.Compare(strings.ToLower(a.FileName), strings.ToLower(b.FileName))
+	case store.SortByUploadedAt:
+		c = a.UploadedAt.Compare(b.UploadedAt)
+	case store.SortBySize:
+		c = cmp.Compare(a.SizeBytes, b.SizeBytes)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func (s *documents) List(ctx context.Context, q store.DocumentQuery) (*store.DocumentPage, error) {
+	switch q.Sort {
+	case store.SortByName, store.SortByUploadedAt, store.SortBySize:
+	default:
+		return nil, fmt.Errorf("cannot sort documents by %q", q.Sort)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matches []store.Document
+	for _, d := range s.documents {
+		if q.OrgID != "" {
+			if d.OrgID != q.OrgID || (q.CollectionID != "" && d.CollectionID != q.CollectionID) {
+				continue
+			}
+		} else if d.UserID != q.UserID || d.OrgID != "" {
+			continue
+		}
+		if (q.ContentType != "" && d.ContentType != q.ContentType) ||
+			(q.Tag != "" && !slices.Contains(s.tags[d.ID], q.Tag)) ||
+			(q.Status != "" && d.Status != q.Status) ||
+			(!q.From.IsZero() && d.UploadedAt.Before(q.From)) ||
+			(!q.To.IsZero() && !d.UploadedAt.Before(q.To)) {
+			continue
+		}
+		matches = append(matches, d)
+	}
+	order := func(a, b store.Document) int {
+		if q.Desc {
+			a, b = b, a
+		}
+		return compareDocuments(q.Sort, a, b)
+	}
+	slices.SortFunc(matches, order)
+
+	page := &store.DocumentPage{Documents: make([]store.Document, 0), Total: len(matches)}
+	if q.After != "" {
+		after, ok := s.documents[q.After]
+		if !ok {
+			return nil, store.ErrNotFound
+		}
+		matches = slices.DeleteFunc(matches, func(d store.Document) bool { return order(d, after) <= 0 })
+	}
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+	page.Documents = append(page.Documents, matches...)
+	return page, nil
+}
+
+func (s *documents) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.documents, id)
+	delete(s.chunks, id)
+	delete(s.tags, id)
+	s.shares = slices.DeleteFunc(s.shares, func(sh store.Share) bool { return sh.DocumentID == id })
+	s.messages = slices.DeleteFunc(s.messages, func(m store.ChatMessage) bool { return m.DocumentID == id })
+	return nil
+}
+
+func (s *documents) update(id string, f func(d *store.Document)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.documents[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	f(&d)
+	s.documents[id] = d
+	return nil
+}
+
+func (s *documents) Rename(ctx context.Context, id, fileName string) error {
+	return s.update(id, func(d *store.Document) { d.FileName = fileName })
+}
+
+func (s *documents) Tags(ctx context.Context, id string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags := slices.Clone(s.tags[id])
+	slices.Sort(tags)
+	if tags == nil {
+		tags = make([]string, 0)
+	}
+	return tags, nil
+}
+
+func (s *documents) SetTags(ctx context.Context, id string, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	s.tags[id] = slices.Compact(tags)
+	return nil
+}
+
+func (s *documents) SetSummary(ctx context.Context, id, summary string) error {
+	return s.update(id, func(d *store.Document) { d.Summary = summary })
+}
+
+func (s *documents) ReplaceChunks(ctx context.Context, doc store.Document, chunks []store.Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.documents[doc.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	t := now()
+	d.ContentType = doc.ContentType
+	d.SizeBytes = doc.SizeBytes
+	d.PageCount = doc.PageCount
+	d.ChunkCount = len(chunks)
+	d.Status = store.DocumentReady
+	d.Warnings = slices.Clone(doc.Warnings)
+	d.Summary = ""
+	d.IngestedAt = &t
+	d.PipelineVersion = doc.PipelineVersion
+	s.documents[doc.ID] = d
+	s.chunks[doc.ID] = copyChunks(doc.ID, chunks)
+	return nil
+}
+
+func (s *documents) SetFailed(ctx context.Context, id, reason string) error {
+	return s.update(id, func(d *store.Document) {
+		d.Status = store.DocumentFailed
+		d.Warnings = []string{reason}
+	})
+}
+
+func (s *documents) ListStale(ctx context.Context, version, limit int) ([]store.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var docs []store.Document
+	for _, d := range s.documents {
+		if d.PipelineVersion < version {
+			docs = append(docs, d)
+		}
+	}
+	slices.SortFunc(docs, func(a, b store.Document) int { return compareDocuments(store.SortByUploadedAt, a, b) })
+	if limit > 0 && len(docs) > limit {
+		docs = docs[:limit]
+	}
+	return docs, nil
+}
+
+type chunks struct{ *data }
+
+func (s *chunks) ListByDocument(ctx context.Context, documentID string) ([]store.Chunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.chunks[documentID]), nil
+}
+
+func (s *chunks) ListAfter(ctx context.Context, documentID string, after, limit int) ([]store.Chunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var page []store.Chunk
+	for _, c := range s.chunks[documentID] {
+		if c.Index > after && len(page) < limit {
+			page = append(page, c)
+		}
+	}
+	return page, nil
+}
+
+type chats struct{ *data }
+
+func (s *chats) SaveExchange(ctx context.Context, documentID, userID, conversationID, query, answer string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at := now()
+	s.messages = append(s.messages,
+		store.ChatMessage{ID: uuid.New().String(), DocumentID: documentID, UserID: userID, Type: store.MessageTypeUser, Content: query, Timestamp: at},
+		store.ChatMessage{ID: uuid.New().String(), DocumentID: documentID, UserID: userID, Type: store.MessageTypeAI, Content: answer, Timestamp: at},
+	)
+	return nil
+}
+
+type apiKeys struct{ *data }
+
+func (s *apiKeys) Create(ctx context.Context, key store.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key.CreatedAt = now()
+	key.Scopes = slices.Clone(key.Scopes)
+	s.apiKeys[key.ID] = key
+	return nil
+}
+
+func (s *apiKeys) ListByUser(ctx context.Context, userID string) ([]store.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]store.APIKey, 0)
+	for _, k := range s.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b store.APIKey) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return keys, nil
+}
+
+func (s *apiKeys) GetByHash(ctx context.Context, hash string) (*store.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.apiKeys {
+		if k.Hash == hash {
+			return &k, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *apiKeys) Revoke(ctx context.Context, id, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.apiKeys[id]
+	if !ok || k.UserID != userID || k.RevokedAt != nil {
+		return store.ErrNotFound
+	}
+	t := now()
+	k.RevokedAt = &t
+	s.apiKeys[id] = k
+	return nil
+}
+
+func (s *apiKeys) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.apiKeys[id]; ok {
+		usedAt = usedAt.UTC()
+		k.LastUsedAt = &usedAt
+		s.apiKeys[id] = k
+	}
+	return nil
+}
+
+type shares struct{ *data }
+
+func (s *shares) Grant(ctx context.Context, share store.Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	share.GranteeEmail = strings.ToLower(share.GranteeEmail)
+	for i, sh := range s.shares {
+		if sh.DocumentID != share.DocumentID {
+			continue
+		}
+		if (share.GranteeUserID != "" && sh.GranteeUserID == share.GranteeUserID) ||
+			(share.GranteeUserID == "" && sh.GranteeEmail == share.GranteeEmail) {
+			s.shares[i].Role = share.Role
+			return nil
+		}
+	}
+	share.CreatedAt = now()
+	s.shares = append(s.shares, share)
+	return nil
+}
+
+func (s *shares) ListByDocument(ctx context.Context, documentID string) ([]store.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]store.Share, 0)
+	for _, sh := range s.shares {
+		if sh.DocumentID == documentID {
+			list = append(list, sh)
+		}
+	}
+	return list, nil
+}
+
+func (s *shares) Revoke(ctx context.Context, documentID, shareID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.shares)
+	s.shares = slices.DeleteFunc(s.shares, func(sh store.Share) bool { return sh.ID == shareID && sh.DocumentID == documentID })
+	if len(s.shares) == n {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// grants reports whether sh was made out to the user by ID or email.
+func grants(sh store.Share, userID, email string) bool {
+	return (sh.GranteeUserID != "" && sh.GranteeUserID == userID) ||
+		(sh.GranteeEmail != "" && sh.GranteeEmail == strings.ToLower(email))
+}
+
+func (s *shares) ListSharedWith(ctx context.Context, userID, email string) ([]store.SharedDocument, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byID := make(map[string]int)
+	docs := make([]store.SharedDocument, 0)
+	for _, sh := range s.shares {
+		d, ok := s.documents[sh.DocumentID]
+		if !ok || d.UserID == userID || !grants(sh, userID, email) {
+			continue
+		}
+		if i, ok := byID[d.ID]; ok {
+			if sh.Role.Allows(docs[i].Role) {
+				docs[i].Role = sh.Role
+			}
+			continue
+		}
+		byID[d.ID] = len(docs)
+		docs = append(docs, store.SharedDocument{Document: d, Role: sh.Role})
+	}
+	slices.SortFunc(docs, func(a, b store.SharedDocument) int { return b.UploadedAt.Compare(a.UploadedAt) })
+	return docs, nil
+}
+
+func (s *shares) RoleFor(ctx context.Context, documentID, userID, email string) (store.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.documents[documentID]
+	if !ok {
+		return store.RoleNone, store.ErrNotFound
+	}
+
+	best := store.RoleNone
+	if d.OrgID != "" {
+		if m, ok := s.members[d.OrgID][userID]; ok {
+			best = m.Role.DocumentRole()
+			if d.UserID == userID {
+				best = store.RoleOwner
+			}
+		}
+	} else if d.UserID == userID {
+		return store.RoleOwner, nil
+	}
+	for _, sh := range s.shares {
+		if sh.DocumentID == documentID && grants(sh, userID, email) && sh.Role.Allows(best) {
+			best = sh.Role
+		}
+	}
+	return best, nil
+}
+
+type orgs struct{ *data }
+
+func (s *orgs) Create(ctx context.Context, org store.Organization, ownerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orgs[org.ID]; ok {
+		return fmt.Errorf("could not insert organization: duplicate id %s", org.ID)
+	}
+	org.CreatedBy = ownerID
+	org.CreatedAt = now()
+	s.orgs[org.ID] = org
+	s.members[org.ID] = map[string]store.Member{ownerID: {UserID: ownerID, Role: store.OrgRoleOwner, JoinedAt: now()}}
+	return nil
+}
+
+func (s *orgs) ListForUser(ctx context.Context, userID string) ([]store.Membership, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	memberships := make([]store.Membership, 0)
+	for orgID, members := range s.members {
+		if m, ok := members[userID]; ok {
+			memberships = append(memberships, store.Membership{Organization: s.orgs[orgID], Role: m.Role})
+		}
+	}
+	slices.SortFunc(memberships, func(a, b store.Membership) int { return cmp.Compare(a.Name, b.Name) })
+	return memberships, nil
+}
+
+func (s *orgs) MemberRole(ctx context.Context, orgID, userID string) (store.OrgRole, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orgs[orgID]; !ok {
+		return store.OrgRoleNone, store.ErrNotFound
+	}
+	return s.members[orgID][userID].Role, nil
+}
+
+func (s *orgs) ListMembers(ctx context.Context, orgID string) ([]store.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := make([]store.Member, 0)
+	for _, m := range s.members[orgID] {
+		// Like the SQL join, members need a user row.
+		u, ok := s.users[m.UserID]
+		if !ok {
+			continue
+		}
+		m.Email = u.Email
+		members = append(members, m)
+	}
+	slices.SortFunc(members, func(a, b store.Member) int { return a.JoinedAt.Compare(b.JoinedAt) })
+	return members, nil
+}
+
+func (s *orgs) AddMember(ctx context.Context, orgID, userID string, role store.OrgRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orgs[orgID]; !ok {
+		return fmt.Errorf("could not add member: no organization %s", orgID)
+	}
+	m, ok := s.members[orgID][userID]
+	if !ok {
+		m = store.Member{UserID: userID, JoinedAt: now()}
+	}
+	m.Role = role
+	s.members[orgID][userID] = m
+	return nil
+}
+
+func (s *orgs) RemoveMember(ctx context.Context, orgID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.members[orgID][userID]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.members[orgID], userID)
+	return nil
+}
+
+func (s *orgs) CountOwners(ctx context.Context, orgID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, m := range s.members[orgID] {
+		if m.Role == store.OrgRoleOwner {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *orgs) Invite(ctx context.Context, invite store.Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invite.Email = strings.ToLower(invite.Email)
+	for i, inv := range s.invites {
+		if inv.OrgID == invite.OrgID && inv.Email == invite.Email {
+			s.invites[i].Role, s.invites[i].InvitedBy = invite.Role, invite.InvitedBy
+			return nil
+		}
+	}
+	invite.CreatedAt = now()
+	s.invites = append(s.invites, invite)
+	return nil
+}
+
+func (s *orgs) ListInvites(ctx context.Context, orgID string) ([]store.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invites := make([]store.Invite, 0)
+	for _, inv := range s.invites {
+		if inv.OrgID == orgID {
+			invites = append(invites, inv)
+		}
+	}
+	return invites, nil
+}
+
+func (s *orgs) RevokeInvite(ctx context.Context, orgID, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.invites)
+	s.invites = slices.DeleteFunc(s.invites, func(inv store.Invite) bool {
+		return inv.OrgID == orgID && inv.Email == strings.ToLower(email)
+	})
+	if len(s.invites) == n {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *orgs) ClaimInvites(ctx context.Context, userID, email string) (int, error) {
+	if email == "" {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := 0
+	s.invites = slices.DeleteFunc(s.invites, func(inv store.Invite) bool {
+		if inv.Email != strings.ToLower(email) {
+			return false
+		}
+		// An existing membership keeps its role; the invite is simply consumed.
+		if _, ok := s.members[inv.OrgID][userID]; !ok && s.members[inv.OrgID] != nil {
+			s.members[inv.OrgID][userID] = store.Member{UserID: userID, Role: inv.Role, JoinedAt: now()}
+		}
+		claimed++
+		return true
+	})
+	return claimed, nil
+}
+
+func (s *orgs) CreateCollection(ctx context.Context, c store.Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.CreatedAt = now()
+	s.collections[c.ID] = c
+	return nil
+}
+
+func (s *orgs) GetCollection(ctx context.Context, id string) (*store.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.collections[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &c, nil
+}
+
+func (s *orgs) ListCollections(ctx context.Context, orgID string) ([]store.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collections := make([]store.Collection, 0)
+	for _, c := range s.collections {
+		if c.OrgID == orgID {
+			collections = append(collections, c)
+		}
+	}
+	slices.SortFunc(collections, func(a, b store.Collection) int { return cmp.Compare(a.Name, b.Name) })
+	return collections, nil
+}
+
+type quotas struct{ *data }
+
+func quotaKey(userID, metric, period string) string {
+	return userID + "\x00" + metric + "\x00" + period
+}
+
+func (s *quotas) Add(ctx context.Context, userID, metric string, periods []string, amount int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, period := range periods {
+		s.quotas[quotaKey(userID, metric, period)] += amount
+	}
+	return nil
+}
+
+func (s *quotas) Used(ctx context.Context, userID, metric, period string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quotas[quotaKey(userID, metric, period)], nil
+}
+
+type usage struct{ *data }
+
+func (s *usage) Record(ctx context.Context, u store.LLMUsage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u.ID == "" {
+		u.ID = uuid.New().String()
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	s.usage = append(s.usage, u)
+	return nil
+}
+
+func (s *usage) Summarize(ctx context.Context, f store.UsageFilter, groupBy string) ([]store.UsageSummary, error) {
+	key, ok := map[string]func(u store.LLMUsage) string{
+		store.GroupByDay:          func(u store.LLMUsage) string { return u.CreatedAt.UTC().Format("2006-01-02") },
+		store.GroupByModel:        func(u store.LLMUsage) string { return u.Model },
+		store.GroupByDocument:     func(u store.LLMUsage) string { return u.DocumentID },
+		store.GroupByConversation: func(u store.LLMUsage) string { return u.ConversationID },
+		store.GroupByUser:         func(u store.LLMUsage) string { return u.UserID },
+	}[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("cannot group usage by %q", groupBy)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byKey := make(map[string]*store.UsageSummary)
+	for _, u := range s.usage {
+		day := u.CreatedAt.UTC().Format("2006-01-02")
+		if (f.UserID != "" && u.UserID != f.UserID) || (f.From != "" && day < f.From) || (f.To != "" && day > f.To) {
+			continue
+		}
+		k := key(u)
+		sum, ok := byKey[k]
+		if !ok {
+			sum = &store.UsageSummary{Key: k}
+			byKey[k] = sum
+		}
+		sum.Calls++
+		sum.PromptTokens += u.PromptTokens
+		sum.ResponseTokens += u.ResponseTokens
+		sum.CostUSD += u.CostUSD
+	}
+	summaries := make([]store.UsageSummary, 0, len(byKey))
+	for _, sum := range byKey {
+		summaries = append(summaries, *sum)
+	}
+	slices.SortFunc(summaries, func(a, b store.UsageSummary) int { return cmp.Compare(a.Key, b.Key) })
+	return summaries, nil
+}
+
+type audit struct{ *data }
+
+func (s *audit) Append(ctx context.Context, e store.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.audit[e.ID]; !ok {
+		e.CreatedAt = e.CreatedAt.UTC()
+		s.audit[e.ID] = e
+	}
+	return nil
+}
+
+func (s *audit) List(ctx context.Context, f store.AuditFilter) ([]store.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]store.AuditEvent, 0)
+	for _, e := range s.audit {
+		if (f.UserID != "" && e.UserID != f.UserID) ||
+			(f.DocumentID != "" && e.DocumentID != f.DocumentID) ||
+			(f.Action != "" && e.Action != f.Action) ||
+			(!f.From.IsZero() && e.CreatedAt.Before(f.From)) ||
+			(!f.To.IsZero() && !e.CreatedAt.Before(f.To)) {
+			continue
+		}
+		events = append(events, e)
+	}
+	slices.SortFunc(events, func(a, b store.AuditEvent) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
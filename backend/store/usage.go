@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type usageStore struct {
+	db *database.DB
+}
+
+// usageGroupColumns maps the GroupBy values to llm_usage columns. Only these
+// are ever interpolated into SQL.
+var usageGroupColumns = map[string]string{
+	GroupByDay:          "day",
+	GroupByModel:        "model",
+	GroupByDocument:     "document_id",
+	GroupByConversation: "conversation_id",
+	GroupByUser:         "user_id",
+}
+
+func (s *usageStore) Record(ctx context.Context, u LLMUsage) error {
+	if u.ID == "" {
+		u.ID = uuid.New().String()
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, `
+    INSERT INTO llm_usage (id, user_id, document_id, conversation_id, operation, model, prompt_tokens, response_tokens, cost_usd, day, created_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		u.ID, u.UserID, nullable(u.DocumentID), nullable(u.ConversationID), u.Operation, u.Model,
+		u.PromptTokens, u.ResponseTokens, u.CostUSD, u.CreatedAt.UTC().Format("2006-01-02"), u.CreatedAt)
+	return err
+}
+
+func (s *usageStore) Summarize(ctx context.Context, f UsageFilter, groupBy string) ([]UsageSummary, error) {
+	column, ok := usageGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("cannot group usage by %q", groupBy)
+	}
+
+	var where []string
+	var args []any
+	if f.UserID != "" {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.From != "" {
+		where = append(where, "day >= ?")
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		where = append(where, "day <= ?")
+		args = append(args, f.To)
+	}
+	query := "SELECT " + column + ", COUNT(*), CAST(SUM(prompt_tokens) AS BIGINT), CAST(SUM(response_tokens) AS BIGINT), SUM(cost_usd) FROM llm_usage"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " GROUP BY " + column + " ORDER BY " + column
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]UsageSummary, 0)
+	for rows.Next() {
+		var key sql.NullString
+		var u UsageSummary
+		if err := rows.Scan(&key, &u.Calls, &u.PromptTokens, &u.ResponseTokens, &u.CostUSD); err != nil {
+			return nil, err
+		}
+		u.Key = key.String
+		summaries = append(summaries, u)
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/malharg/strategic-insight-analyst/backend/database"
+)
+
+type userStore struct {
+	db *database.DB
+}
+
+// Ensure stores a missing email as NULL, so any number of users can have
+// none. Only a known ID is ignored; an email taken by another user is an
+// error.
+func (s *userStore) Ensure(ctx context.Context, u User) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO users (id, email) VALUES (?, NULLIF(?, '')) ON CONFLICT (id) DO NOTHING", u.ID, u.Email)
+	return err
+}
+
+func (s *userStore) Get(ctx context.Context, id string) (*User, error) {
+	var u User
+	err := s.db.QueryRowContext(ctx, "SELECT id, COALESCE(email, ''), created_at FROM users WHERE id = ?", id).Scan(&u.ID, &u.Email, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *userStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	var u User
+	err := s.db.QueryRowContext(ctx, "SELECT id, COALESCE(email, ''), created_at FROM users WHERE LOWER(email) = LOWER(?)", email).Scan(&u.ID, &u.Email, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/malharg/strategic-insight-analyst/backend/auth"
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"github.com/malharg/strategic-insight-analyst/backend/logging"
+)
+
+// runTokenCommand implements the "token" subcommand, which mints a bearer
+// token for a test user that the server accepts when AUTH_MODE=jwt.
+func runTokenCommand(args []string) {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	uid := fs.String("uid", "dev-user", "user ID (sub claim) of the token")
+	email := fs.String("email", "", "email claim of the token (default <uid>@example.com)")
+	ttl := fs.Duration("ttl", 24*time.Hour, "how long the token stays valid")
+	fs.Parse(args)
+
+	if *email == "" {
+		*email = *uid + "@example.com"
+	}
+
+	signer, err := auth.NewSigner(config.LoadAuth())
+	if err != nil {
+		logging.Fatal("failed to create token signer", "error", err)
+	}
+	token, err := signer.Mint(*uid, *email, *ttl)
+	if err != nil {
+		logging.Fatal("failed to mint token", "error", err)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/malharg/strategic-insight-analyst/backend/config"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spans collects every span ended in this package's tests.
+var spans = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	if _, err := Setup(context.Background(), config.TracingConfig{}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSetupWithoutEndpoint(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{ServiceName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	spans.Reset()
+	mux := http.NewServeMux()
+	var inner trace.SpanContext
+	mux.HandleFunc("/api/documents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		inner = trace.SpanContextFromContext(r.Context())
+	})
+	mux.HandleFunc("PATCH /api/v1/documents/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	h := Middleware(mux, mux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest(http.MethodGet, "/api/documents/doc-1", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/api/v1/documents/doc-1", nil))
+
+	ended := spans.GetSpans()
+	if len(ended) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(ended))
+	}
+	// Spans are named after the pattern, never the raw path.
+	if ended[0].Name != "GET /api/documents/{id}" {
+		t.Errorf("span name = %q", ended[0].Name)
+	}
+	if ended[1].Name != "GET" {
+		t.Errorf("unmatched span name = %q, want the method only", ended[1].Name)
+	}
+	// A pattern that names its method is used as is.
+	if ended[2].Name != "PATCH /api/v1/documents/{id}" {
+		t.Errorf("method pattern span name = %q", ended[2].Name)
+	}
+	if got := ended[0].SpanContext.TraceID().String(); got != traceID || inner.TraceID().String() != traceID {
+		t.Errorf("trace ID = %s (handler saw %s), want the propagated %s", got, inner.TraceID(), traceID)
+	}
+}
+
+func TestTransportPropagates(t *testing.T) {
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "caller")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	span.End()
+
+	if traceparent == "" || traceparent[3:35] != span.SpanContext().TraceID().String() {
+		t.Errorf("traceparent = %q, want the caller's trace %s", traceparent, span.SpanContext().TraceID())
+	}
+}
@@ -2,6 +2,7 @@
 
 import { useEffect, useState } from "react";
 import { authenticatedFetch } from "@/lib/api";
+import type { ChatRequest, ChatResponse } from "@/lib/api-types";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { useAuth } from "@/context/AuthContext";
@@ -48,16 +49,16 @@ export default function ChatPage({ params }: { params: Promise<{ documentId: str
     setIsSending(true);
 
     try {
-      const payload = { documentId, query };
+      const payload: ChatRequest = { query };
       
         // =========================================================================
         // DEBUGGING CODE
         // Log the payload right before sending it
-      console.log("Sending payload to /api/chat:", payload);
+      console.log(`Sending payload to /api/v1/documents/${documentId}/chat:`, payload);
         // =========================================================================
-      const data = await authenticatedFetch("/api/chat", {
+      const data: ChatResponse = await authenticatedFetch(`/api/v1/documents/${documentId}/chat`, {
         method: 'POST',
-        body: JSON.stringify({ documentId, query }),
+        body: JSON.stringify(payload),
       });
       const aiMessage: Message = { type: 'ai', content: data.response };
       setMessages(prev => [...prev, aiMessage]);
@@ -20,16 +20,10 @@ import {
   AlertDialogTrigger,
 } from "@/components/ui/alert-dialog";
 import { authenticatedFetch } from "@/lib/api";
+import type { DocumentInfo, DocumentPage } from "@/lib/api-types";
 import { auth } from "@/lib/firebase";
 import Link from "next/link";
 
-// Type definition for a document
-type Document = {
-  id: string;
-  fileName: string;
-  uploadedAt: string;
-};
-
 export default function DashboardPage() {
   const { user, loading } = useAuth();
   const router = useRouter();
@@ -38,17 +32,22 @@ export default function DashboardPage() {
   const [isUploading, setIsUploading] = useState(false);
   const [message, setMessage] = useState("");
   const [error, setError] = useState("");
-  const [documents, setDocuments] = useState<Document[]>([]);
+  const [documents, setDocuments] = useState<DocumentInfo[]>([]);
 
   const fetchDocuments = useCallback(async () => {
     if (!user) return;
     try {
-      const docs = await authenticatedFetch("/api/documents");
-      if (Array.isArray(docs)) {
-        setDocuments(docs);
-      } else {
-        setDocuments([]);
-      }
+      // Follow the cursor until every page is loaded.
+      const all: DocumentInfo[] = [];
+      let cursor: string | undefined;
+      do {
+        const query = new URLSearchParams({ limit: "200" });
+        if (cursor) query.set("cursor", cursor);
+        const page: DocumentPage = await authenticatedFetch(`/api/v1/documents?${query}`);
+        all.push(...page.documents);
+        cursor = page.nextCursor;
+      } while (cursor);
+      setDocuments(all);
     } catch (err: unknown) {
       if (err instanceof Error) {
         setError("Failed to fetch documents: " + err.message);
@@ -72,7 +71,7 @@ export default function DashboardPage() {
     setError("");
 
     try {
-      await authenticatedFetch(`/api/documents/delete?id=${docId}`, {
+      await authenticatedFetch(`/api/v1/documents/${docId}`, {
         method: "DELETE",
       });
     } catch (err: unknown) {
@@ -105,7 +104,7 @@ export default function DashboardPage() {
     const formData = new FormData();
     formData.append("document", file);
     try {
-      await authenticatedFetch("/api/documents/upload", {
+      await authenticatedFetch("/api/v1/documents", {
         method: "POST",
         body: formData,
       });
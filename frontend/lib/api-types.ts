@@ -0,0 +1,510 @@
+// Code generated by `go run . openapi -ts`. DO NOT EDIT.
+
+export interface APIKeyInfo {
+  createdAt: string;
+  id: string;
+  key?: string;
+  lastUsedAt?: string | null;
+  name: string;
+  prefix: string;
+  revokedAt?: string | null;
+  scopes: string[];
+}
+
+export interface AuditEventInfo {
+  action: string;
+  createdAt: string;
+  details?: Record<string, unknown>;
+  documentId?: string;
+  id: string;
+  ip?: string;
+  userAgent?: string;
+  userId?: string;
+}
+
+export interface ChatRequest {
+  conversationId?: string;
+  documentId?: string;
+  query: string;
+}
+
+export interface ChatResponse {
+  conversationId: string;
+  response: string;
+}
+
+export interface CheckResult {
+  status: string;
+}
+
+export interface ChunkInfo {
+  content: string;
+  embedded: boolean;
+  index: number;
+  length: number;
+  page: number;
+}
+
+export interface ChunkPage {
+  chunks: ChunkInfo[];
+  nextCursor?: string;
+  total: number;
+}
+
+export interface CollectionInfo {
+  createdAt: string;
+  createdBy: string;
+  id: string;
+  name: string;
+}
+
+export interface CreateAPIKeyRequest {
+  name: string;
+  scopes?: string[];
+}
+
+export interface DocumentDetail {
+  chunkCount: number;
+  collectionId?: string;
+  contentType: string;
+  fileName: string;
+  id: string;
+  ingestedAt?: string | null;
+  orgId?: string;
+  pageCount: number;
+  pipelineVersion: number;
+  role: string;
+  sizeBytes: number;
+  status: string;
+  summary?: string;
+  tags: string[];
+  uploadedAt: string;
+  warnings: string[];
+}
+
+export interface DocumentInfo {
+  chunkCount: number;
+  collectionId?: string;
+  contentType: string;
+  fileName: string;
+  id: string;
+  orgId?: string;
+  pageCount: number;
+  sizeBytes: number;
+  status: string;
+  uploadedAt: string;
+}
+
+export interface DocumentPage {
+  documents: DocumentInfo[];
+  nextCursor?: string;
+  total: number;
+}
+
+export interface ErrorDetail {
+  code: "invalid_request" | "request_too_large" | "method_not_allowed" | "not_found" | "conflict" | "unauthenticated" | "invalid_token" | "insufficient_scope" | "interactive_login_required" | "admin_required" | "forbidden" | "document_not_found" | "organization_not_found" | "collection_not_found" | "share_not_found" | "invite_not_found" | "member_not_found" | "api_key_not_found" | "file_too_large" | "unsupported_file_type" | "extraction_failed" | "rate_limited" | "quota_exceeded" | "storage_unavailable" | "llm_unavailable" | "internal_error";
+  message: string;
+  requestId?: string;
+}
+
+export interface ErrorResponse {
+  error: ErrorDetail;
+}
+
+export interface InviteInfo {
+  createdAt: string;
+  email: string;
+  invitedBy: string;
+  role: string;
+}
+
+export interface InviteRequest {
+  email: string;
+  role?: string;
+}
+
+export interface MemberInfo {
+  email: string;
+  joinedAt: string;
+  role: string;
+  userId: string;
+}
+
+export interface NameRequest {
+  name: string;
+}
+
+export interface OrgInfo {
+  createdAt: string;
+  id: string;
+  name: string;
+  role: string;
+}
+
+export interface OrgMembersResponse {
+  invites: InviteInfo[];
+  members: MemberInfo[];
+}
+
+export interface QuotaStatus {
+  limit?: number | null;
+  metric: string;
+  period: string;
+  remaining?: number | null;
+  resetsAt: string;
+  used: number;
+}
+
+export interface ReadinessReport {
+  checkedAt: string;
+  checks: Record<string, CheckResult>;
+  status: string;
+}
+
+export interface ReingestResult {
+  chunks: number;
+  embedded: boolean;
+  id: string;
+  pipelineVersion: number;
+  previousChunks: number;
+  warnings?: string[];
+}
+
+export interface RetrievalPreview {
+  chunks: RetrievedChunk[];
+  contextChunks: number;
+  query: string;
+  selected: number;
+}
+
+export interface RetrievalRequest {
+  query: string;
+}
+
+export interface RetrievedChunk {
+  index: number;
+  length: number;
+  page: number;
+  preview: string;
+  rank: number;
+  score: number;
+  selected: boolean;
+}
+
+export interface ShareInfo {
+  createdAt: string;
+  createdBy: string;
+  email?: string;
+  id: string;
+  role: string;
+  userId?: string;
+}
+
+export interface ShareRequest {
+  email?: string;
+  role: string;
+  userId?: string;
+}
+
+export interface SharedDocumentInfo {
+  chunkCount: number;
+  collectionId?: string;
+  contentType: string;
+  fileName: string;
+  id: string;
+  orgId?: string;
+  ownerId: string;
+  pageCount: number;
+  role: string;
+  sizeBytes: number;
+  status: string;
+  uploadedAt: string;
+}
+
+export interface TagsRequest {
+  tags: string[];
+}
+
+export interface UpdateDocumentRequest {
+  fileName?: string | null;
+  tags?: string[] | null;
+}
+
+export interface UploadResult {
+  chunks: number;
+  fileName: string;
+  id: string;
+  warnings?: string[];
+}
+
+export interface UsageReport {
+  from: string;
+  groupBy: string;
+  rows: UsageRow[];
+  to: string;
+  total: UsageRow;
+}
+
+export interface UsageRow {
+  calls: number;
+  costUsd: number;
+  key: string;
+  promptTokens: number;
+  responseTokens: number;
+  totalTokens: number;
+}
+
+export interface Operations {
+  postApiChat: {
+    path: "/api/chat";
+    method: "POST";
+    request: ChatRequest;
+    response: ChatResponse;
+  };
+  getApiDocuments: {
+    path: "/api/documents";
+    method: "GET";
+    request: never;
+    response: DocumentInfo[];
+  };
+  deleteApiDocumentsDelete: {
+    path: "/api/documents/delete";
+    method: "DELETE";
+    request: never;
+    response: null;
+  };
+  postApiDocumentsUpload: {
+    path: "/api/documents/upload";
+    method: "POST";
+    request: FormData;
+    response: UploadResult;
+  };
+  getApiHealth: {
+    path: "/api/health";
+    method: "GET";
+    request: never;
+    response: Record<string, boolean>;
+  };
+  getApiHealthLive: {
+    path: "/api/health/live";
+    method: "GET";
+    request: never;
+    response: Record<string, string>;
+  };
+  getApiHealthReady: {
+    path: "/api/health/ready";
+    method: "GET";
+    request: never;
+    response: ReadinessReport;
+  };
+  getApiOpenapiJson: {
+    path: "/api/openapi.json";
+    method: "GET";
+    request: never;
+    response: Record<string, unknown>;
+  };
+  getApiSecurePing: {
+    path: "/api/secure-ping";
+    method: "GET";
+    request: never;
+    response: Record<string, string>;
+  };
+  getApiV1AdminAudit: {
+    path: "/api/v1/admin/audit";
+    method: "GET";
+    request: never;
+    response: AuditEventInfo[];
+  };
+  getApiV1AdminAuditExport: {
+    path: "/api/v1/admin/audit/export";
+    method: "GET";
+    request: never;
+    response: string;
+  };
+  getApiV1AdminUsage: {
+    path: "/api/v1/admin/usage";
+    method: "GET";
+    request: never;
+    response: UsageReport;
+  };
+  getApiV1Documents: {
+    path: "/api/v1/documents";
+    method: "GET";
+    request: never;
+    response: DocumentPage;
+  };
+  postApiV1Documents: {
+    path: "/api/v1/documents";
+    method: "POST";
+    request: FormData;
+    response: UploadResult;
+  };
+  getApiV1DocumentsShared: {
+    path: "/api/v1/documents/shared";
+    method: "GET";
+    request: never;
+    response: SharedDocumentInfo[];
+  };
+  deleteApiV1DocumentsId: {
+    path: "/api/v1/documents/{id}";
+    method: "DELETE";
+    request: never;
+    response: null;
+  };
+  getApiV1DocumentsId: {
+    path: "/api/v1/documents/{id}";
+    method: "GET";
+    request: never;
+    response: DocumentDetail;
+  };
+  patchApiV1DocumentsId: {
+    path: "/api/v1/documents/{id}";
+    method: "PATCH";
+    request: UpdateDocumentRequest;
+    response: DocumentDetail;
+  };
+  postApiV1DocumentsIdChat: {
+    path: "/api/v1/documents/{id}/chat";
+    method: "POST";
+    request: ChatRequest;
+    response: ChatResponse;
+  };
+  getApiV1DocumentsIdChunks: {
+    path: "/api/v1/documents/{id}/chunks";
+    method: "GET";
+    request: never;
+    response: ChunkPage;
+  };
+  getApiV1DocumentsIdDownload: {
+    path: "/api/v1/documents/{id}/download";
+    method: "GET";
+    request: never;
+    response: string;
+  };
+  postApiV1DocumentsIdReingest: {
+    path: "/api/v1/documents/{id}/reingest";
+    method: "POST";
+    request: never;
+    response: ReingestResult;
+  };
+  postApiV1DocumentsIdRetrieval: {
+    path: "/api/v1/documents/{id}/retrieval";
+    method: "POST";
+    request: RetrievalRequest;
+    response: RetrievalPreview;
+  };
+  getApiV1DocumentsIdShares: {
+    path: "/api/v1/documents/{id}/shares";
+    method: "GET";
+    request: never;
+    response: ShareInfo[];
+  };
+  postApiV1DocumentsIdShares: {
+    path: "/api/v1/documents/{id}/shares";
+    method: "POST";
+    request: ShareRequest;
+    response: ShareInfo[];
+  };
+  deleteApiV1DocumentsIdSharesShareId: {
+    path: "/api/v1/documents/{id}/shares/{shareId}";
+    method: "DELETE";
+    request: never;
+    response: null;
+  };
+  getApiV1DocumentsIdTags: {
+    path: "/api/v1/documents/{id}/tags";
+    method: "GET";
+    request: never;
+    response: TagsRequest;
+  };
+  putApiV1DocumentsIdTags: {
+    path: "/api/v1/documents/{id}/tags";
+    method: "PUT";
+    request: TagsRequest;
+    response: TagsRequest;
+  };
+  getApiV1Keys: {
+    path: "/api/v1/keys";
+    method: "GET";
+    request: never;
+    response: APIKeyInfo[];
+  };
+  postApiV1Keys: {
+    path: "/api/v1/keys";
+    method: "POST";
+    request: CreateAPIKeyRequest;
+    response: APIKeyInfo;
+  };
+  deleteApiV1KeysId: {
+    path: "/api/v1/keys/{id}";
+    method: "DELETE";
+    request: never;
+    response: null;
+  };
+  getApiV1Orgs: {
+    path: "/api/v1/orgs";
+    method: "GET";
+    request: never;
+    response: OrgInfo[];
+  };
+  postApiV1Orgs: {
+    path: "/api/v1/orgs";
+    method: "POST";
+    request: NameRequest;
+    response: OrgInfo;
+  };
+  getApiV1OrgsIdCollections: {
+    path: "/api/v1/orgs/{id}/collections";
+    method: "GET";
+    request: never;
+    response: CollectionInfo[];
+  };
+  postApiV1OrgsIdCollections: {
+    path: "/api/v1/orgs/{id}/collections";
+    method: "POST";
+    request: NameRequest;
+    response: CollectionInfo;
+  };
+  deleteApiV1OrgsIdInvitesEmail: {
+    path: "/api/v1/orgs/{id}/invites/{email}";
+    method: "DELETE";
+    request: never;
+    response: OrgMembersResponse;
+  };
+  getApiV1OrgsIdMembers: {
+    path: "/api/v1/orgs/{id}/members";
+    method: "GET";
+    request: never;
+    response: OrgMembersResponse;
+  };
+  postApiV1OrgsIdMembers: {
+    path: "/api/v1/orgs/{id}/members";
+    method: "POST";
+    request: InviteRequest;
+    response: OrgMembersResponse;
+  };
+  deleteApiV1OrgsIdMembersUserId: {
+    path: "/api/v1/orgs/{id}/members/{userId}";
+    method: "DELETE";
+    request: never;
+    response: OrgMembersResponse;
+  };
+  getApiV1Quota: {
+    path: "/api/v1/quota";
+    method: "GET";
+    request: never;
+    response: QuotaStatus[];
+  };
+  getApiV1SecurePing: {
+    path: "/api/v1/secure-ping";
+    method: "GET";
+    request: never;
+    response: Record<string, string>;
+  };
+  getApiV1Usage: {
+    path: "/api/v1/usage";
+    method: "GET";
+    request: never;
+    response: UsageReport;
+  };
+}
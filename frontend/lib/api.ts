@@ -4,6 +4,33 @@ import { auth } from "./firebase";
 // IMPORTANT: Make sure this points to your Go backend's URL
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_BASE_URL || "http://localhost:8080"; 
 
+// Every API error is sent as {"error": {"code", "message", "requestId"}}.
+// Branch on `code`; `message` is meant for people and may change.
+export class ApiError extends Error {
+  constructor(
+    message: string,
+    public status: number,
+    public code: string,
+    public requestId?: string,
+  ) {
+    super(message);
+    this.name = "ApiError";
+  }
+}
+
+const toApiError = async (response: Response): Promise<ApiError> => {
+  const requestId = response.headers.get("X-Request-ID") ?? undefined;
+  try {
+    const body = await response.json();
+    if (body?.error?.code) {
+      return new ApiError(body.error.message, response.status, body.error.code, body.error.requestId ?? requestId);
+    }
+  } catch {
+    // Not JSON, e.g. an error page from a proxy in front of the backend.
+  }
+  return new ApiError(response.statusText || "An unknown error occurred", response.status, "unknown", requestId);
+};
+
 export const authenticatedFetch = async (endpoint: string, options: RequestInit = {}) => {
   const user = auth.currentUser;
   if (!user) {
@@ -26,9 +53,10 @@ export const authenticatedFetch = async (endpoint: string, options: RequestInit
   });
 
   if (!response.ok) {
-    // Try to parse error as text, as backend might not send JSON on error
-    const errorText = await response.text();
-    throw new Error(errorText || response.statusText || "An unknown error occurred");
+    throw await toApiError(response);
+  }
+  if (response.status === 204) {
+    return null;
   }
 
   // Handle responses that might not have a body or are not JSON